@@ -0,0 +1,233 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nathfavour/vibeauracle/brain"
+	"github.com/nathfavour/vibeauracle/sys"
+	"github.com/nathfavour/vibeauracle/tooling"
+	"github.com/spf13/cobra"
+)
+
+var (
+	approvalsTool    string
+	approvalsScope   string
+	approvalsExpires string
+	approvalsDeny    bool
+)
+
+var approvalsCmd = &cobra.Command{
+	Use:   "approvals",
+	Short: "List, add and revoke persisted tool approvals",
+	Long: `Answering "Approve Session", "Approve in Project" or "Approve Forever" when the
+agent asks to run a tool stores the decision in the data directory
+(enclave/approvals.json). Each approval has a scope: the session (working
+directory) it was given in, the project (the git repository, or the directory
+outside one), or everywhere.
+
+Approvals from the prompt match exactly the call that was approved. Those added
+with 'vibeaura approvals add' match a glob pattern ("*" is any text) on the
+command line of shell commands, or on the arguments of other tools, and can
+expire. A matching deny always wins over an allow.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		approvalsListCmd.Run(cmd, args)
+	},
+}
+
+var approvalsListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List persisted approvals",
+	Run: func(cmd *cobra.Command, args []string) {
+		store := openApprovals()
+		printTitle("✅", "APPROVALS")
+		list := store.List()
+		if len(list) == 0 {
+			printInfo("No persisted approvals.")
+			return
+		}
+		for _, a := range list {
+			text, meta := describeApproval(a)
+			printBulletWithMeta(text, meta)
+		}
+		printNewline()
+		printInfo("Run 'vibeaura approvals revoke <id>' to remove one.")
+	},
+}
+
+var approvalsAddCmd = &cobra.Command{
+	Use:   "add <pattern>",
+	Short: "Approve (or deny) calls matching a pattern",
+	Long: `Adds an approval for calls of --tool matching pattern, for example
+
+  vibeaura approvals add 'go test *' --scope project --expires 30d
+
+allows any 'go test' command in the current repository for 30 days without
+asking. Use --deny to refuse matching calls instead.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		a := tooling.Approval{
+			Tool:     approvalsTool,
+			Pattern:  args[0],
+			Decision: tooling.ApprovalAllow,
+			Scope:    tooling.ApprovalScope(approvalsScope),
+		}
+		if approvalsDeny {
+			a.Decision = tooling.ApprovalDeny
+		}
+		switch a.Scope {
+		case tooling.ScopeGlobal:
+		case tooling.ScopeProject:
+			cwd, _ := os.Getwd()
+			a.Root = tooling.ProjectRoot(cwd)
+		case tooling.ScopeSession:
+			a.Session = brain.New().GetSessionID()
+		default:
+			printError(fmt.Sprintf("unknown scope %q (use global, project or session)", approvalsScope))
+			os.Exit(1)
+		}
+		var err error
+		if a.ExpiresAt, err = parseExpiry(approvalsExpires); err != nil {
+			printError(err.Error())
+			os.Exit(1)
+		}
+
+		a, err = openApprovals().Add(a)
+		if err != nil {
+			printError(err.Error())
+			os.Exit(1)
+		}
+		text, meta := describeApproval(a)
+		printBulletWithMeta(text, meta)
+		printSuccess("Approval " + a.ID + " saved")
+	},
+}
+
+var approvalsRevokeCmd = &cobra.Command{
+	Use:   "revoke <id>...",
+	Short: "Remove persisted approvals by ID (or a unique prefix of one)",
+	Args:  cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		removed, err := openApprovals().Revoke(args...)
+		if err != nil {
+			printError(err.Error())
+			os.Exit(1)
+		}
+		for _, a := range removed {
+			text, _ := describeApproval(a)
+			printBullet(text)
+		}
+		printSuccess(fmt.Sprintf("Revoked %d approval(s)", len(removed)))
+	},
+}
+
+var approvalsPruneCmd = &cobra.Command{
+	Use:   "prune",
+	Short: "Remove expired approvals and those of projects that no longer exist",
+	Run: func(cmd *cobra.Command, args []string) {
+		removed, err := openApprovals().Prune()
+		if err != nil {
+			printError(err.Error())
+			os.Exit(1)
+		}
+		if len(removed) == 0 {
+			printInfo("Nothing to prune.")
+			return
+		}
+		for _, a := range removed {
+			text, _ := describeApproval(a)
+			printBullet(text)
+		}
+		printSuccess(fmt.Sprintf("Pruned %d approval(s)", len(removed)))
+	},
+}
+
+// openApprovals opens the approval store of the configured data directory.
+func openApprovals() *tooling.ApprovalStore {
+	cm, err := sys.NewConfigManager()
+	if err != nil {
+		printError(err.Error())
+		os.Exit(1)
+	}
+	cfg, err := cm.Load()
+	if err != nil {
+		printError(err.Error())
+		os.Exit(1)
+	}
+	store, err := tooling.NewApprovalStore(tooling.ApprovalsPath(cfg.DataDir))
+	if err != nil {
+		printError(err.Error())
+		os.Exit(1)
+	}
+	return store
+}
+
+// describeApproval renders an approval as a line and its details, for the CLI and the
+// /approvals view.
+func describeApproval(a tooling.Approval) (text, meta string) {
+	what := a.Pattern
+	if what == "" {
+		what = a.Summary
+	}
+	text = fmt.Sprintf("%s  %-5s %-16s %s", a.ID, a.Decision, a.Tool, truncateMessage(what))
+
+	details := []string{string(a.Scope)}
+	switch a.Scope {
+	case tooling.ScopeProject:
+		details[0] += " " + a.Root
+	case tooling.ScopeSession:
+		details[0] += " " + strings.TrimPrefix(a.Session, "chat_session:")
+	}
+	if a.Pattern != "" {
+		details = append(details, "pattern")
+	}
+	if !a.ExpiresAt.IsZero() {
+		if a.Expired(time.Now()) {
+			details = append(details, "expired "+a.ExpiresAt.Format("Jan 02 15:04"))
+		} else {
+			details = append(details, "expires "+a.ExpiresAt.Format("Jan 02 15:04"))
+		}
+	}
+	details = append(details, fmt.Sprintf("%d× since %s", a.Count, a.CreatedAt.Format("Jan 02")))
+	return text, strings.Join(details, " · ")
+}
+
+// parseExpiry accepts an RFC 3339 timestamp, a date, or a duration from now, which may
+// be given in days (30d).
+func parseExpiry(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02", s, time.Local); err == nil {
+		return t, nil
+	}
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		if n, err := strconv.Atoi(days); err == nil && n > 0 {
+			return time.Now().AddDate(0, 0, n), nil
+		}
+	}
+	if d, err := time.ParseDuration(s); err == nil && d > 0 {
+		return time.Now().Add(d), nil
+	}
+	return time.Time{}, fmt.Errorf("invalid expiry %q: use 2006-01-02T15:04:05Z07:00, 2006-01-02 or a duration like 12h or 30d", s)
+}
+
+func init() {
+	f := approvalsAddCmd.Flags()
+	f.StringVar(&approvalsTool, "tool", "sys_shell_exec", "tool the approval applies to")
+	f.StringVar(&approvalsScope, "scope", "project", "where it applies: global, project or session")
+	f.StringVar(&approvalsExpires, "expires", "", "when it expires: a time, a date or a duration like 30d (default never)")
+	f.BoolVar(&approvalsDeny, "deny", false, "refuse matching calls instead of allowing them")
+
+	rootCmd.AddCommand(approvalsCmd)
+	approvalsCmd.AddCommand(approvalsListCmd)
+	approvalsCmd.AddCommand(approvalsAddCmd)
+	approvalsCmd.AddCommand(approvalsRevokeCmd)
+	approvalsCmd.AddCommand(approvalsPruneCmd)
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/nathfavour/vibeauracle/brain"
+	"github.com/nathfavour/vibeauracle/sys"
+	"github.com/nathfavour/vibeauracle/tooling"
+	"github.com/spf13/cobra"
+)
+
+var (
+	auditFilter tooling.AuditFilter
+	auditSince  string
+	auditUntil  string
+	auditHere   bool
+	auditVerify bool
+	auditFormat string
+	auditLimit  int
+)
+
+var auditCmd = &cobra.Command{
+	Use:   "audit",
+	Short: "Show, verify and export the security audit log",
+	Long: `Every decision of the approval enclave is appended to an audit log in the
+data directory (enclave/audit.log), with the session and thread it belongs to and,
+for calls that ran, the status of their result. The log is rotated at 10MB, keeping
+five old logs.
+
+Entries are hash-chained: each one includes the hash of the entry before it, so
+--verify detects entries that were edited, removed or reordered. Removing the newest
+entries cannot be detected from the log alone.
+
+Times for --since and --until are RFC 3339 timestamps, dates (2006-01-02) or
+durations back from now (24h).`,
+	Run: func(cmd *cobra.Command, args []string) {
+		cm, err := sys.NewConfigManager()
+		if err != nil {
+			printError(err.Error())
+			os.Exit(1)
+		}
+		cfg, err := cm.Load()
+		if err != nil {
+			printError(err.Error())
+			os.Exit(1)
+		}
+		log := tooling.NewAuditLogger(tooling.AuditPath(cfg.DataDir))
+
+		if auditVerify {
+			v, err := log.Verify()
+			if err != nil {
+				printError(err.Error())
+				os.Exit(1)
+			}
+			printTitle("🔐", "AUDIT LOG")
+			printKeyValue("Entries", fmt.Sprint(v.Entries))
+			if v.Legacy > 0 {
+				printKeyValue("Unchained", fmt.Sprintf("%d (written before the log was chained)", v.Legacy))
+			}
+			if !v.OK() {
+				for _, p := range v.Problems {
+					printBullet(p)
+				}
+				printError(fmt.Sprintf("The audit log has been tampered with: %d problem(s)", len(v.Problems)))
+				os.Exit(1)
+			}
+			printSuccess("The hash chain is intact")
+			return
+		}
+
+		if auditFilter.Since, err = parseAuditTime(auditSince); err != nil {
+			printError(err.Error())
+			os.Exit(1)
+		}
+		if auditFilter.Until, err = parseAuditTime(auditUntil); err != nil {
+			printError(err.Error())
+			os.Exit(1)
+		}
+		if auditHere {
+			auditFilter.Session = brain.New().GetSessionID()
+		}
+
+		entries, err := log.Entries()
+		if err != nil && !os.IsNotExist(err) {
+			printError(err.Error())
+			os.Exit(1)
+		}
+		var selected []tooling.AuditEntry
+		for _, e := range entries {
+			if auditFilter.Match(e) {
+				selected = append(selected, e)
+			}
+		}
+		limit := auditLimit
+		if limit == 0 && (auditFormat == "" || auditFormat == "text") {
+			limit = 50
+		}
+		if limit > 0 && len(selected) > limit {
+			selected = selected[len(selected)-limit:]
+		}
+
+		switch auditFormat {
+		case "json":
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			if selected == nil {
+				selected = []tooling.AuditEntry{}
+			}
+			err = enc.Encode(selected)
+		case "csv":
+			err = writeAuditCSV(selected)
+		case "", "text":
+			printAudit(selected)
+		default:
+			err = fmt.Errorf("unknown format %q (use text, json or csv)", auditFormat)
+		}
+		if err != nil {
+			printError(err.Error())
+			os.Exit(1)
+		}
+	},
+}
+
+// printAudit lists entries, newest last, one line each.
+func printAudit(entries []tooling.AuditEntry) {
+	printTitle("🔐", "AUDIT LOG")
+	if len(entries) == 0 {
+		printInfo("No matching audit entries.")
+		return
+	}
+	for _, e := range entries {
+		meta := []string{e.Timestamp, "risk " + e.Risk}
+		if e.Status != "" {
+			meta = append(meta, e.Status)
+		}
+		if e.Rule != "" {
+			meta = append(meta, "rule "+e.Rule)
+		}
+		if e.Thread != "" {
+			meta = append(meta, "thread "+shortThread(e.Thread))
+		}
+		printBulletWithMeta(fmt.Sprintf("%-22s %-16s %s", e.Decision, e.Tool, truncateMessage(e.Args)), strings.Join(meta, " · "))
+	}
+	printNewline()
+}
+
+// writeAuditCSV writes entries as CSV with a header row.
+func writeAuditCSV(entries []tooling.AuditEntry) error {
+	w := csv.NewWriter(os.Stdout)
+	_ = w.Write([]string{"timestamp", "session", "thread", "tool", "args", "risk", "decision", "scope", "rule", "status", "prev", "hash"})
+	for _, e := range entries {
+		_ = w.Write([]string{e.Timestamp, e.Session, e.Thread, e.Tool, e.Args, e.Risk, e.Decision, e.Scope, e.Rule, e.Status, e.Prev, e.Hash})
+	}
+	w.Flush()
+	return w.Error()
+}
+
+// parseAuditTime accepts an RFC 3339 timestamp, a date or a duration back from now.
+func parseAuditTime(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02", s, time.Local); err == nil {
+		return t, nil
+	}
+	if d, err := time.ParseDuration(s); err == nil {
+		return time.Now().Add(-d), nil
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q: use 2006-01-02T15:04:05Z07:00, 2006-01-02 or a duration like 24h", s)
+}
+
+func init() {
+	f := auditCmd.Flags()
+	f.StringVar(&auditFilter.Tool, "tool", "", "only entries for tools matching this glob, e.g. sys_*")
+	f.StringVar(&auditFilter.Risk, "risk", "", "only entries of this risk (low, medium, high, blocked)")
+	f.StringVar(&auditFilter.Decision, "decision", "", "only decisions containing this text, e.g. denied or session")
+	f.StringVar(&auditFilter.Session, "session", "", "only entries of this session (any part of its ID)")
+	f.StringVar(&auditFilter.Thread, "thread", "", "only entries of this thread (a prefix of its ID)")
+	f.BoolVar(&auditHere, "here", false, "only entries of the session of the current directory")
+	f.StringVar(&auditSince, "since", "", "only entries at or after this time")
+	f.StringVar(&auditUntil, "until", "", "only entries at or before this time")
+	f.BoolVar(&auditVerify, "verify", false, "check the hash chain instead of listing entries")
+	f.StringVar(&auditFormat, "format", "text", "output format: text, json or csv")
+	f.IntVarP(&auditLimit, "limit", "n", 0, "keep only the newest N matching entries (default 50 for text, all for exports)")
+	rootCmd.AddCommand(auditCmd)
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/nathfavour/vibeauracle/brain"
+	"github.com/spf13/cobra"
+)
+
+var cacheCmd = &cobra.Command{
+	Use:   "cache",
+	Short: "Inspect or clear the model response cache",
+	Long: `Background model calls such as project indexing and prompt recommendations
+are cached in the local database so repeated runs do not pay for the same answer
+twice. Interactive chat is never cached.
+
+Configure it under 'cache' in ~/.vibeauracle/config.yaml (enabled, ttl, max_size_mb).`,
+}
+
+var cacheStatsCmd = &cobra.Command{
+	Use:   "stats",
+	Short: "Show response cache size and hit counts",
+	Run: func(cmd *cobra.Command, args []string) {
+		b := brain.New()
+		st, err := b.CacheStats()
+		if err != nil {
+			printError(err.Error())
+			os.Exit(1)
+		}
+
+		printTitle("🗃️", "RESPONSE CACHE")
+		printKeyValue("Entries", fmt.Sprintf("%d", st.Entries))
+		printKeyValue("Size   ", fmt.Sprintf("%.1f KiB", float64(st.Bytes)/1024))
+		printKeyValueHighlight("Hits   ", fmt.Sprintf("%d", st.Hits))
+		if st.Entries > 0 {
+			printKeyValue("Oldest ", st.Oldest.Format("2006-01-02 15:04"))
+			printKeyValue("Newest ", st.Newest.Format("2006-01-02 15:04"))
+		}
+		printNewline()
+	},
+}
+
+var cacheClearCmd = &cobra.Command{
+	Use:   "clear",
+	Short: "Remove every cached response",
+	Run: func(cmd *cobra.Command, args []string) {
+		b := brain.New()
+		n, err := b.ClearCache()
+		if err != nil {
+			printError(err.Error())
+			os.Exit(1)
+		}
+		printSuccess(fmt.Sprintf("Removed %d cached responses", n))
+	},
+}
+
+func init() {
+	cacheCmd.AddCommand(cacheStatsCmd)
+	cacheCmd.AddCommand(cacheClearCmd)
+	rootCmd.AddCommand(cacheCmd)
+}
@@ -87,13 +87,17 @@ type model struct {
 
 	// Action Confirmation / Intervention
 	pendingIntervention *interventionState
+	pendingReview       *reviewState
+
+	// Budget exhaustion: the stopped task /continue resumes
+	pendingContinue *brain.BudgetExhausted
 
 	// Prompt History (arrow up/down to cycle)
 	promptHistory []string
 	historyIndex  int
 	tempPrompt    string // Stores current input when browsing history
 
-	// Streaming response (Copilot SDK)
+	// Streaming response (all streaming-capable providers)
 	streamingContent strings.Builder
 	isStreaming      bool
 	wasStreaming     bool
@@ -214,17 +218,20 @@ type chatState struct {
 }
 
 var allCommands = []string{
-	"/help", "/status", "/cwd", "/version", "/clear", "/exit", "/show-tree", "/shot", "/record", "/auth", "/mcp", "/sys", "/skill", "/models", "/agent", "/session", "/update", "/restart",
+	"/help", "/status", "/cwd", "/version", "/clear", "/exit", "/show-tree", "/shot", "/record", "/auth", "/mcp", "/sys", "/skill", "/models", "/agent", "/session", "/usage", "/continue", "/undo", "/review", "/approvals", "/update", "/restart",
 }
 
 var subCommands = map[string][]string{
-	"/auth":    {"/ollama", "/github-models", "/github-copilot", "/copilot-sdk", "/openai", "/anthropic"},
-	"/mcp":     {"/list", "/add", "/logs", "/call"},
-	"/sys":     {"/stats", "/env", "/update", "/logs"},
-	"/skill":   {"/list", "/info", "/load", "/disable"},
-	"/models":  {"/list", "/use", "/pull"},
-	"/agent":   {"/vibe", "/sdk", "/custom"},
-	"/session": {"/list", "/clear"},
+	"/auth":      {"/ollama", "/github-models", "/github-copilot", "/copilot-sdk", "/openai", "/anthropic"},
+	"/mcp":       {"/list", "/add", "/logs", "/call"},
+	"/sys":       {"/stats", "/env", "/update", "/logs"},
+	"/skill":     {"/list", "/info", "/load", "/disable"},
+	"/models":    {"/list", "/use", "/pull"},
+	"/agent":     {"/vibe", "/sdk", "/custom", "/goal", "/orchestrate"},
+	"/session":   {"/list", "/clear"},
+	"/undo":      {"/list"},
+	"/review":    {"/on", "/off"},
+	"/approvals": {"/list", "/revoke", "/prune"},
 }
 
 func buildBanner(width int) string {
@@ -603,6 +610,9 @@ func (m *model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		switch m.focus {
 		case focusInput:
 			// Intervention handling takes priority
+			if m.pendingReview != nil {
+				return m.handleReviewKey(msg)
+			}
 			if m.pendingIntervention != nil {
 				return m.handleInterventionKey(msg)
 			}
@@ -638,6 +648,14 @@ func (m *model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		if msg.Error != nil {
 			// Check if this is an intervention request
 			var interventionErr *tooling.InterventionError
+			if errors.As(msg.Error, &interventionErr) && interventionErr.Review != nil {
+				// File changes held for review: show the diff and wait for the hunk selection
+				m.pendingReview = newReviewState(interventionErr)
+				m.messages = append(m.messages, m.renderReview())
+				m.viewport.SetContent(m.renderMessages())
+				m.viewport.GotoBottom()
+				return m, nil
+			}
 			if errors.As(msg.Error, &interventionErr) {
 				// ... (intervention handling remains the same)
 				m.pendingIntervention = &interventionState{
@@ -645,7 +663,7 @@ func (m *model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 					choices:  interventionErr.Choices,
 					selected: 0,
 					resume: func(choice string) (interface{}, error) {
-						return interventionErr.Resume(choice)
+						return interventionErr.Resume(context.Background(), choice)
 					},
 					requestID: uuid.NewString(),
 				}
@@ -699,6 +717,10 @@ func (m *model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				m.messages = append(m.messages, rb.String())
 			}
 		}
+		if budget, ok := msg.Metadata["budget_exhausted"].(*brain.BudgetExhausted); ok && msg.Error == nil {
+			m.pendingContinue = budget
+			m.messages = append(m.messages, systemStyle.Render(" BUDGET EXHAUSTED ")+"\n"+helpStyle.Render(budget.String()+"\nType /continue to keep going with a fresh budget."))
+		}
 		m.viewport.SetContent(m.renderMessages())
 		m.viewport.GotoBottom()
 		m.saveState()
@@ -1387,12 +1409,15 @@ func (m *model) applySuggestion() (tea.Model, tea.Cmd) {
 
 	// Auto-execute logic for no-arg commands/subcommands
 	noArgSubs := map[string]map[string]bool{
-		"/models":  {"/list": true},
-		"/sys":     {"/stats": true, "/env": true, "/update": true, "/logs": true},
-		"/mcp":     {"/list": true, "/logs": true},
-		"/skill":   {"/list": true},
-		"/agent":   {"/vibe": true, "/sdk": true},
-		"/session": {"/list": true, "/clear": true},
+		"/models":    {"/list": true},
+		"/sys":       {"/stats": true, "/env": true, "/update": true, "/logs": true},
+		"/mcp":       {"/list": true, "/logs": true},
+		"/skill":     {"/list": true},
+		"/agent":     {"/vibe": true, "/sdk": true, "/goal": true, "/orchestrate": true},
+		"/session":   {"/list": true, "/clear": true},
+		"/undo":      {"/list": true},
+		"/review":    {"/on": true, "/off": true},
+		"/approvals": {"/list": true, "/prune": true},
 	}
 
 	if len(parts) == 1 {
@@ -1686,7 +1711,7 @@ func (m *model) handleSlashCommand(cmd string) (tea.Model, tea.Cmd) {
 
 	switch parts[0] {
 	case "/help":
-		m.messages = append(m.messages, systemStyle.Render(" COMMANDS ")+"\n"+helpStyle.Render("• /help    - Show this list\n• /status  - System resource snapshot\n• /mcp     - Manage MCP tools & servers\n• /skill   - Manage agentic vibes/skills\n• /sys     - Hardware & system details\n• /auth    - Manage AI provider credentials\n• /agent   - Select agentic runtime engine\n• /session - Manage directory-aware sessions\n• /shot    - Take a beautiful TUI screenshot\n• /record  - Start/stop high-quality TUI recording\n• /cwd     - Show current directory\n• /version - Show version info\n• /update  - Check for updates immediately\n• /restart - Restart vibeauracle\n• /clear   - Clear chat history\n• /exit    - Quit vibeauracle"))
+		m.messages = append(m.messages, systemStyle.Render(" COMMANDS ")+"\n"+helpStyle.Render("• /help    - Show this list\n• /status  - System resource snapshot\n• /mcp     - Manage MCP tools & servers\n• /skill   - Manage agentic vibes/skills\n• /sys     - Hardware & system details\n• /auth    - Manage AI provider credentials\n• /agent   - Select agentic runtime engine\n• /session - Manage directory-aware sessions\n• /usage   - Token usage and spend\n• /continue - Resume a task that ran out of budget\n• /undo    - Revert the files changed by the last turn (/undo <thread>, /undo /list)\n• /review  - Review file changes as diffs before they are written\n• /approvals - List, revoke or prune persisted approvals (/approvals /revoke <id>)\n• /shot    - Take a beautiful TUI screenshot\n• /record  - Start/stop high-quality TUI recording\n• /cwd     - Show current directory\n• /version - Show version info\n• /update  - Check for updates immediately\n• /restart - Restart vibeauracle\n• /clear   - Clear chat history\n• /exit    - Quit vibeauracle"))
 	case "/status":
 		snapshot, _ := m.brain.GetSnapshot()
 		status := fmt.Sprintf(systemStyle.Render(" SYSTEM ")+"\n"+helpStyle.Render("CPU: %.1f%% | Mem: %.1f%%"), snapshot.CPUUsage, snapshot.MemoryUsage)
@@ -1704,6 +1729,16 @@ func (m *model) handleSlashCommand(cmd string) (tea.Model, tea.Cmd) {
 		return m.handleAgentCommand(parts)
 	case "/session":
 		return m.handleSessionCommand(parts)
+	case "/usage":
+		return m.handleUsageCommand()
+	case "/continue":
+		return m.handleContinueCommand()
+	case "/undo":
+		return m.handleUndoCommand(parts)
+	case "/review":
+		return m.handleReviewCommand(parts)
+	case "/approvals":
+		return m.handleApprovalsCommand(parts)
 	case "/mcp":
 		return m.handleMcpCommand(parts)
 	case "/sys":
@@ -1878,7 +1913,7 @@ func (m *model) handleAgentCommand(parts []string) (tea.Model, tea.Cmd) {
 		if cfg.Mode == "custom" {
 			msg += helpStyle.Render(fmt.Sprintf(" (%s)", cfg.ActiveCustom))
 		}
-		msg += "\n\n" + helpStyle.Render("Usage: /agent <mode>\nModes: /vibe, /sdk, /custom")
+		msg += "\n\n" + helpStyle.Render("Usage: /agent <mode>\nModes: /vibe, /sdk, /custom, /goal, /orchestrate")
 		msg += "\n\n" + helpStyle.Render("Subcommands for /custom:\n• /agent /custom /list\n• /agent /custom /use <name>\n• /agent /custom /add <name> <prompt>")
 		m.messages = append(m.messages, msg)
 		m.viewport.SetContent(m.renderMessages())
@@ -1937,6 +1972,10 @@ func (m *model) handleAgentCommand(parts []string) (tea.Model, tea.Cmd) {
 			icon = "🚀"
 		} else if mode == "custom" {
 			icon = "👤"
+		} else if mode == "goal" {
+			icon = "🎯"
+		} else if mode == "orchestrate" {
+			icon = "🏛️"
 		}
 		m.messages = append(m.messages, systemStyle.Render(" AGENT SWITCHED ")+"\n"+helpStyle.Render(fmt.Sprintf("%s Now using %s agentic runtime engine.", icon, strings.ToUpper(mode))))
 	}
@@ -1946,6 +1985,194 @@ func (m *model) handleAgentCommand(parts []string) (tea.Model, tea.Cmd) {
 	return m, nil
 }
 
+func (m *model) handleContinueCommand() (tea.Model, tea.Cmd) {
+	if m.pendingContinue == nil {
+		m.messages = append(m.messages, systemStyle.Render(" CONTINUE ")+"\n"+helpStyle.Render("Nothing to continue. /continue resumes a task that ran out of budget."))
+		m.viewport.SetContent(m.renderMessages())
+		m.viewport.GotoBottom()
+		return m, nil
+	}
+
+	prompt := m.pendingContinue.ContinuePrompt()
+	m.pendingContinue = nil
+	m.messages = append(m.messages, userStyle.Render("User ")+m.styleMessage("/continue"))
+	m.viewport.SetContent(m.renderMessages())
+	m.viewport.GotoBottom()
+	m.isThinking = true
+	m.wasStreaming = false
+	return m, m.processRequest(prompt)
+}
+
+func (m *model) handleReviewCommand(parts []string) (tea.Model, tea.Cmd) {
+	on := !m.brain.Config().Agent.ReviewWrites
+	if len(parts) > 1 {
+		on = parts[1] != "/off" && parts[1] != "off"
+	}
+
+	var msg string
+	if err := m.brain.SetReviewWrites(on); err != nil {
+		msg = errorStyle.Render(err.Error())
+	} else if on {
+		msg = helpStyle.Render("Review mode ON: file writes, edits and deletes are shown as diffs first.\nSpace toggles a hunk, Enter applies the selection, a/r accept or reject all.")
+	} else {
+		msg = helpStyle.Render("Review mode OFF: the agent writes files directly (undo with /undo).")
+	}
+	m.messages = append(m.messages, systemStyle.Render(" REVIEW ")+"\n"+msg)
+	m.viewport.SetContent(m.renderMessages())
+	m.viewport.GotoBottom()
+	return m, nil
+}
+
+func (m *model) handleUndoCommand(parts []string) (tea.Model, tea.Cmd) {
+	var thread string
+	force := false
+	for _, p := range parts[1:] {
+		switch p {
+		case "--force", "-f":
+			force = true
+		case "/list", "list":
+			return m.showChanges()
+		default:
+			thread = p
+		}
+	}
+
+	var sb strings.Builder
+	sb.WriteString(systemStyle.Render(" UNDO ") + "\n")
+	res, err := m.brain.Undo(thread, force)
+	if err != nil && res == nil {
+		sb.WriteString(errorStyle.Render(err.Error()))
+	} else {
+		if len(res.Restored) > 0 {
+			sb.WriteString(helpStyle.Render(fmt.Sprintf("Reverted %d file(s) changed by thread %s:", len(res.Restored), shortThread(res.Thread))) + "\n")
+			for _, path := range res.Restored {
+				sb.WriteString(fmt.Sprintf("%s %s\n", aiStyle.Render("•"), helpStyle.Render(path)))
+			}
+		}
+		if len(res.Conflicts) > 0 {
+			sb.WriteString(errorStyle.Render("Modified outside the agent since the snapshot, left untouched:") + "\n")
+			for _, path := range res.Conflicts {
+				sb.WriteString(fmt.Sprintf("%s %s\n", aiStyle.Render("•"), helpStyle.Render(path)))
+			}
+			sb.WriteString(helpStyle.Render(fmt.Sprintf("Use /undo %s --force to overwrite them.", shortThread(res.Thread))))
+		}
+		if err != nil {
+			sb.WriteString("\n" + errorStyle.Render(err.Error()))
+		}
+	}
+	m.messages = append(m.messages, sb.String())
+	m.viewport.SetContent(m.renderMessages())
+	m.viewport.GotoBottom()
+	return m, nil
+}
+
+func (m *model) showChanges() (tea.Model, tea.Cmd) {
+	var sb strings.Builder
+	sb.WriteString(systemStyle.Render(" CHANGES ") + "\n")
+	records, err := m.brain.Changes()
+	switch {
+	case err != nil:
+		sb.WriteString(errorStyle.Render(err.Error()))
+	case len(records) == 0:
+		sb.WriteString(helpStyle.Render("No agent file changes to undo in this session."))
+	default:
+		for _, r := range records {
+			sb.WriteString(fmt.Sprintf("%s %s\n", aiStyle.Render(shortThread(r.Thread)), helpStyle.Render(fmt.Sprintf("%s · %d file(s) · %s", r.Started.Format("Jan 02 15:04"), len(r.Entries), promptSummary(r.Prompt)))))
+		}
+	}
+	m.messages = append(m.messages, sb.String())
+	m.viewport.SetContent(m.renderMessages())
+	m.viewport.GotoBottom()
+	return m, nil
+}
+
+func (m *model) handleApprovalsCommand(parts []string) (tea.Model, tea.Cmd) {
+	var sb strings.Builder
+	sb.WriteString(systemStyle.Render(" APPROVALS ") + "\n")
+	store := m.brain.Approvals()
+	if store == nil {
+		sb.WriteString(errorStyle.Render("The approval enclave is not available."))
+		m.messages = append(m.messages, sb.String())
+		m.viewport.SetContent(m.renderMessages())
+		m.viewport.GotoBottom()
+		return m, nil
+	}
+
+	var (
+		removed []tooling.Approval
+		err     error
+		done    string
+	)
+	sub := ""
+	if len(parts) > 1 {
+		sub = strings.TrimPrefix(parts[1], "/")
+	}
+	switch sub {
+	case "", "list":
+	case "revoke":
+		if len(parts) < 3 {
+			err = fmt.Errorf("usage: /approvals /revoke <id>...")
+			break
+		}
+		removed, err = store.Revoke(parts[2:]...)
+		done = fmt.Sprintf("Revoked %d approval(s):", len(removed))
+	case "prune":
+		removed, err = store.Prune()
+		done = fmt.Sprintf("Pruned %d approval(s):", len(removed))
+	default:
+		err = fmt.Errorf("unknown subcommand %q: use /list, /revoke <id> or /prune", parts[1])
+	}
+
+	switch {
+	case err != nil:
+		sb.WriteString(errorStyle.Render(err.Error()))
+	case done != "":
+		sb.WriteString(helpStyle.Render(done) + "\n")
+		for _, a := range removed {
+			text, _ := describeApproval(a)
+			sb.WriteString(fmt.Sprintf("%s %s\n", aiStyle.Render("•"), helpStyle.Render(text)))
+		}
+	default:
+		list := store.List()
+		if len(list) == 0 {
+			sb.WriteString(helpStyle.Render("No persisted approvals."))
+		}
+		for _, a := range list {
+			text, meta := describeApproval(a)
+			sb.WriteString(fmt.Sprintf("%s %s\n  %s\n", aiStyle.Render("•"), text, helpStyle.Render(meta)))
+		}
+		if len(list) > 0 {
+			sb.WriteString(helpStyle.Render("Use /approvals /revoke <id> to remove one."))
+		}
+	}
+	m.messages = append(m.messages, sb.String())
+	m.viewport.SetContent(m.renderMessages())
+	m.viewport.GotoBottom()
+	return m, nil
+}
+
+func (m *model) handleUsageCommand() (tea.Model, tea.Cmd) {
+	var sb strings.Builder
+	sb.WriteString(systemStyle.Render(" USAGE ") + "\n")
+
+	session := m.brain.SessionUsage()
+	sb.WriteString(helpStyle.Render("Session: "+formatUsage(session.Total)) + "\n")
+	for _, key := range sortedUsageKeys(session.ByModel) {
+		sb.WriteString(fmt.Sprintf("%s %s\n", aiStyle.Render("•"), helpStyle.Render(key+": "+formatUsage(session.ByModel[key]))))
+	}
+
+	if days, err := m.brain.UsageHistory(1); err == nil && len(days) > 0 {
+		today := days[0].Total()
+		sb.WriteString(helpStyle.Render("Today:   " + formatUsage(today.Total)))
+	}
+	sb.WriteString("\n\n" + helpStyle.Render("Run 'vibeaura usage' for daily history."))
+
+	m.messages = append(m.messages, sb.String())
+	m.viewport.SetContent(m.renderMessages())
+	m.viewport.GotoBottom()
+	return m, nil
+}
+
 func (m *model) handleSessionCommand(parts []string) (tea.Model, tea.Cmd) {
 	if len(parts) < 2 {
 		path := m.brain.GetSessionPath()
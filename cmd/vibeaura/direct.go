@@ -5,60 +5,102 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"os/signal"
 	"strings"
 
 	"github.com/nathfavour/vibeauracle/brain"
 	"github.com/nathfavour/vibeauracle/internal/doctor"
+	"github.com/nathfavour/vibeauracle/sys"
 	"github.com/nathfavour/vibeauracle/tooling"
 	"github.com/spf13/cobra"
 )
 
 var (
-	agentModeOverride string
-	directVerbose bool
+	agentModeOverride    string
+	directVerbose        bool
 	directNonInteractive bool
+	directMaxTurns       int
+	directOutputFormat   string
+	directPromptFile     string
+	directApprovePolicy  string
 )
 
 var directCmd = &cobra.Command{
 	Use:   "direct [prompt]",
 	Short: "Direct CLI interaction without TUI (Verbose Debug Mode)",
-	Long: `Direct mode bypasses the Bubble Tea TUI to provide a raw, 
-stream-to-terminal experience. Highly recommended for debugging 
-complex agentic loops and provider issues.`,
+	Long: `Direct mode bypasses the Bubble Tea TUI to provide a raw,
+stream-to-terminal experience. Highly recommended for debugging
+complex agentic loops and provider issues.
+
+The prompt comes from the arguments, --prompt-file ('-' for stdin) or piped
+stdin; without one, an interactive REPL starts. For scripts and CI, use
+--output json (one document at the end) or --output ndjson (one typed event
+per line: status, delta, tool_call, intervention and final), together with
+--approve-policy to answer approvals unattended. Rules in .vibeaura/policy.yaml
+and ~/.vibeauracle/policy.yaml are applied first: allow and deny rules decide
+without asking, ask rules always raise an approval. Their commands section adds
+rules that rate shell commands (low, medium, high or blocked) by program,
+arguments, redirections and pipes.
+
+Exit codes: 0 success, 1 failure, 2 loop detected, 3 unresolved intervention,
+4 budget exhausted.`,
 	Run: func(cmd *cobra.Command, args []string) {
-	doctor.Start()
-	b := brain.New()
-	if agentModeOverride != "" {
-		_ = b.SetAgentMode(agentModeOverride)
-	}
+		doctor.Start()
 
-		// Setup Verbose Status Reporting
-		tooling.StatusReporter = func(icon, step, msg string) {
-			if directVerbose {
-				fmt.Printf("\033[34m[%s] %-12s |\033[0m %s\n", icon, strings.ToUpper(step), msg)
-			}
-			// Always send to doctor for persistent logs
-		doctor.Send("tooling", doctor.SignalInit, fmt.Sprintf("%s %s", step, msg), nil)
+		out, err := newDirectOutput(directOutputFormat)
+		if err != nil {
+			printError(err.Error())
+			os.Exit(exitFailure)
+		}
+		answer, err := approvalAnswerer(directApprovePolicy)
+		if err != nil {
+			printError(err.Error())
+			os.Exit(exitFailure)
+		}
+		prompt, err := directPrompt(args, directPromptFile)
+		if err == nil && prompt == "" && out.structured() {
+			err = fmt.Errorf("--output %s needs a prompt (argument, --prompt-file or stdin)", directOutputFormat)
+		}
+		if err != nil {
+			os.Exit(out.finish(brain.Response{}, err).ExitCode)
 		}
 
-		// Connect brain callbacks to stdout
-		b.OnStreamDelta = func(delta string) {
-			fmt.Print(delta)
+		b := brain.New()
+		if agentModeOverride != "" {
+			_ = b.SetAgentMode(agentModeOverride)
 		}
+
+		// Status reporting; everything also goes to doctor for persistent logs
+		tooling.StatusReporter = func(icon, step, msg string) {
+			out.status(icon, step, msg)
+			doctor.Send("tooling", doctor.SignalInit, fmt.Sprintf("%s %s", step, msg), nil)
+		}
+
+		// Connect brain callbacks to the output
+		b.OnStreamDelta = out.delta
 		b.OnStreamDone = func(full string) {
-			fmt.Println() 
+			out.streamDone()
+		}
+		b.OnToolCall = out.toolCall
+		if answer != nil {
+			b.OnIntervention = func(tool string, iv *tooling.InterventionError) string {
+				choice := answer(tool, iv)
+				out.intervention(tool, iv, choice)
+				return choice
+			}
 		}
 
-		// One-shot execution if prompt provided
-		if len(args) > 0 {
-			prompt := strings.Join(args, " ")
-			if directVerbose {
+		// One-shot execution if a prompt was provided
+		if prompt != "" {
+			if directVerbose && !out.structured() {
 				fmt.Printf("\033[1;32mUser:\033[0m %s\n", prompt)
 			}
-			_, err := b.Process(context.Background(), brain.Request{Content: prompt})
-			if err != nil {
-				fmt.Printf("\n\033[31mBRAIN ERROR:\033[0m %v\n", err)
-				os.Exit(1)
+			// Ctrl+C cancels the in-flight generation, which also stops streaming.
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+			resp, err := b.Process(ctx, brain.Request{Content: prompt, Limits: directLimits()})
+			stop()
+			if code := out.finish(resp, err).ExitCode; code != exitOK {
+				os.Exit(code)
 			}
 			return
 		}
@@ -82,7 +124,7 @@ complex agentic loops and provider issues.`,
 				break
 			}
 			input := strings.TrimSpace(scanner.Text())
-			
+
 			if input == "" {
 				continue
 			}
@@ -101,18 +143,25 @@ complex agentic loops and provider issues.`,
 				// For now, let the brain handle them as text or ignore.
 			}
 
-			_, err := b.Process(context.Background(), brain.Request{Content: input})
-			if err != nil {
-				fmt.Printf("\n\033[31mBRAIN ERROR:\033[0m %v\n", err)
-			}
+			resp, err := b.Process(context.Background(), brain.Request{Content: input, Limits: directLimits()})
+			out.finish(resp, err)
 			fmt.Println()
 		}
 	},
 }
 
+// directLimits are the per-invocation overrides of agent.limits.
+func directLimits() sys.AgentLimits {
+	return sys.AgentLimits{MaxTurns: directMaxTurns}
+}
+
 func init() {
 	directCmd.Flags().BoolVarP(&directVerbose, "verbose", "v", true, "Enable extremely verbose logging (defaults to true in direct mode)")
 	directCmd.Flags().BoolVarP(&directNonInteractive, "non-interactive", "n", false, "Exit after one-shot (if prompt provided)")
+	directCmd.Flags().IntVar(&directMaxTurns, "max-turns", 0, "Override agent.limits.max_turns for this invocation")
+	directCmd.Flags().StringVarP(&directOutputFormat, "output", "o", "text", "Output format: text, json or ndjson")
+	directCmd.Flags().StringVarP(&directPromptFile, "prompt-file", "f", "", "Read the prompt from a file ('-' for stdin)")
+	directCmd.Flags().StringVar(&directApprovePolicy, "approve-policy", "fail", "Answer approvals unattended: fail, deny, safe (approve low/medium risk) or all")
 	directCmd.Flags().StringVarP(&agentModeOverride, "agent", "a", "", "Override agent mode (vibe, sdk, custom)")
 	rootCmd.AddCommand(directCmd)
 }
@@ -8,6 +8,7 @@ require (
 	github.com/charmbracelet/lipgloss v1.1.1-0.20250404203927-76690c660834
 	github.com/google/uuid v1.6.0
 	github.com/mattn/go-runewidth v0.0.16
+	github.com/nathfavour/vibeauracle/agent v0.0.0-00010101000000-000000000000
 	github.com/nathfavour/vibeauracle/brain v0.0.0-00010101000000-000000000000
 	github.com/nathfavour/vibeauracle/internal/doctor v0.0.0-00010101000000-000000000000
 	github.com/nathfavour/vibeauracle/sys v0.0.0
@@ -104,6 +105,8 @@ replace github.com/nathfavour/vibeauracle/sys => ../../internal/sys
 
 replace github.com/nathfavour/vibeauracle/brain => ../../internal/brain
 
+replace github.com/nathfavour/vibeauracle/agent => ../../internal/agent
+
 replace github.com/nathfavour/vibeauracle/tooling => ../../internal/tooling
 
 replace github.com/nathfavour/vibeauracle/internal/doctor => ../../internal/doctor
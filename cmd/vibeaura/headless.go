@@ -0,0 +1,262 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nathfavour/vibeauracle/brain"
+	"github.com/nathfavour/vibeauracle/tooling"
+)
+
+// Exit codes of 'vibeaura direct', for scripts and CI.
+const (
+	exitOK           = 0
+	exitFailure      = 1
+	exitLoop         = 2
+	exitIntervention = 3
+	exitBudget       = 4
+)
+
+// directResult is the outcome of one headless request: the whole document for
+// --output json, and the payload of the final event for --output ndjson.
+type directResult struct {
+	Status       string                 `json:"status"` // ok|error|loop|intervention|budget_exhausted
+	ExitCode     int                    `json:"exit_code"`
+	Content      string                 `json:"content,omitempty"`
+	Error        string                 `json:"error,omitempty"`
+	HaltReason   string                 `json:"halt_reason,omitempty"`
+	Intervention string                 `json:"intervention,omitempty"`
+	Budget       *brain.BudgetExhausted `json:"budget_exhausted,omitempty"`
+	Usage        *tooling.Usage         `json:"usage,omitempty"`
+	ToolCalls    []tooling.ToolCall     `json:"tool_calls,omitempty"`
+}
+
+// directEvent is one line of --output ndjson.
+type directEvent struct {
+	Type    string        `json:"type"` // status|delta|tool_call|intervention|final
+	Time    time.Time     `json:"time"`
+	Icon    string        `json:"icon,omitempty"`
+	Step    string        `json:"step,omitempty"`
+	Message string        `json:"message,omitempty"`
+	Text    string        `json:"text,omitempty"`
+	Tool    string        `json:"tool,omitempty"`
+	Args    interface{}   `json:"args,omitempty"`
+	Output  interface{}   `json:"output,omitempty"`
+	Error   string        `json:"error,omitempty"`
+	Choice  string        `json:"choice,omitempty"`
+	Diff    string        `json:"diff,omitempty"`
+	Result  *directResult `json:"result,omitempty"`
+}
+
+// directOutput renders brain activity in the selected --output format.
+// Its methods may be called from concurrent tool executions.
+type directOutput struct {
+	format string // text|json|ndjson
+
+	mu       sync.Mutex
+	w        io.Writer
+	enc      *json.Encoder
+	streamed bool
+	calls    []tooling.ToolCall
+}
+
+func newDirectOutput(format string) (*directOutput, error) {
+	switch format {
+	case "text", "json", "ndjson":
+	default:
+		return nil, fmt.Errorf("unknown output format %q (want text, json or ndjson)", format)
+	}
+	return &directOutput{format: format, w: os.Stdout, enc: json.NewEncoder(os.Stdout)}, nil
+}
+
+// structured reports whether stdout is reserved for JSON.
+func (o *directOutput) structured() bool {
+	return o.format != "text"
+}
+
+func (o *directOutput) emit(e directEvent) {
+	if o.format != "ndjson" {
+		return
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	e.Time = time.Now()
+	_ = o.enc.Encode(e)
+}
+
+func (o *directOutput) status(icon, step, msg string) {
+	switch o.format {
+	case "text":
+		if directVerbose {
+			fmt.Printf("\033[34m[%s] %-12s |\033[0m %s\n", icon, strings.ToUpper(step), msg)
+		}
+	case "ndjson":
+		o.emit(directEvent{Type: "status", Icon: icon, Step: step, Message: msg})
+	}
+}
+
+func (o *directOutput) delta(text string) {
+	o.mu.Lock()
+	o.streamed = true
+	o.mu.Unlock()
+	switch o.format {
+	case "text":
+		fmt.Print(text)
+	case "ndjson":
+		o.emit(directEvent{Type: "delta", Text: text})
+	}
+}
+
+func (o *directOutput) streamDone() {
+	if o.format == "text" {
+		fmt.Println()
+	}
+}
+
+func (o *directOutput) toolCall(call tooling.ToolCall) {
+	o.mu.Lock()
+	o.calls = append(o.calls, call)
+	o.mu.Unlock()
+	o.emit(directEvent{Type: "tool_call", Tool: call.ToolName, Args: call.Args, Output: call.Result, Error: call.Error})
+}
+
+func (o *directOutput) intervention(tool string, iv *tooling.InterventionError, choice string) {
+	e := directEvent{Type: "intervention", Tool: tool, Message: iv.Title, Choice: choice}
+	if iv.Review != nil {
+		e.Diff = iv.Review.String()
+	}
+	o.emit(e)
+}
+
+// finish classifies the result of a request, renders it and returns it. Calls recorded
+// so far are attached and cleared, so the REPL can reuse the output.
+func (o *directOutput) finish(resp brain.Response, err error) directResult {
+	o.mu.Lock()
+	res := classifyDirect(resp, err)
+	res.ToolCalls, o.calls = o.calls, nil
+	streamed := o.streamed
+	o.streamed = false
+	o.mu.Unlock()
+
+	switch o.format {
+	case "json":
+		enc := json.NewEncoder(o.w)
+		enc.SetIndent("", "  ")
+		_ = enc.Encode(res)
+	case "ndjson":
+		o.emit(directEvent{Type: "final", Result: &res})
+	default:
+		if res.Content != "" && !streamed {
+			fmt.Println(res.Content)
+		}
+		switch res.Status {
+		case "error":
+			fmt.Printf("\n\033[31mBRAIN ERROR:\033[0m %s\n", res.Error)
+		case "intervention":
+			fmt.Printf("\n\033[33mAPPROVAL REQUIRED:\033[0m %s\n", res.Intervention)
+			fmt.Println("Re-run with --approve-policy to answer approvals unattended.")
+		case "loop":
+			fmt.Printf("\n\033[33mHALTED:\033[0m %s\n", res.HaltReason)
+		case "budget_exhausted":
+			fmt.Printf("\n\033[33mBUDGET EXHAUSTED:\033[0m %s\n", res.Budget.String())
+			if res.Budget.Limit == "max_turns" {
+				fmt.Println("Raise the limit with --max-turns or agent.limits.max_turns to let it run longer.")
+			}
+		}
+	}
+	return res
+}
+
+// classifyDirect maps a brain result to a status and exit code.
+func classifyDirect(resp brain.Response, err error) directResult {
+	if err != nil {
+		var intervention *tooling.InterventionError
+		if errors.As(err, &intervention) {
+			return directResult{Status: "intervention", ExitCode: exitIntervention, Intervention: intervention.Title, Error: err.Error()}
+		}
+		return directResult{Status: "error", ExitCode: exitFailure, Error: err.Error()}
+	}
+
+	res := directResult{Status: "ok", ExitCode: exitOK, Content: resp.Content}
+	if usage, ok := resp.Metadata["usage"].(tooling.Usage); ok {
+		res.Usage = &usage
+	}
+	res.HaltReason, _ = resp.Metadata["halt_reason"].(string)
+	if budget, ok := resp.Metadata["budget_exhausted"].(*brain.BudgetExhausted); ok {
+		res.Status, res.ExitCode, res.Budget = "budget_exhausted", exitBudget, budget
+	} else if strings.HasPrefix(res.HaltReason, "loop") {
+		res.Status, res.ExitCode = "loop", exitLoop
+	}
+	return res
+}
+
+// approvalAnswerer implements --approve-policy. "fail" leaves interventions unresolved,
+// "deny" refuses every one, "safe" approves low and medium risk actions once and refuses
+// the rest, and "all" approves everything once. Interventions list the approving
+// choice first and the refusal last.
+func approvalAnswerer(policy string) (func(tool string, iv *tooling.InterventionError) string, error) {
+	approve := func(iv *tooling.InterventionError) string { return iv.Choices[0] }
+	deny := func(iv *tooling.InterventionError) string { return iv.Choices[len(iv.Choices)-1] }
+
+	var decide func(iv *tooling.InterventionError) string
+	switch policy {
+	case "fail":
+		return nil, nil
+	case "deny":
+		decide = deny
+	case "safe":
+		decide = func(iv *tooling.InterventionError) string {
+			if iv.Risk == "low" || iv.Risk == "medium" {
+				return approve(iv)
+			}
+			return deny(iv)
+		}
+	case "all":
+		decide = approve
+	default:
+		return nil, fmt.Errorf("unknown approve policy %q (want fail, deny, safe or all)", policy)
+	}
+	return func(tool string, iv *tooling.InterventionError) string {
+		if len(iv.Choices) == 0 {
+			return ""
+		}
+		return decide(iv)
+	}, nil
+}
+
+// directPrompt resolves the prompt from the arguments, --prompt-file ("-" for stdin)
+// or piped stdin. An empty prompt means the REPL should start.
+func directPrompt(args []string, promptFile string) (string, error) {
+	if len(args) > 0 {
+		return strings.Join(args, " "), nil
+	}
+
+	var data []byte
+	var err error
+	switch {
+	case promptFile == "-":
+		data, err = io.ReadAll(os.Stdin)
+	case promptFile != "":
+		data, err = os.ReadFile(promptFile)
+	default:
+		info, statErr := os.Stdin.Stat()
+		if statErr != nil || info.Mode()&os.ModeCharDevice != 0 {
+			return "", nil
+		}
+		data, err = io.ReadAll(os.Stdin)
+	}
+	if err != nil {
+		return "", fmt.Errorf("reading prompt: %w", err)
+	}
+	prompt := strings.TrimSpace(string(data))
+	if prompt == "" && promptFile != "" {
+		return "", fmt.Errorf("prompt file %s is empty", promptFile)
+	}
+	return prompt, nil
+}
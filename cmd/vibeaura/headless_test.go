@@ -0,0 +1,102 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/nathfavour/vibeauracle/brain"
+	"github.com/nathfavour/vibeauracle/tooling"
+)
+
+func TestClassifyDirect(t *testing.T) {
+	budget := &brain.BudgetExhausted{Limit: "max_turns", Turns: 10}
+	usage := tooling.Usage{PromptTokens: 12, CompletionTokens: 34, Requests: 1}
+	tests := []struct {
+		name   string
+		resp   brain.Response
+		err    error
+		status string
+		code   int
+	}{
+		{"ok", brain.Response{Content: "done", Metadata: map[string]interface{}{"usage": usage}}, nil, "ok", exitOK},
+		{"no metadata", brain.Response{Content: "done"}, nil, "ok", exitOK},
+		{"error", brain.Response{}, errors.New("provider down"), "error", exitFailure},
+		{"intervention", brain.Response{}, &tooling.InterventionError{Title: "Allow rm?"}, "intervention", exitIntervention},
+		{"wrapped intervention", brain.Response{}, fmt.Errorf("goal interrupted: %w", &tooling.InterventionError{Title: "Allow rm?"}), "intervention", exitIntervention},
+		{"loop", brain.Response{Metadata: map[string]interface{}{"halt_reason": "loop: repeated tool call"}}, nil, "loop", exitLoop},
+		{"budget", brain.Response{Metadata: map[string]interface{}{"halt_reason": "budget: max_turns", "budget_exhausted": budget}}, nil, "budget_exhausted", exitBudget},
+		{"other halt", brain.Response{Content: "stopped", Metadata: map[string]interface{}{"halt_reason": "intervention"}}, nil, "ok", exitOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := classifyDirect(tt.resp, tt.err)
+			if res.Status != tt.status || res.ExitCode != tt.code {
+				t.Fatalf("got %s (%d), want %s (%d)", res.Status, res.ExitCode, tt.status, tt.code)
+			}
+			switch tt.status {
+			case "ok":
+				if res.Content != tt.resp.Content {
+					t.Errorf("content = %q, want %q", res.Content, tt.resp.Content)
+				}
+			case "error":
+				if res.Error != tt.err.Error() {
+					t.Errorf("error = %q", res.Error)
+				}
+			case "intervention":
+				if res.Intervention != "Allow rm?" || res.Error == "" {
+					t.Errorf("expected the intervention to be described, got %+v", res)
+				}
+			case "budget_exhausted":
+				if res.Budget != budget || res.HaltReason != "budget: max_turns" {
+					t.Errorf("expected the budget to be attached, got %+v", res)
+				}
+			}
+		})
+	}
+
+	res := classifyDirect(brain.Response{Metadata: map[string]interface{}{"usage": usage}}, nil)
+	if res.Usage == nil || *res.Usage != usage {
+		t.Errorf("expected usage to be attached, got %+v", res.Usage)
+	}
+}
+
+func TestApprovalAnswerer(t *testing.T) {
+	ask := func(risk string) *tooling.InterventionError {
+		return &tooling.InterventionError{Title: "Allow?", Risk: risk, Choices: []string{"Approve Once", "Approve Session", "Deny"}}
+	}
+	tests := []struct {
+		policy string
+		risk   string
+		want   string
+	}{
+		{"deny", "low", "Deny"},
+		{"deny", "high", "Deny"},
+		{"safe", "low", "Approve Once"},
+		{"safe", "medium", "Approve Once"},
+		{"safe", "high", "Deny"},
+		{"safe", "", "Deny"},
+		{"all", "high", "Approve Once"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.policy+"/"+tt.risk, func(t *testing.T) {
+			answer, err := approvalAnswerer(tt.policy)
+			if err != nil || answer == nil {
+				t.Fatalf("approvalAnswerer(%q) returned no answerer (%v)", tt.policy, err)
+			}
+			if got := answer("sys_shell_exec", ask(tt.risk)); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+			if got := answer("sys_shell_exec", &tooling.InterventionError{Title: "Allow?", Risk: tt.risk}); got != "" {
+				t.Errorf("an intervention without choices must stay unanswered, got %q", got)
+			}
+		})
+	}
+
+	if answer, err := approvalAnswerer("fail"); err != nil || answer != nil {
+		t.Errorf("fail should leave interventions unanswered (%v)", err)
+	}
+	if _, err := approvalAnswerer("sometimes"); err == nil {
+		t.Error("expected an unknown policy to be rejected")
+	}
+}
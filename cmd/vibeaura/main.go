@@ -99,7 +99,7 @@ the IDE, and the AI assistant into a single system-aware experience.`,
 var authCmd = &cobra.Command{
 	Use:   "auth",
 	Short: "Manage AI provider credentials",
-	Long:  "Securely store and manage API keys for providers like GitHub Copilot, GitHub Models, OpenAI, and Ollama.",
+	Long:  "Securely store and manage API keys for providers like GitHub Copilot, GitHub Models, OpenAI, Anthropic, and Ollama.",
 }
 
 var authCopilotCmd = &cobra.Command{
@@ -165,6 +165,23 @@ var authOpenAICmd = &cobra.Command{
 	},
 }
 
+var authAnthropicCmd = &cobra.Command{
+	Use:   "anthropic <api-key>",
+	Short: "Configure Anthropic API key",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		key := args[0]
+		b := brain.New()
+		err := b.StoreSecret("anthropic_api_key", key)
+		if err != nil {
+			printError(err.Error())
+			os.Exit(1)
+		}
+		printSuccess("Anthropic API key stored in secure vault.")
+		printCommand("💡 Use", "vibeaura models use anthropic <model>", "to switch.")
+	},
+}
+
 var modelsCmd = &cobra.Command{
 	Use:   "models",
 	Short: "Discover and manage AI models",
@@ -239,6 +256,26 @@ var agentSDKCmd = &cobra.Command{
 	},
 }
 
+var agentGoalCmd = &cobra.Command{
+	Use:   "goal",
+	Short: "Use the goal-driven agent with milestones and resumable checkpoints",
+	Run: func(cmd *cobra.Command, args []string) {
+		b := brain.New()
+		b.SetAgentMode("goal")
+		printStatus("AGENT", "Now using goal-driven Agent Engine (resume with 'vibeaura resume')")
+	},
+}
+
+var agentOrchestrateCmd = &cobra.Command{
+	Use:   "orchestrate",
+	Short: "Use an architect, role-restricted executors and a QA reviewer",
+	Run: func(cmd *cobra.Command, args []string) {
+		b := brain.New()
+		b.SetAgentMode("orchestrate")
+		printStatus("AGENT", "Now using architect/executor/QA orchestration")
+	},
+}
+
 var sysCmd = &cobra.Command{
 	Use:   "sys",
 	Short: "System and hardware intimacy controls",
@@ -280,6 +317,7 @@ func main() {
 	authCmd.AddCommand(authGithubCmd)
 	authCmd.AddCommand(authOllamaCmd)
 	authCmd.AddCommand(authOpenAICmd)
+	authCmd.AddCommand(authAnthropicCmd)
 
 	rootCmd.AddCommand(modelsCmd)
 	modelsCmd.AddCommand(modelsListCmd)
@@ -288,6 +326,8 @@ func main() {
 	rootCmd.AddCommand(agentCmd)
 	agentCmd.AddCommand(agentVibeCmd)
 	agentCmd.AddCommand(agentSDKCmd)
+	agentCmd.AddCommand(agentGoalCmd)
+	agentCmd.AddCommand(agentOrchestrateCmd)
 
 	rootCmd.AddCommand(sysCmd)
 	sysCmd.AddCommand(sysStatsCmd)
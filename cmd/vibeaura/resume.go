@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+
+	"github.com/nathfavour/vibeauracle/agent"
+	"github.com/nathfavour/vibeauracle/brain"
+	"github.com/nathfavour/vibeauracle/tooling"
+	"github.com/spf13/cobra"
+)
+
+var resumeApprovePolicy string
+
+var resumeCmd = &cobra.Command{
+	Use:   "resume [goal-id]",
+	Short: "Resume an interrupted goal from its last checkpoint",
+	Long: `The goal agent ('vibeaura agent goal') saves its progress after every turn.
+A goal that stopped early (crash, Ctrl+C, a pending approval, or running out of
+turns) picks up from its first incomplete milestone.
+
+Use --approve-policy to answer approvals unattended, as in 'vibeaura direct'.
+Without an argument, lists saved goals.`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		answer, err := approvalAnswerer(resumeApprovePolicy)
+		if err != nil {
+			printError(err.Error())
+			os.Exit(1)
+		}
+		b := brain.New()
+
+		if len(args) == 0 {
+			goals, err := b.Goals()
+			if err != nil {
+				printError(err.Error())
+				os.Exit(1)
+			}
+			printTitle("🎯", "GOALS")
+			if len(goals) == 0 {
+				printInfo("No saved goals. Switch to the goal agent with 'vibeaura agent goal'.")
+				return
+			}
+			for _, g := range goals {
+				done, total := g.Goal.Progress()
+				meta := fmt.Sprintf("%s · %d/%d milestones · turn %d", g.Goal.Status, done, total, g.Turns)
+				printBulletWithMeta(fmt.Sprintf("%-26s %s", g.Goal.ID, truncateMessage(g.Goal.Description)), meta)
+			}
+			printNewline()
+			return
+		}
+
+		tooling.StatusReporter = func(icon, step, msg string) {
+			fmt.Printf("\033[34m[%s] %-12s |\033[0m %s\n", icon, strings.ToUpper(step), msg)
+		}
+		if answer != nil {
+			b.OnIntervention = answer
+		}
+
+		// Ctrl+C stops the goal after saving its state, so it can be resumed again.
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+
+		resp, err := b.ResumeGoal(ctx, args[0])
+		if err != nil {
+			var intervention *tooling.InterventionError
+			if errors.As(err, &intervention) {
+				printWarning("Approval required: " + intervention.Title)
+			}
+			var interrupted *agent.InterruptedError
+			if errors.As(err, &interrupted) {
+				printInfo(fmt.Sprintf("Progress saved. Continue with: vibeaura resume %s", interrupted.GoalID))
+			}
+			printError(err.Error())
+			os.Exit(1)
+		}
+
+		if budget, ok := resp.Metadata["budget_exhausted"].(*brain.BudgetExhausted); ok {
+			printWarning(budget.String())
+			printInfo(fmt.Sprintf("Progress saved. Continue with: vibeaura resume %s", args[0]))
+			os.Exit(exitBudget)
+		}
+
+		printNewline()
+		fmt.Println(resp.Content)
+		if milestones, ok := resp.Metadata["milestones"].([]agent.Milestone); ok {
+			printNewline()
+			for _, m := range milestones {
+				printBullet(m.Description)
+			}
+		}
+		printSuccess("Goal completed")
+	},
+}
+
+func init() {
+	resumeCmd.Flags().StringVar(&resumeApprovePolicy, "approve-policy", "fail", "Answer approvals unattended: fail, deny, safe (approve low/medium risk) or all")
+	rootCmd.AddCommand(resumeCmd)
+}
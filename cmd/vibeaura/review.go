@@ -0,0 +1,208 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/nathfavour/vibeauracle/tooling"
+)
+
+// --- Write review: per-hunk diff approval ---
+
+var (
+	diffAddStyle  = lipgloss.NewStyle().Foreground(lipgloss.Color("#5FD75F"))
+	diffDelStyle  = lipgloss.NewStyle().Foreground(lipgloss.Color("#FF5F5F"))
+	diffHunkStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("#04D9FF"))
+	diffFileStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("#FAFAFA")).Bold(true)
+	diffSkipStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("#444444")).Strikethrough(true)
+)
+
+// reviewLine is one rendered line of the diff; hunk is -1 for file headers.
+type reviewLine struct {
+	kind string // file|hunk|" "|"-"|"+"
+	text string
+	hunk int
+}
+
+// reviewState holds a pending write review in the TUI.
+type reviewState struct {
+	title    string
+	lines    []reviewLine
+	starts   []int // first line of each hunk
+	accepted []bool
+	cursor   int // selected hunk
+	scroll   int // first visible line
+	resume   func(choice string) (interface{}, error)
+}
+
+func newReviewState(iv *tooling.InterventionError) *reviewState {
+	r := &reviewState{
+		title:    iv.Title,
+		accepted: make([]bool, iv.Review.HunkCount()),
+		resume: func(choice string) (interface{}, error) {
+			return iv.Resume(context.Background(), choice)
+		},
+	}
+	for i := range r.accepted {
+		r.accepted[i] = true
+	}
+
+	hunk := 0
+	for _, f := range iv.Review.Files {
+		header := f.Path
+		switch {
+		case f.Created:
+			header += " (new file)"
+		case f.Deleted:
+			header += " (deleted)"
+		}
+		r.lines = append(r.lines, reviewLine{kind: "file", text: header, hunk: -1})
+		for _, h := range f.Hunks {
+			r.starts = append(r.starts, len(r.lines))
+			r.lines = append(r.lines, reviewLine{kind: "hunk", text: h.Header(), hunk: hunk})
+			for _, l := range h.Lines {
+				text := strings.TrimRight(strings.ReplaceAll(l.Text, "\t", "    "), "\r\n")
+				r.lines = append(r.lines, reviewLine{kind: l.Kind, text: l.Kind + text, hunk: hunk})
+			}
+			hunk++
+		}
+	}
+	return r
+}
+
+// reviewHeight is the number of diff lines shown at once.
+func (m *model) reviewHeight() int {
+	return max(6, m.viewport.Height-12)
+}
+
+// handleReviewKey handles hunk navigation, toggling and applying in the review UI.
+func (m *model) handleReviewKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	r := m.pendingReview
+	height := m.reviewHeight()
+
+	switch msg.String() {
+	case "up", "k":
+		if r.cursor > 0 {
+			r.cursor--
+		}
+		r.scroll = r.starts[r.cursor]
+	case "down", "j":
+		if r.cursor < len(r.starts)-1 {
+			r.cursor++
+		}
+		r.scroll = r.starts[r.cursor]
+	case "pgup":
+		r.scroll = max(0, r.scroll-height)
+	case "pgdown":
+		r.scroll = min(max(0, len(r.lines)-height), r.scroll+height)
+	case " ", "tab":
+		r.accepted[r.cursor] = !r.accepted[r.cursor]
+	case "a":
+		return m.finishReview(tooling.ReviewAcceptAll)
+	case "r":
+		return m.finishReview(tooling.ReviewRejectAll)
+	case "enter":
+		return m.finishReview(tooling.AcceptHunksChoice(r.accepted))
+	case "esc":
+		m.pendingReview = nil
+		if len(m.messages) > 0 {
+			m.messages = m.messages[:len(m.messages)-1]
+		}
+		m.messages = append(m.messages, subtleStyle.Render("→ Review cancelled, nothing was written"))
+		m.viewport.SetContent(m.renderMessages())
+		m.viewport.GotoBottom()
+		return m, nil
+	default:
+		return m, nil
+	}
+
+	// Fill the pane when the selection is near the end of the diff.
+	r.scroll = max(0, min(r.scroll, len(r.lines)-height))
+	m.messages[len(m.messages)-1] = m.renderReview()
+	m.viewport.SetContent(m.renderMessages())
+	m.viewport.GotoBottom()
+	return m, nil
+}
+
+// finishReview resumes the held write with the user's choice.
+func (m *model) finishReview(choice string) (tea.Model, tea.Cmd) {
+	resumeFn := m.pendingReview.resume
+	m.pendingReview = nil
+	if len(m.messages) > 0 {
+		m.messages = m.messages[:len(m.messages)-1]
+	}
+	m.messages = append(m.messages, subtleStyle.Render("→ "+choice))
+	m.viewport.SetContent(m.renderMessages())
+	m.viewport.GotoBottom()
+
+	m.isThinking = true
+	return m, m.resumeIntervention(resumeFn, choice)
+}
+
+// renderReview draws the visible part of the diff with the hunk selection.
+func (m *model) renderReview() string {
+	r := m.pendingReview
+	if r == nil {
+		return ""
+	}
+	width := max(20, m.viewport.Width-10)
+	height := m.reviewHeight()
+
+	accepted := 0
+	for _, ok := range r.accepted {
+		if ok {
+			accepted++
+		}
+	}
+
+	var lines []string
+	lines = append(lines, interventionTitleStyle.Render("📝 "+r.title))
+	lines = append(lines, helpStyle.Render(fmt.Sprintf("Hunk %d/%d · %d accepted · ↑/↓ hunk · Space toggle · PgUp/PgDn scroll", r.cursor+1, len(r.starts), accepted)))
+	lines = append(lines, helpStyle.Render("Enter apply selection · a accept all · r reject all · Esc cancel"))
+	lines = append(lines, "")
+
+	end := min(len(r.lines), r.scroll+height)
+	for _, l := range r.lines[r.scroll:end] {
+		text := l.text
+		if runes := []rune(text); len(runes) > width {
+			text = string(runes[:width-1]) + "…"
+		}
+		var style lipgloss.Style
+		switch l.kind {
+		case "file":
+			style = diffFileStyle
+		case "hunk":
+			mark := "[x] "
+			if !r.accepted[l.hunk] {
+				mark = "[ ] "
+			}
+			text = mark + text
+			style = diffHunkStyle
+			if l.hunk == r.cursor {
+				style = interventionSelectedStyle.PaddingLeft(0)
+			}
+		case "+":
+			style = diffAddStyle
+		case "-":
+			style = diffDelStyle
+		default:
+			style = subtleStyle
+		}
+		if l.hunk >= 0 && l.kind != "hunk" && !r.accepted[l.hunk] {
+			style = diffSkipStyle
+		}
+		prefix := "  "
+		if l.hunk == r.cursor && l.hunk >= 0 {
+			prefix = "▌ "
+		}
+		lines = append(lines, prefix+style.Render(text))
+	}
+	if end < len(r.lines) {
+		lines = append(lines, helpStyle.Render(fmt.Sprintf("  … %d more lines", len(r.lines)-end)))
+	}
+
+	return interventionBoxStyle.Render(strings.Join(lines, "\n"))
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/nathfavour/vibeauracle/brain"
+	"github.com/spf13/cobra"
+)
+
+var (
+	undoForce bool
+	undoList  bool
+)
+
+var undoCmd = &cobra.Command{
+	Use:   "undo [thread]",
+	Short: "Revert the files changed by the last agent turn",
+	Long: `Every file the agent writes, edits or deletes is snapshotted first, per
+session (working directory) and thread. Without an argument, undo restores the
+files changed by the most recent turn; running it again walks further back.
+Pass a thread ID (or a prefix of one, see --list) to undo a specific turn.
+
+Files modified outside the agent since its last write are left untouched and
+reported; --force restores them anyway. Changes made by shell commands are not
+journaled.`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		b := brain.New()
+
+		if undoList {
+			records, err := b.Changes()
+			if err != nil {
+				printError(err.Error())
+				os.Exit(1)
+			}
+			printTitle("⏪", "CHANGES")
+			if len(records) == 0 {
+				printInfo("No agent file changes to undo in this directory.")
+				return
+			}
+			for _, r := range records {
+				meta := fmt.Sprintf("%s · %d file(s)", r.Started.Format("Jan 02 15:04"), len(r.Entries))
+				printBulletWithMeta(fmt.Sprintf("%-10s %s", shortThread(r.Thread), promptSummary(r.Prompt)), meta)
+			}
+			printNewline()
+			return
+		}
+
+		var thread string
+		if len(args) == 1 {
+			thread = args[0]
+		}
+		res, err := b.Undo(thread, undoForce)
+		if res != nil {
+			for _, path := range res.Restored {
+				printBullet(path)
+			}
+			if len(res.Restored) > 0 {
+				printSuccess(fmt.Sprintf("Reverted %d file(s) changed by thread %s", len(res.Restored), shortThread(res.Thread)))
+			}
+			if len(res.Conflicts) > 0 {
+				printWarning("Modified outside the agent since the snapshot, left untouched:")
+				for _, path := range res.Conflicts {
+					printBullet(path)
+				}
+				printInfo(fmt.Sprintf("Run 'vibeaura undo %s --force' to overwrite them.", shortThread(res.Thread)))
+			}
+		}
+		if err != nil {
+			printError(err.Error())
+			os.Exit(1)
+		}
+	},
+}
+
+// shortThread abbreviates a thread ID for display; undo accepts any unique prefix.
+func shortThread(id string) string {
+	if len(id) > 8 {
+		return id[:8]
+	}
+	return id
+}
+
+// promptSummary is the prompt of a turn on one short line.
+func promptSummary(prompt string) string {
+	return truncateMessage(strings.Join(strings.Fields(prompt), " "))
+}
+
+func init() {
+	undoCmd.Flags().BoolVarP(&undoForce, "force", "f", false, "Also restore files modified outside the agent since the snapshot")
+	undoCmd.Flags().BoolVarP(&undoList, "list", "l", false, "List the turns that can be undone")
+	rootCmd.AddCommand(undoCmd)
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/nathfavour/vibeauracle/brain"
+	"github.com/nathfavour/vibeauracle/tooling"
+	"github.com/spf13/cobra"
+)
+
+var usageDays int
+
+var usageCmd = &cobra.Command{
+	Use:   "usage",
+	Short: "Show token usage and spend per session and per day",
+	Long: `Show token usage and estimated spend.
+
+The first section covers the session of the current directory, broken down by
+provider and model. The second lists daily totals with a per-directory breakdown.
+Prices come from the 'pricing' table in ~/.vibeauracle/config.yaml (USD per
+million tokens). Counts marked '~' were estimated because the provider did not
+report them.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		b := brain.New()
+
+		printTitle("📊", "SESSION USAGE")
+		printKeyValue("Directory", b.GetSessionPath())
+		session := b.SessionUsage()
+		printKeyValueHighlight("Total    ", formatUsage(session.Total))
+		for _, key := range sortedUsageKeys(session.ByModel) {
+			printBulletWithMeta(fmt.Sprintf("%-36s", key), formatUsage(session.ByModel[key]))
+		}
+		printNewline()
+
+		days, err := b.UsageHistory(usageDays)
+		if err != nil {
+			printError(err.Error())
+			os.Exit(1)
+		}
+
+		printTitle("📅", fmt.Sprintf("LAST %d DAYS", usageDays))
+		if len(days) == 0 {
+			printInfo("No usage recorded yet.")
+			return
+		}
+		for _, day := range days {
+			total := day.Total()
+			printKeyValueHighlight(day.Date, formatUsage(total.Total))
+			for _, dir := range sortedUsageKeys(day.Directories) {
+				printBulletWithMeta(dir, formatUsage(day.Directories[dir].Total))
+			}
+		}
+		printNewline()
+	},
+}
+
+// formatUsage renders a one-line token and cost summary.
+func formatUsage(u tooling.Usage) string {
+	approx := ""
+	if u.Estimated {
+		approx = "~"
+	}
+	cost := fmt.Sprintf("$%.4f", u.Cost)
+	if u.Unpriced {
+		cost += " (some models unpriced)"
+	}
+	return fmt.Sprintf("%s%d tokens (%d in / %d out) · %s · %d requests",
+		approx, u.TotalTokens(), u.PromptTokens, u.CompletionTokens, cost, u.Requests)
+}
+
+func sortedUsageKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func init() {
+	usageCmd.Flags().IntVar(&usageDays, "days", 7, "number of days of history to show")
+	rootCmd.AddCommand(usageCmd)
+}
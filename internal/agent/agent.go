@@ -3,35 +3,72 @@ package agent
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/nathfavour/vibeauracle/prompt"
 	"github.com/nathfavour/vibeauracle/tooling"
 )
 
+// Goal statuses.
+const (
+	StatusPending     = "pending"
+	StatusActive      = "active"
+	StatusCompleted   = "completed"
+	StatusFailed      = "failed"
+	StatusInterrupted = "interrupted"
+)
+
+// StatePrefix keys persisted loop states in the state store.
+const StatePrefix = "agent_goal:"
+
+// Budget errors end a goal that ran out of turns or tool calls. They are wrapped in the
+// InterruptedError the engine returns, so callers can tell them apart with errors.Is.
+var (
+	ErrMaxTurns     = errors.New("max turns reached")
+	ErrMaxToolCalls = errors.New("max tool calls reached")
+)
+
 // Goal represents the high-level objective of an agentic loop.
 type Goal struct {
-	ID          string
-	Description string
-	Status      string // pending|active|completed|failed
-	Milestones  []Milestone
-	Confidence  float64
+	ID          string      `json:"id"`
+	Description string      `json:"description"`
+	Status      string      `json:"status"` // pending|active|completed|failed|interrupted
+	Milestones  []Milestone `json:"milestones"`
+	Confidence  float64     `json:"confidence"`
 }
 
 type Milestone struct {
-	Description string
-	Completed   bool
+	Description string `json:"description"`
+	Completed   bool   `json:"completed"`
+}
+
+// Progress returns how many milestones are completed.
+func (g Goal) Progress() (done, total int) {
+	for _, m := range g.Milestones {
+		if m.Completed {
+			done++
+		}
+	}
+	return done, len(g.Milestones)
 }
 
 // LoopState tracks the progress of a multi-turn interaction.
 type LoopState struct {
-	Goal       Goal
-	Turns      int
-	MaxTurns   int
-	History    []string
-	Confidence float64
-	StartTime  time.Time
+	Goal       Goal      `json:"goal"`
+	Turns      int       `json:"turns"`
+	MaxTurns   int       `json:"max_turns"`
+	ToolCalls  int       `json:"tool_calls,omitempty"`
+	History    []string  `json:"history"`
+	Confidence float64   `json:"confidence"`
+	StartTime  time.Time `json:"start_time"`
+	UpdatedAt  time.Time `json:"updated_at"`
+	LastError  string    `json:"last_error,omitempty"`
 }
 
 // Model defines the minimal interface the agent needs to prompt the AI.
@@ -39,21 +76,51 @@ type Model interface {
 	Generate(ctx context.Context, prompt string) (string, error)
 }
 
+// Store persists loop states between turns so interrupted goals can be resumed.
+// context.Memory satisfies it.
+type Store interface {
+	SaveState(id string, state interface{}) error
+	LoadState(id string, target interface{}) error
+	ListStates(prefix string) ([]string, error)
+}
+
+// InterruptedError is returned when a goal stops before completion. The state has
+// been saved, so the goal can be picked up again with Engine.Resume.
+type InterruptedError struct {
+	GoalID string
+	Err    error
+}
+
+func (e *InterruptedError) Error() string {
+	return fmt.Sprintf("goal %s interrupted: %v", e.GoalID, e.Err)
+}
+
+func (e *InterruptedError) Unwrap() error { return e.Err }
+
 // Engine manages the "handshake" loop between AI creativity and agentic control.
 type Engine struct {
 	model    Model
 	registry *tooling.Registry
 	prompts  *prompt.System
+	store    Store
 	config   Config
 }
 
+// ToolRunner runs one tool call and returns its result text. Failures belong in the
+// text; the error is only set when the call needs user intervention.
+type ToolRunner func(ctx context.Context, tool string, args json.RawMessage) (string, error)
+
 type Config struct {
-	MaxTurns       int
-	MinConfidence  float64
+	MaxTurns int
+	// MaxToolCalls bounds the tool calls of one Run or Resume; 0 means no limit.
+	MaxToolCalls    int
+	MinConfidence   float64
 	LearningEnabled bool
+	// RunTool, when set, runs tool calls instead of executing them on the registry.
+	RunTool ToolRunner
 }
 
-func NewEngine(m Model, r *tooling.Registry, p *prompt.System, cfg Config) *Engine {
+func NewEngine(m Model, r *tooling.Registry, p *prompt.System, s Store, cfg Config) *Engine {
 	if cfg.MaxTurns == 0 {
 		cfg.MaxTurns = 10
 	}
@@ -64,96 +131,296 @@ func NewEngine(m Model, r *tooling.Registry, p *prompt.System, cfg Config) *Engi
 		model:    m,
 		registry: r,
 		prompts:  p,
+		store:    s,
 		config:   cfg,
 	}
 }
 
-// Run executes a task loop until completion, turn limit, or confidence drop.
+// Run plans milestones for a new goal and executes the task loop until completion,
+// turn limit, or confidence drop.
 func (e *Engine) Run(ctx context.Context, initialPrompt string, onUpdate func(LoopState)) (string, error) {
 	state := LoopState{
 		Goal: Goal{
+			ID:          fmt.Sprintf("goal-%d", time.Now().UnixNano()),
 			Description: initialPrompt,
-			Status:      "active",
+			Status:      StatusPending,
 		},
 		MaxTurns:   e.config.MaxTurns,
 		Confidence: 1.0,
 		StartTime:  time.Now(),
 	}
 
+	milestones, err := e.planMilestones(ctx, initialPrompt)
+	if err != nil {
+		return "", e.interrupt(&state, err)
+	}
+	state.Goal.Milestones = milestones
+	state.Goal.Status = StatusActive
+	if err := e.save(&state); err != nil {
+		return "", err
+	}
+
+	return e.loop(ctx, &state, onUpdate)
+}
+
+// Resume continues a goal from its last saved turn. Goals that ran out of turns or
+// confidence get a fresh allowance, since resuming is the user's go-ahead.
+func (e *Engine) Resume(ctx context.Context, goalID string, onUpdate func(LoopState)) (string, error) {
+	state, err := e.Load(goalID)
+	if err != nil {
+		return "", err
+	}
+	if state.Goal.Status == StatusCompleted {
+		return "", fmt.Errorf("goal %s is already completed", goalID)
+	}
+
+	state.Goal.Status = StatusActive
+	state.LastError = ""
+	if state.Turns >= state.MaxTurns {
+		state.MaxTurns = state.Turns + e.config.MaxTurns
+	}
+	if state.Confidence < e.config.MinConfidence {
+		state.Confidence = 1.0
+	}
+	state.History = append(state.History, "RESUMED: continue from the first incomplete milestone.")
+	return e.loop(ctx, state, onUpdate)
+}
+
+// Load returns the saved state of a goal.
+func (e *Engine) Load(goalID string) (*LoopState, error) {
+	if e.store == nil {
+		return nil, fmt.Errorf("no state store configured")
+	}
+	var state LoopState
+	if err := e.store.LoadState(StatePrefix+goalID, &state); err != nil {
+		return nil, fmt.Errorf("loading goal %s: %w", goalID, err)
+	}
+	return &state, nil
+}
+
+// List returns every saved goal, most recently updated first.
+func (e *Engine) List() ([]LoopState, error) {
+	if e.store == nil {
+		return nil, fmt.Errorf("no state store configured")
+	}
+	ids, err := e.store.ListStates(StatePrefix)
+	if err != nil {
+		return nil, err
+	}
+	var out []LoopState
+	for _, id := range ids {
+		var state LoopState
+		if err := e.store.LoadState(id, &state); err == nil {
+			out = append(out, state)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].UpdatedAt.After(out[j].UpdatedAt) })
+	return out, nil
+}
+
+func (e *Engine) loop(ctx context.Context, state *LoopState, onUpdate func(LoopState)) (string, error) {
+	startCalls := state.ToolCalls
 	for state.Turns < state.MaxTurns {
 		state.Turns++
 		if onUpdate != nil {
-			onUpdate(state)
+			onUpdate(*state)
 		}
 
 		// 1. Handshake: Build current prompt based on state.
 		// In agent mode, the prompt metamorphoses into a "work instruction".
-		handshakePrompt := e.buildHandshakePrompt(state)
+		handshakePrompt := e.buildHandshakePrompt(*state)
 
 		// 2. AI (Bricklayer) Generation.
 		resp, err := e.model.Generate(ctx, handshakePrompt)
 		if err != nil {
-			return "", fmt.Errorf("agent turn %d: %w", state.Turns, err)
+			state.Turns-- // the turn never happened
+			return "", e.interrupt(state, fmt.Errorf("agent turn %d: %w", state.Turns+1, err))
 		}
 
 		// 3. Analysis: The bureaucratic manager parses the bricks.
 		parsed := prompt.ParseModelResponse(resp)
-		
+		progressed := markMilestones(&state.Goal, resp)
+
 		// 4. Execution Loop: Extract and run tool calls if any.
-		result, toolsCalled, err := e.executeInferredTools(ctx, parsed)
-		if err != nil {
-			// If we hit an approval error, we must bubble it up as an "intervention required" signal.
-			return "", err
+		calls := inferToolCalls(parsed)
+		if e.config.MaxToolCalls > 0 && state.ToolCalls-startCalls+len(calls) > e.config.MaxToolCalls {
+			state.History = append(state.History, resp)
+			return "", e.interrupt(state, fmt.Errorf("%w (%d)", ErrMaxToolCalls, e.config.MaxToolCalls))
 		}
-
-		// 5. Reflection & Confidence Adjustment.
+		toolsCalled := len(calls) > 0
+		result, ran, err := e.executeInferredTools(ctx, calls)
+		state.ToolCalls += ran
 		state.History = append(state.History, resp)
 		if result != "" {
 			state.History = append(state.History, "TOOL_RESULT: "+result)
 		}
-		
-		state.Confidence = e.calculateConfidence(state, toolsCalled)
-
-		// Check for exit conditions.
-		if state.Confidence < e.config.MinConfidence {
-			return resp, fmt.Errorf("agent lost confidence (%.2f < %.2f) - consulting user", state.Confidence, e.config.MinConfidence)
+		if err != nil {
+			// Approval requests bubble up as an "intervention required" signal.
+			state.History = append(state.History, "TOOL_PENDING: "+err.Error())
+			return "", e.interrupt(state, err)
 		}
 
+		// 5. Reflection & Confidence Adjustment.
+		state.Confidence = e.calculateConfidence(*state, toolsCalled, progressed)
+
 		// Look for completion markers in AI response.
-		if strings.Contains(strings.ToLower(resp), "goal completed") || strings.Contains(strings.ToLower(resp), "[task_done]") {
-			state.Goal.Status = "completed"
+		lower := strings.ToLower(resp)
+		if strings.Contains(lower, "goal completed") || strings.Contains(lower, "[task_done]") {
+			for i := range state.Goal.Milestones {
+				state.Goal.Milestones[i].Completed = true
+			}
+			state.Goal.Status = StatusCompleted
+			state.Goal.Confidence = state.Confidence
+			if err := e.save(state); err != nil {
+				return resp, err
+			}
+			if onUpdate != nil {
+				onUpdate(*state)
+			}
 			return resp, nil
 		}
 
+		// Check for exit conditions.
+		if state.Confidence < e.config.MinConfidence {
+			return resp, e.interrupt(state, fmt.Errorf("agent lost confidence (%.2f < %.2f) - consulting user", state.Confidence, e.config.MinConfidence))
+		}
+
 		// If no tools were called and no goal was met, the bricklayer might be stuck.
-		if !toolsCalled && state.Turns > 2 {
+		if !toolsCalled && !progressed && state.Turns > 2 {
 			state.Confidence -= 0.2
 		}
+
+		if err := e.save(state); err != nil {
+			return "", err
+		}
+	}
+
+	cause := fmt.Errorf("%w (%d) without completing goal", ErrMaxTurns, state.MaxTurns)
+	state.Goal.Status = StatusFailed
+	state.LastError = cause.Error()
+	if err := e.save(state); err != nil {
+		return "", err
+	}
+	return "", &InterruptedError{GoalID: state.Goal.ID, Err: cause}
+}
+
+// interrupt records why the loop stopped and saves the state for a later Resume.
+func (e *Engine) interrupt(state *LoopState, cause error) error {
+	state.Goal.Status = StatusInterrupted
+	state.LastError = cause.Error()
+	if err := e.save(state); err != nil {
+		return fmt.Errorf("%w (saving state: %v)", cause, err)
+	}
+	return &InterruptedError{GoalID: state.Goal.ID, Err: cause}
+}
+
+func (e *Engine) save(state *LoopState) error {
+	if e.store == nil {
+		return nil
+	}
+	state.Goal.Confidence = state.Confidence
+	state.UpdatedAt = time.Now()
+	if err := e.store.SaveState(StatePrefix+state.Goal.ID, state); err != nil {
+		return fmt.Errorf("saving goal %s: %w", state.Goal.ID, err)
+	}
+	return nil
+}
+
+// planMilestones asks the model to break the goal into ordered, checkable steps.
+// A plan that cannot be parsed falls back to the goal itself as the only milestone.
+func (e *Engine) planMilestones(ctx context.Context, goal string) ([]Milestone, error) {
+	resp, err := e.model.Generate(ctx, fmt.Sprintf(`### AGENT PLANNING
+GOAL: %s
+
+Break the goal into 2-6 concrete, verifiable milestones in the order they should be done.
+Output ONLY a JSON array of short strings.`, goal))
+	if err != nil {
+		return nil, fmt.Errorf("planning milestones: %w", err)
+	}
+
+	var steps []string
+	if start, end := strings.Index(resp, "["), strings.LastIndex(resp, "]"); start != -1 && end > start {
+		_ = json.Unmarshal([]byte(resp[start:end+1]), &steps)
+	}
+	if len(steps) == 0 {
+		for _, line := range strings.Split(resp, "\n") {
+			line = strings.TrimSpace(line)
+			if item := strings.TrimLeft(line, "-*0123456789.) "); item != "" && item != line {
+				steps = append(steps, item)
+			}
+		}
+	}
+	if len(steps) == 0 {
+		steps = []string{goal}
+	}
+
+	milestones := make([]Milestone, 0, len(steps))
+	for _, s := range steps {
+		if s = strings.TrimSpace(s); s != "" {
+			milestones = append(milestones, Milestone{Description: s})
+		}
 	}
+	return milestones, nil
+}
 
-	return "", fmt.Errorf("max turns (%d) reached without completing goal", state.MaxTurns)
+var milestoneDonePattern = regexp.MustCompile(`(?i)\[milestone_done\s+(\d+)\]`)
+
+// markMilestones applies [MILESTONE_DONE n] markers (1-based) and reports whether
+// any milestone was newly completed.
+func markMilestones(goal *Goal, resp string) bool {
+	progressed := false
+	for _, m := range milestoneDonePattern.FindAllStringSubmatch(resp, -1) {
+		n, err := strconv.Atoi(m[1])
+		if err != nil || n < 1 || n > len(goal.Milestones) || goal.Milestones[n-1].Completed {
+			continue
+		}
+		goal.Milestones[n-1].Completed = true
+		progressed = true
+	}
+	return progressed
 }
 
 func (e *Engine) buildHandshakePrompt(state LoopState) string {
-	// Multi-layered handshake: Goal + History + Rules + Current State.
+	var milestones strings.Builder
+	for i, m := range state.Goal.Milestones {
+		mark := " "
+		if m.Completed {
+			mark = "x"
+		}
+		milestones.WriteString(fmt.Sprintf("%d. [%s] %s\n", i+1, mark, m.Description))
+	}
+
+	tools := ""
+	if e.registry != nil {
+		tools = e.registry.GetPromptDefinitions(nil)
+	}
+
+	// Multi-layered handshake: Goal + Milestones + History + Rules + Current State.
 	return fmt.Sprintf(`### AGENT WORK LOOP (Turn %d/%d)
 GOAL: %s
 CONFIDENCE: %.2f
 
+### MILESTONES:
+%s
 ### RULES:
-- If a task is finished, include "[TASK_DONE]" or "GOAL COMPLETED".
-- Use tools available to perform CRUD or system tasks.
+- Work on the first incomplete milestone.
+- When a milestone is finished, include "[MILESTONE_DONE <number>]".
+- If the whole task is finished, include "[TASK_DONE]" or "GOAL COMPLETED".
+- To use a tool, output a `+"```json"+` block: {"tool": "<name>", "parameters": {...}}
 - If you need clarification from the client, ask directly.
 
+### TOOLS:
+%s
+
 ### WORK HISTORY:
 %s
 
 ### CURRENT ACTION:
-Analyze history and continue working towards the goal.`, 
-		state.Turns, state.MaxTurns, state.Goal.Description, state.Confidence, strings.Join(state.History, "\n---\n"))
+Analyze history and continue working towards the goal.`,
+		state.Turns, state.MaxTurns, state.Goal.Description, state.Confidence, milestones.String(), tools, strings.Join(state.History, "\n---\n"))
 }
 
-func (e *Engine) calculateConfidence(state LoopState, toolsCalled bool) float64 {
+func (e *Engine) calculateConfidence(state LoopState, toolsCalled, progressed bool) float64 {
 	score := state.Confidence
 
 	// Penalities
@@ -170,24 +437,85 @@ func (e *Engine) calculateConfidence(state LoopState, toolsCalled bool) float64
 		}
 	}
 
-	// Reward progress (not implemented deeply here, but placeholders)
+	// Reward progress
 	if toolsCalled {
 		score += 0.05
 	}
+	if progressed {
+		score += 0.1
+	}
 
-	if score > 1.0 { score = 1.0 }
-	if score < 0.0 { score = 0.0 }
+	if score > 1.0 {
+		score = 1.0
+	}
+	if score < 0.0 {
+		score = 0.0
+	}
 	return score
 }
 
-func (e *Engine) executeInferredTools(ctx context.Context, parsed prompt.ParsedResponse) (string, bool, error) {
-	// Simple heuristic: search for tool-like patterns in text or specifically formatted blocks.
-	// For now, we rely on standard tooling registry lookups if we find structured commands.
-	
-	// Implementation note: This is where we'd parse things like `USE sys_write_file {"path": "..."}`
-	// or rely on the bricks (AI) being trained to output valid tool calls.
-	
-	return "", false, nil
+// inferredCall is a tool call written as a ```json {"tool": ..., "parameters": ...} block.
+type inferredCall struct {
+	Tool string          `json:"tool"`
+	Args json.RawMessage `json:"parameters"`
 }
 
-import "strings"
+// inferToolCalls extracts the tool calls of a response, in order.
+func inferToolCalls(parsed prompt.ParsedResponse) []inferredCall {
+	var calls []inferredCall
+	for _, part := range parsed.Parts {
+		if part.Type != prompt.PartCode || (part.Lang != "json" && part.Lang != "") {
+			continue
+		}
+		var call inferredCall
+		if err := json.Unmarshal([]byte(strings.TrimSpace(part.Content)), &call); err != nil || call.Tool == "" {
+			continue
+		}
+		if len(call.Args) == 0 {
+			call.Args = json.RawMessage("{}")
+		}
+		calls = append(calls, call)
+	}
+	return calls
+}
+
+// executeInferredTools runs calls through Config.RunTool or the registry. It stops at the
+// first tool that needs user intervention and returns that error along with the results
+// gathered so far and the number of calls that ran.
+func (e *Engine) executeInferredTools(ctx context.Context, calls []inferredCall) (string, int, error) {
+	var results []string
+	if e.config.RunTool != nil {
+		for _, call := range calls {
+			out, err := e.config.RunTool(ctx, call.Tool, call.Args)
+			if err != nil {
+				return strings.Join(results, "\n"), len(results), err
+			}
+			results = append(results, fmt.Sprintf("[%s]: %s", call.Tool, out))
+		}
+		return strings.Join(results, "\n"), len(results), nil
+	}
+	if e.registry == nil {
+		return "", 0, nil
+	}
+
+	for _, call := range calls {
+		t, ok := e.registry.Get(call.Tool)
+		if !ok {
+			results = append(results, fmt.Sprintf("Error: tool '%s' not found", call.Tool))
+			continue
+		}
+		tooling.ReportStatus("🔧", "agent", fmt.Sprintf("Executing: %s", call.Tool))
+		res, err := t.Execute(ctx, call.Args)
+		if err != nil {
+			var intervention *tooling.InterventionError
+			if errors.As(err, &intervention) || strings.Contains(err.Error(), "intervention required") {
+				return strings.Join(results, "\n"), len(results), err
+			}
+			results = append(results, fmt.Sprintf("Error executing %s: %v", call.Tool, err))
+			continue
+		}
+		results = append(results, fmt.Sprintf("[%s]: %s", call.Tool, res.Content))
+	}
+
+	return strings.Join(results, "\n"), len(results), nil
+}
@@ -0,0 +1,259 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// scriptedModel replies with its responses in order and fails once they run out.
+type scriptedModel struct {
+	replies []string
+	prompts []string
+}
+
+func (m *scriptedModel) Generate(ctx context.Context, prompt string) (string, error) {
+	m.prompts = append(m.prompts, prompt)
+	if len(m.replies) == 0 {
+		return "", errors.New("model unavailable")
+	}
+	reply := m.replies[0]
+	m.replies = m.replies[1:]
+	return reply, nil
+}
+
+// memStore keeps states as JSON, like the SQLite store does.
+type memStore map[string][]byte
+
+func (s memStore) SaveState(id string, state interface{}) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	s[id] = data
+	return nil
+}
+
+func (s memStore) LoadState(id string, target interface{}) error {
+	data, ok := s[id]
+	if !ok {
+		return fmt.Errorf("state %s not found", id)
+	}
+	return json.Unmarshal(data, target)
+}
+
+func (s memStore) ListStates(prefix string) ([]string, error) {
+	var ids []string
+	for id := range s {
+		if strings.HasPrefix(id, prefix) {
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+func TestPlanMilestones(t *testing.T) {
+	tests := []struct {
+		name  string
+		reply string
+		want  []string
+	}{
+		{"json array", `Here is the plan: ["write the parser", "add tests"]`, []string{"write the parser", "add tests"}},
+		{"numbered list", "1. write the parser\n2) add tests\nthat's it", []string{"write the parser", "add tests"}},
+		{"bullets", "- write the parser\n* add tests", []string{"write the parser", "add tests"}},
+		{"blank entries dropped", `["write the parser", "  ", "add tests"]`, []string{"write the parser", "add tests"}},
+		{"unparseable", "I will just do it.", []string{"build a parser"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := NewEngine(&scriptedModel{replies: []string{tt.reply}}, nil, nil, nil, Config{})
+			got, err := e.planMilestones(context.Background(), "build a parser")
+			if err != nil {
+				t.Fatal(err)
+			}
+			var descs []string
+			for _, m := range got {
+				if m.Completed {
+					t.Errorf("%q should start incomplete", m.Description)
+				}
+				descs = append(descs, m.Description)
+			}
+			if strings.Join(descs, "|") != strings.Join(tt.want, "|") {
+				t.Errorf("got %q, want %q", descs, tt.want)
+			}
+		})
+	}
+
+	e := NewEngine(&scriptedModel{}, nil, nil, nil, Config{})
+	if _, err := e.planMilestones(context.Background(), "build a parser"); err == nil {
+		t.Error("expected a model error to be returned")
+	}
+}
+
+func TestMarkMilestones(t *testing.T) {
+	tests := []struct {
+		name       string
+		resp       string
+		done       []bool
+		progressed bool
+	}{
+		{"none", "still working", []bool{false, true, false}, false},
+		{"one", "done [MILESTONE_DONE 1]", []bool{true, true, false}, true},
+		{"case and spacing", "[milestone_done   3]", []bool{false, true, true}, true},
+		{"several", "[MILESTONE_DONE 1] [MILESTONE_DONE 3]", []bool{true, true, true}, true},
+		{"already completed", "[MILESTONE_DONE 2]", []bool{false, true, false}, false},
+		{"out of range", "[MILESTONE_DONE 0] [MILESTONE_DONE 4]", []bool{false, true, false}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			goal := Goal{Milestones: []Milestone{{Description: "a"}, {Description: "b", Completed: true}, {Description: "c"}}}
+			if got := markMilestones(&goal, tt.resp); got != tt.progressed {
+				t.Errorf("progressed = %v, want %v", got, tt.progressed)
+			}
+			for i, m := range goal.Milestones {
+				if m.Completed != tt.done[i] {
+					t.Errorf("milestone %d completed = %v, want %v", i+1, m.Completed, tt.done[i])
+				}
+			}
+		})
+	}
+}
+
+func TestEngine_InterruptSavesState(t *testing.T) {
+	store := memStore{}
+	m := &scriptedModel{replies: []string{`["read", "write"]`, "[MILESTONE_DONE 1]"}}
+	e := NewEngine(m, nil, nil, store, Config{})
+
+	_, err := e.Run(context.Background(), "refactor", nil)
+	var interrupted *InterruptedError
+	if !errors.As(err, &interrupted) {
+		t.Fatalf("expected an InterruptedError, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "model unavailable") {
+		t.Errorf("the cause should be kept: %v", err)
+	}
+
+	state, err := e.Load(interrupted.GoalID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state.Goal.Status != StatusInterrupted || state.LastError == "" {
+		t.Errorf("expected an interrupted state with its error, got %+v", state.Goal)
+	}
+	if state.Turns != 1 {
+		t.Errorf("the failed turn must not be counted, got %d turns", state.Turns)
+	}
+	if done, total := state.Goal.Progress(); done != 1 || total != 2 {
+		t.Errorf("expected 1/2 milestones, got %d/%d", done, total)
+	}
+	if state.UpdatedAt.IsZero() {
+		t.Error("save should stamp UpdatedAt")
+	}
+
+	goals, err := e.List()
+	if err != nil || len(goals) != 1 || goals[0].Goal.ID != interrupted.GoalID {
+		t.Errorf("expected the goal to be listed, got %+v (%v)", goals, err)
+	}
+}
+
+func TestEngine_Resume(t *testing.T) {
+	store := memStore{}
+	m := &scriptedModel{replies: []string{`["read", "write"]`, "[MILESTONE_DONE 1]"}}
+	e := NewEngine(m, nil, nil, store, Config{MaxTurns: 1})
+
+	_, err := e.Run(context.Background(), "refactor", nil)
+	var interrupted *InterruptedError
+	if !errors.As(err, &interrupted) {
+		t.Fatalf("expected the goal to run out of turns, got %v", err)
+	}
+	state, _ := e.Load(interrupted.GoalID)
+	if state.Goal.Status != StatusFailed || state.Turns != 1 {
+		t.Fatalf("expected a failed goal after one turn, got %+v", state)
+	}
+
+	m.replies = []string{"[MILESTONE_DONE 2] [TASK_DONE]"}
+	resp, err := e.Resume(context.Background(), interrupted.GoalID, nil)
+	if err != nil {
+		t.Fatalf("Resume failed: %v", err)
+	}
+	if !strings.Contains(resp, "[TASK_DONE]") {
+		t.Errorf("unexpected response: %q", resp)
+	}
+	last := m.prompts[len(m.prompts)-1]
+	if !strings.Contains(last, "RESUMED") || !strings.Contains(last, "1. [x] read") {
+		t.Errorf("the resumed prompt should carry the history and progress:\n%s", last)
+	}
+
+	state, _ = e.Load(interrupted.GoalID)
+	if state.Goal.Status != StatusCompleted || state.Turns != 2 || state.MaxTurns != 2 {
+		t.Errorf("expected a completed goal with a fresh turn allowance, got %+v", state)
+	}
+	if _, err := e.Resume(context.Background(), interrupted.GoalID, nil); err == nil {
+		t.Error("resuming a completed goal should fail")
+	}
+	if _, err := e.Resume(context.Background(), "goal-missing", nil); err == nil {
+		t.Error("resuming an unknown goal should fail")
+	}
+}
+
+func TestEngine_Budgets(t *testing.T) {
+	toolCall := "```json\n{\"tool\": \"noop\", \"parameters\": {}}\n```"
+	noop := func(ctx context.Context, tool string, args json.RawMessage) (string, error) { return "ok", nil }
+	tests := []struct {
+		name    string
+		cfg     Config
+		replies []string
+		want    error
+		status  string
+		turns   int
+	}{
+		{"max turns", Config{MaxTurns: 2}, []string{`["a"]`, "working", "still working"}, ErrMaxTurns, StatusFailed, 2},
+		{"max tool calls", Config{MaxTurns: 5, MaxToolCalls: 2, RunTool: noop}, []string{`["a"]`, toolCall, toolCall + "\n" + toolCall}, ErrMaxToolCalls, StatusInterrupted, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := memStore{}
+			e := NewEngine(&scriptedModel{replies: tt.replies}, nil, nil, store, tt.cfg)
+			_, err := e.Run(context.Background(), "never finish", nil)
+			var interrupted *InterruptedError
+			if !errors.As(err, &interrupted) || !errors.Is(err, tt.want) {
+				t.Fatalf("expected an interrupted goal wrapping %v, got %v", tt.want, err)
+			}
+			state, _ := e.Load(interrupted.GoalID)
+			if state.Goal.Status != tt.status || state.Turns != tt.turns {
+				t.Errorf("got status %s after %d turns, want %s after %d", state.Goal.Status, state.Turns, tt.status, tt.turns)
+			}
+		})
+	}
+}
+
+func TestEngine_CountsOnlyCallsThatRan(t *testing.T) {
+	toolCall := func(name string) string {
+		return "```json\n{\"tool\": \"" + name + "\", \"parameters\": {}}\n```\n"
+	}
+	var ran []string
+	store := memStore{}
+	e := NewEngine(&scriptedModel{replies: []string{`["a"]`, toolCall("read") + toolCall("write") + toolCall("read")}}, nil, nil, store, Config{
+		MaxTurns: 5,
+		RunTool: func(ctx context.Context, tool string, args json.RawMessage) (string, error) {
+			if tool == "write" {
+				return "", errors.New("intervention required: allow write?")
+			}
+			ran = append(ran, tool)
+			return "ok", nil
+		},
+	})
+
+	_, err := e.Run(context.Background(), "edit things", nil)
+	var interrupted *InterruptedError
+	if !errors.As(err, &interrupted) {
+		t.Fatalf("expected the intervention to interrupt the goal, got %v", err)
+	}
+	state, _ := e.Load(interrupted.GoalID)
+	if len(ran) != 1 || state.ToolCalls != 1 {
+		t.Errorf("calls after the intervention never ran and must not count, got %d counted, ran %v", state.ToolCalls, ran)
+	}
+}
@@ -2,12 +2,17 @@ package brain
 
 import (
 	"context"
+	"crypto/rand"
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
+	"sort"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/cenkalti/backoff/v4"
 	"github.com/nathfavour/vibeauracle/auth"
@@ -26,6 +31,8 @@ import (
 type Request struct {
 	ID      string
 	Content string
+	// Limits override the configured agent limits for this request; zero fields inherit.
+	Limits sys.AgentLimits
 }
 
 // Response represents the brain's output
@@ -48,7 +55,10 @@ type Brain struct {
 	prompts  *prompt.System
 	tools    *tooling.Registry
 	security *tooling.SecurityGuard
+	enclave  *tooling.Enclave
+	journal  *tooling.ChangeJournal
 	sessions map[string]*tooling.Session
+	catalog  *model.Catalog
 
 	// Copilot SDK integration
 	copilotProvider *copilot.Provider
@@ -60,43 +70,13 @@ type Brain struct {
 	// Callbacks
 	OnStreamDelta func(delta string)
 	OnStreamDone  func(full string)
-}
-
-// LoopDetector tracks agent actions to detect infinite loops
-type LoopDetector struct {
-	lastActions []string
-	maxHistory  int
-}
-
-func NewLoopDetector(maxHistory int) *LoopDetector {
-	return &LoopDetector{
-		lastActions: make([]string, 0, maxHistory),
-		maxHistory:  maxHistory,
-	}
-}
-
-func (ld *LoopDetector) AddAction(action string) bool {
-	// Normalize action string (trim whitespace, etc)
-	action = strings.TrimSpace(action)
-
-	// Check for repetition
-	repeatCount := 0
-	for _, a := range ld.lastActions {
-		if a == action {
-			repeatCount++
-		}
-	}
-
-	// If we see the exact same response + tool result sequence 3 times, it's a loop
-	if repeatCount >= 3 {
-		return true
-	}
-
-	ld.lastActions = append(ld.lastActions, action)
-	if len(ld.lastActions) > ld.maxHistory {
-		ld.lastActions = ld.lastActions[1:]
-	}
-	return false
+	// OnToolCall is called with the record of every tool call once it has run.
+	OnToolCall func(call tooling.ToolCall)
+	// OnIntervention may answer an intervention with one of its choices, so headless
+	// runs can continue unattended. Returning "" bubbles the intervention up as usual.
+	OnIntervention func(tool string, intervention *tooling.InterventionError) string
+	// interventionMu serializes OnIntervention across the workers of a parallel batch.
+	interventionMu sync.Mutex
 }
 
 func New() *Brain {
@@ -115,14 +95,27 @@ func New() *Brain {
 		memory:   vcontext.NewMemory(),
 		security: guard,
 		sessions: make(map[string]*tooling.Session),
-		detector: NewLoopDetector(10),
+		catalog:  model.NewCatalog(),
+		detector: NewLoopDetector(10, cfg.Agent.LoopSimilarity),
+		journal:  tooling.NewChangeJournal(cfg.DataDir),
+	}
+	b.initEnclave()
+	if cm != nil {
+		if err := b.catalog.LoadFile(cm.GetDataPath(capabilitiesFile)); err != nil {
+			tooling.ReportStatus("⚠️", "models", fmt.Sprintf("Ignoring %s: %v", capabilitiesFile, err))
+		}
 	}
 
 	// Prompt system is modular and configurable.
 	b.prompts = prompt.New(cfg, b.memory, &prompt.NoopRecommender{}, b.model)
 
-	b.fs = sys.NewLocalFS("")
-	b.tools = tooling.Setup(b.fs, b.monitor, b.security)
+	// Agent file changes go through the journal so a turn can be undone, and are held
+	// for review as a diff first when agent.review_writes is on.
+	b.fs = tooling.NewReviewFS(tooling.NewJournalFS(sys.NewLocalFS(""), b.journal), func() bool {
+		return b.config.Agent.ReviewWrites
+	})
+	b.security.SetWorkspace(b.workspaceRoots)
+	b.tools = tooling.Setup(b.fs, b.monitor, b.security, b.sandboxPolicy)
 	vibe.RegisterInbuiltVibes(context.Background(), b.tools)
 
 	// Seamless GitHub Onboarding & Auto-Switch:
@@ -166,9 +159,6 @@ func (b *Brain) registerToolsWithCopilot() {
 	// Get core tools from the registry
 	for _, t := range b.tools.List() {
 		tool := t
-		if false {
-			continue
-		}
 		meta := t.Metadata()
 		bridge.AddTool(copilot.VibeToolDefinition{
 			Name:        meta.Name,
@@ -187,62 +177,106 @@ func (b *Brain) registerToolsWithCopilot() {
 	b.copilotProvider.RegisterTools(bridge)
 }
 
-func (b *Brain) initProvider() {
-	configMap := map[string]string{
-		"model": b.config.Model.Name,
+// initEnclave connects the approval enclave and the declarative policy rules of the
+// user and the current project to the security guard.
+func (b *Brain) initEnclave() {
+	enclave, err := tooling.NewEnclave(b.config.DataDir)
+	if err != nil {
+		tooling.ReportStatus("⚠️", "security", fmt.Sprintf("Approval enclave unavailable: %v", err))
+		return
 	}
+	cwd, _ := os.Getwd()
+	policy, err := tooling.LoadPolicy(b.config.DataDir, cwd)
+	if err != nil {
+		tooling.ReportStatus("⚠️", "security", fmt.Sprintf("Ignoring policy rules: %v", err))
+		doctor.Send("brain", doctor.SignalWarning, "Invalid policy file", map[string]any{"error": err.Error()})
+	}
+	enclave.SetPolicy(policy)
 
-	// Only include endpoint/base_url if it's not the default Ollama one when using copilot-sdk,
-	// or if it's a non-SDK provider where we always need the endpoint (like Ollama/OpenAI).
-	isSDK := b.config.Model.Provider == "copilot-sdk"
-	isDefaultOllama := b.config.Model.Endpoint == "http://localhost:11434"
+	b.enclave = enclave
+	b.security.SetPolicy(enclave.Policy)
+	b.security.SetInterceptor(enclave.Interceptor)
+	b.security.SetResultHook(enclave.Complete)
+}
 
-	if !isSDK || !isDefaultOllama {
-		configMap["endpoint"] = b.config.Model.Endpoint
-		configMap["base_url"] = b.config.Model.Endpoint
+// Approvals returns the persisted approvals of the enclave, or nil when it is unavailable.
+func (b *Brain) Approvals() *tooling.ApprovalStore {
+	if b.enclave == nil {
+		return nil
 	}
+	return b.enclave.Approvals()
+}
 
-	// Fetch credentials from vault
-	if b.vault != nil {
-		if token, err := b.vault.Get("github_models_pat"); err == nil {
-			configMap["token"] = token
-		}
-		if key, err := b.vault.Get("openai_api_key"); err == nil && key != "" {
-			configMap["api_key"] = key
-			configMap["provider_type"] = "openai"
-		} else if key, err := b.vault.Get("anthropic_api_key"); err == nil && key != "" {
-			configMap["api_key"] = key
-			configMap["provider_type"] = "anthropic"
-		}
+// workspaceRoots are the directories file tools may use without asking: the working
+// directory and agent.workspace_roots.
+func (b *Brain) workspaceRoots() []string {
+	cwd, _ := os.Getwd()
+	return append([]string{cwd}, b.config.Agent.WorkspaceRoots...)
+}
+
+// sandboxPolicy is how sys_shell_exec confines commands, from the sandbox section of
+// the config at the time of the call. It is nil when the sandbox is disabled.
+func (b *Brain) sandboxPolicy() *tooling.SandboxPolicy {
+	s := b.config.Sandbox
+	if !s.Enabled {
+		return nil
 	}
+	root, _ := os.Getwd()
+	p := tooling.DefaultSandboxPolicy(root)
+	p.ReadOnly = s.ReadOnly
+	p.Writable = s.Writable
+	p.Network = s.Network
+	p.MaxMemory = int64(s.MaxMemoryMB) << 20
+	p.MaxCPUTime = s.MaxCPUTime
+	p.MaxProcs = s.MaxProcs
+	p.MaxOutput = s.MaxOutputBytes
+	p.Env = append(p.Env[:len(p.Env):len(p.Env)], s.Env...)
+	return &p
+}
 
-	// Auto-login fallback: Use gh CLI token if still empty for GitHub-based providers
-	if configMap["token"] == "" && (b.config.Model.Provider == "github-models" || b.config.Model.Provider == "github-copilot") {
-		if token, _ := auth.GetGithubCLIToken(); token != "" {
-			configMap["token"] = token
-		}
+// sandboxGuideline tells the model how its shell commands are confined under p, so it
+// neither expects the network where there is none nor assumes its absence. It is empty
+// when the sandbox is disabled.
+func sandboxGuideline(p *tooling.SandboxPolicy) string {
+	if p == nil {
+		return ""
+	}
+	const tail = "; if one fails because of that, say so instead of working around it"
+	if p.ExpectedMode() == tooling.SandboxIsolated && !p.Network {
+		return "Shell commands run in a sandbox without network access or secrets in the environment" + tail
 	}
+	return "Shell commands run in a sandbox without secrets in the environment" + tail
+}
 
+func (b *Brain) initProvider() {
 	// Initialize the provider
-	p, err := model.GetProvider(b.config.Model.Provider, configMap)
+	p, err := model.GetProvider(b.config.Model.Provider, b.providerConfig(b.config.Model.Provider, b.config.Model.Name, b.config.Model.Endpoint))
 	if err != nil {
-		fmt.Printf("Error initializing provider %s: %v\n", b.config.Model.Provider, err)
+		fmt.Fprintf(os.Stderr, "Error initializing provider %s: %v\n", b.config.Model.Provider, err)
 		// Fallback if copilot-sdk fails
 		if b.config.Model.Provider == "copilot-sdk" {
 			tooling.ReportStatus("⚠️", "copilot", fmt.Sprintf("SDK unavailable: %v, falling back", err))
 			b.config.Model.Provider = "github-copilot"
-			p, _ = model.GetProvider("github-copilot", configMap)
+			p, _ = model.GetProvider("github-copilot", b.providerConfig("github-copilot", b.config.Model.Name, b.config.Model.Endpoint))
 		}
 	}
 
+	primary := p
+	if chain := b.fallbackChain(p); chain != nil {
+		p = chain
+	}
+	b.refreshCapabilities(primary)
+
 	b.model = model.New(p)
 	b.usingCopilotSDK = false
 	b.copilotProvider = nil
 
-	// Check if we are using the Copilot SDK provider to enable SDK-specific features
-	if sdkP, ok := p.(*model.CopilotSDKProvider); ok {
+	// Check if we are using the Copilot SDK provider to enable SDK-specific features.
+	// Behind a fallback chain the SDK is driven like any other provider, so agent
+	// mode stays off, but callbacks and tools are still wired for when it serves.
+	if sdkP, ok := primary.(*model.CopilotSDKProvider); ok {
 		b.copilotProvider = sdkP.GetSDKProvider()
-		b.usingCopilotSDK = true
+		b.usingCopilotSDK = p == primary
 		tooling.ReportStatus("🚀", "copilot", "Using native Copilot SDK")
 
 		// Set streaming callbacks
@@ -265,12 +299,108 @@ func (b *Brain) initProvider() {
 	}
 
 	// Update the prompt system's recommender and model to use the newly initialized model.
+	// These are background calls, so they go through the response cache when it is on.
 	if b.prompts != nil && b.model != nil {
-		b.prompts.SetRecommender(prompt.NewModelRecommender(b.model))
-		b.prompts.SetModel(b.model)
+		background := b.cachedModel(p)
+		b.prompts.SetRecommender(prompt.NewModelRecommender(background))
+		b.prompts.SetModel(background)
 	}
 }
 
+// providerConfig builds the factory config for a provider, pulling credentials from the vault.
+func (b *Brain) providerConfig(provider, name, endpoint string) map[string]string {
+	configMap := map[string]string{
+		"model": name,
+	}
+
+	// Only include endpoint/base_url if it's not the default Ollama one when using copilot-sdk,
+	// or if it's a non-SDK provider where we always need the endpoint (like Ollama/OpenAI).
+	isSDK := provider == "copilot-sdk"
+	isDefaultOllama := endpoint == "" || endpoint == "http://localhost:11434"
+
+	// Anthropic never talks to the default Ollama endpoint, so it only takes a custom one.
+	isAnthropic := provider == "anthropic"
+	if (!isSDK && !isAnthropic) || !isDefaultOllama {
+		configMap["endpoint"] = endpoint
+		configMap["base_url"] = endpoint
+	}
+
+	if provider == "replay" {
+		configMap["cassette"] = b.config.Model.Cassette
+		configMap["mode"] = b.config.Model.CassetteMode
+		configMap["match"] = b.config.Model.CassetteMatch
+		configMap["record_provider"] = b.config.Model.RecordProvider
+		provider = b.config.Model.RecordProvider // credentials are for the recorded provider
+		isAnthropic = provider == "anthropic"
+	}
+
+	// Fetch credentials from vault
+	if b.vault != nil {
+		if token, err := b.vault.Get("github_models_pat"); err == nil {
+			configMap["token"] = token
+		}
+		if isAnthropic {
+			if key, err := b.vault.Get("anthropic_api_key"); err == nil && key != "" {
+				configMap["api_key"] = key
+			}
+		} else if key, err := b.vault.Get("openai_api_key"); err == nil && key != "" {
+			configMap["api_key"] = key
+			configMap["provider_type"] = "openai"
+		} else if key, err := b.vault.Get("anthropic_api_key"); err == nil && key != "" {
+			configMap["api_key"] = key
+			configMap["provider_type"] = "anthropic"
+		}
+	}
+
+	// Auto-login fallback: Use gh CLI token if still empty for GitHub-based providers
+	if configMap["token"] == "" && (provider == "github-models" || provider == "github-copilot") {
+		if token, _ := auth.GetGithubCLIToken(); token != "" {
+			configMap["token"] = token
+		}
+	}
+
+	return configMap
+}
+
+// fallbackChain wraps the primary provider with the configured fallbacks.
+// It returns nil when no fallbacks are configured or none could be initialized.
+func (b *Brain) fallbackChain(primary model.Provider) *model.FallbackProvider {
+	var chain []model.Provider
+	var models []string
+	if primary != nil {
+		chain = append(chain, primary)
+		models = append(models, b.config.Model.Name)
+	}
+	for _, fb := range b.config.Model.Fallbacks {
+		p, err := model.GetProvider(fb.Provider, b.providerConfig(fb.Provider, fb.Name, fb.Endpoint))
+		if err != nil {
+			tooling.ReportStatus("⚠️", "fallback", fmt.Sprintf("Skipping %s: %v", fb.Provider, err))
+			continue
+		}
+		chain = append(chain, p)
+		models = append(models, fb.Name)
+	}
+	if len(chain) < 2 {
+		return nil
+	}
+
+	f, err := model.NewFallbackProvider(chain, b.config.Model.FallbackThreshold, b.config.Model.FallbackCooldown)
+	if err != nil {
+		return nil
+	}
+	f.SetModels(models)
+	f.OnSwitch = func(from, to string, reason error) {
+		if reason == nil {
+			tooling.ReportStatus("🔀", "fallback", fmt.Sprintf("%s recovered, switching back from %s", to, from))
+			doctor.Send("brain", doctor.SignalWarning, "Provider recovered", map[string]any{"from": from, "to": to})
+			return
+		}
+		tooling.ReportStatus("🔀", "fallback", fmt.Sprintf("%s failed, switching to %s", from, to))
+		doctor.Send("brain", doctor.SignalWarning, "Provider fallback", map[string]any{"from": from, "to": to, "error": reason.Error()})
+	}
+	return f
+}
+
 // Shutdown gracefully stops all resources including Copilot SDK.
 func (b *Brain) Shutdown() error {
 	if b.copilotProvider != nil {
@@ -290,7 +420,7 @@ func (b *Brain) DiscoverModels(ctx context.Context) ([]ModelDiscovery, error) {
 	var discoveries []ModelDiscovery
 
 	// List of potential providers to check
-	providersToCheck := []string{"ollama", "openai", "github-models", "github-copilot", "copilot-sdk"}
+	providersToCheck := []string{"ollama", "openai", "anthropic", "github-models", "github-copilot", "copilot-sdk"}
 
 	for _, pName := range providersToCheck {
 		configMap := map[string]string{
@@ -318,6 +448,15 @@ func (b *Brain) DiscoverModels(ctx context.Context) ([]ModelDiscovery, error) {
 				} else {
 					continue // No key, skip
 				}
+			case "anthropic":
+				if key, err := b.vault.Get("anthropic_api_key"); err == nil && key != "" {
+					configMap["api_key"] = key
+				} else {
+					continue // No key, skip
+				}
+				if b.config.Model.Endpoint == "http://localhost:11434" {
+					delete(configMap, "base_url") // Default Ollama endpoint is not an Anthropic gateway
+				}
 			case "ollama":
 				// Usually no auth needed for local ollama
 			}
@@ -364,16 +503,22 @@ func (b *Brain) SetModel(provider, name string) error {
 	return nil
 }
 
-// SetAgentMode switches between 'vibe', 'sdk', and 'custom' agentic runtimes
+// SetAgentMode switches between 'vibe', 'sdk', 'custom', 'goal' and 'orchestrate' agentic runtimes
 func (b *Brain) SetAgentMode(mode string) error {
-	if mode != "vibe" && mode != "sdk" && mode != "custom" {
-		return fmt.Errorf("invalid agent mode: %s (must be 'vibe', 'sdk', or 'custom')", mode)
+	if mode != "vibe" && mode != "sdk" && mode != "custom" && mode != "goal" && mode != "orchestrate" {
+		return fmt.Errorf("invalid agent mode: %s (must be 'vibe', 'sdk', 'custom', 'goal' or 'orchestrate')", mode)
 	}
 	b.config.Agent.Mode = mode
 	b.config.Agent.UserConfigured = true
 	return b.cm.Save(b.config)
 }
 
+// SetReviewWrites turns the review of file changes as diffs on or off.
+func (b *Brain) SetReviewWrites(on bool) error {
+	b.config.Agent.ReviewWrites = on
+	return b.cm.Save(b.config)
+}
+
 // RegisterCustomAgent adds or updates a user-defined agent
 func (b *Brain) RegisterCustomAgent(agent sys.CustomAgent) error {
 	for i, a := range b.config.Agent.CustomAgents {
@@ -413,54 +558,83 @@ func (b *Brain) Process(ctx context.Context, req Request) (Response, error) {
 		return Response{}, fmt.Errorf("no AI model configured. Run 'vibeaura auth' to set up a provider")
 	}
 
+	// Budget: the wall-clock deadline covers the whole run, prompt building included.
+	run, ctx, cancel := b.startBudget(ctx, req)
+	defer cancel()
+	limits := run.limits
+
 	// 1. Session & Thread Management
 	sessionID := b.GetSessionID()
-	session, ok := b.sessions[sessionID]
-	if !ok {
-		// Attempt to restore session object from memory
-		var storedSession tooling.Session
-		if err := b.RecallState(sessionID+"_obj", &storedSession); err == nil {
-			session = &storedSession
-		} else {
-			session = tooling.NewSession(sessionID)
-		}
-		b.sessions[sessionID] = session
+	session := b.loadSession(sessionID)
+	if req.ID == "" {
+		req.ID = newRequestID()
+	}
+	b.journal.Begin(sessionID, req.ID, req.Content)
+	if b.enclave != nil {
+		b.enclave.SetThread(sessionID, req.ID)
+	}
+
+	// MODE: GOAL AGENT
+	// The goal engine plans milestones and checkpoints its own state after every turn,
+	// so it does not use the chat conversation built below.
+	if b.config.Agent.Mode == "goal" {
+		tooling.ReportStatus("🎯", "agent-goal", "Executing via goal-driven Agent Engine...")
+		return b.runGoal(ctx, req, "", run)
 	}
 
+	// MODE: ORCHESTRATION
+	// An architect plans, role-restricted executors carry out the plan, and QA reviews it.
+	if b.config.Agent.Mode == "orchestrate" && b.model != nil {
+		tooling.ReportStatus("🏛️", "agent-orchestrate", "Executing via architect/executor/QA team...")
+		return b.orchestrate(ctx, req, session, run)
+	}
+	threadUsage := &tooling.UsageReport{}
+
 	// 2. Perceive: Receive request + SystemSnapshot
 	snapshot, _ := b.monitor.GetSnapshot()
 	tooling.ReportStatus("👁️", "perceive", fmt.Sprintf("CWD: %s", snapshot.WorkingDir))
 
 	// 3. Tool Awareness (Smart Handshake)
-	toolDefs := b.tools.GetPromptDefinitions(nil)
+	// Providers with native tool calling receive schemas directly instead of prompt text.
+	caps := b.Capabilities()
+	nativeTools := !b.usingCopilotSDK && b.model != nil && b.model.SupportsTools() && caps.Tools
+	toolDefs := ""
+	budget := caps.PromptBudget()
+	if nativeTools {
+		budget = promptBudget(caps, b.toolDefinitions(nil))
+	} else {
+		toolDefs = b.tools.GetPromptDefinitions(nil)
+	}
 	tooling.ReportStatus("🔧", "tools", fmt.Sprintf("Loaded %d tools", len(b.tools.List())))
 
 	// 4. Update Rolling Context Window
 	b.memory.AddToWindow(req.ID, req.Content, "user_prompt")
 	tooling.ReportStatus("🧠", "memory", "Analyzing conversation context...")
 
-	// Provide recent history to prompt builder
-	recentHistory := ""
+	// Provide recent history to prompt builder as alternating user/assistant turns
+	var recentHistory []prompt.Message
 	if len(session.Threads) > 0 {
-		var hb strings.Builder
-		hb.WriteString("\nRECENT CONVERSATION HISTORY:\n")
 		start := 0
 		if len(session.Threads) > 5 { // Last 5 turns
 			start = len(session.Threads) - 5
 		}
 		for _, t := range session.Threads[start:] {
-			hb.WriteString(fmt.Sprintf("User: %s\nAssistant: %s\n", t.Prompt, t.Response))
+			recentHistory = append(recentHistory,
+				prompt.Message{Role: prompt.RoleUser, Content: t.Prompt},
+				prompt.Message{Role: prompt.RoleAssistant, Content: t.Response},
+			)
 		}
-		recentHistory = hb.String()
 	}
 
-	// 5. Prompt System: classify + layer instructions + inject recall + build final prompt
-	augmentedPrompt := ""
+	// 5. Prompt System: classify + layer instructions + inject recall + build final conversation
+	var conversation []model.Message
 	var recs []prompt.Recommendation
 	var promptIntent prompt.Intent
 
 	if b.config.Prompt.Enabled && b.prompts != nil {
 		tooling.ReportStatus("📝", "prompt", "Selecting prompt strategy...")
+		b.prompts.SetBudget(budget, model.EstimateTokens)
+		b.prompts.SetSandbox(sandboxGuideline(b.sandboxPolicy()))
 
 		env, builtRecs, err := b.prompts.Build(ctx, req.Content, snapshot, toolDefs, recentHistory)
 		if err != nil {
@@ -471,7 +645,7 @@ func (b *Brain) Process(ctx context.Context, req Request) (Response, error) {
 			tooling.ReportStatus("⏭️", "skip", "Empty/invalid prompt ignored")
 			return Response{Content: "(ignored empty/invalid prompt)"}, nil
 		}
-		augmentedPrompt = env.Prompt
+		conversation = toModelMessages(env.Messages)
 		recs = builtRecs
 		promptIntent = env.Intent
 		tooling.ReportStatus("✅", "prompt", fmt.Sprintf("Strategy: %s", promptIntent))
@@ -481,30 +655,27 @@ func (b *Brain) Process(ctx context.Context, req Request) (Response, error) {
 		snippets, _ := b.memory.Recall(req.Content)
 		contextStr := strings.Join(snippets, "\n")
 		// ... (rest of fallback)
-		augmentedPrompt = fmt.Sprintf(`System Context:
-%s
-
-RECENT CONVERSATION HISTORY:
+		conversation = append(conversation, model.Message{Role: model.RoleSystem, Content: fmt.Sprintf(`System Context:
 %s
 
 System CWD: %s
 Available Tools (JSON-RPC 2.0 Style):
-%s
-
-User Request (Thread ID: %s):
-%s`, contextStr, recentHistory, snapshot.WorkingDir, toolDefs, req.ID, req.Content)
+%s`, contextStr, snapshot.WorkingDir, toolDefs)})
+		conversation = append(conversation, toModelMessages(recentHistory)...)
+		conversation = append(conversation, model.Message{Role: model.RoleUser, Content: fmt.Sprintf("User Request (Thread ID: %s):\n%s", req.ID, req.Content)})
 	}
 
 	// MODE: SDK AGENT
 	// If agent mode is 'sdk' and we are using the SDK provider, delegate the entire loop.
 	if b.config.Agent.Mode == "sdk" && b.usingCopilotSDK && b.copilotProvider != nil {
 		tooling.ReportStatus("🚀", "agent-sdk", "Delegating task to native Copilot SDK runtime...")
-		resp, err := b.copilotProvider.Generate(ctx, augmentedPrompt, true)
+		resp, err := b.copilotProvider.Generate(ctx, model.FlattenMessages(conversation), true)
 		if err != nil {
 			tooling.ReportStatus("❌", "error", fmt.Sprintf("SDK Agent error: %v", err))
 			return Response{}, fmt.Errorf("sdk agent execution: %w", err)
 		}
 		tooling.ReportStatus("✅", "done", "SDK Agent completed task")
+		b.recordUsage(session, threadUsage, "copilot-sdk", model.EstimateUsage(conversation, model.ChatResponse{Content: resp}))
 		_ = b.memory.Store(req.ID, resp)
 		_ = b.StoreState(sessionID+"_obj", session)
 		return Response{
@@ -516,22 +687,16 @@ User Request (Thread ID: %s):
 	}
 
 	// MODE: CUSTOM AGENT
+	var toolSubset []string
 	if b.config.Agent.Mode == "custom" {
-		var activeAgent *sys.CustomAgent
-		for _, a := range b.config.Agent.CustomAgents {
-			if a.Name == b.config.Agent.ActiveCustom {
-				activeAgent = &a
-				break
-			}
-		}
-
-		if activeAgent != nil {
+		if activeAgent := b.activeCustomAgent(); activeAgent != nil {
 			tooling.ReportStatus("👤", "agent-custom", fmt.Sprintf("Executing via Custom Agent: %s", activeAgent.Name))
 			// Inject custom prompt
-			augmentedPrompt = fmt.Sprintf("Custom Agent Instructions: %s\n\n%s", activeAgent.Prompt, augmentedPrompt)
+			conversation = prependSystem(conversation, fmt.Sprintf("Custom Agent Instructions: %s", activeAgent.Prompt))
 
 			// Restrict tools if specified
 			if len(activeAgent.Tools) > 0 {
+				toolSubset = activeAgent.Tools
 				toolDefs = b.tools.GetPromptDefinitions(activeAgent.Tools)
 			}
 		}
@@ -541,24 +706,55 @@ User Request (Thread ID: %s):
 	if b.config.Agent.Mode == "vibe" {
 		tooling.ReportStatus("🎨", "agent-vibe", "Executing via internal Vibe Agent...")
 	}
-	// EXECUTION LOOP (Agentic) - allow up to 10 turns for complex tasks
-	maxTurns := 10
-	history := augmentedPrompt
+	var nativeDefs []model.ToolDefinition
+	if nativeTools {
+		nativeDefs = b.toolDefinitions(toolSubset)
+		budget = promptBudget(caps, nativeDefs)
+		tooling.ReportStatus("🧩", "tools", fmt.Sprintf("Native tool calling enabled (%d schemas)", len(nativeDefs)))
+	}
+
+	// EXECUTION LOOP (Agentic) - bounded by the run's limits
+	maxTurns := limits.MaxTurns
 	var fullResponse strings.Builder
-	b.detector = NewLoopDetector(10) // Reset for each new process
+	var toolCalls []tooling.ToolCall
+	b.detector = NewLoopDetector(10, b.config.Agent.LoopSimilarity) // Reset for each new process
+	hinted := false
+
+	// exhausted stops the run on a spent budget, returning a result the caller can continue.
+	exhausted := func(limit string, turns int, note string) Response {
+		budget := run.exhausted(limit, turns, len(toolCalls), req.Content)
+		resp := b.halt(session, req, fullResponse.String()+"\n\n(Stopped: "+note+")", toolCalls, threadUsage, "budget: "+limit)
+		resp.Metadata["budget_exhausted"] = budget
+		return resp
+	}
+	deadlineHit := func() bool { return run.deadlineHit(ctx) }
 
 	for i := 0; i < maxTurns; i++ {
+		if deadlineHit() {
+			return exhausted("timeout", i, "Agent deadline reached"), nil
+		}
 		tooling.ReportStatus("🔄", "loop", fmt.Sprintf("Turn %d/%d: Thinking...", i+1, maxTurns))
 
 		// 1. Generation
 		var resp string
+		var calls []model.ToolCall
 		var generateErr error
 
+		// Stream deltas to the UI for providers that support it.
+		var streamed bool
+		var onDelta model.StreamFunc
+		if !b.usingCopilotSDK && b.OnStreamDelta != nil && b.model.SupportsStreaming() {
+			onDelta = func(delta string) {
+				streamed = true
+				b.OnStreamDelta(delta)
+			}
+		}
+
 		if b.usingCopilotSDK && b.copilotProvider != nil {
 			// Use Copilot SDK for generation
 			generateErr = backoff.Retry(func() error {
 				var err error
-				resp, err = b.copilotProvider.Generate(ctx, history, true)
+				resp, err = b.copilotProvider.Generate(ctx, model.FlattenMessages(conversation), true)
 				if err != nil {
 					if ctx.Err() != nil {
 						return backoff.Permanent(err)
@@ -566,36 +762,68 @@ User Request (Thread ID: %s):
 					tooling.ReportStatus("⏳", "retry", fmt.Sprintf("Retrying (SDK)... (%v)", err))
 					return err
 				}
+				// The SDK does not report token counts.
+				b.recordUsage(session, threadUsage, "copilot-sdk", model.EstimateUsage(conversation, model.ChatResponse{Content: resp}))
 				return nil
-			}, backoff.WithContext(backoff.NewExponentialBackOff(), ctx))
+			}, retryBackOff(ctx, limits.MaxRetryTime))
 		} else {
-			// Use standard model provider
+			// Keep accumulated tool output within the model's context window.
+			conversation = model.FitMessages(conversation, budget)
+
+			// Use the model provider; nativeDefs is empty for text-only providers
 			generateErr = backoff.Retry(func() error {
-				var err error
-				resp, err = b.model.Generate(ctx, history)
+				chatResp, err := b.model.Chat(ctx, conversation, model.ChatOptions{Tools: nativeDefs, OnDelta: onDelta})
 				if err != nil {
-					if ctx.Err() != nil {
+					// Retrying after partial output would duplicate text in the UI,
+					// and a replayed cassette will not grow a matching interaction.
+					if ctx.Err() != nil || streamed || errors.Is(err, model.ErrCassetteMismatch) {
 						return backoff.Permanent(err)
 					}
 					tooling.ReportStatus("⏳", "retry", fmt.Sprintf("Retrying thinking... (%v)", err))
 					return err
 				}
+				resp, calls = chatResp.Content, chatResp.ToolCalls
+				b.recordUsage(session, threadUsage, chatResp.Provider, chatResp.Usage)
 				return nil
-			}, backoff.WithContext(backoff.NewExponentialBackOff(), ctx))
+			}, retryBackOff(ctx, limits.MaxRetryTime))
 		}
 
+		if generateErr != nil && deadlineHit() {
+			return exhausted("timeout", i, "Agent deadline reached"), nil
+		}
 		if generateErr != nil {
 			tooling.ReportStatus("❌", "error", fmt.Sprintf("Model error: %v", generateErr))
 			doctor.Send("brain", "error", "Generation failed", map[string]any{"error": generateErr.Error(), "turn": i})
 			return Response{}, fmt.Errorf("generating response: %w", generateErr)
 		}
+		if streamed && b.OnStreamDone != nil {
+			b.OnStreamDone(resp)
+		}
+
+		// Text-only providers express tool calls as fenced JSON in the response.
+		var parseErrs []string
+		if !nativeTools {
+			calls, parseErrs = parseFencedToolCalls(resp)
+		}
 
-		// Loop Detection: If model response is identical and we already tried tools, it might be stuck.
-		if b.detector.AddAction(resp) {
-			tooling.ReportStatus("🛑", "loop-detected", "Agent stuck in a repetitive loop. Halting.")
-			doctor.Send("brain", "warning", "Loop detected", map[string]any{"response": resp})
-			finalContent := fullResponse.String() + "\n" + resp + "\n\n(Stopped: Loop detected)"
-			return Response{Content: finalContent}, nil
+		// Loop Detection: repeated or alternating tool calls, or the same response over again.
+		// The agent gets one corrective hint; the next loop halts it.
+		if looping, reason := b.detector.Observe(resp, calls); looping {
+			doctor.Send("brain", "warning", "Loop detected", map[string]any{"reason": reason, "hinted": hinted})
+			if hinted {
+				tooling.ReportStatus("🛑", "loop-detected", fmt.Sprintf("Agent stuck in a loop (%s). Halting.", reason))
+				finalContent := fullResponse.String() + "\n" + resp + "\n\n(Stopped: Loop detected)"
+				return b.halt(session, req, finalContent, toolCalls, threadUsage, "loop: "+reason), nil
+			}
+			hinted = true
+			tooling.ReportStatus("🔁", "loop-detected", fmt.Sprintf("Agent is repeating itself (%s). Asking it to change approach.", reason))
+			hint := fmt.Sprintf(loopHint, reason)
+			conversation = append(conversation, model.Message{Role: model.RoleAssistant, Content: resp, ToolCalls: calls})
+			for _, c := range calls {
+				conversation = append(conversation, model.Message{Role: model.RoleTool, ToolCallID: c.ID, Name: c.Name, Content: "Not executed: this call repeats earlier ones."})
+			}
+			conversation = append(conversation, model.Message{Role: model.RoleUser, Content: hint})
+			continue
 		}
 
 		// Accumulate response
@@ -615,29 +843,41 @@ User Request (Thread ID: %s):
 
 		tooling.ReportStatus("🔎", "parsing", "Analyzing response for tool calls...")
 
-		// 2. Parse & Execute Tools
-		executed, resultVal, interventionErr, execErr := b.executeToolCalls(ctx, resp)
+		// 2. Execute Tools
+		if limits.MaxToolCalls > 0 && len(toolCalls)+len(calls) > limits.MaxToolCalls {
+			return exhausted("max_tool_calls", i+1, "Tool call limit reached"), nil
+		}
+		outcomes, interventionErr := b.executeToolCalls(ctx, b.tools, calls)
+		for j := range outcomes {
+			outcomes[j].Content = truncateOutput(outcomes[j].Content, limits.MaxOutputBytes)
+		}
+		toolCalls = append(toolCalls, b.reportToolCalls(outcomes)...)
+		executed, resultVal, execErr := summarizeOutcomes(outcomes)
+		if len(parseErrs) > 0 {
+			// Malformed tool calls must be reported back instead of silently dropped.
+			executed = true
+			if execErr == nil {
+				execErr = fmt.Errorf("%s", strings.Join(parseErrs, "; "))
+			}
+			resultVal = strings.TrimSpace(resultVal + "\n" + strings.Join(parseErrs, "\n"))
+		}
 
-		// Bubble up intervention immediately so UI can handle it
+		// Bubble up intervention immediately so UI can handle it, keeping the tool calls
+		// that already ran on the thread.
 		if interventionErr != nil {
 			tooling.ReportStatus("⚠️", "intervention", "User approval required")
-			return Response{}, interventionErr
-		}
-
-		// Add tool results to loop detection too
-		if executed && b.detector.AddAction(resultVal) {
-			tooling.ReportStatus("🛑", "loop-detected", "Tool results are repetitive. Halting.")
-			finalContent := fullResponse.String() + "\n\n(Stopped: Loop detected in tool results)"
-			return Response{Content: finalContent}, nil
+			return b.halt(session, req, fullResponse.String(), toolCalls, threadUsage, "intervention"), interventionErr
 		}
 
 		if !executed {
 			tooling.ReportStatus("✅", "done", "Task complete")
 			finalContent := fullResponse.String()
 			session.AddThread(&tooling.Thread{
-				ID:       req.ID,
-				Prompt:   req.Content,
-				Response: finalContent,
+				ID:        req.ID,
+				Prompt:    req.Content,
+				Response:  finalContent,
+				ToolCalls: toolCalls,
+				Usage:     threadUsage,
 				Metadata: map[string]interface{}{
 					"prompt_intent":    promptIntent,
 					"recommendations":  recs,
@@ -650,43 +890,76 @@ User Request (Thread ID: %s):
 				Content: finalContent,
 				Metadata: map[string]interface{}{
 					"recommendations": recs,
+					"usage":           threadUsage.Total,
 				},
 			}, nil
 		}
 
-		// 3. Observation (feed back into history) - prompt to continue with remaining tasks
-		history += "\n" + resp
+		// 3. Observation (feed back into the conversation as assistant + tool messages)
+		conversation = append(conversation, model.Message{Role: model.RoleAssistant, Content: resp, ToolCalls: calls})
+		for _, o := range outcomes {
+			conversation = append(conversation, model.Message{
+				Role:       model.RoleTool,
+				ToolCallID: o.Call.ID,
+				Name:       o.Call.Name,
+				Content:    o.Content,
+			})
+		}
+		if len(parseErrs) > 0 {
+			conversation = append(conversation, model.Message{Role: model.RoleUser, Content: strings.Join(parseErrs, "\n")})
+		}
 
 		if execErr != nil {
 			tooling.ReportStatus("❌", "tool", fmt.Sprintf("Tool error: %v", execErr))
-			history += fmt.Sprintf("\n\nObservation: Tool execution failed: %v\n\nContinue executing the remaining steps. Output the next tool call.", execErr)
 		} else {
 			resultPreview := resultVal
 			if len(resultPreview) > 80 {
 				resultPreview = resultPreview[:80] + "..."
 			}
 			tooling.ReportStatus("✅", "tool", fmt.Sprintf("Result: %s", resultPreview))
-			history += fmt.Sprintf("\n\nObservation: Tool output:\n%s\n\nOriginal request: %s\n\nIf there are more steps to complete, output the next tool call now. Only provide a summary when ALL tasks are done.", resultVal, req.Content)
+		}
+		if !nativeTools {
+			// Text-only providers need an explicit nudge to keep emitting fenced tool calls.
+			conversation = append(conversation, model.Message{Role: model.RoleUser, Content: fmt.Sprintf("Original request: %s\n\nIf there are more steps to complete, output the next tool call now. Only provide a summary when ALL tasks are done.", req.Content)})
 		}
 
 		// 4. Record intermediate step
 		_ = b.memory.Store(req.ID+"_step_"+fmt.Sprint(i), resultVal)
 	}
 
-	tooling.ReportStatus("⚠️", "limit", "Agent loop limit reached")
-	finalContent := fullResponse.String() + "\n\n(Stopped: Agent loop limit reached)"
-	return Response{Content: finalContent}, nil
+	return exhausted("max_turns", maxTurns, "Agent loop limit reached"), nil
 }
 
-// executeToolCalls parses the response for JSON tool invocations and executes ALL of them.
-func (b *Brain) executeToolCalls(ctx context.Context, input string) (bool, string, error, error) {
-	var results []string
-	var lastErr error
-	var interventionErr error
-	executed := false
+// halt records a thread that stopped before the agent finished, along with the reason,
+// and returns its response.
+func (b *Brain) halt(session *tooling.Session, req Request, content string, toolCalls []tooling.ToolCall, usage *tooling.UsageReport, reason string) Response {
+	session.AddThread(&tooling.Thread{
+		ID:        req.ID,
+		Prompt:    req.Content,
+		Response:  content,
+		ToolCalls: toolCalls,
+		Usage:     usage,
+		Metadata:  map[string]interface{}{"halt_reason": reason},
+	})
+	_ = b.memory.Store(req.ID, content)
+	_ = b.StoreState(session.ID+"_obj", session)
+	return Response{
+		Content: content,
+		Metadata: map[string]interface{}{
+			"halt_reason": reason,
+			"usage":       usage.Total,
+		},
+	}
+}
+
+// parseFencedToolCalls extracts tool invocations written as ```json blocks with a "tool" key.
+// It is only used for providers that lack native tool calling. Blocks that look like tool
+// calls but fail to parse are reported back so the model can correct itself.
+func parseFencedToolCalls(input string) ([]model.ToolCall, []string) {
+	var calls []model.ToolCall
+	var errs []string
 	remaining := input
 
-	// Find and execute ALL tool calls in the response
 	for {
 		start := strings.Index(remaining, "```json")
 		if start == -1 {
@@ -698,6 +971,9 @@ func (b *Brain) executeToolCalls(ctx context.Context, input string) (bool, strin
 
 		end := strings.Index(blockContent, "```")
 		if end == -1 {
+			if strings.Contains(blockContent, `"tool"`) {
+				errs = append(errs, "Error: unterminated ```json tool call block")
+			}
 			break
 		}
 
@@ -710,47 +986,267 @@ func (b *Brain) executeToolCalls(ctx context.Context, input string) (bool, strin
 			Args json.RawMessage `json:"parameters"`
 		}
 		if err := json.Unmarshal([]byte(jsonStr), &call); err != nil {
-			continue // Not a valid tool call, skip
+			if strings.Contains(jsonStr, `"tool"`) {
+				errs = append(errs, fmt.Sprintf("Error: malformed tool call JSON: %v", err))
+			}
+			continue // Not a tool call, skip
 		}
 
 		if call.Tool == "" {
 			continue
 		}
+		if len(call.Args) == 0 {
+			call.Args = json.RawMessage("{}")
+		}
 
-		// Found a tool call!
-		executed = true
-		tooling.ReportStatus("🔧", "tool", fmt.Sprintf("Executing: %s", call.Tool))
+		calls = append(calls, model.ToolCall{
+			ID:        fmt.Sprintf("call_%d", len(calls)),
+			Name:      call.Tool,
+			Arguments: call.Args,
+		})
+	}
 
-		t, found := b.tools.Get(call.Tool)
-		if false {
-			lastErr = fmt.Errorf("tool '%s' not found", call.Tool)
-			doctor.Send("brain", "error", "Tool not found", map[string]any{"tool": call.Tool})
-			results = append(results, fmt.Sprintf("Error: tool '%s' not found", call.Tool))
-			continue
+	return calls, errs
+}
+
+// toolDefinitions converts registry metadata into native tool schemas.
+// If subset is empty, every registered tool is included.
+func (b *Brain) toolDefinitions(subset []string) []model.ToolDefinition {
+	var targets []tooling.Tool
+	if len(subset) == 0 {
+		targets = b.tools.List()
+	} else {
+		for _, name := range subset {
+			if t, ok := b.tools.Get(name); ok {
+				targets = append(targets, t)
+			}
 		}
+	}
 
-		res, err := t.Execute(ctx, call.Args)
-		if err != nil {
-			// Check for intervention error
-			if strings.Contains(err.Error(), "intervention required") {
-				interventionErr = err
-				doctor.Send("brain", "intervention", "Intervention required", map[string]any{"tool": call.Tool})
-				break // Stop processing, need user input
+	defs := make([]model.ToolDefinition, 0, len(targets))
+	for _, t := range targets {
+		meta := t.Metadata()
+		defs = append(defs, model.ToolDefinition{
+			Name:        meta.Name,
+			Description: meta.Description,
+			Parameters:  meta.Parameters,
+		})
+	}
+	sort.Slice(defs, func(i, j int) bool { return defs[i].Name < defs[j].Name })
+	return defs
+}
+
+// toolOutcome is the result of a single tool call, fed back to the model as a tool message.
+type toolOutcome struct {
+	Call    model.ToolCall
+	Content string
+	Err     error
+	At      time.Time
+}
+
+// maxParallelTools bounds how many read-only tool calls run at once.
+const maxParallelTools = 4
+
+// maxInterventionRounds bounds how many chained interventions OnIntervention answers
+// for a single tool call before it is reported as failed.
+const maxInterventionRounds = 8
+
+// executeToolCalls runs the calls requested in one model response. Consecutive
+// read-only calls run concurrently; any other call runs alone, after everything
+// before it and before everything after it. Outcomes are returned in request order.
+// When a tool requires user intervention the rest of its batch still completes,
+// later calls are skipped and the intervention error is returned.
+func (b *Brain) executeToolCalls(ctx context.Context, tools *tooling.Registry, calls []model.ToolCall) ([]toolOutcome, error) {
+	outcomes := make([]toolOutcome, 0, len(calls))
+
+	for start := 0; start < len(calls); {
+		end := start + 1
+		if isReadOnly(tools, calls[start]) {
+			for end < len(calls) && isReadOnly(tools, calls[end]) {
+				end++
+			}
+		}
+
+		batch, interventionErr := b.executeBatch(ctx, tools, calls[start:end])
+		outcomes = append(outcomes, batch...)
+		if interventionErr != nil {
+			return outcomes, interventionErr
+		}
+		start = end
+	}
+
+	return outcomes, nil
+}
+
+// isReadOnly reports whether call targets a tool that declares only PermRead.
+func isReadOnly(tools *tooling.Registry, call model.ToolCall) bool {
+	t, found := tools.Get(call.Name)
+	if !found {
+		return false
+	}
+	return t.Metadata().ReadOnly()
+}
+
+// executeBatch runs calls on a bounded pool and returns their outcomes in order,
+// leaving out calls that asked for intervention.
+func (b *Brain) executeBatch(ctx context.Context, tools *tooling.Registry, calls []model.ToolCall) ([]toolOutcome, error) {
+	results := make([]toolOutcome, len(calls))
+	errs := make([]error, len(calls))
+
+	if len(calls) == 1 {
+		results[0], errs[0] = b.executeToolCall(ctx, tools, calls[0])
+	} else {
+		tooling.ReportStatus("⚡", "tool", fmt.Sprintf("Running %d read-only tools in parallel", len(calls)))
+		sem := make(chan struct{}, maxParallelTools)
+		var wg sync.WaitGroup
+		for i, call := range calls {
+			wg.Add(1)
+			sem <- struct{}{}
+			go func() {
+				defer wg.Done()
+				defer func() { <-sem }()
+				results[i], errs[i] = b.executeToolCall(ctx, tools, call)
+			}()
+		}
+		wg.Wait()
+	}
+
+	var outcomes []toolOutcome
+	var interventionErr error
+	for i := range calls {
+		if errs[i] != nil {
+			if interventionErr == nil {
+				interventionErr = errs[i]
 			}
-			lastErr = err
-			doctor.Send("brain", "error", "Tool execution failed", map[string]any{"tool": call.Tool, "error": err.Error()})
-			results = append(results, fmt.Sprintf("Error executing %s: %v", call.Tool, err))
 			continue
 		}
+		outcomes = append(outcomes, results[i])
+	}
+	return outcomes, interventionErr
+}
 
-		results = append(results, fmt.Sprintf("[%s]: %s", call.Tool, res.Content))
+// executeToolCall runs a single call. The error is only set when the tool requires
+// user intervention; every other failure is reported in the outcome.
+func (b *Brain) executeToolCall(ctx context.Context, tools *tooling.Registry, call model.ToolCall) (toolOutcome, error) {
+	tooling.ReportStatus("🔧", "tool", fmt.Sprintf("Executing: %s", call.Name))
+	outcome := toolOutcome{Call: call, At: time.Now()}
+
+	t, found := tools.Get(call.Name)
+	if !found {
+		doctor.Send("brain", "error", "Tool not found", map[string]any{"tool": call.Name})
+		outcome.Content = fmt.Sprintf("Error: tool '%s' not found", call.Name)
+		outcome.Err = fmt.Errorf("tool '%s' not found", call.Name)
+		return outcome, nil
 	}
 
-	if interventionErr != nil {
-		return executed, strings.Join(results, "\n"), interventionErr, nil
+	if !json.Valid(call.Arguments) {
+		doctor.Send("brain", "error", "Invalid tool arguments", map[string]any{"tool": call.Name})
+		outcome.Content = fmt.Sprintf("Error: arguments for %s are not valid JSON: %s", call.Name, string(call.Arguments))
+		outcome.Err = fmt.Errorf("invalid arguments for tool '%s'", call.Name)
+		return outcome, nil
 	}
 
-	return executed, strings.Join(results, "\n"), nil, lastErr
+	res, err := t.Execute(ctx, call.Arguments)
+	// An answered intervention may raise the next one, e.g. a review of the changes
+	// after access outside the workspace was allowed.
+	var intervention *tooling.InterventionError
+	if errors.As(err, &intervention) && b.OnIntervention != nil {
+		b.interventionMu.Lock()
+		for round := 0; errors.As(err, &intervention) && intervention.Resume != nil; round++ {
+			if round == maxInterventionRounds {
+				err = fmt.Errorf("%s: still asking for approval after %d answers", call.Name, round)
+				break
+			}
+			choice := b.OnIntervention(call.Name, intervention)
+			if choice == "" {
+				break
+			}
+			tooling.ReportStatus("🤖", "intervention", fmt.Sprintf("%s → %s", intervention.Title, choice))
+			res, err = intervention.Resume(ctx, choice)
+			if err == nil && res == nil {
+				res = &tooling.ToolResult{Status: "success", Content: "Action completed"}
+			}
+		}
+		b.interventionMu.Unlock()
+	}
+	if err != nil {
+		// Check for intervention error
+		if strings.Contains(err.Error(), "intervention required") {
+			doctor.Send("brain", "intervention", "Intervention required", map[string]any{"tool": call.Name})
+			return outcome, err // Stop processing, need user input
+		}
+		doctor.Send("brain", "error", "Tool execution failed", map[string]any{"tool": call.Name, "error": err.Error()})
+		outcome.Content = fmt.Sprintf("Error executing %s: %v", call.Name, err)
+		outcome.Err = err
+		return outcome, nil
+	}
+
+	outcome.Content = res.Content
+	return outcome, nil
+}
+
+// reportToolCalls converts outcomes into thread records and passes each to OnToolCall.
+func (b *Brain) reportToolCalls(outcomes []toolOutcome) []tooling.ToolCall {
+	records := threadToolCalls(outcomes)
+	if b.OnToolCall != nil {
+		for _, r := range records {
+			b.OnToolCall(r)
+		}
+	}
+	return records
+}
+
+// threadToolCalls converts outcomes into the tool call records kept on a Thread.
+func threadToolCalls(outcomes []toolOutcome) []tooling.ToolCall {
+	records := make([]tooling.ToolCall, 0, len(outcomes))
+	for _, o := range outcomes {
+		record := tooling.ToolCall{
+			ToolName:  o.Call.Name,
+			Args:      o.Call.Arguments,
+			Result:    o.Content,
+			Timestamp: o.At,
+		}
+		if o.Err != nil {
+			record.Error = o.Err.Error()
+		}
+		records = append(records, record)
+	}
+	return records
+}
+
+// summarizeOutcomes flattens tool outcomes into the text used for loop detection,
+// status reporting and memory. It reports whether anything ran and the last error.
+func summarizeOutcomes(outcomes []toolOutcome) (bool, string, error) {
+	var results []string
+	var lastErr error
+	for _, o := range outcomes {
+		if o.Err != nil {
+			lastErr = o.Err
+			results = append(results, o.Content)
+			continue
+		}
+		results = append(results, fmt.Sprintf("[%s]: %s", o.Call.Name, o.Content))
+	}
+	return len(outcomes) > 0, strings.Join(results, "\n"), lastErr
+}
+
+// toModelMessages converts composed prompt messages into model chat messages.
+func toModelMessages(msgs []prompt.Message) []model.Message {
+	out := make([]model.Message, 0, len(msgs))
+	for _, m := range msgs {
+		out = append(out, model.Message{Role: model.Role(m.Role), Content: m.Content})
+	}
+	return out
+}
+
+// prependSystem adds instructions ahead of the conversation's system message,
+// inserting one if the conversation has none.
+func prependSystem(conversation []model.Message, instructions string) []model.Message {
+	if len(conversation) > 0 && conversation[0].Role == model.RoleSystem {
+		conversation[0].Content = instructions + "\n\n" + conversation[0].Content
+		return conversation
+	}
+	return append([]model.Message{{Role: model.RoleSystem, Content: instructions}}, conversation...)
 }
 
 // PullModel requests a model download (currently only supported by Ollama)
@@ -877,6 +1373,15 @@ func (b *Brain) GetIdentity() string {
 	return ""
 }
 
+// newRequestID identifies requests sent without an ID, so their changes can be undone.
+func newRequestID() string {
+	var b [8]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return fmt.Sprintf("%x", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b[:])
+}
+
 // GetSessionID returns a robust session ID based on the current directory.
 // This ensures chats are directory-specific.
 func (b *Brain) GetSessionID() string {
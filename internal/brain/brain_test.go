@@ -2,9 +2,17 @@ package brain
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/nathfavour/vibeauracle/model"
+	"github.com/nathfavour/vibeauracle/sys"
+	"github.com/nathfavour/vibeauracle/tooling"
 )
 
 type MockProvider struct{}
@@ -45,3 +53,458 @@ func TestBrain_Process(t *testing.T) {
 	}
 }
 
+// echoTool is a minimal tool used to observe executions from the agent loop.
+type echoTool struct {
+	mu    sync.Mutex
+	calls []string
+}
+
+func (e *echoTool) Metadata() tooling.ToolMetadata {
+	return tooling.ToolMetadata{
+		Name:        "test_echo",
+		Description: "Echo the given text.",
+		Permissions: []tooling.Permission{tooling.PermRead},
+		Parameters:  json.RawMessage(`{"type": "object", "properties": {"text": {"type": "string"}}, "required": ["text"]}`),
+	}
+}
+
+func (e *echoTool) Execute(ctx context.Context, args json.RawMessage) (*tooling.ToolResult, error) {
+	var input struct {
+		Text string `json:"text"`
+	}
+	if err := json.Unmarshal(args, &input); err != nil {
+		return nil, err
+	}
+	e.mu.Lock()
+	e.calls = append(e.calls, input.Text)
+	e.mu.Unlock()
+	return &tooling.ToolResult{Status: "success", Content: "echo: " + input.Text}, nil
+}
+
+// nativeToolProvider requests a tool call natively on its first turn, while also
+// printing an example fence that must NOT be executed.
+type nativeToolProvider struct {
+	MockProvider
+	conversations [][]model.Message
+	tools         []model.ToolDefinition
+}
+
+func (p *nativeToolProvider) Chat(ctx context.Context, messages []model.Message, opts model.ChatOptions) (model.ChatResponse, error) {
+	p.conversations = append(p.conversations, append([]model.Message(nil), messages...))
+	p.tools = opts.Tools
+	if len(p.conversations) == 1 {
+		example := "For example:\n```json\n{\"tool\": \"test_echo\", \"parameters\": {\"text\": \"example\"}}\n```"
+		return model.ChatResponse{
+			Content:   example,
+			ToolCalls: []model.ToolCall{{ID: "call_1", Name: "test_echo", Arguments: json.RawMessage(`{"text": "native"}`)}},
+		}, nil
+	}
+	return model.ChatResponse{Content: "All done."}, nil
+}
+
+func (p *nativeToolProvider) GenerateWithTools(ctx context.Context, prompt string, tools []model.ToolDefinition) (string, []model.ToolCall, error) {
+	resp, err := p.Chat(ctx, []model.Message{{Role: model.RoleUser, Content: prompt}}, model.ChatOptions{Tools: tools})
+	return resp.Content, resp.ToolCalls, err
+}
+
+// fenceProvider only speaks text, so tool calls travel as fenced JSON.
+type fenceProvider struct {
+	MockProvider
+	turns int
+}
+
+func (p *fenceProvider) Generate(ctx context.Context, prompt string) (string, error) {
+	p.turns++
+	if p.turns == 1 {
+		return "```json\n{\"tool\": \"test_echo\", \"parameters\": {\"text\": \"fenced\"}}\n```", nil
+	}
+	return "All done.", nil
+}
+
+func newTestBrain(p model.Provider) (*Brain, *echoTool) {
+	b := New()
+	b.config.Agent.Mode = "vibe"
+	b.usingCopilotSDK = false
+	b.copilotProvider = nil
+	b.model = model.New(p)
+	b.prompts.SetModel(b.model)
+	// The scripted providers call tools natively, whatever the configured model is.
+	caps := b.Capabilities()
+	caps.Tools = true
+	b.catalog.Set(b.config.Model.Provider, b.config.Model.Name, caps)
+
+	echo := &echoTool{}
+	b.tools.Register(echo)
+	return b, echo
+}
+
+func TestBrain_Process_NativeToolCalls(t *testing.T) {
+	p := &nativeToolProvider{}
+	b, echo := newTestBrain(p)
+
+	resp, err := b.Process(context.Background(), Request{ID: "native-1", Content: "please echo native"})
+	if err != nil {
+		t.Fatalf("Process failed: %v", err)
+	}
+
+	if len(echo.calls) != 1 || echo.calls[0] != "native" {
+		t.Fatalf("expected only the native call to run, got %v", echo.calls)
+	}
+	if !strings.Contains(resp.Content, "All done.") {
+		t.Errorf("unexpected final content: %q", resp.Content)
+	}
+	if len(p.conversations) != 2 {
+		t.Fatalf("expected two model turns, got %d", len(p.conversations))
+	}
+	first, second := p.conversations[0], p.conversations[1]
+	if first[0].Role != model.RoleSystem || first[len(first)-1].Role != model.RoleUser {
+		t.Errorf("expected system ... user conversation, got %+v", first)
+	}
+	tail := second[len(first):]
+	if len(tail) != 2 || tail[0].Role != model.RoleAssistant || len(tail[0].ToolCalls) != 1 {
+		t.Fatalf("expected assistant tool call to be appended, got %+v", tail)
+	}
+	if tail[1].Role != model.RoleTool || tail[1].ToolCallID != "call_1" || tail[1].Content != "echo: native" {
+		t.Errorf("tool result was not fed back as a tool message: %+v", tail[1])
+	}
+
+	found := false
+	for _, d := range p.tools {
+		if d.Name == "test_echo" && strings.Contains(string(d.Parameters), `"text"`) {
+			found = true
+		}
+	}
+	if !found {
+		t.Error("tool schema was not passed to the provider")
+	}
+}
+
+func TestBrain_Process_FencedToolCallsFallback(t *testing.T) {
+	b, echo := newTestBrain(&fenceProvider{})
+
+	resp, err := b.Process(context.Background(), Request{ID: "fence-1", Content: "please echo fenced"})
+	if err != nil {
+		t.Fatalf("Process failed: %v", err)
+	}
+	if len(echo.calls) != 1 || echo.calls[0] != "fenced" {
+		t.Fatalf("expected fenced call to run, got %v", echo.calls)
+	}
+	if !strings.Contains(resp.Content, "All done.") {
+		t.Errorf("unexpected final content: %q", resp.Content)
+	}
+}
+
+func TestParseFencedToolCalls_ReportsMalformed(t *testing.T) {
+	calls, errs := parseFencedToolCalls("```json\n{\"tool\": \"test_echo\", \"parameters\": {\"text\": }\n```")
+	if len(calls) != 0 {
+		t.Errorf("expected no calls, got %v", calls)
+	}
+	if len(errs) != 1 {
+		t.Fatalf("expected one parse error, got %v", errs)
+	}
+
+	calls, errs = parseFencedToolCalls("```json\n{\"name\": \"not a tool\"}\n```")
+	if len(calls) != 0 || len(errs) != 0 {
+		t.Errorf("plain JSON examples must be ignored, got calls=%v errs=%v", calls, errs)
+	}
+}
+
+// streamingProvider emits its answer in chunks through the streaming interfaces.
+type streamingProvider struct {
+	MockProvider
+}
+
+func (p *streamingProvider) GenerateStream(ctx context.Context, prompt string, onDelta model.StreamFunc) (string, error) {
+	for _, d := range []string{"Hello", ", ", "stream"} {
+		onDelta(d)
+	}
+	return "Hello, stream", nil
+}
+
+func TestBrain_Process_StreamsDeltas(t *testing.T) {
+	b, _ := newTestBrain(&streamingProvider{})
+
+	var deltas []string
+	var done []string
+	b.OnStreamDelta = func(d string) { deltas = append(deltas, d) }
+	b.OnStreamDone = func(full string) { done = append(done, full) }
+
+	resp, err := b.Process(context.Background(), Request{ID: "stream-1", Content: "say hello"})
+	if err != nil {
+		t.Fatalf("Process failed: %v", err)
+	}
+	if strings.Join(deltas, "") != "Hello, stream" {
+		t.Errorf("unexpected deltas: %q", deltas)
+	}
+	if len(done) != 1 || done[0] != "Hello, stream" {
+		t.Errorf("expected one OnStreamDone with the full text, got %q", done)
+	}
+	if resp.Content != "Hello, stream" {
+		t.Errorf("unexpected final content: %q", resp.Content)
+	}
+}
+
+func TestBrain_FallbackChain(t *testing.T) {
+	b := New()
+	b.config.Model.Fallbacks = nil
+	if chain := b.fallbackChain(&MockProvider{}); chain != nil {
+		t.Fatal("expected no chain without configured fallbacks")
+	}
+
+	b.config.Model.Fallbacks = []sys.ModelFallback{
+		{Provider: "does-not-exist", Name: "x"},
+		{Provider: "ollama", Name: "llama3.1", Endpoint: "http://localhost:11434"},
+	}
+	chain := b.fallbackChain(&MockProvider{})
+	if chain == nil {
+		t.Fatal("expected a fallback chain")
+	}
+	providers := chain.Providers()
+	if len(providers) != 2 || providers[0].Name() != "mock" || providers[1].Name() != "ollama" {
+		t.Errorf("unexpected chain: %v", providers)
+	}
+}
+
+func TestBrain_Process_RecordsUsage(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	b := New()
+	b.config.Agent.Mode = "vibe"
+	b.config.Model.Provider = "mock"
+	b.config.Model.Name = "mock-model"
+	b.config.Pricing = []sys.ModelPrice{{Provider: "mock", Model: "*", Input: 1_000_000, Output: 1_000_000}}
+	b.usingCopilotSDK = false
+	b.model = model.New(&MockProvider{})
+	b.prompts.SetModel(b.model)
+
+	resp, err := b.Process(context.Background(), Request{ID: "usage-1", Content: "Hello"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	turn, ok := resp.Metadata["usage"].(tooling.Usage)
+	if !ok || turn.Requests != 1 || !turn.Estimated || turn.TotalTokens() == 0 {
+		t.Fatalf("unexpected turn usage: %+v", resp.Metadata["usage"])
+	}
+	if turn.Cost != float64(turn.TotalTokens()) {
+		t.Errorf("got cost %v for %d tokens at $1/token", turn.Cost, turn.TotalTokens())
+	}
+
+	session := b.SessionUsage()
+	if session.Total.TotalTokens() != turn.TotalTokens() || session.ByModel["mock/mock-model"].Requests != 1 {
+		t.Errorf("session totals were not aggregated: %+v", session)
+	}
+
+	days, err := b.UsageHistory(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(days) != 1 || days[0].Total().Total.Cost != turn.Cost {
+		t.Errorf("daily ledger was not persisted: %+v", days)
+	}
+}
+
+// barrierTool is read-only and blocks until `want` calls are in flight at once,
+// which only happens if the agent loop runs them concurrently.
+type barrierTool struct {
+	want    int
+	mu      sync.Mutex
+	arrived int
+	release chan struct{}
+}
+
+func (t *barrierTool) Metadata() tooling.ToolMetadata {
+	return tooling.ToolMetadata{
+		Name:        "test_barrier",
+		Description: "Wait for sibling calls.",
+		Permissions: []tooling.Permission{tooling.PermRead},
+		Parameters:  json.RawMessage(`{"type": "object", "properties": {"text": {"type": "string"}}}`),
+	}
+}
+
+func (t *barrierTool) Execute(ctx context.Context, args json.RawMessage) (*tooling.ToolResult, error) {
+	t.mu.Lock()
+	t.arrived++
+	if t.arrived == t.want {
+		close(t.release)
+	}
+	t.mu.Unlock()
+
+	select {
+	case <-t.release:
+		return &tooling.ToolResult{Status: "success", Content: "together: " + string(args)}, nil
+	case <-time.After(5 * time.Second):
+		return nil, fmt.Errorf("calls did not run concurrently")
+	}
+}
+
+// writeTool is a non-read tool that must act as an ordering barrier.
+type writeTool struct{ echoTool }
+
+func (w *writeTool) Metadata() tooling.ToolMetadata {
+	meta := w.echoTool.Metadata()
+	meta.Name = "test_write"
+	meta.Permissions = []tooling.Permission{tooling.PermWrite}
+	return meta
+}
+
+func TestBrain_Process_ParallelReadOnlyTools(t *testing.T) {
+	call := func(id, name, text string) model.ToolCall {
+		return model.ToolCall{ID: id, Name: name, Arguments: json.RawMessage(fmt.Sprintf(`{"text": %q}`, text))}
+	}
+	r := scriptedCassette(
+		model.CassetteResponse{ToolCalls: []model.ToolCall{
+			call("c1", "test_barrier", "a"),
+			call("c2", "test_barrier", "b"),
+			call("c3", "test_write", "c"),
+			call("c4", "test_echo", "d"),
+		}},
+		model.CassetteResponse{Content: "finished"},
+	)
+	b, echo := replayBrain(t, r)
+	barrier := &barrierTool{want: 2, release: make(chan struct{})}
+	write := &writeTool{}
+	b.tools.Register(barrier)
+	b.tools.Register(write)
+
+	resp, err := b.Process(context.Background(), Request{ID: "parallel-1", Content: "read two files then write"})
+	if err != nil {
+		t.Fatalf("Process failed: %v", err)
+	}
+	if resp.Content != "finished" {
+		t.Errorf("unexpected final content: %q", resp.Content)
+	}
+	if len(write.calls) != 1 || len(echo.calls) != 1 {
+		t.Errorf("expected the write and the trailing read to run once, got %v and %v", write.calls, echo.calls)
+	}
+
+	session := b.loadSession(b.GetSessionID())
+	thread := session.Threads[len(session.Threads)-1]
+	if len(thread.ToolCalls) != 4 {
+		t.Fatalf("expected four recorded tool calls, got %+v", thread.ToolCalls)
+	}
+	for i, name := range []string{"test_barrier", "test_barrier", "test_write", "test_echo"} {
+		tc := thread.ToolCalls[i]
+		if tc.ToolName != name || tc.Error != "" || tc.Timestamp.IsZero() {
+			t.Errorf("tool call %d: got %+v, want a successful %s", i, tc, name)
+		}
+	}
+	if thread.ToolCalls[0].Result != `together: {"text": "a"}` {
+		t.Errorf("results must keep request order, got %v", thread.ToolCalls[0].Result)
+	}
+}
+
+// askingTool is read-only and raises an intervention whose answer raises another one,
+// `rounds` times over (forever if rounds is negative).
+type askingTool struct {
+	rounds int
+}
+
+func (a *askingTool) Metadata() tooling.ToolMetadata {
+	return tooling.ToolMetadata{
+		Name:        "test_ask",
+		Description: "Ask before reading.",
+		Permissions: []tooling.Permission{tooling.PermRead},
+		Parameters:  json.RawMessage(`{"type": "object", "properties": {"text": {"type": "string"}}}`),
+	}
+}
+
+func (a *askingTool) Execute(ctx context.Context, args json.RawMessage) (*tooling.ToolResult, error) {
+	return a.ask(string(args), 0)
+}
+
+func (a *askingTool) ask(args string, round int) (*tooling.ToolResult, error) {
+	if a.rounds >= 0 && round == a.rounds {
+		return &tooling.ToolResult{Status: "success", Content: "asked: " + args}, nil
+	}
+	return nil, &tooling.InterventionError{
+		Title:   fmt.Sprintf("Allow round %d?", round),
+		Choices: []string{"yes"},
+		Resume: func(ctx context.Context, choice string) (*tooling.ToolResult, error) {
+			return a.ask(args, round+1)
+		},
+	}
+}
+
+func TestBrain_Process_SerializesInterventions(t *testing.T) {
+	call := func(id, text string) model.ToolCall {
+		return model.ToolCall{ID: id, Name: "test_ask", Arguments: json.RawMessage(fmt.Sprintf(`{"text": %q}`, text))}
+	}
+	r := scriptedCassette(
+		model.CassetteResponse{ToolCalls: []model.ToolCall{call("c1", "a"), call("c2", "b"), call("c3", "c")}},
+		model.CassetteResponse{Content: "finished"},
+	)
+	b, _ := replayBrain(t, r)
+	b.tools.Register(&askingTool{rounds: 2})
+
+	var inFlight, overlaps, asked int32
+	b.OnIntervention = func(tool string, iv *tooling.InterventionError) string {
+		if atomic.AddInt32(&inFlight, 1) > 1 {
+			atomic.AddInt32(&overlaps, 1)
+		}
+		time.Sleep(5 * time.Millisecond)
+		atomic.AddInt32(&inFlight, -1)
+		atomic.AddInt32(&asked, 1)
+		return iv.Choices[0]
+	}
+
+	resp, err := b.Process(context.Background(), Request{ID: "serial-1", Content: "read three files"})
+	if err != nil {
+		t.Fatalf("Process failed: %v", err)
+	}
+	if resp.Content != "finished" {
+		t.Errorf("unexpected final content: %q", resp.Content)
+	}
+	if overlaps != 0 {
+		t.Errorf("OnIntervention ran concurrently %d time(s)", overlaps)
+	}
+	if asked != 6 {
+		t.Errorf("expected two answers per call, got %d", asked)
+	}
+}
+
+func TestBrain_Process_CapsInterventionRounds(t *testing.T) {
+	r := scriptedCassette(
+		model.CassetteResponse{ToolCalls: []model.ToolCall{
+			{ID: "c1", Name: "test_ask", Arguments: json.RawMessage(`{"text": "a"}`)},
+		}},
+		model.CassetteResponse{Content: "gave up"},
+	)
+	b, _ := replayBrain(t, r)
+	b.tools.Register(&askingTool{rounds: -1})
+	asked := 0
+	b.OnIntervention = func(tool string, iv *tooling.InterventionError) string {
+		asked++
+		return iv.Choices[0]
+	}
+
+	resp, err := b.Process(context.Background(), Request{ID: "cap-1", Content: "read a file"})
+	if err != nil {
+		t.Fatalf("Process failed: %v", err)
+	}
+	if resp.Content != "gave up" {
+		t.Errorf("unexpected final content: %q", resp.Content)
+	}
+	if asked != maxInterventionRounds {
+		t.Errorf("expected %d answers, got %d", maxInterventionRounds, asked)
+	}
+	session := b.loadSession(b.GetSessionID())
+	thread := session.Threads[len(session.Threads)-1]
+	if len(thread.ToolCalls) != 1 || !strings.Contains(thread.ToolCalls[0].Error, "still asking for approval") {
+		t.Errorf("expected the call to fail once the cap is hit, got %+v", thread.ToolCalls)
+	}
+}
+
+func TestSandboxGuideline(t *testing.T) {
+	if got := sandboxGuideline(nil); got != "" {
+		t.Errorf("a disabled sandbox needs no guideline, got %q", got)
+	}
+
+	p := tooling.DefaultSandboxPolicy(t.TempDir())
+	isolated := p.ExpectedMode() == tooling.SandboxIsolated
+	if got := sandboxGuideline(&p); strings.Contains(got, "network") != isolated {
+		t.Errorf("only an isolated sandbox is without network access, got %q in %s mode", got, p.ExpectedMode())
+	}
+	p.Network = true
+	if got := sandboxGuideline(&p); got == "" || strings.Contains(got, "network") {
+		t.Errorf("a sandbox with network access must not deny it, got %q", got)
+	}
+}
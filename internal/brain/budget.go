@@ -0,0 +1,39 @@
+package brain
+
+import (
+	"context"
+	"time"
+
+	"github.com/nathfavour/vibeauracle/model"
+)
+
+// capabilitiesFile is the user's capabilities catalog, overriding the built-in table.
+const capabilitiesFile = "models.json"
+
+// Capabilities returns what the catalog knows about the configured model.
+func (b *Brain) Capabilities() model.Capabilities {
+	return b.catalog.Lookup(b.config.Model.Provider, b.config.Model.Name)
+}
+
+// refreshCapabilities asks the provider to describe the configured model.
+// It runs in the background so slow or offline providers never block startup.
+func (b *Brain) refreshCapabilities(p model.Provider) {
+	if p == nil {
+		return
+	}
+	name := b.config.Model.Name
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		_ = b.catalog.Refresh(ctx, p, name)
+	}()
+}
+
+// promptBudget is the input token budget of a model once tool schemas are sent.
+func promptBudget(caps model.Capabilities, tools []model.ToolDefinition) int {
+	budget := caps.PromptBudget()
+	for _, t := range tools {
+		budget -= model.EstimateTokens(t.Name) + model.EstimateTokens(t.Description) + model.EstimateTokens(string(t.Parameters))
+	}
+	return max(budget, 0)
+}
@@ -0,0 +1,36 @@
+package brain
+
+import (
+	vcontext "github.com/nathfavour/vibeauracle/context"
+	"github.com/nathfavour/vibeauracle/model"
+)
+
+// cachedModel wraps p for background call sites. Interactive chat keeps using
+// b.model directly so it never sees a cached reply.
+func (b *Brain) cachedModel(p model.Provider) *model.Model {
+	if !b.config.Cache.Enabled || p == nil {
+		return b.model
+	}
+	if b.config.Cache.MaxSizeMB > 0 {
+		b.memory.SetCacheLimit(int64(b.config.Cache.MaxSizeMB) << 20)
+	}
+	cache := func(p model.Provider, name string) model.Provider {
+		return model.NewCachingProvider(p, name, b.memory, b.config.Cache.TTL)
+	}
+	// Each member of a fallback chain caches under its own provider and model, so a
+	// reply from a fallback is never replayed as if the primary had given it.
+	if f, ok := p.(*model.FallbackProvider); ok {
+		return model.New(f.Wrap(cache))
+	}
+	return model.New(cache(p, b.config.Model.Name))
+}
+
+// CacheStats describes the response cache.
+func (b *Brain) CacheStats() (vcontext.CacheStats, error) {
+	return b.memory.CacheStats()
+}
+
+// ClearCache drops every cached response and returns how many were removed.
+func (b *Brain) ClearCache() (int64, error) {
+	return b.memory.CacheClear()
+}
@@ -0,0 +1,158 @@
+package brain
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/nathfavour/vibeauracle/agent"
+	"github.com/nathfavour/vibeauracle/model"
+	"github.com/nathfavour/vibeauracle/sys"
+	"github.com/nathfavour/vibeauracle/tooling"
+)
+
+// goalModel adapts the brain's model to the agent engine, recording token usage
+// for every turn the engine takes.
+type goalModel struct {
+	b       *Brain
+	session *tooling.Session
+	usage   *tooling.UsageReport
+}
+
+func (g *goalModel) Generate(ctx context.Context, prompt string) (string, error) {
+	resp, err := g.b.model.Chat(ctx, []model.Message{{Role: model.RoleUser, Content: prompt}}, model.ChatOptions{})
+	if err != nil {
+		return "", err
+	}
+	g.b.recordUsage(g.session, g.usage, resp.Provider, resp.Usage)
+	return resp.Content, nil
+}
+
+// goalEngine builds the goal-driven runtime on top of the brain's model, tools and memory.
+// Tool calls go through executeToolCall like in the other modes, so OnIntervention can
+// answer approvals; their records are appended to toolCalls when it is set.
+func (b *Brain) goalEngine(session *tooling.Session, usage *tooling.UsageReport, limits sys.AgentLimits, toolCalls *[]tooling.ToolCall) *agent.Engine {
+	m := &goalModel{b: b, session: session, usage: usage}
+	n := 0
+	return agent.NewEngine(m, b.tools, b.prompts, b.memory, agent.Config{
+		MaxTurns:        limits.MaxTurns,
+		MaxToolCalls:    limits.MaxToolCalls,
+		LearningEnabled: b.config.Prompt.LearningEnabled,
+		RunTool: func(ctx context.Context, tool string, args json.RawMessage) (string, error) {
+			n++
+			call := model.ToolCall{ID: fmt.Sprintf("goal_call_%d", n), Name: tool, Arguments: args}
+			outcome, err := b.executeToolCall(ctx, b.tools, call)
+			if err != nil {
+				return "", err
+			}
+			outcome.Content = truncateOutput(outcome.Content, limits.MaxOutputBytes)
+			records := b.reportToolCalls([]toolOutcome{outcome})
+			if toolCalls != nil {
+				*toolCalls = append(*toolCalls, records...)
+			}
+			return outcome.Content, nil
+		},
+	})
+}
+
+// Goals lists saved goals, most recently updated first.
+func (b *Brain) Goals() ([]agent.LoopState, error) {
+	return b.goalEngine(nil, nil, sys.AgentLimits{}, nil).List()
+}
+
+// ResumeGoal continues an interrupted goal from its last saved turn.
+func (b *Brain) ResumeGoal(ctx context.Context, goalID string) (Response, error) {
+	if b.model == nil {
+		return Response{}, fmt.Errorf("no AI model configured. Run 'vibeaura auth' to set up a provider")
+	}
+	req := Request{ID: goalID, Content: "resume " + goalID}
+	run, ctx, cancel := b.startBudget(ctx, req)
+	defer cancel()
+	b.journal.Begin(b.GetSessionID(), goalID, req.Content)
+	return b.runGoal(ctx, req, goalID, run)
+}
+
+// runGoal runs req as a new goal, or resumes goalID when it is set. A goal that runs out
+// of turns, tool calls or time is saved and reported as BudgetExhausted.
+func (b *Brain) runGoal(ctx context.Context, req Request, goalID string, run *runBudget) (Response, error) {
+	session := b.loadSession(b.GetSessionID())
+	threadUsage := &tooling.UsageReport{}
+	var toolCalls []tooling.ToolCall
+	engine := b.goalEngine(session, threadUsage, run.limits, &toolCalls)
+
+	var last agent.LoopState
+	onUpdate := func(state agent.LoopState) {
+		last = state
+		done, total := state.Goal.Progress()
+		tooling.ReportStatus("🎯", "goal", fmt.Sprintf("%s · turn %d/%d · %d/%d milestones", state.Goal.ID, state.Turns, state.MaxTurns, done, total))
+	}
+
+	var resp string
+	var err error
+	if goalID != "" {
+		tooling.ReportStatus("⏯️", "goal", fmt.Sprintf("Resuming %s", goalID))
+		resp, err = engine.Resume(ctx, goalID, onUpdate)
+	} else {
+		tooling.ReportStatus("🎯", "goal", "Planning milestones...")
+		resp, err = engine.Run(ctx, req.Content, onUpdate)
+	}
+
+	var interrupted *agent.InterruptedError
+	if errors.As(err, &interrupted) {
+		goalID = interrupted.GoalID
+		tooling.ReportStatus("⏸️", "goal", fmt.Sprintf("Interrupted. Continue with: vibeaura resume %s", goalID))
+	} else if last.Goal.ID != "" {
+		goalID = last.Goal.ID
+	}
+	if err != nil {
+		limit := ""
+		switch {
+		case errors.Is(err, agent.ErrMaxTurns):
+			limit = "max_turns"
+		case errors.Is(err, agent.ErrMaxToolCalls):
+			limit = "max_tool_calls"
+		case run.deadlineHit(ctx):
+			limit = "timeout"
+		default:
+			var intervention *tooling.InterventionError
+			if errors.As(err, &intervention) {
+				return b.halt(session, req, "", toolCalls, threadUsage, "intervention"), err
+			}
+			return Response{}, err
+		}
+		if state, loadErr := engine.Load(goalID); loadErr == nil {
+			last = *state
+		}
+		budget := run.exhausted(limit, last.Turns, last.ToolCalls, req.Content)
+		resp := b.halt(session, req, fmt.Sprintf("(Stopped: %s Continue with: vibeaura resume %s)", budget.String(), goalID), toolCalls, threadUsage, "budget: "+limit)
+		resp.Metadata["budget_exhausted"] = budget
+		resp.Metadata["goal_id"] = goalID
+		resp.Metadata["milestones"] = last.Goal.Milestones
+		return resp, nil
+	}
+
+	tooling.ReportStatus("✅", "done", "Goal completed")
+	session.AddThread(&tooling.Thread{
+		ID:        req.ID,
+		Prompt:    req.Content,
+		Response:  resp,
+		ToolCalls: toolCalls,
+		Usage:     threadUsage,
+		Metadata: map[string]interface{}{
+			"goal_id":    goalID,
+			"milestones": last.Goal.Milestones,
+		},
+	})
+	_ = b.memory.Store(req.ID, resp)
+	_ = b.StoreState(session.ID+"_obj", session)
+
+	return Response{
+		Content: resp,
+		Metadata: map[string]interface{}{
+			"goal_id":    goalID,
+			"milestones": last.Goal.Milestones,
+			"usage":      threadUsage.Total,
+		},
+	}, nil
+}
@@ -0,0 +1,136 @@
+package brain
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/cenkalti/backoff/v4"
+	"github.com/nathfavour/vibeauracle/internal/doctor"
+	"github.com/nathfavour/vibeauracle/sys"
+	"github.com/nathfavour/vibeauracle/tooling"
+)
+
+// defaultMaxTurns applies when no configured limit sets max_turns.
+const defaultMaxTurns = 10
+
+const continuePrefix = "Continue the previous task from where you stopped. The original request was:\n"
+
+// BudgetExhausted reports which limit stopped an agent run before it finished.
+// Process returns it in Response.Metadata["budget_exhausted"]; sending
+// ContinuePrompt as the next request picks the task up with a fresh budget.
+type BudgetExhausted struct {
+	Limit     string          `json:"limit"` // max_turns|timeout|max_tool_calls
+	Turns     int             `json:"turns"`
+	ToolCalls int             `json:"tool_calls"`
+	Elapsed   time.Duration   `json:"elapsed"`
+	Limits    sys.AgentLimits `json:"limits"`
+	Prompt    string          `json:"prompt"`
+}
+
+func (e *BudgetExhausted) String() string {
+	var what string
+	switch e.Limit {
+	case "max_turns":
+		what = fmt.Sprintf("reached the limit of %d turns", e.Limits.MaxTurns)
+	case "timeout":
+		what = fmt.Sprintf("ran past its %s deadline", e.Limits.Timeout)
+	case "max_tool_calls":
+		what = fmt.Sprintf("reached the limit of %d tool calls", e.Limits.MaxToolCalls)
+	default:
+		what = "ran out of budget"
+	}
+	return fmt.Sprintf("The agent %s (%d turns, %d tool calls, %s).", what, e.Turns, e.ToolCalls, e.Elapsed.Round(time.Second))
+}
+
+// ContinuePrompt is the request that resumes the interrupted task. The halted thread is
+// part of the session history, so the model sees how far it got.
+func (e *BudgetExhausted) ContinuePrompt() string {
+	if strings.HasPrefix(e.Prompt, continuePrefix) {
+		return e.Prompt
+	}
+	return continuePrefix + e.Prompt
+}
+
+// limitsFor resolves the limits of a run: agent.limits, then the active custom agent's
+// overrides, then the request's own.
+func (b *Brain) limitsFor(req Request) sys.AgentLimits {
+	limits := b.config.Agent.Limits
+	if b.config.Agent.Mode == "custom" {
+		if agent := b.activeCustomAgent(); agent != nil {
+			limits = limits.Merge(agent.Limits)
+		}
+	}
+	limits = limits.Merge(req.Limits)
+	if limits.MaxTurns <= 0 {
+		limits.MaxTurns = defaultMaxTurns
+	}
+	return limits
+}
+
+// runBudget is the resolved limits of one run and the clock they are measured against.
+type runBudget struct {
+	limits  sys.AgentLimits
+	started time.Time
+	caller  context.Context
+}
+
+// startBudget resolves the limits of req and applies its deadline to ctx.
+func (b *Brain) startBudget(ctx context.Context, req Request) (*runBudget, context.Context, context.CancelFunc) {
+	run := &runBudget{limits: b.limitsFor(req), started: time.Now(), caller: ctx}
+	if run.limits.Timeout > 0 {
+		ctx, cancel := context.WithTimeout(ctx, run.limits.Timeout)
+		return run, ctx, cancel
+	}
+	return run, ctx, func() {}
+}
+
+// deadlineHit tells the run's own deadline apart from the caller cancelling.
+func (r *runBudget) deadlineHit(ctx context.Context) bool {
+	return errors.Is(ctx.Err(), context.DeadlineExceeded) && r.caller.Err() == nil
+}
+
+// exhausted reports that limit stopped the run and describes it for the caller.
+func (r *runBudget) exhausted(limit string, turns, toolCalls int, prompt string) *BudgetExhausted {
+	budget := &BudgetExhausted{
+		Limit:     limit,
+		Turns:     turns,
+		ToolCalls: toolCalls,
+		Elapsed:   time.Since(r.started),
+		Limits:    r.limits,
+		Prompt:    prompt,
+	}
+	tooling.ReportStatus("⏳", "limit", budget.String())
+	doctor.Send("brain", "warning", "Budget exhausted", map[string]any{"limit": limit, "turns": turns})
+	return budget
+}
+
+// activeCustomAgent returns the selected custom agent, if it still exists.
+func (b *Brain) activeCustomAgent() *sys.CustomAgent {
+	for i, a := range b.config.Agent.CustomAgents {
+		if a.Name == b.config.Agent.ActiveCustom {
+			return &b.config.Agent.CustomAgents[i]
+		}
+	}
+	return nil
+}
+
+// retryBackOff is the retry policy for model calls, capped at maxElapsed when set.
+func retryBackOff(ctx context.Context, maxElapsed time.Duration) backoff.BackOff {
+	bo := backoff.NewExponentialBackOff()
+	if maxElapsed > 0 {
+		bo.MaxElapsedTime = maxElapsed
+	}
+	return backoff.WithContext(bo, ctx)
+}
+
+// truncateOutput caps a tool result at max bytes (0 means no cap), cutting on a rune boundary.
+func truncateOutput(s string, max int) string {
+	if max <= 0 || len(s) <= max {
+		return s
+	}
+	cut := strings.ToValidUTF8(s[:max], "")
+	return fmt.Sprintf("%s\n... (output truncated: showing %d of %d bytes)", cut, len(cut), len(s))
+}
@@ -0,0 +1,131 @@
+package brain
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/nathfavour/vibeauracle/model"
+)
+
+// loopHint is sent to the model the first time it is caught looping.
+const loopHint = "You appear to be stuck repeating the same actions (%s). Their results will not change. " +
+	"Try a different approach, or stop and summarize what you have found and what is blocking you."
+
+// LoopDetector tracks agent turns to detect infinite loops. Turns are compared by
+// their normalized tool call signatures, so rewording around a call or reordering its
+// arguments does not hide a repeat, and responses are compared by word similarity.
+type LoopDetector struct {
+	signatures []string
+	responses  []string
+	maxHistory int
+	// similarity is the word overlap at which two responses count as the same (0 disables).
+	similarity float64
+}
+
+func NewLoopDetector(maxHistory int, similarity float64) *LoopDetector {
+	return &LoopDetector{
+		signatures: make([]string, 0, maxHistory),
+		responses:  make([]string, 0, maxHistory),
+		maxHistory: maxHistory,
+		similarity: similarity,
+	}
+}
+
+// Observe records one turn and reports whether the agent is looping, and why.
+// A loop is the same tool calls three times in a row, two sets of calls alternating
+// (A-B-A-B), or the same response three times. Turns without tool calls are skipped
+// when comparing calls, so reading a file again after editing it is not a repeat.
+func (ld *LoopDetector) Observe(response string, calls []model.ToolCall) (bool, string) {
+	sig := callSignature(calls)
+	response = strings.TrimSpace(response)
+
+	ld.signatures = append(ld.signatures, sig)
+	ld.responses = append(ld.responses, response)
+	if len(ld.signatures) > ld.maxHistory {
+		ld.signatures = ld.signatures[1:]
+		ld.responses = ld.responses[1:]
+	}
+
+	if sig != "" {
+		// Turns without tool calls do not interrupt a repeat; other calls do.
+		var recent []string
+		for _, s := range ld.signatures {
+			if s != "" {
+				recent = append(recent, s)
+			}
+		}
+		n := len(recent)
+		repeats := 0
+		for i := n - 1; i >= 0 && recent[i] == sig; i-- {
+			repeats++
+		}
+		if repeats >= 3 {
+			return true, fmt.Sprintf("repeated %s %d times", sig, repeats)
+		}
+
+		if n >= 4 {
+			a, b := recent[n-2], recent[n-1]
+			if a != b && recent[n-4] == a && recent[n-3] == b {
+				return true, fmt.Sprintf("alternating between %s and %s", a, b)
+			}
+		}
+	}
+
+	if ld.similarity > 0 && response != "" {
+		similar := 0
+		for _, r := range ld.responses[:len(ld.responses)-1] {
+			if r != "" && model.WordSimilarity(r, response) >= ld.similarity {
+				similar++
+			}
+		}
+		if similar >= 2 {
+			return true, "repeating the same response"
+		}
+	}
+	return false, ""
+}
+
+// callSignature is the order-independent identity of a turn's tool calls: each call's
+// name and canonicalized arguments. Call IDs are ignored.
+func callSignature(calls []model.ToolCall) string {
+	if len(calls) == 0 {
+		return ""
+	}
+	parts := make([]string, 0, len(calls))
+	for _, c := range calls {
+		parts = append(parts, c.Name+canonicalArgs(c.Arguments))
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, "+")
+}
+
+// canonicalArgs re-encodes JSON arguments with sorted keys and trimmed strings.
+func canonicalArgs(raw json.RawMessage) string {
+	var v interface{}
+	if err := json.Unmarshal(raw, &v); err != nil {
+		return string(raw)
+	}
+	out, err := json.Marshal(trimStrings(v))
+	if err != nil {
+		return string(raw)
+	}
+	return string(out)
+}
+
+func trimStrings(v interface{}) interface{} {
+	switch t := v.(type) {
+	case string:
+		return strings.TrimSpace(t)
+	case map[string]interface{}:
+		for k, val := range t {
+			t[k] = trimStrings(val)
+		}
+	case []interface{}:
+		for i, val := range t {
+			t[i] = trimStrings(val)
+		}
+	}
+	return v
+}
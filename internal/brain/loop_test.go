@@ -0,0 +1,90 @@
+package brain
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/nathfavour/vibeauracle/model"
+)
+
+func call(name, args string) []model.ToolCall {
+	return []model.ToolCall{{ID: "ignored", Name: name, Arguments: json.RawMessage(args)}}
+}
+
+func TestLoopDetector_NormalizesSignatures(t *testing.T) {
+	ld := NewLoopDetector(10, 0)
+	turns := []struct {
+		resp string
+		args string
+	}{
+		{"Let me read the config.", `{"path": "config.yaml", "limit": 10}`},
+		{"I'll look at the file again.", `{"limit":10,"path":"config.yaml "}`},
+		{"Reading it once more to be sure.", `{ "path": "config.yaml", "limit": 10 }`},
+	}
+	for i, turn := range turns {
+		looping, reason := ld.Observe(turn.resp, call("sys_read_file", turn.args))
+		if looping != (i == 2) {
+			t.Fatalf("turn %d: looping=%v (%s)", i+1, looping, reason)
+		}
+	}
+}
+
+func TestLoopDetector_RepeatsMustBeConsecutive(t *testing.T) {
+	ld := NewLoopDetector(10, 0)
+	read := call("sys_read_file", `{"path": "main.go"}`)
+	turns := [][]model.ToolCall{
+		read,
+		call("sys_edit_file", `{"path": "main.go", "old": "a", "new": "b"}`),
+		read,
+		call("sys_edit_file", `{"path": "main.go", "old": "c", "new": "d"}`),
+		read,
+	}
+	for i, calls := range turns {
+		if looping, reason := ld.Observe("", calls); looping {
+			t.Fatalf("turn %d: reading a file between edits flagged as a loop: %s", i+1, reason)
+		}
+	}
+
+	// Turns that only talk do not break a run of the same calls.
+	ld = NewLoopDetector(10, 0)
+	for i, calls := range [][]model.ToolCall{read, nil, read, nil, read} {
+		looping, _ := ld.Observe(fmt.Sprintf("turn %d", i), calls)
+		if looping != (i == 4) {
+			t.Fatalf("turn %d: looping=%v", i+1, looping)
+		}
+	}
+}
+
+func TestLoopDetector_Alternating(t *testing.T) {
+	ld := NewLoopDetector(10, 0)
+	seq := []string{"a", "b", "a", "b"}
+	for i, name := range seq {
+		looping, _ := ld.Observe("", call(name, `{}`))
+		if looping != (i == 3) {
+			t.Fatalf("call %d: looping=%v", i+1, looping)
+		}
+	}
+}
+
+func TestLoopDetector_SimilarResponses(t *testing.T) {
+	ld := NewLoopDetector(10, 0.8)
+	responses := []string{
+		"I cannot find the file you mentioned in this project.",
+		"I cannot find the file you mentioned in the project.",
+		"Sorry, I cannot find the file you mentioned in this project.",
+	}
+	for i, r := range responses {
+		looping, _ := ld.Observe(r, nil)
+		if looping != (i == 2) {
+			t.Fatalf("response %d: looping=%v", i+1, looping)
+		}
+	}
+
+	distinct := NewLoopDetector(10, 0.8)
+	for _, r := range []string{"Reading main.go.", "Now the tests.", "Everything passes."} {
+		if looping, reason := distinct.Observe(r, nil); looping {
+			t.Fatalf("distinct responses flagged as a loop: %s", reason)
+		}
+	}
+}
@@ -0,0 +1,399 @@
+package brain
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nathfavour/vibeauracle/model"
+	"github.com/nathfavour/vibeauracle/tooling"
+)
+
+// maxReviewRounds is how many times QA may send work back before giving up.
+const maxReviewRounds = 2
+
+// rolePrompts are the system instructions for each orchestration role.
+var rolePrompts = map[tooling.AgentRole]string{
+	tooling.RoleArchitect: `You are the ARCHITECT of a small engineering team. Inspect the project as needed, but do not modify anything.
+Break the request into a short ordered list of subtasks and reply with ONLY a JSON array:
+[{"role": "coder" | "engineer", "task": "..."}]
+Use "coder" for focused file edits and "engineer" for work that needs shell commands, builds or scripts.`,
+	tooling.RoleCoder: `You are the CODER. Carry out your subtask with precise file edits using the tools available to you.
+Finish with a short summary of what you changed.`,
+	tooling.RoleEngineer: `You are the ENGINEER. Carry out your subtask, using the shell where needed.
+Finish with a short summary of what you did.`,
+	tooling.RoleQA: `You are QA. Verify the work described below, running the project's tests or build where possible. Do not fix anything yourself.
+End your reply with a line "VERDICT: PASS" or "VERDICT: FAIL". After a FAIL, add one line "FAILED <n>: <problem>" for each numbered subtask that needs fixing.`,
+}
+
+var roleIcons = map[tooling.AgentRole]string{
+	tooling.RoleArchitect: "🏛️",
+	tooling.RoleCoder:     "⌨️",
+	tooling.RoleEngineer:  "🛠️",
+	tooling.RoleQA:        "🧪",
+}
+
+var (
+	qaPassPattern   = regexp.MustCompile(`(?i)verdict:\s*pass`)
+	qaFailedPattern = regexp.MustCompile(`(?im)^\W*failed\s+(\d+)\s*:\s*(.*)$`)
+)
+
+// budgetStop ends an orchestration when a sub-agent runs out of turns, tool calls or time.
+type budgetStop struct {
+	limit string
+	turns int
+}
+
+func (e *budgetStop) Error() string {
+	return "budget exhausted: " + e.limit
+}
+
+// subtask is one step of the architect's plan.
+type subtask struct {
+	Role tooling.AgentRole `json:"role"`
+	Task string            `json:"task"`
+}
+
+// orchestrate runs a request through the architect → executor → QA pipeline. Every
+// sub-agent sees only the tools its role permits, and its transcript is stored as a
+// child of the request's thread. Each sub-agent gets the run's turn limit, while tool
+// calls and the deadline are shared by the whole team.
+func (b *Brain) orchestrate(ctx context.Context, req Request, session *tooling.Session, run *runBudget) (Response, error) {
+	parent := &tooling.Thread{
+		ID:        req.ID,
+		Prompt:    req.Content,
+		Role:      "orchestrator",
+		Usage:     &tooling.UsageReport{},
+		Metadata:  map[string]interface{}{},
+		Timestamp: time.Now(),
+	}
+	// Keep the transcripts even when a sub-agent stops for an intervention or an error.
+	defer func() {
+		for _, child := range parent.Children {
+			parent.Usage.Merge(*child.Usage)
+			parent.ToolCalls = append(parent.ToolCalls, child.ToolCalls...)
+		}
+		session.AddThread(parent)
+		_ = b.StoreState(session.ID+"_obj", session)
+	}()
+	// fail ends the run early, turning a spent budget into a result the caller can continue.
+	fail := func(err error) (Response, error) {
+		var spent *budgetStop
+		if !errors.As(err, &spent) {
+			return Response{}, err
+		}
+		budget := run.exhausted(spent.limit, spent.turns, teamToolCalls(parent), req.Content)
+		parent.Response = "(Stopped: " + budget.String() + ")"
+		parent.Metadata["halt_reason"] = "budget: " + spent.limit
+		_ = b.memory.Store(req.ID, parent.Response)
+		return Response{
+			Content: parent.Response,
+			Metadata: map[string]interface{}{
+				"halt_reason":      "budget: " + spent.limit,
+				"budget_exhausted": budget,
+				"usage":            threadUsageTotal(parent),
+			},
+		}, nil
+	}
+
+	// 1. Plan
+	plan, err := b.runSubAgent(ctx, session, parent, tooling.RoleArchitect, req.Content, run)
+	if err != nil {
+		return fail(err)
+	}
+	subtasks := parseSubtasks(plan, req.Content)
+	tooling.ReportStatus("🏛️", "architect", fmt.Sprintf("Planned %d subtasks", len(subtasks)))
+
+	// 2. Execute
+	work := make([][]string, len(subtasks))
+	for i, st := range subtasks {
+		task := fmt.Sprintf("Overall request: %s\n\nYour subtask (%d/%d): %s", req.Content, i+1, len(subtasks), st.Task)
+		if i > 0 {
+			task += "\n\nWork done so far:\n" + workReport(subtasks[:i], work)
+		}
+		out, err := b.runSubAgent(ctx, session, parent, st.Role, task, run)
+		if err != nil {
+			return fail(err)
+		}
+		work[i] = append(work[i], "result: "+out)
+	}
+
+	// 3. Review; each failed subtask goes back to the role that carried it out.
+	var review string
+	var problems []string
+	rounds := 0
+	for {
+		rounds++
+		review, err = b.runSubAgent(ctx, session, parent, tooling.RoleQA,
+			fmt.Sprintf("Request: %s\n\nWork reported by the team:\n%s", req.Content, workReport(subtasks, work)), run)
+		if err != nil {
+			return fail(err)
+		}
+		problems = qaProblems(review, len(subtasks))
+		if problems == nil || rounds > maxReviewRounds {
+			break
+		}
+
+		for i, problem := range problems {
+			if problem == "" {
+				continue
+			}
+			st := subtasks[i]
+			tooling.ReportStatus("🔁", "review", fmt.Sprintf("QA rejected subtask %d, sending it back to the %s", i+1, st.Role))
+			fix, err := b.runSubAgent(ctx, session, parent, st.Role,
+				fmt.Sprintf("Overall request: %s\n\nYour subtask (%d/%d): %s\n\nQA rejected your work:\n%s\n\nFix the problems QA found.",
+					req.Content, i+1, len(subtasks), st.Task, problem), run)
+			if err != nil {
+				return fail(err)
+			}
+			work[i] = append(work[i], "rework: "+fix)
+		}
+	}
+	passed := problems == nil
+
+	var unresolved []int
+	var sb strings.Builder
+	sb.WriteString("Results:\n" + workReport(subtasks, work) + "\n")
+	if passed {
+		sb.WriteString(fmt.Sprintf("QA: passed after %d review round(s).\n", rounds))
+	} else {
+		sb.WriteString(fmt.Sprintf("QA: still failing after %d review rounds. Unresolved subtasks:\n", rounds))
+		for i, problem := range problems {
+			if problem != "" {
+				unresolved = append(unresolved, i+1)
+				sb.WriteString(fmt.Sprintf("%d. [%s] %s: %s\n", i+1, subtasks[i].Role, subtasks[i].Task, problem))
+			}
+		}
+		sb.WriteString("\n")
+	}
+	sb.WriteString(review)
+	finalContent := sb.String()
+
+	parent.Response = finalContent
+	parent.Metadata["subtasks"] = len(subtasks)
+	parent.Metadata["qa_passed"] = passed
+	parent.Metadata["review_rounds"] = rounds
+	if len(unresolved) > 0 {
+		parent.Metadata["unresolved"] = unresolved
+	}
+	_ = b.memory.Store(req.ID, finalContent)
+
+	status := "✅"
+	if !passed {
+		status = "⚠️"
+	}
+	tooling.ReportStatus(status, "done", fmt.Sprintf("Orchestration finished (QA passed: %v)", passed))
+	return Response{
+		Content: finalContent,
+		Metadata: map[string]interface{}{
+			"subtasks":      len(subtasks),
+			"qa_passed":     passed,
+			"review_rounds": rounds,
+			"unresolved":    unresolved,
+			"usage":         threadUsageTotal(parent),
+		},
+	}, nil
+}
+
+// workReport lists the subtasks, numbered as QA refers to them, with what their
+// executors reported.
+func workReport(subtasks []subtask, work [][]string) string {
+	var sb strings.Builder
+	for i, st := range subtasks {
+		sb.WriteString(fmt.Sprintf("%d. [%s] %s\n", i+1, st.Role, st.Task))
+		for _, w := range work[i] {
+			sb.WriteString("   " + w + "\n")
+		}
+	}
+	return sb.String()
+}
+
+// qaProblems reads a QA review of n subtasks. It returns nil when the work passed, and
+// otherwise the problems found in each subtask, empty for those that passed. A failure
+// that names no subtask is charged to all of them.
+func qaProblems(review string, n int) []string {
+	if qaPassPattern.MatchString(review) {
+		return nil
+	}
+	problems := make([]string, n)
+	named := false
+	for _, m := range qaFailedPattern.FindAllStringSubmatch(review, -1) {
+		i, _ := strconv.Atoi(m[1])
+		if i < 1 || i > n {
+			continue
+		}
+		named = true
+		if problems[i-1] != "" {
+			problems[i-1] += "\n"
+		}
+		problems[i-1] += strings.TrimSpace(m[2])
+	}
+	if !named {
+		for i := range problems {
+			problems[i] = strings.TrimSpace(review)
+		}
+	}
+	return problems
+}
+
+// threadUsageTotal sums a thread's own usage and that of its children.
+func threadUsageTotal(t *tooling.Thread) tooling.Usage {
+	var total tooling.UsageReport
+	total.Merge(*t.Usage)
+	for _, child := range t.Children {
+		total.Merge(*child.Usage)
+	}
+	return total.Total
+}
+
+// teamToolCalls counts the tool calls of every sub-agent of parent.
+func teamToolCalls(parent *tooling.Thread) int {
+	n := 0
+	for _, child := range parent.Children {
+		n += len(child.ToolCalls)
+	}
+	return n
+}
+
+// runSubAgent runs one role-restricted tool loop and records it as a child thread of parent.
+// It returns a *budgetStop when the run's limits stop it.
+func (b *Brain) runSubAgent(ctx context.Context, session *tooling.Session, parent *tooling.Thread, role tooling.AgentRole, task string, run *runBudget) (string, error) {
+	tools := b.tools.ForRole(role)
+	caps := b.Capabilities()
+	native := b.model.SupportsTools() && caps.Tools
+
+	var names []string
+	for _, t := range tools.List() {
+		names = append(names, t.Metadata().Name)
+	}
+	system := rolePrompts[role]
+	var defs []model.ToolDefinition
+	budget := caps.PromptBudget()
+	if native {
+		if len(names) > 0 {
+			defs = b.toolDefinitions(names)
+		}
+		budget = promptBudget(caps, defs)
+	} else {
+		system += "\n\nTo use a tool, output a ```json block: {\"tool\": \"<name>\", \"parameters\": {...}}\n\nAvailable Tools:\n" + tools.GetPromptDefinitions(nil)
+	}
+
+	thread := &tooling.Thread{
+		ID:        fmt.Sprintf("%s/%s-%d", parent.ID, role, len(parent.Children)+1),
+		Prompt:    task,
+		Role:      string(role),
+		Usage:     &tooling.UsageReport{},
+		Timestamp: time.Now(),
+	}
+	parent.Children = append(parent.Children, thread)
+
+	conversation := []model.Message{
+		{Role: model.RoleSystem, Content: system},
+		{Role: model.RoleUser, Content: task},
+	}
+	defer func() { thread.Transcript = transcriptOf(conversation) }()
+
+	icon := roleIcons[role]
+	var last string
+	maxTurns := run.limits.MaxTurns
+	for turn := 1; turn <= maxTurns; turn++ {
+		if run.deadlineHit(ctx) {
+			return "", &budgetStop{limit: "timeout", turns: turn - 1}
+		}
+		tooling.ReportStatus(icon, string(role), fmt.Sprintf("Turn %d/%d: Thinking...", turn, maxTurns))
+
+		resp, err := b.model.Chat(ctx, model.FitMessages(conversation, budget), model.ChatOptions{Tools: defs})
+		if err != nil {
+			if run.deadlineHit(ctx) {
+				return "", &budgetStop{limit: "timeout", turns: turn - 1}
+			}
+			return "", fmt.Errorf("%s agent: %w", role, err)
+		}
+		b.recordUsage(session, thread.Usage, resp.Provider, resp.Usage)
+		last = resp.Content
+
+		calls := resp.ToolCalls
+		var parseErrs []string
+		if !native {
+			calls, parseErrs = parseFencedToolCalls(resp.Content)
+		}
+		conversation = append(conversation, model.Message{Role: model.RoleAssistant, Content: resp.Content, ToolCalls: calls})
+		if len(calls) == 0 && len(parseErrs) == 0 {
+			thread.Response = resp.Content
+			return resp.Content, nil
+		}
+
+		if run.limits.MaxToolCalls > 0 && teamToolCalls(parent)+len(calls) > run.limits.MaxToolCalls {
+			thread.Response = last
+			return "", &budgetStop{limit: "max_tool_calls", turns: turn}
+		}
+		outcomes, interventionErr := b.executeToolCalls(ctx, tools, calls)
+		for i := range outcomes {
+			outcomes[i].Content = truncateOutput(outcomes[i].Content, run.limits.MaxOutputBytes)
+		}
+		thread.ToolCalls = append(thread.ToolCalls, b.reportToolCalls(outcomes)...)
+		if interventionErr != nil {
+			return "", interventionErr
+		}
+		for _, o := range outcomes {
+			conversation = append(conversation, model.Message{Role: model.RoleTool, ToolCallID: o.Call.ID, Name: o.Call.Name, Content: o.Content})
+		}
+		if len(parseErrs) > 0 {
+			conversation = append(conversation, model.Message{Role: model.RoleUser, Content: strings.Join(parseErrs, "\n")})
+		}
+	}
+
+	thread.Response = last + "\n\n(Stopped: sub-agent turn limit reached)"
+	return "", &budgetStop{limit: "max_turns", turns: maxTurns}
+}
+
+// parseSubtasks reads the architect's JSON plan. Unknown roles are handed to the
+// engineer, and an unreadable plan becomes a single engineer subtask.
+func parseSubtasks(plan, request string) []subtask {
+	var steps []subtask
+	if start, end := strings.Index(plan, "["), strings.LastIndex(plan, "]"); start != -1 && end > start {
+		_ = json.Unmarshal([]byte(plan[start:end+1]), &steps)
+	}
+
+	var out []subtask
+	for _, st := range steps {
+		if strings.TrimSpace(st.Task) == "" {
+			continue
+		}
+		if st.Role != tooling.RoleCoder {
+			st.Role = tooling.RoleEngineer
+		}
+		out = append(out, st)
+	}
+	if len(out) == 0 {
+		out = []subtask{{Role: tooling.RoleEngineer, Task: request}}
+	}
+	return out
+}
+
+// transcriptOf converts a sub-agent conversation into a stored transcript.
+func transcriptOf(conversation []model.Message) []tooling.TranscriptEntry {
+	out := make([]tooling.TranscriptEntry, 0, len(conversation))
+	for _, msg := range conversation {
+		entry := tooling.TranscriptEntry{Role: string(msg.Role), Content: msg.Content, Tool: msg.Name}
+		if msg.Role == model.RoleAssistant && len(msg.ToolCalls) > 0 {
+			entry.Content += describeToolCalls(msg.ToolCalls)
+		}
+		out = append(out, entry)
+	}
+	return out
+}
+
+// describeToolCalls renders tool calls as text for a stored transcript.
+func describeToolCalls(calls []model.ToolCall) string {
+	var sb strings.Builder
+	for _, c := range calls {
+		sb.WriteString(fmt.Sprintf("\n[tool_call %s] %s %s", c.ID, c.Name, string(c.Arguments)))
+	}
+	return sb.String()
+}
@@ -0,0 +1,533 @@
+package brain
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/nathfavour/vibeauracle/agent"
+	"github.com/nathfavour/vibeauracle/model"
+	"github.com/nathfavour/vibeauracle/sys"
+	"github.com/nathfavour/vibeauracle/tooling"
+)
+
+// End-to-end tests of the vibe agent loop driven by the replay provider, so they run
+// offline and deterministically. Cassettes under testdata use fuzzy matching because
+// the system prompt embeds the working directory and a snapshot of the tree.
+
+func replayBrain(t *testing.T, r *model.ReplayProvider) (*Brain, *echoTool) {
+	t.Helper()
+	t.Setenv("HOME", t.TempDir())
+	b, echo := newTestBrain(r)
+	// Background project indexing must not consume the scripted interactions.
+	b.prompts.SetModel(model.New(&MockProvider{}))
+	t.Cleanup(func() {
+		if n := r.Remaining(); n != 0 {
+			t.Errorf("%d recorded interactions were never requested", n)
+		}
+	})
+	return b, echo
+}
+
+func loadReplay(t *testing.T, name string) *model.ReplayProvider {
+	t.Helper()
+	r, err := model.NewReplayProvider("testdata/"+name, model.ReplayFuzzy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+// scriptedCassette builds a cassette whose interactions match any request.
+func scriptedCassette(responses ...model.CassetteResponse) *model.ReplayProvider {
+	c := &model.Cassette{Provider: "scripted", NativeTools: true}
+	for _, r := range responses {
+		c.Interactions = append(c.Interactions, model.Interaction{Response: r})
+	}
+	return model.NewReplayProviderFromCassette(c, model.ReplayFuzzy)
+}
+
+func echoCall(id, text string) model.CassetteResponse {
+	return model.CassetteResponse{ToolCalls: []model.ToolCall{{
+		ID:        id,
+		Name:      "test_echo",
+		Arguments: json.RawMessage(fmt.Sprintf(`{"text": %q}`, text)),
+	}}}
+}
+
+func TestVibeLoop_Replay_NativeTools(t *testing.T) {
+	b, echo := replayBrain(t, loadReplay(t, "native_tools.json"))
+
+	resp, err := b.Process(context.Background(), Request{ID: "e2e-native", Content: "please echo hello"})
+	if err != nil {
+		t.Fatalf("Process failed: %v", err)
+	}
+	if len(echo.calls) != 1 || echo.calls[0] != "hello" {
+		t.Fatalf("expected one echo call, got %v", echo.calls)
+	}
+	if resp.Content != "I echoed hello for you." {
+		t.Errorf("unexpected final content: %q", resp.Content)
+	}
+	if usage, ok := resp.Metadata["usage"].(tooling.Usage); !ok || usage.Requests != 2 {
+		t.Errorf("expected usage for two requests, got %+v", resp.Metadata["usage"])
+	}
+}
+
+func TestVibeLoop_Replay_FencedTools(t *testing.T) {
+	b, echo := replayBrain(t, loadReplay(t, "fenced_tools.json"))
+
+	resp, err := b.Process(context.Background(), Request{ID: "e2e-fenced", Content: "please echo fenced"})
+	if err != nil {
+		t.Fatalf("Process failed: %v", err)
+	}
+	if len(echo.calls) != 1 || echo.calls[0] != "fenced" {
+		t.Fatalf("expected the fenced call to run, got %v", echo.calls)
+	}
+	if !strings.HasSuffix(resp.Content, "All done.") {
+		t.Errorf("unexpected final content: %q", resp.Content)
+	}
+}
+
+func TestVibeLoop_Replay_LoopDetection(t *testing.T) {
+	same := echoCall("call_1", "again")
+	b, echo := replayBrain(t, scriptedCassette(same, same, same, same))
+
+	resp, err := b.Process(context.Background(), Request{ID: "e2e-loop", Content: "keep echoing"})
+	if err != nil {
+		t.Fatalf("Process failed: %v", err)
+	}
+	if !strings.Contains(resp.Content, "(Stopped: Loop detected)") {
+		t.Errorf("expected the loop to be detected, got %q", resp.Content)
+	}
+	// The third call is answered with a hint instead of running; the fourth halts.
+	if len(echo.calls) != 2 {
+		t.Errorf("expected two executions before halting, got %d", len(echo.calls))
+	}
+	reason, _ := resp.Metadata["halt_reason"].(string)
+	if !strings.HasPrefix(reason, "loop: repeated test_echo") {
+		t.Errorf("unexpected halt reason %q", reason)
+	}
+	threads := b.loadSession(b.GetSessionID()).Threads
+	if last := threads[len(threads)-1]; last.Metadata["halt_reason"] != reason {
+		t.Errorf("halt reason was not recorded on the thread: %v", last.Metadata)
+	}
+}
+
+func TestVibeLoop_Replay_LoopHintRecovers(t *testing.T) {
+	a := echoCall("call_a", "a")
+	b2 := echoCall("call_b", "b")
+	r := scriptedCassette(a, b2, a, b2, model.CassetteResponse{Content: "I was going in circles; here is what I found."})
+	b, echo := replayBrain(t, r)
+
+	resp, err := b.Process(context.Background(), Request{ID: "e2e-cycle", Content: "ping pong"})
+	if err != nil {
+		t.Fatalf("Process failed: %v", err)
+	}
+	if got := strings.Join(echo.calls, ","); got != "a,b,a" {
+		t.Errorf("the A-B-A-B cycle should be interrupted before its fourth call, got %s", got)
+	}
+	if _, halted := resp.Metadata["halt_reason"]; halted || !strings.HasSuffix(resp.Content, "here is what I found.") {
+		t.Errorf("the agent should finish after the hint, got %q (%v)", resp.Content, resp.Metadata)
+	}
+}
+
+// approvalTool always asks the user before doing anything.
+type approvalTool struct{ echoTool }
+
+func (a *approvalTool) Metadata() tooling.ToolMetadata {
+	meta := a.echoTool.Metadata()
+	meta.Name = "test_approve"
+	meta.Permissions = []tooling.Permission{tooling.PermWrite}
+	return meta
+}
+
+func (a *approvalTool) Execute(ctx context.Context, args json.RawMessage) (*tooling.ToolResult, error) {
+	return nil, &tooling.InterventionError{Title: "Allow test_approve?", Choices: []string{"yes", "no"}}
+}
+
+func TestVibeLoop_Replay_InterventionBubbles(t *testing.T) {
+	r := scriptedCassette(model.CassetteResponse{ToolCalls: []model.ToolCall{
+		{ID: "call_1", Name: "test_echo", Arguments: json.RawMessage(`{"text": "first"}`)},
+		{ID: "call_2", Name: "test_approve", Arguments: json.RawMessage(`{"text": "second"}`)},
+		{ID: "call_3", Name: "test_echo", Arguments: json.RawMessage(`{"text": "third"}`)},
+	}})
+	b, echo := replayBrain(t, r)
+	b.tools.Register(&approvalTool{})
+
+	_, err := b.Process(context.Background(), Request{ID: "e2e-approve", Content: "do something risky"})
+	var intervention *tooling.InterventionError
+	if !errors.As(err, &intervention) || intervention.Title != "Allow test_approve?" {
+		t.Fatalf("expected the intervention to bubble up, got %v", err)
+	}
+	if len(echo.calls) != 1 || echo.calls[0] != "first" {
+		t.Errorf("calls after the intervention must not run, got %v", echo.calls)
+	}
+
+	session := b.loadSession(b.GetSessionID())
+	thread := session.Threads[len(session.Threads)-1]
+	if thread.ID != "e2e-approve" || thread.Metadata["halt_reason"] != "intervention" {
+		t.Fatalf("expected the interrupted thread to be recorded, got %+v", thread)
+	}
+	if len(thread.ToolCalls) != 1 || thread.ToolCalls[0].ToolName != "test_echo" {
+		t.Errorf("expected the finished call to stay on the thread, got %+v", thread.ToolCalls)
+	}
+}
+
+func TestVibeLoop_Replay_MaxTurns(t *testing.T) {
+	var turns []model.CassetteResponse
+	for i := 0; i < 10; i++ {
+		turns = append(turns, echoCall(fmt.Sprintf("call_%d", i), fmt.Sprintf("step %d", i)))
+	}
+	b, echo := replayBrain(t, scriptedCassette(turns...))
+
+	resp, err := b.Process(context.Background(), Request{ID: "e2e-turns", Content: "never finish"})
+	if err != nil {
+		t.Fatalf("Process failed: %v", err)
+	}
+	if !strings.Contains(resp.Content, "(Stopped: Agent loop limit reached)") {
+		t.Errorf("expected the turn limit to stop the loop, got %q", resp.Content)
+	}
+	if len(echo.calls) != 10 {
+		t.Errorf("expected one execution per turn, got %d", len(echo.calls))
+	}
+	budget, ok := resp.Metadata["budget_exhausted"].(*BudgetExhausted)
+	if !ok || budget.Limit != "max_turns" || budget.Turns != 10 || budget.ToolCalls != 10 {
+		t.Fatalf("expected a structured budget result, got %+v", resp.Metadata)
+	}
+	if !strings.HasSuffix(budget.ContinuePrompt(), "never finish") {
+		t.Errorf("the continue prompt must carry the original request: %q", budget.ContinuePrompt())
+	}
+}
+
+func TestVibeLoop_Replay_RequestLimits(t *testing.T) {
+	r := scriptedCassette(
+		echoCall("call_1", "one"),
+		model.CassetteResponse{ToolCalls: []model.ToolCall{
+			{ID: "call_2", Name: "test_echo", Arguments: json.RawMessage(`{"text": "two"}`)},
+			{ID: "call_3", Name: "test_echo", Arguments: json.RawMessage(`{"text": "three"}`)},
+		}},
+	)
+	b, echo := replayBrain(t, r)
+
+	limits := sys.AgentLimits{MaxTurns: 5, MaxToolCalls: 2, MaxOutputBytes: 4}
+	resp, err := b.Process(context.Background(), Request{ID: "e2e-limits", Content: "echo a lot", Limits: limits})
+	if err != nil {
+		t.Fatalf("Process failed: %v", err)
+	}
+	if len(echo.calls) != 1 {
+		t.Errorf("the batch over the tool call limit must not run, got %v", echo.calls)
+	}
+	budget, ok := resp.Metadata["budget_exhausted"].(*BudgetExhausted)
+	if !ok || budget.Limit != "max_tool_calls" || budget.Limits.MaxTurns != 5 {
+		t.Fatalf("expected the tool call limit to stop the run, got %+v", resp.Metadata)
+	}
+
+	threads := b.loadSession(b.GetSessionID()).Threads
+	last := threads[len(threads)-1]
+	if len(last.ToolCalls) != 1 || !strings.HasPrefix(fmt.Sprint(last.ToolCalls[0].Result), "echo\n... (output truncated") {
+		t.Errorf("expected the tool output to be truncated, got %+v", last.ToolCalls)
+	}
+}
+
+func TestGoalMode_InterruptAndResume(t *testing.T) {
+	r := scriptedCassette(
+		model.CassetteResponse{Content: `["Ask for approval", "Echo the result"]`},
+		model.CassetteResponse{Content: "```json\n{\"tool\": \"test_approve\", \"parameters\": {\"text\": \"x\"}}\n```"},
+		model.CassetteResponse{Content: "[MILESTONE_DONE 1]\n```json\n{\"tool\": \"test_echo\", \"parameters\": {\"text\": \"resumed\"}}\n```"},
+		model.CassetteResponse{Content: "[MILESTONE_DONE 2] [TASK_DONE]"},
+	)
+	b, echo := replayBrain(t, r)
+	b.tools.Register(&approvalTool{})
+	b.config.Agent.Mode = "goal"
+
+	_, err := b.Process(context.Background(), Request{ID: "goal-1", Content: "approve then echo"})
+	var interrupted *agent.InterruptedError
+	var intervention *tooling.InterventionError
+	if !errors.As(err, &interrupted) || !errors.As(err, &intervention) {
+		t.Fatalf("expected an interrupted goal waiting for approval, got %v", err)
+	}
+
+	goals, err := b.Goals()
+	if err != nil || len(goals) != 1 {
+		t.Fatalf("expected one saved goal, got %v, %v", goals, err)
+	}
+	saved := goals[0]
+	if saved.Goal.ID != interrupted.GoalID || saved.Goal.Status != agent.StatusInterrupted || saved.Turns != 1 || len(saved.Goal.Milestones) != 2 {
+		t.Fatalf("unexpected checkpoint: %+v", saved)
+	}
+
+	resp, err := b.ResumeGoal(context.Background(), interrupted.GoalID)
+	if err != nil {
+		t.Fatalf("resume failed: %v", err)
+	}
+	if len(echo.calls) != 1 || echo.calls[0] != "resumed" {
+		t.Errorf("expected the resumed turn to run its tool, got %v", echo.calls)
+	}
+	if resp.Metadata["goal_id"] != interrupted.GoalID {
+		t.Errorf("unexpected goal id in metadata: %v", resp.Metadata["goal_id"])
+	}
+
+	goals, _ = b.Goals()
+	done, total := goals[0].Goal.Progress()
+	if goals[0].Goal.Status != agent.StatusCompleted || done != total || goals[0].Turns != 3 {
+		t.Errorf("unexpected final state: %+v", goals[0])
+	}
+	if _, err := b.ResumeGoal(context.Background(), interrupted.GoalID); err == nil {
+		t.Error("a completed goal must not be resumable")
+	}
+}
+
+func TestGoalMode_ToolCallsUseCallbacks(t *testing.T) {
+	r := scriptedCassette(
+		model.CassetteResponse{Content: `["Echo after approval"]`},
+		model.CassetteResponse{Content: "```json\n{\"tool\": \"test_approve\", \"parameters\": {\"text\": \"approved\"}}\n```"},
+		model.CassetteResponse{Content: "[MILESTONE_DONE 1] [TASK_DONE]"},
+	)
+	b, _ := replayBrain(t, r)
+	approve := &resumableApproval{}
+	b.tools.Register(approve)
+	b.config.Agent.Mode = "goal"
+
+	var asked []string
+	var reported []tooling.ToolCall
+	b.OnIntervention = func(tool string, iv *tooling.InterventionError) string {
+		asked = append(asked, tool)
+		return iv.Choices[0]
+	}
+	b.OnToolCall = func(call tooling.ToolCall) { reported = append(reported, call) }
+
+	if _, err := b.Process(context.Background(), Request{ID: "goal-callbacks", Content: "approve then echo"}); err != nil {
+		t.Fatalf("an answered intervention must not interrupt the goal: %v", err)
+	}
+	if len(asked) != 1 || asked[0] != "test_approve" {
+		t.Errorf("expected OnIntervention to answer the approval, got %v", asked)
+	}
+	if len(approve.calls) != 1 || approve.calls[0] != "approved" {
+		t.Errorf("expected the approved call to run, got %v", approve.calls)
+	}
+	if len(reported) != 1 || reported[0].ToolName != "test_approve" || reported[0].Error != "" {
+		t.Errorf("expected the call to be reported, got %+v", reported)
+	}
+	threads := b.loadSession(b.GetSessionID()).Threads
+	if last := threads[len(threads)-1]; len(last.ToolCalls) != 1 {
+		t.Errorf("expected the goal thread to record its tool call, got %+v", last.ToolCalls)
+	}
+}
+
+func TestGoalMode_BudgetExhausted(t *testing.T) {
+	r := scriptedCassette(
+		model.CassetteResponse{Content: `["Echo twice"]`},
+		model.CassetteResponse{Content: "```json\n{\"tool\": \"test_echo\", \"parameters\": {\"text\": \"one\"}}\n```"},
+		model.CassetteResponse{Content: "```json\n{\"tool\": \"test_echo\", \"parameters\": {\"text\": \"two\"}}\n```"},
+	)
+	b, echo := replayBrain(t, r)
+	b.config.Agent.Mode = "goal"
+
+	resp, err := b.Process(context.Background(), Request{ID: "goal-budget", Content: "echo forever", Limits: sys.AgentLimits{MaxTurns: 2}})
+	if err != nil {
+		t.Fatalf("a spent budget must not be an error: %v", err)
+	}
+	budget, ok := resp.Metadata["budget_exhausted"].(*BudgetExhausted)
+	if !ok || budget.Limit != "max_turns" || budget.Turns != 2 || budget.ToolCalls != 2 {
+		t.Fatalf("expected the goal to stop at its turn limit, got %+v", resp.Metadata)
+	}
+	if len(echo.calls) != 2 {
+		t.Errorf("expected one call per turn, got %v", echo.calls)
+	}
+	goalID, _ := resp.Metadata["goal_id"].(string)
+	if goalID == "" || !strings.Contains(resp.Content, "vibeaura resume "+goalID) {
+		t.Errorf("expected the goal to be resumable, got %q", resp.Content)
+	}
+	threads := b.loadSession(b.GetSessionID()).Threads
+	if last := threads[len(threads)-1]; last.ID != "goal-budget" || last.Metadata["halt_reason"] != "budget: max_turns" {
+		t.Errorf("expected a halted thread, got %+v", last)
+	}
+}
+
+// qaOnlyTool is restricted to the QA role.
+type qaOnlyTool struct{ echoTool }
+
+func (q *qaOnlyTool) Metadata() tooling.ToolMetadata {
+	meta := q.echoTool.Metadata()
+	meta.Name = "test_qa_only"
+	meta.Roles = []tooling.AgentRole{tooling.RoleQA}
+	return meta
+}
+
+func TestOrchestrateMode_ReviewLoop(t *testing.T) {
+	r := scriptedCassette(
+		model.CassetteResponse{Content: `Plan: [{"role": "coder", "task": "echo one"}]`},
+		model.CassetteResponse{ToolCalls: []model.ToolCall{
+			{ID: "call_1", Name: "test_echo", Arguments: json.RawMessage(`{"text": "one"}`)},
+			{ID: "call_2", Name: "test_qa_only", Arguments: json.RawMessage(`{"text": "sneaky"}`)},
+		}},
+		model.CassetteResponse{Content: "echoed one"},
+		model.CassetteResponse{Content: "The second echo is missing.\nVERDICT: FAIL"},
+		model.CassetteResponse{Content: "fixed it"},
+		model.CassetteResponse{Content: "Looks good.\nVERDICT: PASS"},
+	)
+	b, echo := replayBrain(t, r)
+	qa := &qaOnlyTool{}
+	b.tools.Register(qa)
+	b.config.Agent.Mode = "orchestrate"
+
+	resp, err := b.Process(context.Background(), Request{ID: "team-1", Content: "echo one"})
+	if err != nil {
+		t.Fatalf("Process failed: %v", err)
+	}
+	if len(echo.calls) != 1 || echo.calls[0] != "one" {
+		t.Errorf("expected the coder to echo once, got %v", echo.calls)
+	}
+	if len(qa.calls) != 0 {
+		t.Errorf("the coder must not run QA-only tools, got %v", qa.calls)
+	}
+	if resp.Metadata["qa_passed"] != true || resp.Metadata["review_rounds"] != 2 {
+		t.Errorf("unexpected metadata: %v", resp.Metadata)
+	}
+
+	threads := b.loadSession(b.GetSessionID()).Threads
+	parent := threads[len(threads)-1]
+	var roles []string
+	for _, child := range parent.Children {
+		roles = append(roles, child.Role)
+		if len(child.Transcript) < 3 {
+			t.Errorf("%s transcript was not recorded: %+v", child.ID, child.Transcript)
+		}
+	}
+	if got := strings.Join(roles, ","); got != "architect,coder,qa,coder,qa" {
+		t.Fatalf("unexpected sub-agents: %s", got)
+	}
+	coder := parent.Children[1]
+	if len(coder.ToolCalls) != 2 || !strings.Contains(coder.ToolCalls[1].Error, "not found") {
+		t.Errorf("expected the QA-only tool to be unavailable to the coder, got %+v", coder.ToolCalls)
+	}
+}
+
+func TestOrchestrateMode_ReworkGoesToEachFailedSubtask(t *testing.T) {
+	r := scriptedCassette(
+		model.CassetteResponse{Content: `[{"role": "coder", "task": "edit a"}, {"role": "engineer", "task": "build b"}, {"role": "coder", "task": "edit c"}]`},
+		model.CassetteResponse{Content: "edited a"},
+		model.CassetteResponse{Content: "built b"},
+		model.CassetteResponse{Content: "edited c"},
+		model.CassetteResponse{Content: "VERDICT: FAIL\nFAILED 1: a is wrong\nFAILED 2: b does not build"},
+		model.CassetteResponse{Content: "fixed a"},
+		model.CassetteResponse{Content: "fixed b"},
+		model.CassetteResponse{Content: "VERDICT: FAIL\nFAILED 2: b still does not build"},
+		model.CassetteResponse{Content: "fixed b again"},
+		model.CassetteResponse{Content: "VERDICT: FAIL\nFAILED 2: b still does not build"},
+	)
+	b, _ := replayBrain(t, r)
+	b.config.Agent.Mode = "orchestrate"
+
+	resp, err := b.Process(context.Background(), Request{ID: "team-rework", Content: "change a, b and c"})
+	if err != nil {
+		t.Fatalf("Process failed: %v", err)
+	}
+	if resp.Metadata["qa_passed"] != false || resp.Metadata["review_rounds"] != 3 {
+		t.Errorf("unexpected metadata: %v", resp.Metadata)
+	}
+	if !strings.Contains(resp.Content, "Unresolved subtasks:\n2. [engineer] build b: b still does not build\n") {
+		t.Errorf("expected the unresolved subtask to be reported, got:\n%s", resp.Content)
+	}
+
+	threads := b.loadSession(b.GetSessionID()).Threads
+	parent := threads[len(threads)-1]
+	var roles []string
+	for _, child := range parent.Children {
+		roles = append(roles, child.Role)
+	}
+	if got := strings.Join(roles, ","); got != "architect,coder,engineer,coder,qa,coder,engineer,qa,engineer,qa" {
+		t.Fatalf("expected each failed subtask to go back to its own executor, got %s", got)
+	}
+	if rework := parent.Children[6]; !strings.Contains(rework.Prompt, "(2/3): build b") || !strings.Contains(rework.Prompt, "b does not build") || strings.Contains(rework.Prompt, "a is wrong") {
+		t.Errorf("the rework should carry only its own subtask's problems, got %q", rework.Prompt)
+	}
+	if fmt.Sprint(parent.Metadata["unresolved"]) != "[2]" || !strings.Contains(parent.Response, "Unresolved subtasks") {
+		t.Errorf("expected the thread to record the unresolved subtasks, got %v", parent.Metadata)
+	}
+}
+
+func TestOrchestrateMode_BudgetExhausted(t *testing.T) {
+	r := scriptedCassette(
+		model.CassetteResponse{Content: `[{"role": "coder", "task": "echo"}]`},
+		echoCall("call_1", "one"),
+		model.CassetteResponse{ToolCalls: []model.ToolCall{
+			{ID: "call_2", Name: "test_echo", Arguments: json.RawMessage(`{"text": "two"}`)},
+			{ID: "call_3", Name: "test_echo", Arguments: json.RawMessage(`{"text": "three"}`)},
+		}},
+	)
+	b, echo := replayBrain(t, r)
+	b.config.Agent.Mode = "orchestrate"
+
+	resp, err := b.Process(context.Background(), Request{ID: "team-budget", Content: "echo a lot", Limits: sys.AgentLimits{MaxToolCalls: 2, MaxOutputBytes: 4}})
+	if err != nil {
+		t.Fatalf("a spent budget must not be an error: %v", err)
+	}
+	budget, ok := resp.Metadata["budget_exhausted"].(*BudgetExhausted)
+	if !ok || budget.Limit != "max_tool_calls" || budget.ToolCalls != 1 {
+		t.Fatalf("expected the team to stop at its tool call limit, got %+v", resp.Metadata)
+	}
+	if len(echo.calls) != 1 {
+		t.Errorf("the batch over the limit must not run, got %v", echo.calls)
+	}
+
+	threads := b.loadSession(b.GetSessionID()).Threads
+	parent := threads[len(threads)-1]
+	if parent.Metadata["halt_reason"] != "budget: max_tool_calls" || len(parent.ToolCalls) != 1 {
+		t.Fatalf("expected a halted thread with the finished call, got %+v", parent)
+	}
+	if !strings.HasPrefix(fmt.Sprint(parent.ToolCalls[0].Result), "echo\n... (output truncated") {
+		t.Errorf("expected the run's output limit to apply, got %v", parent.ToolCalls[0].Result)
+	}
+}
+
+// resumableApproval asks for approval and runs once the intervention is answered "yes".
+type resumableApproval struct{ approvalTool }
+
+func (a *resumableApproval) Execute(ctx context.Context, args json.RawMessage) (*tooling.ToolResult, error) {
+	return nil, &tooling.InterventionError{
+		Title:   "Allow test_approve?",
+		Choices: []string{"yes", "no"},
+		Risk:    "high",
+		Resume: func(ctx context.Context, choice string) (*tooling.ToolResult, error) {
+			if choice != "yes" {
+				return nil, errors.New("security: user denied test_approve")
+			}
+			return a.echoTool.Execute(ctx, args)
+		},
+	}
+}
+
+func TestVibeLoop_Replay_InterventionAnsweredByCallback(t *testing.T) {
+	r := scriptedCassette(
+		model.CassetteResponse{ToolCalls: []model.ToolCall{{ID: "call_1", Name: "test_approve", Arguments: json.RawMessage(`{"text": "risky"}`)}}},
+		model.CassetteResponse{Content: "It was denied, so I stopped."},
+	)
+	b, echo := replayBrain(t, r)
+	b.tools.Register(&resumableApproval{})
+
+	var asked []string
+	var reported []tooling.ToolCall
+	b.OnIntervention = func(tool string, iv *tooling.InterventionError) string {
+		asked = append(asked, tool+":"+iv.Risk)
+		return iv.Choices[len(iv.Choices)-1]
+	}
+	b.OnToolCall = func(call tooling.ToolCall) { reported = append(reported, call) }
+
+	resp, err := b.Process(context.Background(), Request{ID: "e2e-headless", Content: "do something risky"})
+	if err != nil {
+		t.Fatalf("an answered intervention must not bubble up: %v", err)
+	}
+	if resp.Content != "It was denied, so I stopped." || len(echo.calls) != 0 {
+		t.Errorf("unexpected result %q, calls %v", resp.Content, echo.calls)
+	}
+	if len(asked) != 1 || asked[0] != "test_approve:high" {
+		t.Errorf("unexpected interventions: %v", asked)
+	}
+	if len(reported) != 1 || !strings.Contains(reported[0].Error, "user denied") {
+		t.Errorf("expected the denied call to be reported, got %+v", reported)
+	}
+}
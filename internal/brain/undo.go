@@ -0,0 +1,15 @@
+package brain
+
+import "github.com/nathfavour/vibeauracle/tooling"
+
+// Changes lists the journaled agent turns of the current session, newest first.
+func (b *Brain) Changes() ([]*tooling.JournalRecord, error) {
+	return b.journal.Records(b.GetSessionID())
+}
+
+// Undo restores the files changed by the last agent turn of the current session, or by
+// the thread whose ID starts with thread. Files modified outside the agent since its
+// last write are reported as conflicts and only restored when force is set.
+func (b *Brain) Undo(thread string, force bool) (*tooling.UndoResult, error) {
+	return b.journal.Undo(b.GetSessionID(), thread, force)
+}
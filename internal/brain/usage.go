@@ -0,0 +1,112 @@
+package brain
+
+import (
+	"sort"
+	"time"
+
+	"github.com/nathfavour/vibeauracle/model"
+	"github.com/nathfavour/vibeauracle/tooling"
+)
+
+// usageDayPrefix keys the per-day usage ledgers in the state store.
+const usageDayPrefix = "usage_day:"
+
+// DailyUsage is the usage ledger for one calendar day, keyed by working directory.
+type DailyUsage struct {
+	Date        string                         `json:"date"`
+	Directories map[string]tooling.UsageReport `json:"directories"`
+}
+
+// Total sums the usage of every directory for the day.
+func (d DailyUsage) Total() tooling.UsageReport {
+	var total tooling.UsageReport
+	for _, r := range d.Directories {
+		total.Merge(r)
+	}
+	return total
+}
+
+// loadSession returns the in-memory session for id, restoring it from the state store if needed.
+func (b *Brain) loadSession(id string) *tooling.Session {
+	if session, ok := b.sessions[id]; ok {
+		return session
+	}
+	session := tooling.NewSession(id)
+	var stored tooling.Session
+	if err := b.RecallState(id+"_obj", &stored); err == nil {
+		session = &stored
+	}
+	b.sessions[id] = session
+	return session
+}
+
+// recordUsage prices one model request and adds it to the thread, the session
+// and today's ledger. Sessions are persisted right away so usage survives
+// interrupted or failed turns.
+func (b *Brain) recordUsage(session *tooling.Session, thread *tooling.UsageReport, provider string, u model.Usage) {
+	modelName := b.modelNameFor(provider)
+	entry := tooling.Usage{
+		PromptTokens:     u.PromptTokens,
+		CompletionTokens: u.CompletionTokens,
+		Requests:         1,
+		Estimated:        u.Estimated,
+	}
+	if price, ok := b.config.PriceFor(provider, modelName); ok {
+		entry.Cost = price.Cost(u.PromptTokens, u.CompletionTokens)
+	} else {
+		entry.Unpriced = true
+	}
+
+	thread.Record(provider, modelName, entry)
+	session.RecordUsage(provider, modelName, entry)
+	_ = b.StoreState(session.ID+"_obj", session)
+
+	day := time.Now().Format(time.DateOnly)
+	var ledger DailyUsage
+	if err := b.RecallState(usageDayPrefix+day, &ledger); err != nil || ledger.Directories == nil {
+		ledger = DailyUsage{Date: day, Directories: make(map[string]tooling.UsageReport)}
+	}
+	dir := b.GetSessionPath()
+	r := ledger.Directories[dir]
+	r.Record(provider, modelName, entry)
+	ledger.Directories[dir] = r
+	_ = b.StoreState(usageDayPrefix+day, ledger)
+}
+
+// modelNameFor returns the configured model of a provider in the fallback chain.
+func (b *Brain) modelNameFor(provider string) string {
+	if provider == b.config.Model.Provider {
+		return b.config.Model.Name
+	}
+	for _, fb := range b.config.Model.Fallbacks {
+		if fb.Provider == provider {
+			return fb.Name
+		}
+	}
+	return b.config.Model.Name
+}
+
+// SessionUsage returns the token and cost totals of the current directory session.
+func (b *Brain) SessionUsage() tooling.UsageReport {
+	return b.loadSession(b.GetSessionID()).Usage
+}
+
+// UsageHistory returns the daily ledgers of the last n days, newest first.
+func (b *Brain) UsageHistory(days int) ([]DailyUsage, error) {
+	ids, err := b.memory.ListStates(usageDayPrefix)
+	if err != nil {
+		return nil, err
+	}
+	cutoff := time.Now().AddDate(0, 0, -days+1).Format(time.DateOnly)
+
+	var out []DailyUsage
+	for _, id := range ids {
+		var ledger DailyUsage
+		if err := b.RecallState(id, &ledger); err != nil || ledger.Date < cutoff {
+			continue
+		}
+		out = append(out, ledger)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Date > out[j].Date })
+	return out, nil
+}
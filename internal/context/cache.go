@@ -0,0 +1,127 @@
+package context
+
+import (
+	"fmt"
+	"time"
+)
+
+// DefaultCacheMaxBytes bounds the response cache until SetCacheLimit is called.
+const DefaultCacheMaxBytes = 50 << 20
+
+// CacheStats summarizes the response cache.
+type CacheStats struct {
+	Entries int64
+	Bytes   int64
+	Hits    int64
+	Oldest  time.Time
+	Newest  time.Time
+}
+
+// SetCacheLimit sets the total size the response cache may grow to before the
+// least recently used entries are evicted.
+func (m *Memory) SetCacheLimit(maxBytes int64) {
+	m.cacheMaxBytes = maxBytes
+}
+
+// CacheGet returns a cached response younger than ttl. Expired entries are removed.
+func (m *Memory) CacheGet(key string, ttl time.Duration) (string, bool) {
+	if m.db == nil {
+		return "", false
+	}
+	var value string
+	var created int64
+	err := m.db.QueryRow("SELECT value, created_at FROM response_cache WHERE key = ?", key).Scan(&value, &created)
+	if err != nil {
+		return "", false
+	}
+	if ttl > 0 && time.Since(time.Unix(created, 0)) > ttl {
+		_, _ = m.db.Exec("DELETE FROM response_cache WHERE key = ?", key)
+		return "", false
+	}
+	_, _ = m.db.Exec("UPDATE response_cache SET hits = hits + 1, last_used = ? WHERE key = ?", time.Now().Unix(), key)
+	return value, true
+}
+
+// CachePut stores a response and evicts least recently used entries over the size limit.
+func (m *Memory) CachePut(key, provider, model, value string) error {
+	if m.db == nil {
+		return fmt.Errorf("database not initialized")
+	}
+	now := time.Now().Unix()
+	_, err := m.db.Exec(`
+		INSERT OR REPLACE INTO response_cache (key, provider, model, value, size, hits, created_at, last_used)
+		VALUES (?, ?, ?, ?, ?, 0, ?, ?)`,
+		key, provider, model, value, len(value), now, now)
+	if err != nil {
+		return err
+	}
+	return m.evictCache()
+}
+
+func (m *Memory) evictCache() error {
+	limit := m.cacheMaxBytes
+	if limit <= 0 {
+		limit = DefaultCacheMaxBytes
+	}
+	var total int64
+	if err := m.db.QueryRow("SELECT COALESCE(SUM(size), 0) FROM response_cache").Scan(&total); err != nil {
+		return err
+	}
+	if total <= limit {
+		return nil
+	}
+
+	rows, err := m.db.Query("SELECT key, size FROM response_cache ORDER BY last_used ASC")
+	if err != nil {
+		return err
+	}
+	var victims []string
+	for rows.Next() && total > limit {
+		var key string
+		var size int64
+		if err := rows.Scan(&key, &size); err == nil {
+			victims = append(victims, key)
+			total -= size
+		}
+	}
+	rows.Close()
+
+	for _, key := range victims {
+		if _, err := m.db.Exec("DELETE FROM response_cache WHERE key = ?", key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CacheStats reports the size and hit count of the response cache.
+func (m *Memory) CacheStats() (CacheStats, error) {
+	var st CacheStats
+	if m.db == nil {
+		return st, fmt.Errorf("database not initialized")
+	}
+	var oldest, newest int64
+	err := m.db.QueryRow(`
+		SELECT COUNT(*), COALESCE(SUM(size), 0), COALESCE(SUM(hits), 0),
+		       COALESCE(MIN(created_at), 0), COALESCE(MAX(created_at), 0)
+		FROM response_cache`).Scan(&st.Entries, &st.Bytes, &st.Hits, &oldest, &newest)
+	if err != nil {
+		return st, err
+	}
+	if st.Entries > 0 {
+		st.Oldest, st.Newest = time.Unix(oldest, 0), time.Unix(newest, 0)
+	}
+	return st, nil
+}
+
+// CacheClear removes every cached response and returns how many were dropped.
+func (m *Memory) CacheClear() (int64, error) {
+	if m.db == nil {
+		return 0, fmt.Errorf("database not initialized")
+	}
+	res, err := m.db.Exec("DELETE FROM response_cache")
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
@@ -0,0 +1,96 @@
+package context
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestMemory(t *testing.T) *Memory {
+	t.Helper()
+	t.Setenv("HOME", t.TempDir())
+	m := NewMemory()
+	if m.db == nil {
+		t.Fatal("database not initialized")
+	}
+	t.Cleanup(func() { m.db.Close() })
+	return m
+}
+
+func TestMemory_CacheGetPut(t *testing.T) {
+	m := newTestMemory(t)
+
+	if _, ok := m.CacheGet("missing", time.Hour); ok {
+		t.Fatal("expected a miss for an unknown key")
+	}
+	if err := m.CachePut("k1", "ollama", "llama3", "reply"); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if v, ok := m.CacheGet("k1", time.Hour); !ok || v != "reply" {
+			t.Fatalf("hit %d: got %q, %v", i, v, ok)
+		}
+	}
+
+	st, err := m.CacheStats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.Entries != 1 || st.Bytes != int64(len("reply")) || st.Hits != 2 || st.Oldest.IsZero() {
+		t.Errorf("unexpected stats: %+v", st)
+	}
+}
+
+func TestMemory_CacheExpires(t *testing.T) {
+	m := newTestMemory(t)
+	if err := m.CachePut("old", "ollama", "llama3", "stale"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.db.Exec("UPDATE response_cache SET created_at = ? WHERE key = ?", time.Now().Add(-2*time.Hour).Unix(), "old"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := m.CacheGet("old", 0); !ok {
+		t.Error("a zero ttl should never expire entries")
+	}
+	if _, ok := m.CacheGet("old", time.Hour); ok {
+		t.Error("expected the entry to have expired")
+	}
+	if st, _ := m.CacheStats(); st.Entries != 0 {
+		t.Errorf("expected the expired entry to be removed, got %d entries", st.Entries)
+	}
+}
+
+func TestMemory_CacheEvictsLeastRecentlyUsed(t *testing.T) {
+	m := newTestMemory(t)
+	m.SetCacheLimit(25)
+
+	value := strings.Repeat("x", 10)
+	for i, key := range []string{"a", "b"} {
+		if err := m.CachePut(key, "p", "m", value); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := m.db.Exec("UPDATE response_cache SET last_used = ? WHERE key = ?", int64(100+i), key); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Reading a makes b the least recently used entry.
+	m.CacheGet("a", 0)
+	if err := m.CachePut("c", "p", "m", value); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := m.CacheGet("b", 0); ok {
+		t.Error("expected the least recently used entry to be evicted")
+	}
+	for _, key := range []string{"a", "c"} {
+		if _, ok := m.CacheGet(key, 0); !ok {
+			t.Errorf("expected %s to be kept", key)
+		}
+	}
+
+	n, err := m.CacheClear()
+	if err != nil || n != 2 {
+		t.Errorf("expected two entries to be cleared, got %d (%v)", n, err)
+	}
+}
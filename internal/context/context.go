@@ -141,6 +141,8 @@ func (w *Window) GetContext() string {
 type Memory struct {
 	db     *sql.DB
 	Window *Window
+
+	cacheMaxBytes int64
 }
 
 func NewMemory() *Memory {
@@ -174,6 +176,16 @@ func NewMemory() *Memory {
 			logical_map TEXT,
 			last_indexed TIMESTAMP DEFAULT CURRENT_TIMESTAMP
 		);
+		CREATE TABLE IF NOT EXISTS response_cache (
+			key TEXT PRIMARY KEY,
+			provider TEXT,
+			model TEXT,
+			value TEXT,
+			size INTEGER,
+			hits INTEGER DEFAULT 0,
+			created_at INTEGER,
+			last_used INTEGER
+		);
 	`)
 	if err != nil {
 		fmt.Printf("Error initializing database tables: %v\n", err)
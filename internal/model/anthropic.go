@@ -0,0 +1,392 @@
+package model
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+const (
+	AnthropicBaseURL = "https://api.anthropic.com/v1"
+
+	anthropicVersion          = "2023-06-01"
+	anthropicDefaultModel     = "claude-sonnet-4-5"
+	anthropicDefaultMaxTokens = 8192
+)
+
+func init() {
+	Register("anthropic", func(config map[string]string) (Provider, error) {
+		return NewAnthropicProvider(config["api_key"], config["model"], config["base_url"])
+	})
+}
+
+// AnthropicProvider implements the Provider interface for the Anthropic Messages API
+type AnthropicProvider struct {
+	apiKey    string
+	model     string
+	baseURL   string
+	maxTokens int
+	client    *http.Client
+}
+
+func (p *AnthropicProvider) Name() string { return "anthropic" }
+
+// NewAnthropicProvider creates a new Anthropic provider
+func NewAnthropicProvider(apiKey string, modelName string, baseURL string) (*AnthropicProvider, error) {
+	if apiKey == "" {
+		return nil, fmt.Errorf("anthropic init: api key is required")
+	}
+	if modelName == "" {
+		modelName = anthropicDefaultModel
+	}
+	if baseURL == "" {
+		baseURL = AnthropicBaseURL
+	}
+
+	return &AnthropicProvider{
+		apiKey:    apiKey,
+		model:     modelName,
+		baseURL:   strings.TrimSuffix(baseURL, "/"),
+		maxTokens: anthropicDefaultMaxTokens,
+		client:    http.DefaultClient,
+	}, nil
+}
+
+// Generate sends a prompt to Anthropic and returns the response
+func (p *AnthropicProvider) Generate(ctx context.Context, prompt string) (string, error) {
+	resp, err := p.Chat(ctx, []Message{{Role: RoleUser, Content: prompt}}, ChatOptions{})
+	return resp.Content, err
+}
+
+// GenerateWithTools sends a prompt with native tool schemas to Anthropic
+func (p *AnthropicProvider) GenerateWithTools(ctx context.Context, prompt string, tools []ToolDefinition) (string, []ToolCall, error) {
+	resp, err := p.Chat(ctx, []Message{{Role: RoleUser, Content: prompt}}, ChatOptions{Tools: tools})
+	return resp.Content, resp.ToolCalls, err
+}
+
+// GenerateStream streams the response from Anthropic, calling onDelta for each text delta
+func (p *AnthropicProvider) GenerateStream(ctx context.Context, prompt string, onDelta StreamFunc) (string, error) {
+	resp, err := p.Chat(ctx, []Message{{Role: RoleUser, Content: prompt}}, ChatOptions{OnDelta: onDelta})
+	return resp.Content, err
+}
+
+// GenerateWithToolsStream streams text deltas while collecting native tool calls
+func (p *AnthropicProvider) GenerateWithToolsStream(ctx context.Context, prompt string, tools []ToolDefinition, onDelta StreamFunc) (string, []ToolCall, error) {
+	resp, err := p.Chat(ctx, []Message{{Role: RoleUser, Content: prompt}}, ChatOptions{Tools: tools, OnDelta: onDelta})
+	return resp.Content, resp.ToolCalls, err
+}
+
+// anthropicBlock is a content block in a Messages API request or response.
+type anthropicBlock struct {
+	Type      string          `json:"type"`
+	Text      string          `json:"text,omitempty"`
+	ID        string          `json:"id,omitempty"`
+	Name      string          `json:"name,omitempty"`
+	Input     json.RawMessage `json:"input,omitempty"`
+	ToolUseID string          `json:"tool_use_id,omitempty"`
+	Content   string          `json:"content,omitempty"`
+}
+
+type anthropicMessage struct {
+	Role    string           `json:"role"`
+	Content []anthropicBlock `json:"content"`
+}
+
+type anthropicTool struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	InputSchema json.RawMessage `json:"input_schema"`
+}
+
+type anthropicRequest struct {
+	Model     string             `json:"model"`
+	MaxTokens int                `json:"max_tokens"`
+	System    string             `json:"system,omitempty"`
+	Messages  []anthropicMessage `json:"messages"`
+	Tools     []anthropicTool    `json:"tools,omitempty"`
+	Stream    bool               `json:"stream,omitempty"`
+}
+
+type anthropicUsage struct {
+	InputTokens  int `json:"input_tokens"`
+	OutputTokens int `json:"output_tokens"`
+}
+
+type anthropicResponse struct {
+	Content    []anthropicBlock `json:"content"`
+	StopReason string           `json:"stop_reason"`
+	Usage      anthropicUsage   `json:"usage"`
+}
+
+// Chat sends a role-separated conversation to the Messages API.
+// A non-nil opts.OnDelta enables server-sent event streaming.
+func (p *AnthropicProvider) Chat(ctx context.Context, messages []Message, opts ChatOptions) (ChatResponse, error) {
+	system, msgs := toAnthropicMessages(messages)
+	reqBody := anthropicRequest{
+		Model:     p.model,
+		MaxTokens: p.maxTokens,
+		System:    system,
+		Messages:  msgs,
+		Stream:    opts.OnDelta != nil,
+	}
+	for _, t := range opts.Tools {
+		reqBody.Tools = append(reqBody.Tools, anthropicTool{
+			Name:        t.Name,
+			Description: t.Description,
+			InputSchema: schemaOrEmpty(t.Parameters),
+		})
+	}
+
+	payload, err := json.Marshal(reqBody)
+	if err != nil {
+		return ChatResponse{}, fmt.Errorf("anthropic chat: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", p.baseURL+"/messages", bytes.NewReader(payload))
+	if err != nil {
+		return ChatResponse{}, fmt.Errorf("anthropic chat: %w", err)
+	}
+	p.setHeaders(req)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return ChatResponse{}, fmt.Errorf("anthropic chat: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return ChatResponse{}, fmt.Errorf("anthropic chat: %w", anthropicError(resp))
+	}
+
+	var out anthropicResponse
+	if opts.OnDelta != nil {
+		out, err = readAnthropicStream(ctx, resp.Body, opts.OnDelta)
+	} else {
+		err = json.NewDecoder(resp.Body).Decode(&out)
+	}
+	if err != nil {
+		return ChatResponse{}, fmt.Errorf("anthropic chat: %w", err)
+	}
+
+	chat := fromAnthropicBlocks(out.Content)
+	chat.Usage = Usage{PromptTokens: out.Usage.InputTokens, CompletionTokens: out.Usage.OutputTokens}
+	return chat, nil
+}
+
+// ListModels returns a list of available models from Anthropic
+func (p *AnthropicProvider) ListModels(ctx context.Context) ([]string, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", p.baseURL+"/models?limit=1000", nil)
+	if err != nil {
+		return nil, err
+	}
+	p.setHeaders(req)
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fetching anthropic models: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusUnauthorized {
+		return nil, fmt.Errorf("anthropic api key is invalid or expired")
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("anthropic models list failed: %w", anthropicError(resp))
+	}
+
+	var data struct {
+		Data []struct {
+			ID string `json:"id"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
+		return nil, fmt.Errorf("decoding anthropic models: %w", err)
+	}
+
+	var models []string
+	for _, m := range data.Data {
+		models = append(models, m.ID)
+	}
+	return models, nil
+}
+
+func (p *AnthropicProvider) setHeaders(req *http.Request) {
+	req.Header.Set("x-api-key", p.apiKey)
+	req.Header.Set("anthropic-version", anthropicVersion)
+}
+
+// anthropicError extracts the API's error message from a failed response.
+func anthropicError(resp *http.Response) error {
+	var body struct {
+		Error struct {
+			Type    string `json:"type"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	se := &StatusError{StatusCode: resp.StatusCode, Status: resp.Status}
+	raw, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	if err := json.Unmarshal(raw, &body); err == nil && body.Error.Message != "" {
+		se.Message = fmt.Sprintf("%s (%s)", body.Error.Message, body.Error.Type)
+	}
+	return se
+}
+
+// toAnthropicMessages splits out the system prompt and converts the rest of the
+// conversation. Tool results become tool_result blocks on a user turn, and adjacent
+// turns with the same role are merged as the API expects.
+func toAnthropicMessages(messages []Message) (string, []anthropicMessage) {
+	var system []string
+	var out []anthropicMessage
+
+	add := func(role string, blocks ...anthropicBlock) {
+		if len(blocks) == 0 {
+			return
+		}
+		if n := len(out); n > 0 && out[n-1].Role == role {
+			out[n-1].Content = append(out[n-1].Content, blocks...)
+			return
+		}
+		out = append(out, anthropicMessage{Role: role, Content: blocks})
+	}
+
+	for _, msg := range messages {
+		switch msg.Role {
+		case RoleSystem:
+			system = append(system, msg.Content)
+		case RoleAssistant:
+			var blocks []anthropicBlock
+			if msg.Content != "" {
+				blocks = append(blocks, anthropicBlock{Type: "text", Text: msg.Content})
+			}
+			for _, c := range msg.ToolCalls {
+				blocks = append(blocks, anthropicBlock{Type: "tool_use", ID: c.ID, Name: c.Name, Input: toolInput(c.Arguments)})
+			}
+			add("assistant", blocks...)
+		case RoleTool:
+			add("user", anthropicBlock{Type: "tool_result", ToolUseID: msg.ToolCallID, Content: msg.Content})
+		default:
+			if msg.Content != "" {
+				add("user", anthropicBlock{Type: "text", Text: msg.Content})
+			}
+		}
+	}
+
+	return strings.Join(system, "\n\n"), out
+}
+
+// toolInput guarantees tool_use input is a JSON object.
+func toolInput(args json.RawMessage) json.RawMessage {
+	if len(bytes.TrimSpace(args)) == 0 {
+		return json.RawMessage("{}")
+	}
+	return args
+}
+
+func fromAnthropicBlocks(blocks []anthropicBlock) ChatResponse {
+	var out ChatResponse
+	var text strings.Builder
+	for _, b := range blocks {
+		switch b.Type {
+		case "text":
+			text.WriteString(b.Text)
+		case "tool_use":
+			out.ToolCalls = append(out.ToolCalls, ToolCall{ID: b.ID, Name: b.Name, Arguments: toolInput(b.Input)})
+		}
+	}
+	out.Content = text.String()
+	return out
+}
+
+// readAnthropicStream consumes Messages API server-sent events, forwarding text
+// deltas and reassembling content blocks (including streamed tool input JSON) and usage.
+func readAnthropicStream(ctx context.Context, body io.Reader, onDelta StreamFunc) (anthropicResponse, error) {
+	var blocks []anthropicBlock
+	var partialInput []string
+	var usage anthropicUsage
+
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		if err := ctx.Err(); err != nil {
+			return anthropicResponse{}, err
+		}
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+		data := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+
+		var event struct {
+			Type         string          `json:"type"`
+			Index        int             `json:"index"`
+			ContentBlock *anthropicBlock `json:"content_block"`
+			Message      struct {
+				Usage anthropicUsage `json:"usage"`
+			} `json:"message"`
+			Usage anthropicUsage `json:"usage"`
+			Delta struct {
+				Type        string `json:"type"`
+				Text        string `json:"text"`
+				PartialJSON string `json:"partial_json"`
+			} `json:"delta"`
+			Error struct {
+				Type    string `json:"type"`
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		if err := json.Unmarshal([]byte(data), &event); err != nil {
+			return anthropicResponse{}, fmt.Errorf("decoding stream event: %w", err)
+		}
+
+		switch event.Type {
+		case "message_start":
+			usage.InputTokens = event.Message.Usage.InputTokens
+		case "message_delta":
+			if event.Usage.OutputTokens > 0 {
+				usage.OutputTokens = event.Usage.OutputTokens
+			}
+		case "content_block_start":
+			for len(blocks) <= event.Index {
+				blocks = append(blocks, anthropicBlock{})
+				partialInput = append(partialInput, "")
+			}
+			if event.ContentBlock != nil {
+				blocks[event.Index] = *event.ContentBlock
+			}
+		case "content_block_delta":
+			if event.Index >= len(blocks) {
+				continue
+			}
+			switch event.Delta.Type {
+			case "text_delta":
+				blocks[event.Index].Text += event.Delta.Text
+				if event.Delta.Text != "" {
+					onDelta(event.Delta.Text)
+				}
+			case "input_json_delta":
+				partialInput[event.Index] += event.Delta.PartialJSON
+			}
+		case "content_block_stop":
+			if event.Index < len(blocks) && partialInput[event.Index] != "" {
+				blocks[event.Index].Input = json.RawMessage(partialInput[event.Index])
+			}
+		case "error":
+			return anthropicResponse{}, fmt.Errorf("stream error: %s (%s)", event.Error.Message, event.Error.Type)
+		case "message_stop":
+			return anthropicResponse{Content: blocks, Usage: usage}, nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return anthropicResponse{}, err
+	}
+	if err := ctx.Err(); err != nil {
+		return anthropicResponse{}, err
+	}
+	return anthropicResponse{Content: blocks, Usage: usage}, nil
+}
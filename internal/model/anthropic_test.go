@@ -0,0 +1,203 @@
+package model
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// newAnthropicServer fakes the Messages API, recording the last request body.
+func newAnthropicServer(t *testing.T, lastBody *map[string]any, handler func(w http.ResponseWriter, body map[string]any)) *httptest.Server {
+	t.Helper()
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("x-api-key") != "test-key" || r.Header.Get("anthropic-version") == "" {
+			w.WriteHeader(http.StatusUnauthorized)
+			io.WriteString(w, `{"type":"error","error":{"type":"authentication_error","message":"invalid x-api-key"}}`)
+			return
+		}
+		switch r.URL.Path {
+		case "/models":
+			io.WriteString(w, `{"data":[{"id":"claude-sonnet-4-5","type":"model"},{"id":"claude-haiku-4-5","type":"model"}],"has_more":false}`)
+		case "/messages":
+			raw, _ := io.ReadAll(r.Body)
+			var body map[string]any
+			_ = json.Unmarshal(raw, &body)
+			if lastBody != nil {
+				*lastBody = body
+			}
+			handler(w, body)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+}
+
+func TestAnthropicProvider_Generate(t *testing.T) {
+	var body map[string]any
+	srv := newAnthropicServer(t, &body, func(w http.ResponseWriter, _ map[string]any) {
+		io.WriteString(w, `{"id":"msg_1","type":"message","role":"assistant","content":[{"type":"text","text":"Hello from Claude"}],"stop_reason":"end_turn","usage":{"input_tokens":5,"output_tokens":3}}`)
+	})
+	defer srv.Close()
+
+	p, err := NewAnthropicProvider("test-key", "", srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := p.Generate(context.Background(), "hi")
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	if resp != "Hello from Claude" {
+		t.Errorf("got %q", resp)
+	}
+	if body["model"] != anthropicDefaultModel || body["max_tokens"] == nil {
+		t.Errorf("unexpected request: %v", body)
+	}
+}
+
+func TestAnthropicProvider_ChatWithTools(t *testing.T) {
+	var body map[string]any
+	srv := newAnthropicServer(t, &body, func(w http.ResponseWriter, _ map[string]any) {
+		io.WriteString(w, `{"content":[{"type":"text","text":"Let me read it."},{"type":"tool_use","id":"call_abc","name":"sys_read_file","input":{"path":"README.md"}}],"stop_reason":"tool_use","usage":{"input_tokens":20,"output_tokens":9}}`)
+	})
+	defer srv.Close()
+
+	p, err := NewAnthropicProvider("test-key", "claude-sonnet-4-5", srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := p.Chat(context.Background(), toolConversation, ChatOptions{Tools: []ToolDefinition{readFileTool}})
+	if err != nil {
+		t.Fatalf("Chat failed: %v", err)
+	}
+	if resp.Content != "Let me read it." {
+		t.Errorf("got content %q", resp.Content)
+	}
+	if resp.Usage != (Usage{PromptTokens: 20, CompletionTokens: 9}) {
+		t.Errorf("got usage %+v", resp.Usage)
+	}
+	assertReadFileCall(t, resp.ToolCalls)
+
+	if body["system"] != "You are helpful." {
+		t.Errorf("system prompt was not lifted out of messages: %v", body["system"])
+	}
+	tools, _ := body["tools"].([]any)
+	if len(tools) != 1 || tools[0].(map[string]any)["input_schema"] == nil {
+		t.Errorf("tool schema was not forwarded: %v", body["tools"])
+	}
+
+	msgs, _ := body["messages"].([]any)
+	if len(msgs) != 3 {
+		t.Fatalf("got %d messages, want 3 (user, assistant, user): %v", len(msgs), msgs)
+	}
+	assistant := msgs[1].(map[string]any)["content"].([]any)[0].(map[string]any)
+	if assistant["type"] != "tool_use" || assistant["id"] != "call_abc" {
+		t.Errorf("assistant tool call not converted: %v", assistant)
+	}
+	result := msgs[2].(map[string]any)
+	block := result["content"].([]any)[0].(map[string]any)
+	if result["role"] != "user" || block["type"] != "tool_result" || block["tool_use_id"] != "call_abc" {
+		t.Errorf("tool result not converted: %v", result)
+	}
+}
+
+func TestAnthropicProvider_Stream(t *testing.T) {
+	events := []string{
+		`{"type":"message_start","message":{"id":"msg_1","content":[],"usage":{"input_tokens":30,"output_tokens":1}}}`,
+		`{"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}`,
+		`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"Read"}}`,
+		`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"ing."}}`,
+		`{"type":"content_block_stop","index":0}`,
+		`{"type":"content_block_start","index":1,"content_block":{"type":"tool_use","id":"call_abc","name":"sys_read_file","input":{}}}`,
+		`{"type":"content_block_delta","index":1,"delta":{"type":"input_json_delta","partial_json":"{\"path\":"}}`,
+		`{"type":"content_block_delta","index":1,"delta":{"type":"input_json_delta","partial_json":"\"README.md\"}"}}`,
+		`{"type":"content_block_stop","index":1}`,
+		`{"type":"message_delta","delta":{"stop_reason":"tool_use"},"usage":{"output_tokens":12}}`,
+		`{"type":"message_stop"}`,
+	}
+	srv := newAnthropicServer(t, nil, func(w http.ResponseWriter, body map[string]any) {
+		if body["stream"] != true {
+			t.Errorf("expected stream=true, got %v", body["stream"])
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, e := range events {
+			var typ struct {
+				Type string `json:"type"`
+			}
+			_ = json.Unmarshal([]byte(e), &typ)
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", typ.Type, e)
+		}
+	})
+	defer srv.Close()
+
+	p, err := NewAnthropicProvider("test-key", "", srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var deltas []string
+	resp, err := p.Chat(context.Background(), []Message{{Role: RoleUser, Content: "read it"}}, ChatOptions{
+		Tools:   []ToolDefinition{readFileTool},
+		OnDelta: func(d string) { deltas = append(deltas, d) },
+	})
+	if err != nil {
+		t.Fatalf("stream failed: %v", err)
+	}
+	if resp.Content != "Reading." || strings.Join(deltas, "|") != "Read|ing." {
+		t.Errorf("got text %q, deltas %q", resp.Content, deltas)
+	}
+	assertReadFileCall(t, resp.ToolCalls)
+	if resp.Usage != (Usage{PromptTokens: 30, CompletionTokens: 12}) {
+		t.Errorf("got usage %+v", resp.Usage)
+	}
+}
+
+func TestAnthropicProvider_ListModelsAndErrors(t *testing.T) {
+	srv := newAnthropicServer(t, nil, func(w http.ResponseWriter, _ map[string]any) {
+		w.WriteHeader(http.StatusTooManyRequests)
+		io.WriteString(w, `{"type":"error","error":{"type":"rate_limit_error","message":"slow down"}}`)
+	})
+	defer srv.Close()
+
+	p, _ := NewAnthropicProvider("test-key", "", srv.URL)
+	models, err := p.ListModels(context.Background())
+	if err != nil {
+		t.Fatalf("ListModels failed: %v", err)
+	}
+	if len(models) != 2 || models[0] != "claude-sonnet-4-5" {
+		t.Errorf("unexpected models: %v", models)
+	}
+
+	if _, err := p.Generate(context.Background(), "hi"); err == nil || !strings.Contains(err.Error(), "slow down") {
+		t.Errorf("expected API error message to surface, got %v", err)
+	}
+
+	bad, _ := NewAnthropicProvider("wrong-key", "", srv.URL)
+	if _, err := bad.ListModels(context.Background()); err == nil || !strings.Contains(err.Error(), "invalid or expired") {
+		t.Errorf("expected auth error, got %v", err)
+	}
+
+	if _, err := NewAnthropicProvider("", "", srv.URL); err == nil {
+		t.Error("expected an error without an API key")
+	}
+}
+
+func TestAnthropicProvider_Registered(t *testing.T) {
+	p, err := GetProvider("anthropic", map[string]string{"api_key": "k"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Name() != "anthropic" {
+		t.Errorf("got %q", p.Name())
+	}
+	if !New(p).SupportsTools() || !New(p).SupportsStreaming() {
+		t.Error("anthropic provider should support tools and streaming")
+	}
+}
@@ -0,0 +1,83 @@
+package model
+
+import "fmt"
+
+// minToolTokens is the smallest size a tool output is trimmed down to.
+const minToolTokens = 64
+
+// TruncateMiddle shortens text to roughly maxTokens, keeping the head and the tail
+// where file contents and command output usually carry the most signal.
+func TruncateMiddle(text string, maxTokens int) string {
+	tokens := EstimateTokens(text)
+	if tokens <= maxTokens {
+		return text
+	}
+	marker := fmt.Sprintf("\n... [%d tokens trimmed to fit the context window] ...\n", tokens-maxTokens)
+	runes := []rune(text)
+	keep := len(runes) * max(maxTokens-EstimateTokens(marker), 0) / tokens
+	head, tail := keep*2/3, keep/3
+	return string(runes[:head]) + marker + string(runes[len(runes)-tail:])
+}
+
+// MessagesTokens estimates the prompt size of a conversation.
+func MessagesTokens(messages []Message) int {
+	return EstimateUsage(messages, ChatResponse{}).PromptTokens
+}
+
+// FitMessages shrinks tool output so the conversation fits within budget tokens.
+// Every tool result is first capped at a quarter of the budget; if that is not
+// enough, older results are cut down to a stub and then the most recent ones are
+// halved. Messages are never dropped, so tool call/result pairing stays valid.
+func FitMessages(messages []Message, budget int) []Message {
+	if budget <= 0 {
+		return messages
+	}
+	out := make([]Message, len(messages))
+	copy(out, messages)
+
+	var tools []int
+	for i, msg := range out {
+		if msg.Role == RoleTool {
+			tools = append(tools, i)
+			out[i].Content = TruncateMiddle(msg.Content, max(budget/4, minToolTokens))
+		}
+	}
+
+	total := MessagesTokens(out)
+	shrink := func(i, limit int) bool {
+		before := EstimateTokens(out[i].Content)
+		out[i].Content = TruncateMiddle(out[i].Content, limit)
+		saved := before - EstimateTokens(out[i].Content)
+		total -= saved
+		return saved > 0
+	}
+
+	// Older results go first; the latest batch (after the last assistant turn) is kept longest.
+	latest := len(out)
+	for i := len(out) - 1; i >= 0; i-- {
+		if out[i].Role == RoleAssistant {
+			latest = i
+			break
+		}
+	}
+	for _, i := range tools {
+		if total <= budget {
+			return out
+		}
+		if i < latest {
+			shrink(i, minToolTokens)
+		}
+	}
+	for total > budget {
+		shrunk := false
+		for _, i := range tools {
+			if n := EstimateTokens(out[i].Content); n > minToolTokens && shrink(i, max(n/2, minToolTokens)) {
+				shrunk = true
+			}
+		}
+		if !shrunk {
+			break
+		}
+	}
+	return out
+}
@@ -0,0 +1,75 @@
+package model
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+)
+
+// ResponseCache persists generated responses by key.
+type ResponseCache interface {
+	CacheGet(key string, ttl time.Duration) (string, bool)
+	CachePut(key, provider, model, value string) error
+}
+
+type bypassCacheKey struct{}
+
+// BypassCache marks a call so CachingProvider neither reads nor writes the cache.
+func BypassCache(ctx context.Context) context.Context {
+	return context.WithValue(ctx, bypassCacheKey{}, true)
+}
+
+func cacheBypassed(ctx context.Context) bool {
+	bypass, _ := ctx.Value(bypassCacheKey{}).(bool)
+	return bypass
+}
+
+// CachingProvider is a Provider decorator that memoizes Generate results keyed by
+// provider, model and a hash of the prompt. It is meant for deterministic background
+// calls (project indexing, recommendations); call sites opt in by being handed a
+// caching provider, so interactive chat never goes through it.
+type CachingProvider struct {
+	provider Provider
+	model    string
+	store    ResponseCache
+	ttl      time.Duration
+}
+
+// NewCachingProvider wraps p. Entries older than ttl are treated as misses.
+func NewCachingProvider(p Provider, modelName string, store ResponseCache, ttl time.Duration) *CachingProvider {
+	return &CachingProvider{provider: p, model: modelName, store: store, ttl: ttl}
+}
+
+// CacheKey derives the cache key for a prompt sent to provider/model.
+func CacheKey(provider, modelName, prompt string) string {
+	sum := sha256.Sum256([]byte(provider + "\x00" + modelName + "\x00" + prompt))
+	return hex.EncodeToString(sum[:])
+}
+
+func (c *CachingProvider) Name() string { return c.provider.Name() }
+
+// Generate returns a cached response when one exists and otherwise calls the
+// wrapped provider, storing non-empty successful responses.
+func (c *CachingProvider) Generate(ctx context.Context, prompt string) (string, error) {
+	if cacheBypassed(ctx) {
+		return c.provider.Generate(ctx, prompt)
+	}
+
+	key := CacheKey(c.provider.Name(), c.model, prompt)
+	if resp, ok := c.store.CacheGet(key, c.ttl); ok {
+		return resp, nil
+	}
+
+	resp, err := c.provider.Generate(ctx, prompt)
+	if err != nil || resp == "" {
+		return resp, err
+	}
+	_ = c.store.CachePut(key, c.provider.Name(), c.model, resp)
+	return resp, nil
+}
+
+// ListModels is never cached.
+func (c *CachingProvider) ListModels(ctx context.Context) ([]string, error) {
+	return c.provider.ListModels(ctx)
+}
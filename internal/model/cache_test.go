@@ -0,0 +1,84 @@
+package model
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+type mapCache struct {
+	entries map[string]string
+	puts    int
+}
+
+func (m *mapCache) CacheGet(key string, ttl time.Duration) (string, bool) {
+	v, ok := m.entries[key]
+	return v, ok
+}
+
+func (m *mapCache) CachePut(key, provider, model, value string) error {
+	m.entries[key] = value
+	m.puts++
+	return nil
+}
+
+func TestCachingProvider(t *testing.T) {
+	inner := &flakyProvider{name: "ollama"}
+	store := &mapCache{entries: map[string]string{}}
+	c := NewCachingProvider(inner, "llama3", store, time.Hour)
+
+	for i := 0; i < 3; i++ {
+		resp, err := c.Generate(context.Background(), "index this repo")
+		if err != nil || resp != "ollama says hi" {
+			t.Fatalf("call %d: got %q, %v", i, resp, err)
+		}
+	}
+	if inner.calls != 1 || store.puts != 1 {
+		t.Errorf("expected one provider call and one put, got %d calls, %d puts", inner.calls, store.puts)
+	}
+
+	if _, err := c.Generate(context.Background(), "a different prompt"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Generate(BypassCache(context.Background()), "index this repo"); err != nil {
+		t.Fatal(err)
+	}
+	if inner.calls != 3 {
+		t.Errorf("new prompts and bypassed calls must reach the provider, got %d calls", inner.calls)
+	}
+
+	if CacheKey("ollama", "llama3", "p") == CacheKey("ollama", "llama3.1", "p") {
+		t.Error("cache keys must depend on the model")
+	}
+}
+
+func TestCachingProvider_FallbackChainKeysOnServingMember(t *testing.T) {
+	primary := &flakyProvider{name: "ollama", err: &StatusError{StatusCode: 503, Status: "503 Service Unavailable"}, fails: 1}
+	backup := &flakyProvider{name: "openai"}
+	chain, err := NewFallbackProvider([]Provider{primary, backup}, 5, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	chain.SetModels([]string{"llama3", "gpt-4o"})
+	store := &mapCache{entries: map[string]string{}}
+	cached := chain.Wrap(func(p Provider, model string) Provider {
+		return NewCachingProvider(p, model, store, time.Hour)
+	})
+
+	// The primary is down, so the backup answers and is cached under its own key.
+	resp, err := cached.Generate(context.Background(), "index this repo")
+	if err != nil || resp != "openai says hi" {
+		t.Fatalf("got %q, %v", resp, err)
+	}
+	if _, ok := store.entries[CacheKey("openai", "gpt-4o", "index this repo")]; !ok || len(store.entries) != 1 {
+		t.Errorf("expected the reply cached under the backup's key only, got %v", store.entries)
+	}
+
+	// Once the primary is back it answers for itself instead of replaying the backup.
+	if resp, err := cached.Generate(context.Background(), "index this repo"); err != nil || resp != "ollama says hi" {
+		t.Errorf("expected the recovered primary to answer, got %q, %v", resp, err)
+	}
+	if backup.calls != 1 {
+		t.Errorf("expected one backup call, got %d", backup.calls)
+	}
+}
@@ -0,0 +1,158 @@
+package model
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"sync"
+)
+
+// Capabilities describes what a model accepts and produces.
+type Capabilities struct {
+	ContextWindow   int  `json:"context_window"`
+	MaxOutputTokens int  `json:"max_output_tokens"`
+	Tools           bool `json:"tools"`
+	Vision          bool `json:"vision"`
+	Streaming       bool `json:"streaming"`
+}
+
+// DefaultCapabilities is assumed for models the catalog knows nothing about.
+// It is deliberately small so unknown models are never overfilled.
+var DefaultCapabilities = Capabilities{
+	ContextWindow:   8192,
+	MaxOutputTokens: 2048,
+	Tools:           true,
+	Streaming:       true,
+}
+
+// PromptBudget returns how many tokens the input may use while leaving room
+// for the reply. At most a quarter of the window is reserved for output.
+func (c Capabilities) PromptBudget() int {
+	reserve := c.MaxOutputTokens
+	if reserve > c.ContextWindow/4 {
+		reserve = c.ContextWindow / 4
+	}
+	return c.ContextWindow - reserve
+}
+
+// CapabilitiesReporter is implemented by providers whose APIs describe their models.
+type CapabilitiesReporter interface {
+	ModelCapabilities(ctx context.Context, model string) (Capabilities, error)
+}
+
+// CatalogEntry is one row of a capabilities file. Model may be a glob and an
+// empty Provider matches any provider.
+type CatalogEntry struct {
+	Provider string `json:"provider,omitempty"`
+	Model    string `json:"model"`
+	Capabilities
+}
+
+//go:embed capabilities.json
+var builtinCatalog []byte
+
+// Catalog resolves the capabilities of provider/model pairs. Values reported by
+// provider APIs win over the user's catalog file, which wins over the built-in table.
+type Catalog struct {
+	mu       sync.RWMutex
+	reported map[string]Capabilities
+	user     []CatalogEntry
+	builtin  []CatalogEntry
+}
+
+// NewCatalog returns a catalog seeded with the built-in table.
+func NewCatalog() *Catalog {
+	c := &Catalog{reported: make(map[string]Capabilities)}
+	if err := json.Unmarshal(builtinCatalog, &c.builtin); err != nil {
+		panic(fmt.Sprintf("model: invalid built-in capabilities catalog: %v", err))
+	}
+	return c
+}
+
+// LoadFile adds the entries of a JSON capabilities file. A missing file is not an error.
+func (c *Catalog) LoadFile(file string) error {
+	data, err := os.ReadFile(file)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var entries []CatalogEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return fmt.Errorf("parsing %s: %w", file, err)
+	}
+
+	c.mu.Lock()
+	c.user = append(c.user, entries...)
+	c.mu.Unlock()
+	return nil
+}
+
+// Set records capabilities reported for a specific model.
+func (c *Catalog) Set(provider, model string, caps Capabilities) {
+	c.mu.Lock()
+	c.reported[provider+"/"+model] = caps
+	c.mu.Unlock()
+}
+
+// Refresh asks the provider to describe model, if it can.
+func (c *Catalog) Refresh(ctx context.Context, p Provider, model string) error {
+	r, ok := p.(CapabilitiesReporter)
+	if !ok {
+		return nil
+	}
+	caps, err := r.ModelCapabilities(ctx, model)
+	if err != nil {
+		return err
+	}
+	c.Set(p.Name(), model, caps)
+	return nil
+}
+
+// Lookup returns the capabilities of provider/model, falling back to DefaultCapabilities.
+func (c *Catalog) Lookup(provider, model string) Capabilities {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if caps, ok := c.reported[provider+"/"+model]; ok {
+		return caps
+	}
+	for _, entries := range [][]CatalogEntry{c.user, c.builtin} {
+		if caps, ok := bestMatch(entries, provider, model); ok {
+			return caps
+		}
+	}
+	return DefaultCapabilities
+}
+
+// bestMatch picks the most specific matching entry: provider-specific rows beat
+// provider-agnostic ones, then the pattern with the most literal characters wins.
+func bestMatch(entries []CatalogEntry, provider, model string) (Capabilities, bool) {
+	best, bestScore := Capabilities{}, -1
+	lower := strings.ToLower(model)
+	base := path.Base(lower) // "meta/llama-3.1-8b" also matches "llama-3.1*"
+	for _, e := range entries {
+		if e.Provider != "" && e.Provider != provider {
+			continue
+		}
+		pattern := strings.ToLower(e.Model)
+		full, _ := path.Match(pattern, lower)
+		short, _ := path.Match(pattern, base)
+		if !full && !short {
+			continue
+		}
+		score := len(strings.ReplaceAll(e.Model, "*", ""))
+		if e.Provider != "" {
+			score += 1 << 16
+		}
+		if score > bestScore {
+			best, bestScore = e.Capabilities, score
+		}
+	}
+	return best, bestScore >= 0
+}
@@ -0,0 +1,92 @@
+package model
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCatalog_Lookup(t *testing.T) {
+	c := NewCatalog()
+
+	if got := c.Lookup("anthropic", "claude-sonnet-4-5"); got.MaxOutputTokens != 64000 || !got.Tools {
+		t.Errorf("expected the specific claude-sonnet-4 row, got %+v", got)
+	}
+	if got := c.Lookup("github-models", "meta/Meta-Llama-3.1-8B-Instruct"); got.ContextWindow != 128000 {
+		t.Errorf("expected provider-agnostic llama row via the base name, got %+v", got)
+	}
+	// Many local models cannot call tools, so ollama models use fenced tool calls until
+	// /api/show says otherwise.
+	if got := c.Lookup("ollama", "llama3"); got.Tools {
+		t.Errorf("unknown ollama models should not be sent native tools, got %+v", got)
+	}
+	if got := c.Lookup("openai", "some-new-model"); got != DefaultCapabilities {
+		t.Errorf("unknown models should get defaults, got %+v", got)
+	}
+
+	file := filepath.Join(t.TempDir(), "models.json")
+	os.WriteFile(file, []byte(`[{"provider":"openai","model":"some-new-model","context_window":32000,"max_output_tokens":1000}]`), 0644)
+	if err := c.LoadFile(file); err != nil {
+		t.Fatal(err)
+	}
+	if got := c.Lookup("openai", "some-new-model"); got.ContextWindow != 32000 {
+		t.Errorf("user catalog entry was ignored, got %+v", got)
+	}
+	if err := c.LoadFile(filepath.Join(t.TempDir(), "missing.json")); err != nil {
+		t.Errorf("a missing catalog file should be ignored, got %v", err)
+	}
+
+	c.Set("openai", "some-new-model", Capabilities{ContextWindow: 64000})
+	if got := c.Lookup("openai", "some-new-model"); got.ContextWindow != 64000 {
+		t.Errorf("reported capabilities should win, got %+v", got)
+	}
+}
+
+func TestOllamaProvider_ModelCapabilities(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/show" {
+			http.NotFound(w, r)
+			return
+		}
+		io.WriteString(w, `{"parameters":"num_ctx                        16384","model_info":{"general.architecture":"llama","llama.context_length":131072},"capabilities":["completion","tools"]}`)
+	}))
+	defer srv.Close()
+	t.Setenv("OLLAMA_HOST", srv.URL)
+
+	p, _ := NewOllamaProvider("", "llama3.1")
+	c := NewCatalog()
+	if err := c.Refresh(context.Background(), p, "llama3.1"); err != nil {
+		t.Fatal(err)
+	}
+	got := c.Lookup("ollama", "llama3.1")
+	if got.ContextWindow != 16384 || !got.Tools || got.Vision {
+		t.Errorf("unexpected capabilities: %+v", got)
+	}
+}
+
+func TestFitMessages(t *testing.T) {
+	big := string(make([]byte, 40000)) // ~10k tokens
+	conv := []Message{
+		{Role: RoleSystem, Content: "system"},
+		{Role: RoleUser, Content: string(make([]byte, 12000))},
+		{Role: RoleAssistant, ToolCalls: []ToolCall{{ID: "1", Name: "sys_read_file"}}},
+		{Role: RoleTool, ToolCallID: "1", Content: big},
+		{Role: RoleAssistant, ToolCalls: []ToolCall{{ID: "2", Name: "sys_read_file"}}},
+		{Role: RoleTool, ToolCallID: "2", Content: big},
+	}
+
+	fitted := FitMessages(conv, 4000)
+	if n := MessagesTokens(fitted); n > 4000 {
+		t.Errorf("conversation still uses %d tokens", n)
+	}
+	if len(fitted) != len(conv) || conv[3].Content != big {
+		t.Error("FitMessages must not drop messages or modify its input")
+	}
+	if EstimateTokens(fitted[3].Content) >= EstimateTokens(fitted[5].Content) {
+		t.Error("older tool output should be trimmed harder than the latest")
+	}
+}
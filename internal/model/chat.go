@@ -0,0 +1,131 @@
+package model
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// Role identifies the author of a chat message.
+type Role string
+
+const (
+	RoleSystem    Role = "system"
+	RoleUser      Role = "user"
+	RoleAssistant Role = "assistant"
+	RoleTool      Role = "tool"
+)
+
+// Message is a single entry in a structured conversation.
+type Message struct {
+	Role    Role   `json:"role"`
+	Content string `json:"content"`
+
+	// ToolCalls holds the calls requested by an assistant message.
+	ToolCalls []ToolCall `json:"tool_calls,omitempty"`
+
+	// ToolCallID and Name link a tool message back to the call it answers.
+	ToolCallID string `json:"tool_call_id,omitempty"`
+	Name       string `json:"name,omitempty"`
+}
+
+// ChatOptions tunes a single Chat call.
+type ChatOptions struct {
+	// Tools are offered to the model for native tool calling.
+	Tools []ToolDefinition
+	// OnDelta enables streaming when non-nil.
+	OnDelta StreamFunc
+}
+
+// ChatResponse is the assistant's reply to a Chat call.
+type ChatResponse struct {
+	Content   string
+	ToolCalls []ToolCall
+
+	// Usage is reported by the provider when available and estimated otherwise.
+	Usage Usage
+	// Provider names the provider that actually served the request.
+	Provider string
+}
+
+// ChatProvider represents a provider that accepts role-separated conversations.
+type ChatProvider interface {
+	Chat(ctx context.Context, messages []Message, opts ChatOptions) (ChatResponse, error)
+}
+
+// Chat sends a structured conversation to the provider. Providers without a native
+// chat API receive the conversation flattened into a single prompt.
+// Token usage is estimated when the provider does not report it.
+func (m *Model) Chat(ctx context.Context, messages []Message, opts ChatOptions) (ChatResponse, error) {
+	if m.provider == nil {
+		return ChatResponse{}, fmt.Errorf("no provider configured")
+	}
+	resp, err := m.chat(ctx, messages, opts)
+	if err != nil {
+		return resp, err
+	}
+	if resp.Provider == "" {
+		resp.Provider = m.provider.Name()
+	}
+	if resp.Usage.IsZero() {
+		resp.Usage = EstimateUsage(messages, resp)
+	}
+	return resp, nil
+}
+
+func (m *Model) chat(ctx context.Context, messages []Message, opts ChatOptions) (ChatResponse, error) {
+	if cp, ok := m.provider.(ChatProvider); ok {
+		return cp.Chat(ctx, messages, opts)
+	}
+
+	prompt := FlattenMessages(messages)
+	if len(opts.Tools) > 0 && m.SupportsTools() {
+		var content string
+		var calls []ToolCall
+		var err error
+		if opts.OnDelta != nil {
+			content, calls, err = m.GenerateWithToolsStream(ctx, prompt, opts.Tools, opts.OnDelta)
+		} else {
+			content, calls, err = m.GenerateWithTools(ctx, prompt, opts.Tools)
+		}
+		return ChatResponse{Content: content, ToolCalls: calls}, err
+	}
+
+	var content string
+	var err error
+	if opts.OnDelta != nil {
+		content, err = m.GenerateStream(ctx, prompt, opts.OnDelta)
+	} else {
+		content, err = m.provider.Generate(ctx, prompt)
+	}
+	return ChatResponse{Content: content}, err
+}
+
+// FlattenMessages renders a conversation as one prompt for single-string providers.
+func FlattenMessages(messages []Message) string {
+	var b strings.Builder
+	for i, msg := range messages {
+		if i > 0 {
+			b.WriteString("\n\n")
+		}
+		switch msg.Role {
+		case RoleSystem:
+			b.WriteString(msg.Content)
+		case RoleUser:
+			b.WriteString("User: ")
+			b.WriteString(msg.Content)
+		case RoleAssistant:
+			b.WriteString("Assistant: ")
+			b.WriteString(msg.Content)
+			for _, c := range msg.ToolCalls {
+				b.WriteString(fmt.Sprintf("\n[tool_call %s] %s %s", c.ID, c.Name, string(c.Arguments)))
+			}
+		case RoleTool:
+			b.WriteString(fmt.Sprintf("Observation (%s): ", msg.Name))
+			b.WriteString(msg.Content)
+		default:
+			b.WriteString(msg.Content)
+		}
+	}
+	return b.String()
+}
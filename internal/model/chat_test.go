@@ -0,0 +1,124 @@
+package model
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+var toolConversation = []Message{
+	{Role: RoleSystem, Content: "You are helpful."},
+	{Role: RoleUser, Content: "read the readme"},
+	{Role: RoleAssistant, ToolCalls: []ToolCall{{ID: "call_abc", Name: "sys_read_file", Arguments: json.RawMessage(`{"path":"README.md"}`)}}},
+	{Role: RoleTool, ToolCallID: "call_abc", Name: "sys_read_file", Content: "# Title"},
+}
+
+func TestOpenAIProvider_Chat(t *testing.T) {
+	var body map[string]any
+	srv := newOpenAICompatServer(t, &body)
+	defer srv.Close()
+
+	p, err := NewOpenAIProvider("test-key", "gpt-4o", srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := p.Chat(context.Background(), toolConversation, ChatOptions{Tools: []ToolDefinition{readFileTool}})
+	if err != nil {
+		t.Fatalf("Chat failed: %v", err)
+	}
+	assertReadFileCall(t, resp.ToolCalls)
+	if resp.Usage != (Usage{PromptTokens: 10, CompletionTokens: 5}) {
+		t.Errorf("API usage was not reported: %+v", resp.Usage)
+	}
+
+	msgs, _ := body["messages"].([]any)
+	if len(msgs) != 4 {
+		t.Fatalf("got %d messages, want 4: %v", len(msgs), body["messages"])
+	}
+	roles := []string{"system", "user", "assistant", "tool"}
+	for i, raw := range msgs {
+		if role := raw.(map[string]any)["role"]; role != roles[i] {
+			t.Errorf("message %d: got role %v, want %s", i, role, roles[i])
+		}
+	}
+	assistant := msgs[2].(map[string]any)
+	if calls, _ := assistant["tool_calls"].([]any); len(calls) != 1 {
+		t.Errorf("assistant tool calls were not forwarded: %v", assistant)
+	}
+	if id := msgs[3].(map[string]any)["tool_call_id"]; id != "call_abc" {
+		t.Errorf("got tool_call_id %v, want call_abc", id)
+	}
+}
+
+func TestOllamaProvider_Chat(t *testing.T) {
+	var body map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		raw, _ := io.ReadAll(r.Body)
+		_ = json.Unmarshal(raw, &body)
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		io.WriteString(w, `{"model":"llama3.1","message":{"role":"assistant","content":"The title is Title."},"done":true,"prompt_eval_count":42,"eval_count":7}`+"\n")
+	}))
+	defer srv.Close()
+	t.Setenv("OLLAMA_HOST", srv.URL)
+
+	p, err := NewOllamaProvider("", "llama3.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := p.Chat(context.Background(), toolConversation, ChatOptions{})
+	if err != nil {
+		t.Fatalf("Chat failed: %v", err)
+	}
+	if resp.Content != "The title is Title." {
+		t.Errorf("unexpected content: %q", resp.Content)
+	}
+	if resp.Usage.PromptTokens != 42 || resp.Usage.CompletionTokens != 7 {
+		t.Errorf("eval counts were not reported as usage: %+v", resp.Usage)
+	}
+
+	msgs, _ := body["messages"].([]any)
+	if len(msgs) != 4 {
+		t.Fatalf("got %d messages, want 4", len(msgs))
+	}
+	tool := msgs[3].(map[string]any)
+	if tool["role"] != "tool" || tool["tool_call_id"] != "call_abc" {
+		t.Errorf("tool result was not forwarded: %v", tool)
+	}
+}
+
+func TestModel_Chat_FlattensForPlainProviders(t *testing.T) {
+	var got string
+	m := New(&promptRecorder{prompt: &got})
+
+	resp, err := m.Chat(context.Background(), toolConversation, ChatOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !resp.Usage.Estimated || resp.Usage.PromptTokens == 0 || resp.Usage.CompletionTokens == 0 {
+		t.Errorf("expected estimated usage for a plain provider, got %+v", resp.Usage)
+	}
+	if resp.Provider != "mock" {
+		t.Errorf("got provider %q, want mock", resp.Provider)
+	}
+	for _, want := range []string{"You are helpful.", "User: read the readme", "[tool_call call_abc] sys_read_file", "Observation (sys_read_file): # Title"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("flattened prompt is missing %q:\n%s", want, got)
+		}
+	}
+}
+
+type promptRecorder struct {
+	MockProvider
+	prompt *string
+}
+
+func (p *promptRecorder) Generate(ctx context.Context, prompt string) (string, error) {
+	*p.prompt = prompt
+	return "ok", nil
+}
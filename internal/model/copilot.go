@@ -29,13 +29,17 @@ func (p *CopilotProvider) Name() string { return "github-copilot" }
 
 // NewCopilotProvider creates a new GitHub Copilot provider
 func NewCopilotProvider(token string, modelName string) (*CopilotProvider, error) {
+	return newCopilotProvider(token, modelName, CopilotBaseURL)
+}
+
+func newCopilotProvider(token string, modelName string, baseURL string) (*CopilotProvider, error) {
 	if modelName == "" {
 		modelName = "gpt-4o" // Copilot default
 	}
 
 	llm, err := openai.New(
 		openai.WithToken(token),
-		openai.WithBaseURL(CopilotBaseURL), // Copilot LLM endpoint
+		openai.WithBaseURL(baseURL), // Copilot LLM endpoint
 		openai.WithModel(modelName),
 		openai.WithHTTPClient(&http.Client{
 			Transport: newGithubTransport(token, http.DefaultTransport),
@@ -61,6 +65,33 @@ func (p *CopilotProvider) Generate(ctx context.Context, prompt string) (string,
 	return resp, nil
 }
 
+// Chat sends a role-separated conversation to GitHub Copilot
+func (p *CopilotProvider) Chat(ctx context.Context, messages []Message, opts ChatOptions) (ChatResponse, error) {
+	resp, err := chatLLM(ctx, p.llm, messages, opts)
+	if err != nil {
+		return ChatResponse{}, fmt.Errorf("github copilot chat: %w", err)
+	}
+	return resp, nil
+}
+
+// GenerateWithTools sends a prompt with native tool schemas to GitHub Copilot
+func (p *CopilotProvider) GenerateWithTools(ctx context.Context, prompt string, tools []ToolDefinition) (string, []ToolCall, error) {
+	resp, err := p.Chat(ctx, []Message{{Role: RoleUser, Content: prompt}}, ChatOptions{Tools: tools})
+	return resp.Content, resp.ToolCalls, err
+}
+
+// GenerateStream streams the response from GitHub Copilot, calling onDelta for each chunk
+func (p *CopilotProvider) GenerateStream(ctx context.Context, prompt string, onDelta StreamFunc) (string, error) {
+	resp, err := p.Chat(ctx, []Message{{Role: RoleUser, Content: prompt}}, ChatOptions{OnDelta: onDelta})
+	return resp.Content, err
+}
+
+// GenerateWithToolsStream streams text deltas while collecting native tool calls
+func (p *CopilotProvider) GenerateWithToolsStream(ctx context.Context, prompt string, tools []ToolDefinition, onDelta StreamFunc) (string, []ToolCall, error) {
+	resp, err := p.Chat(ctx, []Message{{Role: RoleUser, Content: prompt}}, ChatOptions{Tools: tools, OnDelta: onDelta})
+	return resp.Content, resp.ToolCalls, err
+}
+
 // ListModels returns available models (stub for now, Copilot usually has fixed gpt-4o/gpt-3.5-turbo)
 func (p *CopilotProvider) ListModels(ctx context.Context) ([]string, error) {
 	return []string{"gpt-4o", "gpt-4-turbo", "gpt-3.5-turbo"}, nil
@@ -62,6 +62,16 @@ func (p *CopilotSDKProvider) Generate(ctx context.Context, prompt string) (strin
 	return p.provider.Generate(ctx, prompt, false)
 }
 
+// Chat flattens the conversation for the SDK, which keeps its own session history
+// and registers tools separately.
+func (p *CopilotSDKProvider) Chat(ctx context.Context, messages []Message, opts ChatOptions) (ChatResponse, error) {
+	resp, err := p.provider.Generate(ctx, FlattenMessages(messages), false)
+	if err != nil {
+		return ChatResponse{}, err
+	}
+	return ChatResponse{Content: resp}, nil
+}
+
 // ListModels returns available models from the SDK.
 func (p *CopilotSDKProvider) ListModels(ctx context.Context) ([]string, error) {
 	return p.provider.ListModels(ctx)
@@ -0,0 +1,329 @@
+package model
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/ollama/ollama/api"
+)
+
+const (
+	DefaultFallbackThreshold = 3
+	DefaultFallbackCooldown  = 30 * time.Second
+)
+
+// StatusError is returned when a provider API answers with a non-success HTTP status.
+type StatusError struct {
+	StatusCode int
+	Status     string
+	Message    string
+}
+
+func (e *StatusError) Error() string {
+	if e.Message == "" {
+		return e.Status
+	}
+	return fmt.Sprintf("%s: %s", e.Status, e.Message)
+}
+
+// statusCodePattern matches status codes embedded in langchaingo's plain-text errors.
+var statusCodePattern = regexp.MustCompile(`status code:? (\d{3})`)
+
+// IsTransient reports whether err is a transport failure or a 5xx response,
+// i.e. an error worth failing over to another provider for.
+func IsTransient(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+
+	var se *StatusError
+	if errors.As(err, &se) {
+		return se.StatusCode >= 500
+	}
+	var ose api.StatusError
+	if errors.As(err, &ose) {
+		return ose.StatusCode >= 500
+	}
+
+	var netErr net.Error
+	var urlErr *url.Error
+	if errors.As(err, &netErr) || errors.As(err, &urlErr) ||
+		errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+
+	msg := err.Error()
+	if m := statusCodePattern.FindStringSubmatch(msg); m != nil {
+		return m[1][0] == '5'
+	}
+	lower := strings.ToLower(msg)
+	for _, s := range []string{"connection refused", "connection reset", "no such host", "unexpected eof", "server misbehaving"} {
+		if strings.Contains(lower, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// fallbackMember is one provider in the chain plus its circuit breaker state.
+type fallbackMember struct {
+	provider  Provider
+	model     string
+	failures  int
+	openUntil time.Time
+}
+
+// FallbackProvider is a composite Provider that walks an ordered chain of providers.
+// Each member has a circuit breaker: after threshold consecutive transient failures it
+// is skipped for the cooldown period, then given one trial request (half-open).
+// Non-transient errors (bad requests, auth, cancellation) are returned immediately.
+type FallbackProvider struct {
+	mu        sync.Mutex
+	members   []*fallbackMember
+	active    int
+	threshold int
+	cooldown  time.Duration
+	now       func() time.Time
+
+	// OnSwitch is called whenever the chain moves away from a failing provider
+	// (reason is the error) or back to a recovered one (reason is nil).
+	OnSwitch func(from, to string, reason error)
+}
+
+// NewFallbackProvider creates a fallback chain. The first provider is the primary.
+func NewFallbackProvider(providers []Provider, threshold int, cooldown time.Duration) (*FallbackProvider, error) {
+	if len(providers) == 0 {
+		return nil, fmt.Errorf("fallback chain needs at least one provider")
+	}
+	if threshold <= 0 {
+		threshold = DefaultFallbackThreshold
+	}
+	if cooldown <= 0 {
+		cooldown = DefaultFallbackCooldown
+	}
+
+	f := &FallbackProvider{threshold: threshold, cooldown: cooldown, now: time.Now}
+	for _, p := range providers {
+		f.members = append(f.members, &fallbackMember{provider: p})
+	}
+	return f, nil
+}
+
+// Name returns the name of the provider currently serving requests.
+func (f *FallbackProvider) Name() string {
+	return f.Active().Name()
+}
+
+// Active returns the provider currently serving requests.
+func (f *FallbackProvider) Active() Provider {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.members[f.active].provider
+}
+
+// SetModels names the model each provider of the chain serves, in chain order.
+func (f *FallbackProvider) SetModels(names []string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for i, m := range f.members {
+		if i < len(names) {
+			m.model = names[i]
+		}
+	}
+}
+
+// Wrap returns a chain of the same providers, each decorated by wrap with the model it
+// serves. The new chain keeps its own circuit breakers and reports to the same OnSwitch.
+func (f *FallbackProvider) Wrap(wrap func(p Provider, model string) Provider) *FallbackProvider {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	w := &FallbackProvider{threshold: f.threshold, cooldown: f.cooldown, now: f.now, OnSwitch: f.OnSwitch}
+	for _, m := range f.members {
+		w.members = append(w.members, &fallbackMember{provider: wrap(m.provider, m.model), model: m.model})
+	}
+	return w
+}
+
+// Providers returns the chain in order.
+func (f *FallbackProvider) Providers() []Provider {
+	out := make([]Provider, len(f.members))
+	for i, m := range f.members {
+		out[i] = m.provider
+	}
+	return out
+}
+
+// Generate tries each healthy provider in order until one succeeds.
+func (f *FallbackProvider) Generate(ctx context.Context, prompt string) (string, error) {
+	var out string
+	err := f.do(ctx, func(p Provider) error {
+		var err error
+		out, err = p.Generate(ctx, prompt)
+		return err
+	})
+	return out, err
+}
+
+// Chat tries each healthy provider in order until one succeeds. Once any text has been
+// streamed to the caller the chain stops failing over, to avoid duplicated output.
+func (f *FallbackProvider) Chat(ctx context.Context, messages []Message, opts ChatOptions) (ChatResponse, error) {
+	var out ChatResponse
+	streamed := false
+	if opts.OnDelta != nil {
+		onDelta := opts.OnDelta
+		opts.OnDelta = func(delta string) {
+			streamed = true
+			onDelta(delta)
+		}
+	}
+
+	err := f.do(ctx, func(p Provider) error {
+		var err error
+		out, err = New(p).Chat(ctx, messages, opts)
+		if err != nil && streamed {
+			return &haltError{err}
+		}
+		return err
+	})
+	return out, err
+}
+
+// SupportsTools reports whether every provider in the chain calls tools natively. The
+// caller decides between native tools and fenced tool calls before knowing which member
+// will answer, so one member without native tools means fences for the whole chain.
+func (f *FallbackProvider) SupportsTools() bool {
+	for _, m := range f.members {
+		if !New(m.provider).SupportsTools() {
+			return false
+		}
+	}
+	return true
+}
+
+// GenerateWithTools runs a tool-enabled prompt through the chain.
+func (f *FallbackProvider) GenerateWithTools(ctx context.Context, prompt string, tools []ToolDefinition) (string, []ToolCall, error) {
+	resp, err := f.Chat(ctx, []Message{{Role: RoleUser, Content: prompt}}, ChatOptions{Tools: tools})
+	return resp.Content, resp.ToolCalls, err
+}
+
+// GenerateStream streams a prompt through the chain.
+func (f *FallbackProvider) GenerateStream(ctx context.Context, prompt string, onDelta StreamFunc) (string, error) {
+	resp, err := f.Chat(ctx, []Message{{Role: RoleUser, Content: prompt}}, ChatOptions{OnDelta: onDelta})
+	return resp.Content, err
+}
+
+// GenerateWithToolsStream streams a tool-enabled prompt through the chain.
+func (f *FallbackProvider) GenerateWithToolsStream(ctx context.Context, prompt string, tools []ToolDefinition, onDelta StreamFunc) (string, []ToolCall, error) {
+	resp, err := f.Chat(ctx, []Message{{Role: RoleUser, Content: prompt}}, ChatOptions{Tools: tools, OnDelta: onDelta})
+	return resp.Content, resp.ToolCalls, err
+}
+
+// ListModels lists the models of the provider currently serving requests.
+func (f *FallbackProvider) ListModels(ctx context.Context) ([]string, error) {
+	return f.Active().ListModels(ctx)
+}
+
+// haltError stops the chain from failing over while preserving the original error.
+type haltError struct{ err error }
+
+func (h *haltError) Error() string { return h.err.Error() }
+func (h *haltError) Unwrap() error { return h.err }
+
+func (f *FallbackProvider) do(ctx context.Context, call func(Provider) error) error {
+	var lastErr error
+	prev := -1
+
+	for _, i := range f.candidates() {
+		m := f.members[i]
+		if prev >= 0 {
+			f.notify(f.members[prev].provider.Name(), m.provider.Name(), lastErr)
+		}
+
+		err := call(m.provider)
+		if err == nil {
+			f.recordSuccess(i)
+			return nil
+		}
+
+		var halt *haltError
+		if errors.As(err, &halt) {
+			f.recordFailure(i)
+			return halt.err
+		}
+		if ctx.Err() != nil || !IsTransient(err) {
+			return err
+		}
+
+		f.recordFailure(i)
+		lastErr = err
+		prev = i
+	}
+
+	if lastErr == nil {
+		lastErr = fmt.Errorf("no providers available in fallback chain")
+	}
+	return fmt.Errorf("all providers in fallback chain failed: %w", lastErr)
+}
+
+// candidates returns member indexes in chain order, skipping open circuits.
+// If every circuit is open the whole chain is tried rather than failing outright.
+func (f *FallbackProvider) candidates() []int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	now := f.now()
+	var out []int
+	for i, m := range f.members {
+		if m.openUntil.IsZero() || !now.Before(m.openUntil) {
+			out = append(out, i)
+		}
+	}
+	if len(out) == 0 {
+		for i := range f.members {
+			out = append(out, i)
+		}
+	}
+	return out
+}
+
+func (f *FallbackProvider) recordFailure(i int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	m := f.members[i]
+	m.failures++
+	if m.failures >= f.threshold {
+		m.openUntil = f.now().Add(f.cooldown)
+	}
+}
+
+func (f *FallbackProvider) recordSuccess(i int) {
+	f.mu.Lock()
+	m := f.members[i]
+	m.failures = 0
+	m.openUntil = time.Time{}
+	prev := f.active
+	f.active = i
+	f.mu.Unlock()
+
+	// Moving back up the chain means an earlier provider recovered.
+	if i < prev {
+		f.notify(f.members[prev].provider.Name(), m.provider.Name(), nil)
+	}
+}
+
+func (f *FallbackProvider) notify(from, to string, reason error) {
+	if f.OnSwitch != nil {
+		f.OnSwitch(from, to, reason)
+	}
+}
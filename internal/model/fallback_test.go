@@ -0,0 +1,186 @@
+package model
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// flakyProvider fails with err for the first n calls.
+type flakyProvider struct {
+	name  string
+	err   error
+	fails int
+	calls int
+}
+
+func (p *flakyProvider) Name() string { return p.name }
+
+func (p *flakyProvider) Generate(ctx context.Context, prompt string) (string, error) {
+	p.calls++
+	if p.calls <= p.fails {
+		return "", p.err
+	}
+	return p.name + " says hi", nil
+}
+
+func (p *flakyProvider) ListModels(ctx context.Context) ([]string, error) {
+	return []string{p.name + "-model"}, nil
+}
+
+type switchEvent struct {
+	from, to string
+	reason   error
+}
+
+func newTestChain(t *testing.T, providers ...Provider) (*FallbackProvider, *[]switchEvent, *time.Time) {
+	t.Helper()
+	f, err := NewFallbackProvider(providers, 2, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clock := time.Unix(1000, 0)
+	f.now = func() time.Time { return clock }
+	var events []switchEvent
+	f.OnSwitch = func(from, to string, reason error) {
+		events = append(events, switchEvent{from, to, reason})
+	}
+	return f, &events, &clock
+}
+
+func TestFallbackProvider_FailsOverOnTransientErrors(t *testing.T) {
+	primary := &flakyProvider{name: "ollama", err: &StatusError{StatusCode: 503, Status: "503 Service Unavailable"}, fails: 100}
+	backup := &flakyProvider{name: "openai"}
+	f, events, clock := newTestChain(t, primary, backup)
+
+	for i := 0; i < 3; i++ {
+		resp, err := f.Generate(context.Background(), "hi")
+		if err != nil {
+			t.Fatalf("call %d: %v", i, err)
+		}
+		if resp != "openai says hi" {
+			t.Fatalf("call %d: got %q", i, resp)
+		}
+	}
+
+	// Threshold is 2, so the breaker opens and the third call skips the primary.
+	if primary.calls != 2 {
+		t.Errorf("primary called %d times, want 2 before the circuit opened", primary.calls)
+	}
+	if len(*events) != 2 || (*events)[0].from != "ollama" || (*events)[0].to != "openai" {
+		t.Errorf("unexpected switch events: %+v", *events)
+	}
+	if f.Name() != "openai" {
+		t.Errorf("active provider is %q, want openai", f.Name())
+	}
+
+	// After the cooldown the primary is retried (half-open) and recovery is reported.
+	primary.fails = 0
+	*clock = clock.Add(2 * time.Minute)
+	resp, err := f.Generate(context.Background(), "hi")
+	if err != nil || resp != "ollama says hi" {
+		t.Fatalf("expected recovery to primary, got %q, %v", resp, err)
+	}
+	last := (*events)[len(*events)-1]
+	if last.from != "openai" || last.to != "ollama" || last.reason != nil {
+		t.Errorf("expected a recovery event, got %+v", last)
+	}
+}
+
+func TestFallbackProvider_NonTransientErrorsDoNotFailOver(t *testing.T) {
+	primary := &flakyProvider{name: "openai", err: &StatusError{StatusCode: 400, Status: "400 Bad Request"}, fails: 1}
+	backup := &flakyProvider{name: "ollama"}
+	f, events, _ := newTestChain(t, primary, backup)
+
+	if _, err := f.Generate(context.Background(), "hi"); err == nil {
+		t.Fatal("expected the 400 error to be returned")
+	}
+	if backup.calls != 0 || len(*events) != 0 {
+		t.Errorf("a client error must not trigger failover (backup calls=%d, events=%v)", backup.calls, *events)
+	}
+}
+
+func TestFallbackProvider_AllFail(t *testing.T) {
+	down := fmt.Errorf("dial tcp: %w", errors.New("connection refused"))
+	f, _, _ := newTestChain(t,
+		&flakyProvider{name: "a", err: down, fails: 10},
+		&flakyProvider{name: "b", err: down, fails: 10},
+	)
+	if _, err := f.Generate(context.Background(), "hi"); err == nil {
+		t.Fatal("expected an error when every provider fails")
+	}
+}
+
+func TestFallbackProvider_ChatAgainstDeadServer(t *testing.T) {
+	dead := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer dead.Close()
+
+	var body map[string]any
+	live := newOpenAICompatServer(t, &body)
+	defer live.Close()
+
+	primary, _ := NewOpenAIProvider("k", "gpt-4o", dead.URL)
+	backup, _ := newGithubProvider("t", "gpt-4o", live.URL)
+	f, events, _ := newTestChain(t, primary, backup)
+
+	resp, err := f.Chat(context.Background(), toolConversation, ChatOptions{Tools: []ToolDefinition{readFileTool}})
+	if err != nil {
+		t.Fatalf("Chat failed: %v", err)
+	}
+	assertReadFileCall(t, resp.ToolCalls)
+	if len(*events) != 1 || (*events)[0].to != "github-models" {
+		t.Errorf("unexpected switch events: %+v", *events)
+	}
+}
+
+func TestIsTransient(t *testing.T) {
+	cases := []struct {
+		err  error
+		want bool
+	}{
+		{nil, false},
+		{context.Canceled, false},
+		{&StatusError{StatusCode: 500}, true},
+		{&StatusError{StatusCode: 429}, false},
+		{errors.New("API returned unexpected status code: 502: bad gateway"), true},
+		{errors.New("API returned unexpected status code: 401: nope"), false},
+		{errors.New("dial tcp 127.0.0.1:11434: connect: connection refused"), true},
+	}
+	for _, c := range cases {
+		if got := IsTransient(c.err); got != c.want {
+			t.Errorf("IsTransient(%v) = %v, want %v", c.err, got, c.want)
+		}
+	}
+}
+
+func TestFallbackProvider_SupportsTools(t *testing.T) {
+	openai, _ := NewOpenAIProvider("k", "gpt-4o", "http://127.0.0.1:0")
+	github, _ := NewOpenAIProvider("k", "gpt-4o-mini", "http://127.0.0.1:0")
+
+	cases := []struct {
+		name  string
+		chain []Provider
+		want  bool
+	}{
+		{"all members call tools", []Provider{openai, github}, true},
+		{"a member without tools", []Provider{openai, &flakyProvider{name: "plain"}}, false},
+		{"primary without tools", []Provider{&flakyProvider{name: "plain"}, openai}, false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			f, err := NewFallbackProvider(tc.chain, 0, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := New(f).SupportsTools(); got != tc.want {
+				t.Errorf("SupportsTools() = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
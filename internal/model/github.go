@@ -15,8 +15,9 @@ import (
 // Since GitHub Models is OpenAI-compatible, we wrap the OpenAI provider
 // but point it to the GitHub inference endpoint.
 type GithubProvider struct {
-	llm   llms.Model
-	token string
+	llm     llms.Model
+	token   string
+	baseURL string
 }
 
 const (
@@ -33,13 +34,17 @@ func (p *GithubProvider) Name() string { return "github-models" }
 
 // NewGithubProvider creates a new GitHub Models provider
 func NewGithubProvider(token string, modelName string) (*GithubProvider, error) {
+	return newGithubProvider(token, modelName, GithubModelsBaseURL)
+}
+
+func newGithubProvider(token string, modelName string, baseURL string) (*GithubProvider, error) {
 	if modelName == "" {
 		modelName = "gpt-4o" // Sensible default for GitHub Models
 	}
 
 	llm, err := openai.New(
 		openai.WithToken(token),
-		openai.WithBaseURL(GithubModelsBaseURL),
+		openai.WithBaseURL(baseURL),
 		openai.WithModel(modelName),
 		openai.WithHTTPClient(&http.Client{
 			Transport: newGithubTransport(token, http.DefaultTransport),
@@ -50,8 +55,9 @@ func NewGithubProvider(token string, modelName string) (*GithubProvider, error)
 	}
 
 	return &GithubProvider{
-		llm:   llm,
-		token: token,
+		llm:     llm,
+		token:   token,
+		baseURL: baseURL,
 	}, nil
 }
 
@@ -65,35 +71,42 @@ func (p *GithubProvider) Generate(ctx context.Context, prompt string) (string, e
 	return resp, nil
 }
 
-// ListModels returns a list of available models from GitHub Models
-func (p *GithubProvider) ListModels(ctx context.Context) ([]string, error) {
-	// GitHub Models uses the standard OpenAI /models endpoint or its own models API
-	req, err := http.NewRequestWithContext(ctx, "GET", GithubModelsBaseURL+"/models", nil)
-	if err != nil {
-		return nil, err
-	}
-	client := &http.Client{
-		Transport: newGithubTransport(p.token, http.DefaultTransport),
-	}
-	resp, err := client.Do(req)
+// Chat sends a role-separated conversation to GitHub Models
+func (p *GithubProvider) Chat(ctx context.Context, messages []Message, opts ChatOptions) (ChatResponse, error) {
+	resp, err := chatLLM(ctx, p.llm, messages, opts)
 	if err != nil {
-		return nil, err
+		return ChatResponse{}, fmt.Errorf("github models chat: %w", err)
 	}
-	defer resp.Body.Close()
+	return resp, nil
+}
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("github models list failed: %s", resp.Status)
-	}
+// GenerateWithTools sends a prompt with native tool schemas to GitHub Models
+func (p *GithubProvider) GenerateWithTools(ctx context.Context, prompt string, tools []ToolDefinition) (string, []ToolCall, error) {
+	resp, err := p.Chat(ctx, []Message{{Role: RoleUser, Content: prompt}}, ChatOptions{Tools: tools})
+	return resp.Content, resp.ToolCalls, err
+}
 
-	// GitHub Models API can return either a top-level array or an object with a "data" field (OpenAI style)
-	var raw interface{}
-	if err := json.NewDecoder(resp.Body).Decode(&raw); err != nil {
-		return nil, fmt.Errorf("decoding github models: %w", err)
+// GenerateStream streams the response from GitHub Models, calling onDelta for each chunk
+func (p *GithubProvider) GenerateStream(ctx context.Context, prompt string, onDelta StreamFunc) (string, error) {
+	resp, err := p.Chat(ctx, []Message{{Role: RoleUser, Content: prompt}}, ChatOptions{OnDelta: onDelta})
+	return resp.Content, err
+}
+
+// GenerateWithToolsStream streams text deltas while collecting native tool calls
+func (p *GithubProvider) GenerateWithToolsStream(ctx context.Context, prompt string, tools []ToolDefinition, onDelta StreamFunc) (string, []ToolCall, error) {
+	resp, err := p.Chat(ctx, []Message{{Role: RoleUser, Content: prompt}}, ChatOptions{Tools: tools, OnDelta: onDelta})
+	return resp.Content, resp.ToolCalls, err
+}
+
+// ListModels returns a list of available models from GitHub Models
+func (p *GithubProvider) ListModels(ctx context.Context) ([]string, error) {
+	entries, err := p.modelEntries(ctx)
+	if err != nil {
+		return nil, err
 	}
 
 	var models []string
-
-	processEntry := func(m map[string]interface{}) {
+	for _, m := range entries {
 		// Use "name" as the primary identifier if available, per AI.md example
 		name, _ := m["name"].(string)
 		id, _ := m["id"].(string)
@@ -129,31 +142,100 @@ func (p *GithubProvider) ListModels(ctx context.Context) ([]string, error) {
 		}
 	}
 
-	switch v := raw.(type) {
-	case []interface{}:
-		// Top-level array format
-		for _, item := range v {
+	if len(models) == 0 {
+		return nil, fmt.Errorf("no models found in github response")
+	}
+
+	return models, nil
+}
+
+// modelEntries fetches the raw model catalog.
+func (p *GithubProvider) modelEntries(ctx context.Context) ([]map[string]interface{}, error) {
+	// GitHub Models uses the standard OpenAI /models endpoint or its own models API
+	req, err := http.NewRequestWithContext(ctx, "GET", p.baseURL+"/models", nil)
+	if err != nil {
+		return nil, err
+	}
+	client := &http.Client{
+		Transport: newGithubTransport(p.token, http.DefaultTransport),
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("github models list failed: %s", resp.Status)
+	}
+
+	// GitHub Models API can return either a top-level array or an object with a "data" field (OpenAI style)
+	var raw interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&raw); err != nil {
+		return nil, fmt.Errorf("decoding github models: %w", err)
+	}
+
+	var entries []map[string]interface{}
+	collect := func(items []interface{}) {
+		for _, item := range items {
 			if m, ok := item.(map[string]interface{}); ok {
-				processEntry(m)
+				entries = append(entries, m)
 			}
 		}
+	}
+
+	switch v := raw.(type) {
+	case []interface{}:
+		// Top-level array format
+		collect(v)
 	case map[string]interface{}:
 		// Object format (check for "data" field)
 		if data, ok := v["data"].([]interface{}); ok {
-			for _, item := range data {
-				if m, ok := item.(map[string]interface{}); ok {
-					processEntry(m)
-				}
-			}
+			collect(data)
 		} else {
 			// Maybe it's just a single object? (unlikely but safe)
-			processEntry(v)
+			entries = append(entries, v)
 		}
 	}
+	return entries, nil
+}
 
-	if len(models) == 0 {
-		return nil, fmt.Errorf("no models found in github response")
+// ModelCapabilities reads limits and capability flags from the model catalog entry.
+func (p *GithubProvider) ModelCapabilities(ctx context.Context, model string) (Capabilities, error) {
+	entries, err := p.modelEntries(ctx)
+	if err != nil {
+		return Capabilities{}, err
 	}
+	for _, m := range entries {
+		name, _ := m["name"].(string)
+		id, _ := m["id"].(string)
+		if !strings.EqualFold(name, model) && !strings.EqualFold(id, model) {
+			continue
+		}
 
-	return models, nil
+		limits, _ := m["limits"].(map[string]interface{})
+		input, _ := limits["max_input_tokens"].(float64)
+		output, _ := limits["max_output_tokens"].(float64)
+		if input == 0 {
+			return Capabilities{}, fmt.Errorf("github models does not report limits for %s", model)
+		}
+		caps := Capabilities{ContextWindow: int(input + output), MaxOutputTokens: int(output)}
+		flags, _ := m["capabilities"].([]interface{})
+		for _, f := range flags {
+			switch f {
+			case "tool-calling":
+				caps.Tools = true
+			case "streaming":
+				caps.Streaming = true
+			}
+		}
+		modalities, _ := m["supported_input_modalities"].([]interface{})
+		for _, mod := range modalities {
+			if mod == "image" {
+				caps.Vision = true
+			}
+		}
+		return caps, nil
+	}
+	return Capabilities{}, fmt.Errorf("model %s not found in github models catalog", model)
 }
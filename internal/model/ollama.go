@@ -2,9 +2,11 @@ package model
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/url"
+	"strings"
 
 	"github.com/ollama/ollama/api"
 )
@@ -82,6 +84,157 @@ func (p *OllamaProvider) Generate(ctx context.Context, prompt string) (string, e
 	return response, nil
 }
 
+// GenerateStream streams the response from Ollama, calling onDelta for each token batch
+func (p *OllamaProvider) GenerateStream(ctx context.Context, prompt string, onDelta StreamFunc) (string, error) {
+	var response strings.Builder
+	stream := true
+
+	req := &api.GenerateRequest{
+		Model:  p.model,
+		Prompt: prompt,
+		Stream: &stream,
+	}
+
+	fn := func(resp api.GenerateResponse) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if resp.Response != "" {
+			response.WriteString(resp.Response)
+			onDelta(resp.Response)
+		}
+		return nil
+	}
+
+	if err := p.client.Generate(ctx, req, fn); err != nil {
+		return "", fmt.Errorf("ollama generate: %w", err)
+	}
+
+	return response.String(), nil
+}
+
+// GenerateWithTools sends a prompt to Ollama's chat endpoint with the `tools` field populated
+func (p *OllamaProvider) GenerateWithTools(ctx context.Context, prompt string, tools []ToolDefinition) (string, []ToolCall, error) {
+	resp, err := p.Chat(ctx, []Message{{Role: RoleUser, Content: prompt}}, ChatOptions{Tools: tools})
+	return resp.Content, resp.ToolCalls, err
+}
+
+// GenerateWithToolsStream streams text deltas from Ollama's chat endpoint while collecting tool calls
+func (p *OllamaProvider) GenerateWithToolsStream(ctx context.Context, prompt string, tools []ToolDefinition, onDelta StreamFunc) (string, []ToolCall, error) {
+	resp, err := p.Chat(ctx, []Message{{Role: RoleUser, Content: prompt}}, ChatOptions{Tools: tools, OnDelta: onDelta})
+	return resp.Content, resp.ToolCalls, err
+}
+
+// Chat sends a role-separated conversation to Ollama's chat endpoint.
+// A non-nil opts.OnDelta enables streaming.
+func (p *OllamaProvider) Chat(ctx context.Context, messages []Message, opts ChatOptions) (ChatResponse, error) {
+	apiTools, err := toOllamaTools(opts.Tools)
+	if err != nil {
+		return ChatResponse{}, fmt.Errorf("ollama tools: %w", err)
+	}
+	apiMessages, err := toOllamaMessages(messages)
+	if err != nil {
+		return ChatResponse{}, fmt.Errorf("ollama messages: %w", err)
+	}
+
+	stream := opts.OnDelta != nil
+	req := &api.ChatRequest{
+		Model:    p.model,
+		Messages: apiMessages,
+		Stream:   &stream,
+		Tools:    apiTools,
+	}
+
+	var content strings.Builder
+	var calls []ToolCall
+	var usage Usage
+	fn := func(resp api.ChatResponse) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if resp.Done {
+			usage = Usage{PromptTokens: resp.PromptEvalCount, CompletionTokens: resp.EvalCount}
+		}
+		if resp.Message.Content != "" {
+			content.WriteString(resp.Message.Content)
+			if opts.OnDelta != nil {
+				opts.OnDelta(resp.Message.Content)
+			}
+		}
+		for _, tc := range resp.Message.ToolCalls {
+			args, err := json.Marshal(tc.Function.Arguments)
+			if err != nil {
+				return fmt.Errorf("encoding arguments for %s: %w", tc.Function.Name, err)
+			}
+			id := tc.ID
+			if id == "" {
+				// Ollama doesn't always assign IDs; synthesize stable ones per response.
+				id = fmt.Sprintf("call_%d", len(calls))
+			}
+			calls = append(calls, ToolCall{
+				ID:        id,
+				Name:      tc.Function.Name,
+				Arguments: args,
+			})
+		}
+		return nil
+	}
+
+	if err := p.client.Chat(ctx, req, fn); err != nil {
+		return ChatResponse{}, fmt.Errorf("ollama chat: %w", err)
+	}
+
+	return ChatResponse{Content: content.String(), ToolCalls: calls, Usage: usage}, nil
+}
+
+// toOllamaMessages converts a conversation to Ollama's chat message format.
+func toOllamaMessages(messages []Message) ([]api.Message, error) {
+	out := make([]api.Message, 0, len(messages))
+	for _, msg := range messages {
+		am := api.Message{
+			Role:       string(msg.Role),
+			Content:    msg.Content,
+			ToolName:   msg.Name,
+			ToolCallID: msg.ToolCallID,
+		}
+		for _, c := range msg.ToolCalls {
+			var args api.ToolCallFunctionArguments
+			if err := json.Unmarshal(c.Arguments, &args); err != nil {
+				return nil, fmt.Errorf("arguments for %s: %w", c.Name, err)
+			}
+			am.ToolCalls = append(am.ToolCalls, api.ToolCall{
+				ID:       c.ID,
+				Function: api.ToolCallFunction{Name: c.Name, Arguments: args},
+			})
+		}
+		out = append(out, am)
+	}
+	return out, nil
+}
+
+// toOllamaTools converts JSON Schema tool definitions into Ollama's typed tool format.
+func toOllamaTools(tools []ToolDefinition) (api.Tools, error) {
+	out := make(api.Tools, 0, len(tools))
+	for _, t := range tools {
+		var params api.ToolFunctionParameters
+		if err := json.Unmarshal(schemaOrEmpty(t.Parameters), &params); err != nil {
+			return nil, fmt.Errorf("schema for %s: %w", t.Name, err)
+		}
+		if params.Type == "" {
+			params.Type = "object"
+		}
+		out = append(out, api.Tool{
+			Type: "function",
+			Function: api.ToolFunction{
+				Name:        t.Name,
+				Description: t.Description,
+				Parameters:  params,
+			},
+		})
+	}
+	return out, nil
+}
+
 // ListModels returns a list of available models from Ollama
 func (p *OllamaProvider) ListModels(ctx context.Context) ([]string, error) {
 	resp, err := p.client.List(ctx)
@@ -96,3 +249,39 @@ func (p *OllamaProvider) ListModels(ctx context.Context) ([]string, error) {
 	return models, nil
 }
 
+
+// ollamaDefaultContext is the server's context length unless num_ctx or
+// OLLAMA_CONTEXT_LENGTH raises it; prompts beyond it are silently truncated.
+const ollamaDefaultContext = 4096
+
+// ModelCapabilities reads the context length and capability flags from /api/show.
+func (p *OllamaProvider) ModelCapabilities(ctx context.Context, model string) (Capabilities, error) {
+	show, err := p.client.Show(ctx, &api.ShowRequest{Model: model})
+	if err != nil {
+		return Capabilities{}, fmt.Errorf("ollama show: %w", err)
+	}
+
+	caps := Capabilities{ContextWindow: ollamaDefaultContext, Streaming: true}
+	if arch, ok := show.ModelInfo["general.architecture"].(string); ok {
+		if n, ok := show.ModelInfo[arch+".context_length"].(float64); ok && int(n) < caps.ContextWindow {
+			caps.ContextWindow = int(n)
+		}
+	}
+	for _, line := range strings.Split(show.Parameters, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 2 && fields[0] == "num_ctx" {
+			fmt.Sscanf(fields[1], "%d", &caps.ContextWindow)
+		}
+	}
+	caps.MaxOutputTokens = caps.ContextWindow / 2
+
+	for _, c := range show.Capabilities {
+		switch string(c) {
+		case "tools":
+			caps.Tools = true
+		case "vision":
+			caps.Vision = true
+		}
+	}
+	return caps, nil
+}
@@ -67,6 +67,33 @@ func (p *OpenAIProvider) Generate(ctx context.Context, prompt string) (string, e
 	return resp, nil
 }
 
+// Chat sends a role-separated conversation to OpenAI
+func (p *OpenAIProvider) Chat(ctx context.Context, messages []Message, opts ChatOptions) (ChatResponse, error) {
+	resp, err := chatLLM(ctx, p.llm, messages, opts)
+	if err != nil {
+		return ChatResponse{}, fmt.Errorf("openai chat: %w", err)
+	}
+	return resp, nil
+}
+
+// GenerateWithTools sends a prompt with native tool schemas to OpenAI
+func (p *OpenAIProvider) GenerateWithTools(ctx context.Context, prompt string, tools []ToolDefinition) (string, []ToolCall, error) {
+	resp, err := p.Chat(ctx, []Message{{Role: RoleUser, Content: prompt}}, ChatOptions{Tools: tools})
+	return resp.Content, resp.ToolCalls, err
+}
+
+// GenerateStream streams the response from OpenAI, calling onDelta for each chunk
+func (p *OpenAIProvider) GenerateStream(ctx context.Context, prompt string, onDelta StreamFunc) (string, error) {
+	resp, err := p.Chat(ctx, []Message{{Role: RoleUser, Content: prompt}}, ChatOptions{OnDelta: onDelta})
+	return resp.Content, err
+}
+
+// GenerateWithToolsStream streams text deltas while collecting native tool calls
+func (p *OpenAIProvider) GenerateWithToolsStream(ctx context.Context, prompt string, tools []ToolDefinition, onDelta StreamFunc) (string, []ToolCall, error) {
+	resp, err := p.Chat(ctx, []Message{{Role: RoleUser, Content: prompt}}, ChatOptions{Tools: tools, OnDelta: onDelta})
+	return resp.Content, resp.ToolCalls, err
+}
+
 // ListModels returns a list of available models from OpenAI
 func (p *OpenAIProvider) ListModels(ctx context.Context) ([]string, error) {
 	url := p.baseURL + "/models"
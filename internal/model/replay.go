@@ -0,0 +1,341 @@
+package model
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// ReplayMatch selects how a replaying provider pairs requests with recorded interactions.
+type ReplayMatch string
+
+const (
+	// ReplayStrict serves interactions in recorded order and fails when a request differs.
+	ReplayStrict ReplayMatch = "strict"
+	// ReplayFuzzy serves the next unused interaction whose last message resembles the
+	// request's. Interactions recorded without messages match any request.
+	ReplayFuzzy ReplayMatch = "fuzzy"
+)
+
+// fuzzyThreshold is the word overlap two messages need to count as the same request.
+const fuzzyThreshold = 0.5
+
+// ErrCassetteMismatch is returned when a request has no matching recorded interaction.
+var ErrCassetteMismatch = errors.New("no matching interaction in cassette")
+
+func init() {
+	Register("replay", func(config map[string]string) (Provider, error) {
+		if config["mode"] == "record" {
+			switch config["record_provider"] {
+			case "":
+				return nil, fmt.Errorf("replay init: record mode needs a record_provider")
+			case "replay":
+				return nil, fmt.Errorf("replay init: cannot record the replay provider itself")
+			}
+			inner, err := GetProvider(config["record_provider"], config)
+			if err != nil {
+				return nil, fmt.Errorf("replay init: %w", err)
+			}
+			return NewRecordingProvider(inner, config["model"], config["cassette"])
+		}
+		return NewReplayProvider(config["cassette"], ReplayMatch(config["match"]))
+	})
+}
+
+// Cassette is the on-disk record of a provider session.
+type Cassette struct {
+	Provider string `json:"provider,omitempty"`
+	Model    string `json:"model,omitempty"`
+	// NativeTools records whether the provider called tools natively, so replays
+	// take the same path through the agent loop as the recording did.
+	NativeTools  bool          `json:"native_tools"`
+	Interactions []Interaction `json:"interactions"`
+}
+
+// Interaction is one request and the response it produced.
+type Interaction struct {
+	Request  CassetteRequest  `json:"request"`
+	Response CassetteResponse `json:"response"`
+}
+
+// CassetteRequest is the part of a Chat call used for matching.
+type CassetteRequest struct {
+	Messages []Message `json:"messages,omitempty"`
+	Tools    []string  `json:"tools,omitempty"`
+}
+
+// CassetteResponse is a recorded reply. Error is set when the call failed.
+type CassetteResponse struct {
+	Content   string     `json:"content,omitempty"`
+	ToolCalls []ToolCall `json:"tool_calls,omitempty"`
+	Usage     Usage      `json:"usage"`
+	Error     string     `json:"error,omitempty"`
+}
+
+// LoadCassette reads a cassette file.
+func LoadCassette(file string) (*Cassette, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var c Cassette
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("parsing cassette %s: %w", file, err)
+	}
+	return &c, nil
+}
+
+// Save writes the cassette atomically.
+func (c *Cassette) Save(file string) error {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return err
+	}
+	tmp := file + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, file)
+}
+
+// ReplayProvider records the traffic of a real provider to a cassette, or serves a
+// cassette back without touching the network. It is meant for deterministic tests of
+// the agent loop and for reproducing sessions offline.
+type ReplayProvider struct {
+	mu       sync.Mutex
+	cassette *Cassette
+	file     string
+	match    ReplayMatch
+	used     []bool
+
+	// inner is set in record mode.
+	inner Provider
+}
+
+// NewReplayProvider serves the interactions stored in file.
+func NewReplayProvider(file string, match ReplayMatch) (*ReplayProvider, error) {
+	if file == "" {
+		return nil, fmt.Errorf("replay init: cassette path is required")
+	}
+	c, err := LoadCassette(file)
+	if err != nil {
+		return nil, fmt.Errorf("replay init: %w", err)
+	}
+	return NewReplayProviderFromCassette(c, match), nil
+}
+
+// NewReplayProviderFromCassette serves an in-memory cassette.
+func NewReplayProviderFromCassette(c *Cassette, match ReplayMatch) *ReplayProvider {
+	if match == "" {
+		match = ReplayFuzzy
+	}
+	return &ReplayProvider{cassette: c, match: match, used: make([]bool, len(c.Interactions))}
+}
+
+// NewRecordingProvider wraps inner and writes every interaction to file, replacing
+// any previous recording.
+func NewRecordingProvider(inner Provider, modelName, file string) (*ReplayProvider, error) {
+	if file == "" {
+		return nil, fmt.Errorf("replay init: cassette path is required")
+	}
+	c := &Cassette{Provider: inner.Name(), Model: modelName, NativeTools: New(inner).SupportsTools()}
+	if err := c.Save(file); err != nil {
+		return nil, fmt.Errorf("replay init: %w", err)
+	}
+	return &ReplayProvider{cassette: c, file: file, inner: inner}, nil
+}
+
+func (r *ReplayProvider) Name() string { return "replay" }
+
+// Recording reports whether the provider is recording rather than replaying.
+func (r *ReplayProvider) Recording() bool { return r.inner != nil }
+
+// SupportsTools reports whether the recorded provider called tools natively.
+func (r *ReplayProvider) SupportsTools() bool { return r.cassette.NativeTools }
+
+// Remaining returns how many recorded interactions have not been served yet.
+func (r *ReplayProvider) Remaining() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	n := 0
+	for _, u := range r.used {
+		if !u {
+			n++
+		}
+	}
+	return n
+}
+
+// Chat records or replays a structured conversation.
+func (r *ReplayProvider) Chat(ctx context.Context, messages []Message, opts ChatOptions) (ChatResponse, error) {
+	req := CassetteRequest{Messages: messages}
+	for _, t := range opts.Tools {
+		req.Tools = append(req.Tools, t.Name)
+	}
+
+	if r.inner != nil {
+		return r.record(ctx, req, opts)
+	}
+
+	if err := ctx.Err(); err != nil {
+		return ChatResponse{}, err
+	}
+	resp, err := r.next(req)
+	if err != nil {
+		return ChatResponse{}, err
+	}
+	if resp.Error != "" {
+		return ChatResponse{}, errors.New(resp.Error)
+	}
+	if opts.OnDelta != nil && resp.Content != "" {
+		opts.OnDelta(resp.Content)
+	}
+	return ChatResponse{Content: resp.Content, ToolCalls: resp.ToolCalls, Usage: resp.Usage, Provider: r.cassette.Provider}, nil
+}
+
+func (r *ReplayProvider) record(ctx context.Context, req CassetteRequest, opts ChatOptions) (ChatResponse, error) {
+	resp, err := New(r.inner).Chat(ctx, req.Messages, opts)
+	rec := CassetteResponse{Content: resp.Content, ToolCalls: resp.ToolCalls, Usage: resp.Usage}
+	if err != nil {
+		rec.Error = err.Error()
+	}
+
+	r.mu.Lock()
+	r.cassette.Interactions = append(r.cassette.Interactions, Interaction{Request: req, Response: rec})
+	saveErr := r.cassette.Save(r.file)
+	r.mu.Unlock()
+
+	if err == nil && saveErr != nil {
+		err = fmt.Errorf("recording cassette: %w", saveErr)
+	}
+	return resp, err
+}
+
+// next picks the interaction that answers req and marks it used.
+func (r *ReplayProvider) next(req CassetteRequest) (CassetteResponse, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i, in := range r.cassette.Interactions {
+		if r.used[i] {
+			continue
+		}
+		if r.match == ReplayStrict {
+			if diff := diffRequests(in.Request, req); diff != "" {
+				return CassetteResponse{}, fmt.Errorf("%w: interaction %d: %s", ErrCassetteMismatch, i, diff)
+			}
+		} else if !fuzzyMatch(in.Request, req) {
+			continue
+		}
+		r.used[i] = true
+		return in.Response, nil
+	}
+	return CassetteResponse{}, fmt.Errorf("%w (last message: %q)", ErrCassetteMismatch, preview(lastMessage(req.Messages).Content))
+}
+
+// diffRequests describes the first difference between a recorded and an actual request.
+func diffRequests(want, got CassetteRequest) string {
+	if strings.Join(want.Tools, ",") != strings.Join(got.Tools, ",") {
+		return fmt.Sprintf("tools %v, got %v", want.Tools, got.Tools)
+	}
+	if len(want.Messages) != len(got.Messages) {
+		return fmt.Sprintf("%d messages, got %d", len(want.Messages), len(got.Messages))
+	}
+	for i := range want.Messages {
+		w, _ := json.Marshal(want.Messages[i])
+		g, _ := json.Marshal(got.Messages[i])
+		if string(w) != string(g) {
+			return fmt.Sprintf("message %d (%s) differs: %q, got %q", i, got.Messages[i].Role, preview(want.Messages[i].Content), preview(got.Messages[i].Content))
+		}
+	}
+	return ""
+}
+
+// fuzzyMatch compares the last non-system message of both requests by role and word overlap.
+// System prompts are ignored because they embed the working directory and file snapshots.
+func fuzzyMatch(want, got CassetteRequest) bool {
+	w := lastMessage(want.Messages)
+	if w.Role == "" {
+		return true
+	}
+	g := lastMessage(got.Messages)
+	return w.Role == g.Role && WordSimilarity(w.Content, g.Content) >= fuzzyThreshold
+}
+
+func lastMessage(messages []Message) Message {
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].Role != RoleSystem {
+			return messages[i]
+		}
+	}
+	return Message{}
+}
+
+// WordSimilarity returns the Jaccard similarity of the lower-cased words of a and b.
+func WordSimilarity(a, b string) float64 {
+	split := func(s string) map[string]bool {
+		words := make(map[string]bool)
+		for _, w := range strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+		}) {
+			words[w] = true
+		}
+		return words
+	}
+	wa, wb := split(a), split(b)
+	if len(wa) == 0 && len(wb) == 0 {
+		return 1
+	}
+	shared := 0
+	for w := range wa {
+		if wb[w] {
+			shared++
+		}
+	}
+	return float64(shared) / float64(len(wa)+len(wb)-shared)
+}
+
+func preview(s string) string {
+	if len(s) > 60 {
+		return s[:60] + "..."
+	}
+	return s
+}
+
+// Generate records or replays a single prompt as a one-message conversation.
+func (r *ReplayProvider) Generate(ctx context.Context, prompt string) (string, error) {
+	resp, err := r.Chat(ctx, []Message{{Role: RoleUser, Content: prompt}}, ChatOptions{})
+	return resp.Content, err
+}
+
+// GenerateWithTools records or replays a tool-enabled prompt.
+func (r *ReplayProvider) GenerateWithTools(ctx context.Context, prompt string, tools []ToolDefinition) (string, []ToolCall, error) {
+	resp, err := r.Chat(ctx, []Message{{Role: RoleUser, Content: prompt}}, ChatOptions{Tools: tools})
+	return resp.Content, resp.ToolCalls, err
+}
+
+// GenerateStream records or replays a prompt; replayed text arrives as one delta.
+func (r *ReplayProvider) GenerateStream(ctx context.Context, prompt string, onDelta StreamFunc) (string, error) {
+	resp, err := r.Chat(ctx, []Message{{Role: RoleUser, Content: prompt}}, ChatOptions{OnDelta: onDelta})
+	return resp.Content, err
+}
+
+// ListModels returns the recorded model, or the wrapped provider's models when recording.
+func (r *ReplayProvider) ListModels(ctx context.Context) ([]string, error) {
+	if r.inner != nil {
+		return r.inner.ListModels(ctx)
+	}
+	if r.cassette.Model == "" {
+		return nil, nil
+	}
+	return []string{r.cassette.Model}, nil
+}
@@ -0,0 +1,115 @@
+package model
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// scriptedToolProvider answers with a tool call first and plain text afterwards.
+type scriptedToolProvider struct {
+	flakyProvider
+}
+
+func (p *scriptedToolProvider) GenerateWithTools(ctx context.Context, prompt string, tools []ToolDefinition) (string, []ToolCall, error) {
+	p.calls++
+	if p.calls == 1 {
+		return "", []ToolCall{{ID: "call_1", Name: "fs_read", Arguments: json.RawMessage(`{"path":"go.mod"}`)}}, nil
+	}
+	return "done", nil, nil
+}
+
+func TestReplayProvider_RecordThenReplay(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "session.json")
+	rec, err := NewRecordingProvider(&scriptedToolProvider{flakyProvider{name: "live"}}, "live-1", file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !New(rec).SupportsTools() {
+		t.Fatal("recording a tool caller should report native tools")
+	}
+
+	tools := []ToolDefinition{{Name: "fs_read"}}
+	first := []Message{{Role: RoleSystem, Content: "sys"}, {Role: RoleUser, Content: "read go.mod"}}
+	resp, err := New(rec).Chat(context.Background(), first, ChatOptions{Tools: tools})
+	if err != nil || len(resp.ToolCalls) != 1 {
+		t.Fatalf("record: got %+v, %v", resp, err)
+	}
+	second := append(first, Message{Role: RoleAssistant, ToolCalls: resp.ToolCalls},
+		Message{Role: RoleTool, ToolCallID: "call_1", Name: "fs_read", Content: "module x"})
+	if _, err := New(rec).Chat(context.Background(), second, ChatOptions{Tools: tools}); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := LoadCassette(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(c.Interactions) != 2 || c.Provider != "live" || !c.NativeTools {
+		t.Fatalf("unexpected cassette: %+v", c)
+	}
+
+	// Strict replay serves the exact same conversation back.
+	strict, err := NewReplayProvider(file, ReplayStrict)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err = New(strict).Chat(context.Background(), first, ChatOptions{Tools: tools})
+	if err != nil || resp.ToolCalls[0].Name != "fs_read" || resp.Provider != "live" {
+		t.Fatalf("strict replay: got %+v, %v", resp, err)
+	}
+	if _, err := New(strict).Chat(context.Background(), first, ChatOptions{Tools: tools}); !errors.Is(err, ErrCassetteMismatch) {
+		t.Fatalf("expected a mismatch for a diverging request, got %v", err)
+	}
+
+	// Fuzzy replay tolerates a different system prompt and small wording changes.
+	fuzzy, err := NewReplayProvider(file, ReplayFuzzy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	drifted := []Message{{Role: RoleSystem, Content: "another cwd"}, {Role: RoleUser, Content: "please read go.mod"}}
+	if resp, err := New(fuzzy).Chat(context.Background(), drifted, ChatOptions{}); err != nil || len(resp.ToolCalls) != 1 {
+		t.Fatalf("fuzzy replay: got %+v, %v", resp, err)
+	}
+	if fuzzy.Remaining() != 1 {
+		t.Errorf("expected one interaction left, got %d", fuzzy.Remaining())
+	}
+	if _, err := New(fuzzy).Chat(context.Background(), []Message{{Role: RoleUser, Content: "something else entirely"}}, ChatOptions{}); !errors.Is(err, ErrCassetteMismatch) {
+		t.Errorf("expected unrelated request to miss, got %v", err)
+	}
+}
+
+func TestReplayProvider_WildcardAndErrors(t *testing.T) {
+	r := NewReplayProviderFromCassette(&Cassette{Interactions: []Interaction{
+		{Response: CassetteResponse{Error: "500 Internal Server Error"}},
+		{Response: CassetteResponse{Content: "hello"}},
+	}}, ReplayFuzzy)
+	if New(r).SupportsTools() {
+		t.Error("a cassette without native tools must not report tool support")
+	}
+
+	if _, err := r.Generate(context.Background(), "anything"); err == nil || err.Error() != "500 Internal Server Error" {
+		t.Fatalf("expected the recorded error, got %v", err)
+	}
+	var deltas []string
+	out, err := r.GenerateStream(context.Background(), "anything", func(d string) { deltas = append(deltas, d) })
+	if err != nil || out != "hello" || len(deltas) != 1 {
+		t.Fatalf("got %q, %v, deltas %v", out, err, deltas)
+	}
+	if r.Remaining() != 0 {
+		t.Errorf("expected the cassette to be exhausted")
+	}
+}
+
+func TestReplayProvider_RecordProviderMustBeAnother(t *testing.T) {
+	cassette := filepath.Join(t.TempDir(), "c.json")
+	for _, inner := range []string{"", "replay"} {
+		_, err := GetProvider("replay", map[string]string{"mode": "record", "record_provider": inner, "cassette": cassette})
+		if err == nil || !strings.Contains(err.Error(), "replay init") {
+			t.Errorf("record_provider %q: expected an error, got %v", inner, err)
+		}
+	}
+}
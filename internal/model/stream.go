@@ -0,0 +1,98 @@
+package model
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// StreamFunc receives incremental text as the model produces it.
+type StreamFunc func(delta string)
+
+// StreamingProvider represents a provider that can emit token deltas while generating.
+// Cancelling ctx aborts the underlying stream.
+type StreamingProvider interface {
+	GenerateStream(ctx context.Context, prompt string, onDelta StreamFunc) (string, error)
+}
+
+// StreamingToolCaller represents a provider that streams text deltas while still
+// returning structured tool calls once the response is complete.
+type StreamingToolCaller interface {
+	GenerateWithToolsStream(ctx context.Context, prompt string, tools []ToolDefinition, onDelta StreamFunc) (string, []ToolCall, error)
+}
+
+// SupportsStreaming reports whether the configured provider can stream deltas.
+func (m *Model) SupportsStreaming() bool {
+	_, ok := m.provider.(StreamingProvider)
+	return ok
+}
+
+// GenerateStream generates a response, calling onDelta as text arrives.
+// Providers without streaming support deliver the whole response as a single delta.
+func (m *Model) GenerateStream(ctx context.Context, prompt string, onDelta StreamFunc) (string, error) {
+	if m.provider == nil {
+		return "", fmt.Errorf("no provider configured")
+	}
+	if sp, ok := m.provider.(StreamingProvider); ok {
+		return sp.GenerateStream(ctx, prompt, onDelta)
+	}
+	resp, err := m.provider.Generate(ctx, prompt)
+	if err == nil && onDelta != nil && resp != "" {
+		onDelta(resp)
+	}
+	return resp, err
+}
+
+// GenerateWithToolsStream is the streaming counterpart of GenerateWithTools.
+// Tool callers without streaming support deliver the text as a single delta.
+func (m *Model) GenerateWithToolsStream(ctx context.Context, prompt string, tools []ToolDefinition, onDelta StreamFunc) (string, []ToolCall, error) {
+	if m.provider == nil {
+		return "", nil, fmt.Errorf("no provider configured")
+	}
+	if sp, ok := m.provider.(StreamingToolCaller); ok {
+		return sp.GenerateWithToolsStream(ctx, prompt, tools, onDelta)
+	}
+	resp, calls, err := m.GenerateWithTools(ctx, prompt, tools)
+	if err == nil && onDelta != nil && resp != "" {
+		onDelta(resp)
+	}
+	return resp, calls, err
+}
+
+// llmStreamingFunc adapts a StreamFunc to langchaingo's chunk callback.
+// langchaingo also pushes serialized tool-call deltas through the same callback;
+// those are filtered out so only assistant text reaches the caller.
+func llmStreamingFunc(onDelta StreamFunc) func(ctx context.Context, chunk []byte) error {
+	return func(ctx context.Context, chunk []byte) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if len(chunk) == 0 || isToolCallChunk(chunk) {
+			return nil
+		}
+		onDelta(string(chunk))
+		return nil
+	}
+}
+
+// isToolCallChunk detects the JSON-encoded tool call deltas langchaingo emits while streaming.
+func isToolCallChunk(chunk []byte) bool {
+	if chunk[0] != '[' {
+		return false
+	}
+	var deltas []struct {
+		Function *struct {
+			Name      string `json:"name"`
+			Arguments string `json:"arguments"`
+		} `json:"function"`
+	}
+	if err := json.Unmarshal(chunk, &deltas); err != nil || len(deltas) == 0 {
+		return false
+	}
+	for _, d := range deltas {
+		if d.Function == nil {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,163 @@
+package model
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newSSEServer fakes a streaming /chat/completions endpoint that emits the given SSE data lines.
+func newSSEServer(t *testing.T, events []string) *httptest.Server {
+	t.Helper()
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, e := range events {
+			fmt.Fprintf(w, "data: %s\n\n", e)
+			w.(http.Flusher).Flush()
+		}
+		io.WriteString(w, "data: [DONE]\n\n")
+	}))
+}
+
+func textChunk(s string) string {
+	return fmt.Sprintf(`{"id":"c","object":"chat.completion.chunk","created":1,"model":"gpt-4o","choices":[{"index":0,"delta":{"content":%q}}]}`, s)
+}
+
+func TestOpenAIProvider_GenerateStream(t *testing.T) {
+	srv := newSSEServer(t, []string{textChunk("Hel"), textChunk("lo"), textChunk(" world")})
+	defer srv.Close()
+
+	p, err := NewOpenAIProvider("test-key", "gpt-4o", srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var deltas []string
+	full, err := p.GenerateStream(context.Background(), "hi", func(d string) { deltas = append(deltas, d) })
+	if err != nil {
+		t.Fatalf("GenerateStream failed: %v", err)
+	}
+	if full != "Hello world" {
+		t.Errorf("got %q, want %q", full, "Hello world")
+	}
+	if strings.Join(deltas, "|") != "Hel|lo| world" {
+		t.Errorf("unexpected deltas: %q", deltas)
+	}
+}
+
+func TestGithubProvider_GenerateWithToolsStream(t *testing.T) {
+	toolChunk := `{"id":"c","object":"chat.completion.chunk","created":1,"model":"gpt-4o","choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"id":"call_abc","type":"function","function":{"name":"sys_read_file","arguments":""}}]}}]}`
+	argsChunk := `{"id":"c","object":"chat.completion.chunk","created":1,"model":"gpt-4o","choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"arguments":"{\"path\":\"README.md\"}"}}]}}]}`
+	srv := newSSEServer(t, []string{textChunk("Reading it."), toolChunk, argsChunk})
+	defer srv.Close()
+
+	p, err := newGithubProvider("test-token", "gpt-4o", srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var deltas []string
+	text, calls, err := p.GenerateWithToolsStream(context.Background(), "read the readme", []ToolDefinition{readFileTool}, func(d string) { deltas = append(deltas, d) })
+	if err != nil {
+		t.Fatalf("GenerateWithToolsStream failed: %v", err)
+	}
+	if text != "Reading it." {
+		t.Errorf("got text %q", text)
+	}
+	if len(deltas) != 1 || deltas[0] != "Reading it." {
+		t.Errorf("tool call chunks leaked into text deltas: %q", deltas)
+	}
+	assertReadFileCall(t, calls)
+}
+
+func TestOllamaProvider_GenerateStream(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/generate" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		for _, tok := range []string{"Hel", "lo"} {
+			fmt.Fprintf(w, `{"model":"llama3","response":%q,"done":false}`+"\n", tok)
+		}
+		io.WriteString(w, `{"model":"llama3","response":"","done":true}`+"\n")
+	}))
+	defer srv.Close()
+	t.Setenv("OLLAMA_HOST", srv.URL)
+
+	p, err := NewOllamaProvider("", "llama3")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var deltas []string
+	full, err := p.GenerateStream(context.Background(), "hi", func(d string) { deltas = append(deltas, d) })
+	if err != nil {
+		t.Fatalf("GenerateStream failed: %v", err)
+	}
+	if full != "Hello" || len(deltas) != 2 {
+		t.Errorf("got %q with deltas %q", full, deltas)
+	}
+}
+
+func TestGenerateStream_Cancellation(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		for i := 0; ; i++ {
+			if _, err := fmt.Fprintf(w, "data: %s\n\n", textChunk("tok ")); err != nil {
+				return
+			}
+			w.(http.Flusher).Flush()
+			select {
+			case <-r.Context().Done():
+				return
+			case <-time.After(10 * time.Millisecond):
+			}
+		}
+	}))
+	defer srv.Close()
+
+	p, err := NewOpenAIProvider("test-key", "gpt-4o", srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	received := 0
+	done := make(chan error, 1)
+	go func() {
+		_, err := p.GenerateStream(ctx, "hi", func(string) {
+			received++
+			if received == 3 {
+				cancel()
+			}
+		})
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Fatal("expected an error after cancellation")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("stream did not stop after context cancellation")
+	}
+}
+
+func TestModel_GenerateStream_Fallback(t *testing.T) {
+	m := New(&MockProvider{Response: "whole answer"})
+	var deltas []string
+	resp, err := m.GenerateStream(context.Background(), "hi", func(d string) { deltas = append(deltas, d) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(deltas) != 1 || deltas[0] != resp {
+		t.Errorf("expected the full response as a single delta, got %q", deltas)
+	}
+}
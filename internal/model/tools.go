@@ -0,0 +1,183 @@
+package model
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/tmc/langchaingo/llms"
+)
+
+// ToolDefinition describes a function the model may invoke natively.
+type ToolDefinition struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description"`
+	Parameters  json.RawMessage `json:"parameters"` // JSON Schema
+}
+
+// ToolCall is a structured tool invocation emitted by the model.
+type ToolCall struct {
+	ID        string          `json:"id"`
+	Name      string          `json:"name"`
+	Arguments json.RawMessage `json:"arguments"`
+}
+
+// ToolCaller represents a provider that supports native function/tool calling.
+// Providers that don't implement it fall back to text-based tool invocation.
+type ToolCaller interface {
+	GenerateWithTools(ctx context.Context, prompt string, tools []ToolDefinition) (string, []ToolCall, error)
+}
+
+// ToolSupportReporter is implemented by wrapping providers whose native tool support
+// depends on what they wrap rather than on the methods they expose.
+type ToolSupportReporter interface {
+	SupportsTools() bool
+}
+
+// SupportsTools reports whether the configured provider can call tools natively.
+func (m *Model) SupportsTools() bool {
+	if r, ok := m.provider.(ToolSupportReporter); ok {
+		return r.SupportsTools()
+	}
+	_, ok := m.provider.(ToolCaller)
+	return ok
+}
+
+// GenerateWithTools sends a prompt along with tool schemas and returns the text
+// response plus any tool calls the model requested.
+func (m *Model) GenerateWithTools(ctx context.Context, prompt string, tools []ToolDefinition) (string, []ToolCall, error) {
+	if m.provider == nil {
+		return "", nil, fmt.Errorf("no provider configured")
+	}
+	tc, ok := m.provider.(ToolCaller)
+	if !ok {
+		return "", nil, fmt.Errorf("provider '%s' does not support native tool calling", m.provider.Name())
+	}
+	return tc.GenerateWithTools(ctx, prompt, tools)
+}
+
+// schemaOrEmpty guarantees a valid JSON Schema object for tools without parameters.
+func schemaOrEmpty(schema json.RawMessage) json.RawMessage {
+	if len(strings.TrimSpace(string(schema))) == 0 {
+		return json.RawMessage(`{"type": "object", "properties": {}}`)
+	}
+	return schema
+}
+
+// toLLMTools converts tool definitions to the OpenAI-compatible tools payload.
+func toLLMTools(tools []ToolDefinition) []llms.Tool {
+	out := make([]llms.Tool, 0, len(tools))
+	for _, t := range tools {
+		out = append(out, llms.Tool{
+			Type: "function",
+			Function: &llms.FunctionDefinition{
+				Name:        t.Name,
+				Description: t.Description,
+				Parameters:  schemaOrEmpty(t.Parameters),
+			},
+		})
+	}
+	return out
+}
+
+// chatLLM runs a chat completion against an OpenAI-compatible langchaingo model
+// (OpenAI, GitHub Models, GitHub Copilot). A non-nil opts.OnDelta switches the
+// request to streaming mode.
+func chatLLM(ctx context.Context, llm llms.Model, messages []Message, opts ChatOptions) (ChatResponse, error) {
+	var callOpts []llms.CallOption
+	if len(opts.Tools) > 0 {
+		callOpts = append(callOpts, llms.WithTools(toLLMTools(opts.Tools)))
+	}
+	if opts.OnDelta != nil {
+		callOpts = append(callOpts, llms.WithStreamingFunc(llmStreamingFunc(opts.OnDelta)))
+	}
+
+	resp, err := llm.GenerateContent(ctx, toLLMMessages(messages), callOpts...)
+	if err != nil {
+		return ChatResponse{}, err
+	}
+	// langchaingo may hand back a truncated stream without an error once ctx is cancelled.
+	if err := ctx.Err(); err != nil {
+		return ChatResponse{}, err
+	}
+	if len(resp.Choices) == 0 {
+		return ChatResponse{}, fmt.Errorf("empty response from model")
+	}
+
+	choice := resp.Choices[0]
+	var calls []ToolCall
+	for _, tc := range choice.ToolCalls {
+		if tc.FunctionCall == nil || tc.FunctionCall.Name == "" {
+			continue
+		}
+		args := strings.TrimSpace(tc.FunctionCall.Arguments)
+		if args == "" {
+			args = "{}"
+		}
+		calls = append(calls, ToolCall{
+			ID:        tc.ID,
+			Name:      tc.FunctionCall.Name,
+			Arguments: json.RawMessage(args),
+		})
+	}
+
+	return ChatResponse{Content: choice.Content, ToolCalls: calls, Usage: llmUsage(choice.GenerationInfo)}, nil
+}
+
+// llmUsage reads the token counts langchaingo copies from the API response.
+func llmUsage(info map[string]any) Usage {
+	count := func(key string) int {
+		switch v := info[key].(type) {
+		case int:
+			return v
+		case int32:
+			return int(v)
+		case int64:
+			return int(v)
+		case float64:
+			return int(v)
+		}
+		return 0
+	}
+	return Usage{PromptTokens: count("PromptTokens"), CompletionTokens: count("CompletionTokens")}
+}
+
+// toLLMMessages converts a conversation to langchaingo's message format.
+func toLLMMessages(messages []Message) []llms.MessageContent {
+	out := make([]llms.MessageContent, 0, len(messages))
+	for _, msg := range messages {
+		switch msg.Role {
+		case RoleSystem:
+			out = append(out, llms.TextParts(llms.ChatMessageTypeSystem, msg.Content))
+		case RoleAssistant:
+			mc := llms.MessageContent{Role: llms.ChatMessageTypeAI}
+			if msg.Content != "" {
+				mc.Parts = append(mc.Parts, llms.TextContent{Text: msg.Content})
+			}
+			for _, c := range msg.ToolCalls {
+				mc.Parts = append(mc.Parts, llms.ToolCall{
+					ID:   c.ID,
+					Type: "function",
+					FunctionCall: &llms.FunctionCall{
+						Name:      c.Name,
+						Arguments: string(c.Arguments),
+					},
+				})
+			}
+			out = append(out, mc)
+		case RoleTool:
+			out = append(out, llms.MessageContent{
+				Role: llms.ChatMessageTypeTool,
+				Parts: []llms.ContentPart{llms.ToolCallResponse{
+					ToolCallID: msg.ToolCallID,
+					Name:       msg.Name,
+					Content:    msg.Content,
+				}},
+			})
+		default:
+			out = append(out, llms.TextParts(llms.ChatMessageTypeHuman, msg.Content))
+		}
+	}
+	return out
+}
@@ -0,0 +1,189 @@
+package model
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+var readFileTool = ToolDefinition{
+	Name:        "sys_read_file",
+	Description: "Read the content of a file from the filesystem.",
+	Parameters:  json.RawMessage(`{"type": "object", "properties": {"path": {"type": "string", "description": "Path to the file"}}, "required": ["path"]}`),
+}
+
+// newOpenAICompatServer fakes the /chat/completions endpoint and records the last request body.
+func newOpenAICompatServer(t *testing.T, lastBody *map[string]any) *httptest.Server {
+	t.Helper()
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasSuffix(r.URL.Path, "/chat/completions") {
+			http.NotFound(w, r)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		_ = json.Unmarshal(body, lastBody)
+
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{
+			"id": "chatcmpl-1",
+			"object": "chat.completion",
+			"created": 1,
+			"model": "gpt-4o",
+			"choices": [{
+				"index": 0,
+				"finish_reason": "tool_calls",
+				"message": {
+					"role": "assistant",
+					"content": "",
+					"tool_calls": [{
+						"id": "call_abc",
+						"type": "function",
+						"function": {"name": "sys_read_file", "arguments": "{\"path\":\"README.md\"}"}
+					}]
+				}
+			}],
+			"usage": {"prompt_tokens": 10, "completion_tokens": 5, "total_tokens": 15}
+		}`)
+	}))
+}
+
+func assertReadFileCall(t *testing.T, calls []ToolCall) {
+	t.Helper()
+	if len(calls) != 1 {
+		t.Fatalf("got %d tool calls, want 1", len(calls))
+	}
+	if calls[0].Name != "sys_read_file" || calls[0].ID != "call_abc" {
+		t.Errorf("unexpected tool call: %+v", calls[0])
+	}
+	var args struct {
+		Path string `json:"path"`
+	}
+	if err := json.Unmarshal(calls[0].Arguments, &args); err != nil {
+		t.Fatalf("arguments are not valid JSON: %v", err)
+	}
+	if args.Path != "README.md" {
+		t.Errorf("got path %q, want README.md", args.Path)
+	}
+}
+
+func assertToolsSent(t *testing.T, body map[string]any) {
+	t.Helper()
+	tools, ok := body["tools"].([]any)
+	if !ok || len(tools) != 1 {
+		t.Fatalf("expected one tool in request, got %v", body["tools"])
+	}
+	fn := tools[0].(map[string]any)["function"].(map[string]any)
+	if fn["name"] != "sys_read_file" {
+		t.Errorf("got tool name %v, want sys_read_file", fn["name"])
+	}
+	params := fn["parameters"].(map[string]any)
+	if _, ok := params["properties"].(map[string]any)["path"]; !ok {
+		t.Errorf("tool schema was not forwarded: %v", params)
+	}
+}
+
+func TestOpenAIProvider_GenerateWithTools(t *testing.T) {
+	var body map[string]any
+	srv := newOpenAICompatServer(t, &body)
+	defer srv.Close()
+
+	p, err := NewOpenAIProvider("test-key", "gpt-4o", srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, calls, err := p.GenerateWithTools(context.Background(), "read the readme", []ToolDefinition{readFileTool})
+	if err != nil {
+		t.Fatalf("GenerateWithTools failed: %v", err)
+	}
+	assertToolsSent(t, body)
+	assertReadFileCall(t, calls)
+}
+
+func TestGithubProvider_GenerateWithTools(t *testing.T) {
+	var body map[string]any
+	srv := newOpenAICompatServer(t, &body)
+	defer srv.Close()
+
+	p, err := newGithubProvider("test-token", "gpt-4o", srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, calls, err := p.GenerateWithTools(context.Background(), "read the readme", []ToolDefinition{readFileTool})
+	if err != nil {
+		t.Fatalf("GenerateWithTools failed: %v", err)
+	}
+	assertToolsSent(t, body)
+	assertReadFileCall(t, calls)
+}
+
+func TestCopilotProvider_GenerateWithTools(t *testing.T) {
+	var body map[string]any
+	srv := newOpenAICompatServer(t, &body)
+	defer srv.Close()
+
+	p, err := newCopilotProvider("test-token", "gpt-4o", srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, calls, err := p.GenerateWithTools(context.Background(), "read the readme", []ToolDefinition{readFileTool})
+	if err != nil {
+		t.Fatalf("GenerateWithTools failed: %v", err)
+	}
+	assertToolsSent(t, body)
+	assertReadFileCall(t, calls)
+}
+
+func TestOllamaProvider_GenerateWithTools(t *testing.T) {
+	var body map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/chat" {
+			http.NotFound(w, r)
+			return
+		}
+		raw, _ := io.ReadAll(r.Body)
+		_ = json.Unmarshal(raw, &body)
+
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		io.WriteString(w, `{"model":"llama3.1","message":{"role":"assistant","content":"","tool_calls":[{"function":{"name":"sys_read_file","arguments":{"path":"README.md"}}}]},"done":true}`+"\n")
+	}))
+	defer srv.Close()
+	t.Setenv("OLLAMA_HOST", srv.URL)
+
+	p, err := NewOllamaProvider("", "llama3.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, calls, err := p.GenerateWithTools(context.Background(), "read the readme", []ToolDefinition{readFileTool})
+	if err != nil {
+		t.Fatalf("GenerateWithTools failed: %v", err)
+	}
+	assertToolsSent(t, body)
+
+	if len(calls) != 1 || calls[0].Name != "sys_read_file" {
+		t.Fatalf("unexpected tool calls: %+v", calls)
+	}
+	if calls[0].ID == "" {
+		t.Error("expected a synthesized tool call ID")
+	}
+	if !strings.Contains(string(calls[0].Arguments), `"README.md"`) {
+		t.Errorf("unexpected arguments: %s", calls[0].Arguments)
+	}
+}
+
+func TestModel_SupportsTools(t *testing.T) {
+	if New(&MockProvider{}).SupportsTools() {
+		t.Error("plain providers must not report native tool support")
+	}
+	p, _ := NewOpenAIProvider("k", "gpt-4o", "http://127.0.0.1:0")
+	if !New(p).SupportsTools() {
+		t.Error("openai provider should report native tool support")
+	}
+}
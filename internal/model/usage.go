@@ -0,0 +1,58 @@
+package model
+
+// Usage reports the tokens consumed by a single request.
+type Usage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+
+	// Estimated is true when the counts were approximated locally because the
+	// provider did not report them.
+	Estimated bool `json:"estimated,omitempty"`
+}
+
+// TotalTokens returns prompt plus completion tokens.
+func (u Usage) TotalTokens() int {
+	return u.PromptTokens + u.CompletionTokens
+}
+
+// IsZero reports whether no usage was recorded.
+func (u Usage) IsZero() bool {
+	return u.PromptTokens == 0 && u.CompletionTokens == 0
+}
+
+// messageOverhead approximates the per-message framing tokens chat APIs add.
+const messageOverhead = 4
+
+// EstimateTokens approximates the token count of text. It assumes roughly four
+// characters per token for ASCII, which tracks BPE tokenizers closely enough for
+// cost tracking, and counts every other rune as its own token.
+func EstimateTokens(text string) int {
+	if text == "" {
+		return 0
+	}
+	ascii, other := 0, 0
+	for _, r := range text {
+		if r < 0x80 {
+			ascii++
+		} else {
+			other++
+		}
+	}
+	return (ascii+3)/4 + other
+}
+
+// EstimateUsage approximates the usage of a Chat call from its input and output.
+func EstimateUsage(messages []Message, resp ChatResponse) Usage {
+	u := Usage{Estimated: true}
+	for _, msg := range messages {
+		u.PromptTokens += messageOverhead + EstimateTokens(msg.Content)
+		for _, c := range msg.ToolCalls {
+			u.PromptTokens += EstimateTokens(c.Name) + EstimateTokens(string(c.Arguments))
+		}
+	}
+	u.CompletionTokens = EstimateTokens(resp.Content)
+	for _, c := range resp.ToolCalls {
+		u.CompletionTokens += EstimateTokens(c.Name) + EstimateTokens(string(c.Arguments))
+	}
+	return u
+}
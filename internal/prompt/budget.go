@@ -0,0 +1,83 @@
+package prompt
+
+import "strings"
+
+// Shares of the prompt budget. History gets half of whatever is left after the
+// system message and the user prompt; the rest is headroom for tool output.
+const (
+	recallShare  = 8  // recall may use 1/8 of the budget
+	messageShare = 8  // a single history message may use 1/8 of the budget
+	summaryShare = 20 // the summary of trimmed turns may use 1/20 of the budget
+)
+
+// SetBudget bounds composed conversations to roughly tokens, measured with count.
+// A non-positive budget disables trimming; a nil count assumes four characters per token.
+func (s *System) SetBudget(tokens int, count func(string) int) {
+	s.budget = tokens
+	s.countTokens = count
+}
+
+func (s *System) count(text string) int {
+	if s.countTokens != nil {
+		return s.countTokens(text)
+	}
+	return (len(text) + 3) / 4
+}
+
+// clip keeps the beginning of text up to maxTokens.
+func (s *System) clip(text string, maxTokens int) string {
+	n := s.count(text)
+	if n <= maxTokens {
+		return text
+	}
+	runes := []rune(text)
+	return string(runes[:len(runes)*maxTokens/n]) + "\n[truncated]"
+}
+
+// fitHistory caps each prior turn and keeps the newest ones within budget tokens.
+// Dropped turns are returned oldest first.
+func (s *System) fitHistory(history []Message, budget int) (kept, dropped []Message) {
+	capped := make([]Message, 0, len(history))
+	for _, h := range history {
+		if strings.TrimSpace(h.Content) == "" {
+			continue
+		}
+		h.Content = s.clip(h.Content, s.budget/messageShare)
+		capped = append(capped, h)
+	}
+
+	used, cut := 0, len(capped)
+	for i := len(capped) - 1; i >= 0; i-- {
+		n := s.count(capped[i].Content)
+		if used+n > budget {
+			break
+		}
+		used += n
+		cut = i
+	}
+	// Never start the kept history with a dangling assistant reply.
+	for cut < len(capped) && capped[cut].Role != RoleUser {
+		cut++
+	}
+	return capped[cut:], capped[:cut]
+}
+
+// summarizeDropped lists what the user asked in trimmed turns, one line each.
+func (s *System) summarizeDropped(dropped []Message) string {
+	var b strings.Builder
+	for _, msg := range dropped {
+		if msg.Role != RoleUser {
+			continue
+		}
+		line := strings.TrimSpace(strings.SplitN(msg.Content, "\n", 2)[0])
+		if r := []rune(line); len(r) > 120 {
+			line = string(r[:120]) + "..."
+		}
+		entry := "- " + line + "\n"
+		if s.count(b.String()+entry) > s.budget/summaryShare {
+			break
+		}
+		b.WriteString(entry)
+	}
+	return b.String()
+}
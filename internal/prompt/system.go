@@ -22,6 +22,13 @@ type System struct {
 
 	// Budgeting to avoid unintended spend.
 	recoUsed int
+
+	// Context budget of the active model, see SetBudget.
+	budget      int
+	countTokens func(string) int
+
+	// sandbox tells the model how shell commands are confined, see SetSandbox.
+	sandbox string
 }
 
 func New(cfg *sys.Config, memory Memory, recommender Recommender, model Model) *System {
@@ -38,8 +45,15 @@ func (s *System) SetModel(m Model) {
 	s.model = m
 }
 
+// SetSandbox sets the guideline describing how shell commands are confined. An empty
+// guideline, e.g. with the sandbox disabled, leaves it out of the prompt.
+func (s *System) SetSandbox(guideline string) {
+	s.sandbox = guideline
+}
+
 // Build produces the prompt envelope for a user input.
-func (s *System) Build(ctx context.Context, userText string, snapshot sys.Snapshot, toolDefs string, history string) (Envelope, []Recommendation, error) {
+// history holds prior user/assistant turns, oldest first.
+func (s *System) Build(ctx context.Context, userText string, snapshot sys.Snapshot, toolDefs string, history []Message) (Envelope, []Recommendation, error) {
 	intent := ClassifyIntent(userText)
 	if s.cfg != nil && s.cfg.Prompt.Mode != "" {
 		// Config can force a mode. "auto" keeps classification.
@@ -57,7 +71,7 @@ func (s *System) Build(ctx context.Context, userText string, snapshot sys.Snapsh
 	}
 
 	if !LooksLikePrompt(userText) {
-		return Envelope{Intent: intent, Prompt: "", Instructions: nil, Metadata: map[string]any{"ignored": true}}, nil, nil
+		return Envelope{Intent: intent, Messages: nil, Instructions: nil, Metadata: map[string]any{"ignored": true}}, nil, nil
 	}
 
 	instructions := s.layers(intent, snapshot.WorkingDir)
@@ -71,7 +85,7 @@ func (s *System) Build(ctx context.Context, userText string, snapshot sys.Snapsh
 		}
 	}
 
-	prompt := s.compose(intent, instructions, recall, snapshot, toolDefs, userText, history)
+	messages := s.compose(intent, instructions, recall, snapshot, toolDefs, userText, history)
 
 	// Proactive Project Perception:
 	// If we haven't indexed this project or the SHA changed, re-evaluate architectural info.
@@ -106,7 +120,7 @@ func (s *System) Build(ctx context.Context, userText string, snapshot sys.Snapsh
 
 	return Envelope{
 		Intent:       intent,
-		Prompt:       prompt,
+		Messages:     messages,
 		Instructions: instructions,
 		Metadata: map[string]any{
 			"working_dir": snapshot.WorkingDir,
@@ -179,7 +193,14 @@ func (s *System) layers(intent Intent, wd string) []string {
 	return layers
 }
 
-func (s *System) compose(intent Intent, layers []string, recall string, snapshot sys.Snapshot, toolDefs string, userText string, history string) string {
+// compose assembles the conversation: one system message carrying instructions,
+// recall, snapshot and tool usage, followed by prior turns and the user prompt.
+// With a budget set, recall is clipped and older history is replaced by a summary.
+func (s *System) compose(intent Intent, layers []string, recall string, snapshot sys.Snapshot, toolDefs string, userText string, history []Message) []Message {
+	if s.budget > 0 {
+		recall = s.clip(recall, s.budget/recallShare)
+	}
+
 	b := strings.Builder{}
 	b.WriteString("SYSTEM INSTRUCTIONS:\n")
 	for _, l := range layers {
@@ -188,12 +209,6 @@ func (s *System) compose(intent Intent, layers []string, recall string, snapshot
 		b.WriteString("\n")
 	}
 
-	if strings.TrimSpace(history) != "" {
-		b.WriteString("\n")
-		b.WriteString(history)
-		b.WriteString("\n")
-	}
-
 	if strings.TrimSpace(recall) != "" {
 		b.WriteString("\nLEARNING/RECALL (local):\n")
 		b.WriteString(recall)
@@ -227,17 +242,39 @@ Example - Read a file:
 Guidelines:
 - Execute tool calls directly without asking for permission
 - Handle typos by interpreting the user's intent
-- Report results briefly after tool execution
+- Change existing files with sys_edit_file, sys_multi_edit or sys_apply_patch instead of rewriting them
+- Search code with fs_grep rather than running grep or find through the shell
+`)
+		if s.sandbox != "" {
+			b.WriteString("- " + s.sandbox + "\n")
+		}
+		b.WriteString(`- Report results briefly after tool execution
 - Current directory: ` + snapshot.WorkingDir + `
 
 `)
 	}
 
-	b.WriteString("\nUSER PROMPT:\n")
-	b.WriteString(userText)
-	b.WriteString("\n")
+	if s.budget > 0 {
+		remaining := s.budget - s.count(b.String()) - s.count(userText)
+		var dropped []Message
+		history, dropped = s.fitHistory(history, remaining/2)
+		if summary := s.summarizeDropped(dropped); summary != "" {
+			b.WriteString("\nEARLIER IN THIS SESSION (trimmed to fit the context window), the user asked:\n")
+			b.WriteString(summary)
+		}
+	}
+
+	messages := make([]Message, 0, len(history)+2)
+	messages = append(messages, Message{Role: RoleSystem, Content: strings.TrimSpace(b.String())})
+	for _, h := range history {
+		if strings.TrimSpace(h.Content) == "" {
+			continue
+		}
+		messages = append(messages, h)
+	}
+	messages = append(messages, Message{Role: RoleUser, Content: userText})
 
-	return b.String()
+	return messages
 }
 
 func (s *System) maybeRecommend(ctx context.Context, intent Intent, userText string, wd string) ([]Recommendation, error) {
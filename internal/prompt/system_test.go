@@ -2,6 +2,7 @@ package prompt
 
 import (
 	"context"
+	"strings"
 	"testing"
 
 	"github.com/nathfavour/vibeauracle/sys"
@@ -30,15 +31,54 @@ func TestBuild_ClassifiesAsk(t *testing.T) {
 	cfg.Prompt.LearningEnabled = true
 
 	s := New(&cfg, &memStub{}, &NoopRecommender{}, &modelStub{})
-	env, _, err := s.Build(context.Background(), "why does this happen?", sys.Snapshot{WorkingDir: "/tmp"}, "")
+	env, _, err := s.Build(context.Background(), "why does this happen?", sys.Snapshot{WorkingDir: "/tmp"}, "", nil)
 	if err != nil {
 		t.Fatalf("Build failed: %v", err)
 	}
 	if env.Intent != IntentAsk {
 		t.Fatalf("got intent %q, want %q", env.Intent, IntentAsk)
 	}
-	if env.Prompt == "" {
-		t.Fatal("expected prompt to be non-empty")
+	if len(env.Messages) == 0 {
+		t.Fatal("expected messages to be non-empty")
+	}
+}
+
+func TestBuild_ComposesConversation(t *testing.T) {
+	cfg := sys.Config{}
+	cfg.Prompt.Mode = "auto"
+	cfg.Prompt.LearningEnabled = true
+
+	s := New(&cfg, &memStub{}, &NoopRecommender{}, &modelStub{})
+	history := []Message{
+		{Role: RoleUser, Content: "create main.go"},
+		{Role: RoleAssistant, Content: "Created main.go."},
+	}
+	env, _, err := s.Build(context.Background(), "now add a test", sys.Snapshot{WorkingDir: "/tmp"}, "sys_read_file: read a file", history)
+	if err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+
+	roles := []string{RoleSystem, RoleUser, RoleAssistant, RoleUser}
+	if len(env.Messages) != len(roles) {
+		t.Fatalf("got %d messages, want %d", len(env.Messages), len(roles))
+	}
+	for i, r := range roles {
+		if env.Messages[i].Role != r {
+			t.Errorf("message %d: got role %q, want %q", i, env.Messages[i].Role, r)
+		}
+	}
+
+	system := env.Messages[0].Content
+	for _, want := range []string{"SYSTEM INSTRUCTIONS:", "previous hint", "AVAILABLE TOOLS:"} {
+		if !strings.Contains(system, want) {
+			t.Errorf("system message is missing %q", want)
+		}
+	}
+	if strings.Contains(system, "now add a test") {
+		t.Error("user prompt leaked into the system message")
+	}
+	if env.Messages[3].Content != "now add a test" {
+		t.Errorf("got final user message %q", env.Messages[3].Content)
 	}
 }
 
@@ -52,3 +92,60 @@ func TestParseModelResponse_CodeFence(t *testing.T) {
 		t.Fatalf("expected middle part to be go code")
 	}
 }
+
+func TestBuild_TrimsToBudget(t *testing.T) {
+	cfg := sys.Config{}
+	cfg.Prompt.Mode = "auto"
+
+	s := New(&cfg, nil, &NoopRecommender{}, &modelStub{})
+	s.SetBudget(2000, nil)
+
+	var history []Message
+	for i := 0; i < 20; i++ {
+		history = append(history,
+			Message{Role: RoleUser, Content: "request number " + strings.Repeat("x", 10) + string(rune('a'+i))},
+			Message{Role: RoleAssistant, Content: strings.Repeat("long answer ", 100)},
+		)
+	}
+	env, _, err := s.Build(context.Background(), "what next?", sys.Snapshot{WorkingDir: "/tmp"}, "", history)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	total := 0
+	for _, m := range env.Messages {
+		total += s.count(m.Content)
+	}
+	if total > 2000 {
+		t.Errorf("composed conversation uses %d tokens, budget is 2000", total)
+	}
+	if len(env.Messages) >= len(history)+2 || env.Messages[1].Role != RoleUser {
+		t.Errorf("expected older turns to be trimmed, got %d messages", len(env.Messages))
+	}
+	if !strings.Contains(env.Messages[0].Content, "request number xxxxxxxxxxa") {
+		t.Error("trimmed turns should be summarized in the system message")
+	}
+	if env.Messages[len(env.Messages)-1].Content != "what next?" {
+		t.Error("the user prompt must always be kept")
+	}
+}
+
+func TestBuild_SandboxGuideline(t *testing.T) {
+	s := New(&sys.Config{}, nil, &NoopRecommender{}, &modelStub{})
+	build := func() string {
+		t.Helper()
+		env, _, err := s.Build(context.Background(), "run the tests", sys.Snapshot{WorkingDir: "/tmp"}, "sys_shell_exec: run a command", nil)
+		if err != nil {
+			t.Fatalf("Build failed: %v", err)
+		}
+		return env.Messages[0].Content
+	}
+
+	if system := build(); strings.Contains(system, "sandbox") {
+		t.Error("without a sandbox the prompt must not claim one")
+	}
+	s.SetSandbox("Shell commands run in a sandbox without network access")
+	if system := build(); !strings.Contains(system, "- Shell commands run in a sandbox without network access\n- Report results") {
+		t.Errorf("expected the sandbox guideline among the others:\n%s", system)
+	}
+}
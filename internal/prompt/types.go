@@ -17,10 +17,23 @@ const (
 	IntentChat Intent = "chat" // general conversation
 )
 
+// Message roles used in a composed conversation.
+const (
+	RoleSystem    = "system"
+	RoleUser      = "user"
+	RoleAssistant = "assistant"
+)
+
+// Message is one role-separated entry of the conversation sent to the model.
+type Message struct {
+	Role    string
+	Content string
+}
+
 // Envelope is the final payload sent to the model.
 type Envelope struct {
 	Intent       Intent
-	Prompt       string
+	Messages     []Message
 	Instructions []string
 	Metadata     map[string]any
 }
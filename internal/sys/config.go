@@ -4,7 +4,9 @@ import (
 	"fmt"
 	"os"
 	"os/exec"
+	"path"
 	"path/filepath"
+	"slices"
 	"time"
 
 	"github.com/spf13/viper"
@@ -16,6 +18,72 @@ type CustomAgent struct {
 	Description string   `mapstructure:"description" json:"description"`
 	Prompt      string   `mapstructure:"prompt" json:"prompt"`
 	Tools       []string `mapstructure:"tools" json:"tools"`
+	// Limits override agent.limits while this agent is active.
+	Limits AgentLimits `mapstructure:"limits" json:"limits"`
+}
+
+// AgentLimits bound a single agent run. A zero field inherits when merging and
+// means "no limit" after every override is applied, except that MaxTurns falls
+// back to 10 and MaxRetryTime to the backoff default.
+type AgentLimits struct {
+	MaxTurns       int           `mapstructure:"max_turns" json:"max_turns,omitempty" yaml:"max_turns,omitempty"`
+	Timeout        time.Duration `mapstructure:"timeout" json:"timeout,omitempty" yaml:"timeout,omitempty"`
+	MaxToolCalls   int           `mapstructure:"max_tool_calls" json:"max_tool_calls,omitempty" yaml:"max_tool_calls,omitempty"`
+	MaxOutputBytes int           `mapstructure:"max_output_bytes" json:"max_output_bytes,omitempty" yaml:"max_output_bytes,omitempty"`
+	MaxRetryTime   time.Duration `mapstructure:"max_retry_time" json:"max_retry_time,omitempty" yaml:"max_retry_time,omitempty"`
+}
+
+// Merge returns l with every non-zero field of o applied on top.
+func (l AgentLimits) Merge(o AgentLimits) AgentLimits {
+	if o.MaxTurns > 0 {
+		l.MaxTurns = o.MaxTurns
+	}
+	if o.Timeout > 0 {
+		l.Timeout = o.Timeout
+	}
+	if o.MaxToolCalls > 0 {
+		l.MaxToolCalls = o.MaxToolCalls
+	}
+	if o.MaxOutputBytes > 0 {
+		l.MaxOutputBytes = o.MaxOutputBytes
+	}
+	if o.MaxRetryTime > 0 {
+		l.MaxRetryTime = o.MaxRetryTime
+	}
+	return l
+}
+
+// ModelFallback is one entry of the ordered provider fallback chain
+type ModelFallback struct {
+	Provider string `mapstructure:"provider" json:"provider"`
+	Name     string `mapstructure:"name" json:"name"`
+	Endpoint string `mapstructure:"endpoint" json:"endpoint"`
+}
+
+// ModelPrice is one row of the price table, in USD per million tokens.
+// Model may be a glob (e.g. "gpt-4o*" or "*") and Provider may be empty to match any provider.
+type ModelPrice struct {
+	Provider string  `mapstructure:"provider" json:"provider"`
+	Model    string  `mapstructure:"model" json:"model"`
+	Input    float64 `mapstructure:"input" json:"input"`
+	Output   float64 `mapstructure:"output" json:"output"`
+}
+
+// Cost returns the spend for the given token counts.
+func (p ModelPrice) Cost(promptTokens, completionTokens int) float64 {
+	return (float64(promptTokens)*p.Input + float64(completionTokens)*p.Output) / 1_000_000
+}
+
+// DefaultPricing covers local models and a few common hosted ones.
+// Users override or extend it through the pricing list in config.yaml; Load
+// puts those rows ahead of the defaults and Save writes back only those rows.
+var DefaultPricing = []ModelPrice{
+	{Provider: "ollama", Model: "*"},
+	{Provider: "openai", Model: "gpt-4o-mini*", Input: 0.15, Output: 0.60},
+	{Provider: "openai", Model: "gpt-4o*", Input: 2.50, Output: 10.00},
+	{Provider: "anthropic", Model: "claude-opus-4*", Input: 15.00, Output: 75.00},
+	{Provider: "anthropic", Model: "claude-sonnet-4*", Input: 3.00, Output: 15.00},
+	{Provider: "anthropic", Model: "claude-haiku-4*", Input: 1.00, Output: 5.00},
 }
 
 // Config holds all configuration for vibe auracle
@@ -27,13 +95,32 @@ type Config struct {
 		Endpoint       string `mapstructure:"endpoint"`
 		Name           string `mapstructure:"name"`
 		UserConfigured bool   `mapstructure:"user_configured"`
+
+		// Fallbacks are tried in order when the primary provider hits transport or 5xx errors.
+		Fallbacks         []ModelFallback `mapstructure:"fallbacks"`
+		FallbackThreshold int             `mapstructure:"fallback_threshold"` // consecutive failures before a provider is skipped
+		FallbackCooldown  time.Duration   `mapstructure:"fallback_cooldown"`  // how long a failing provider is skipped
+
+		// Cassette settings for the "replay" provider, used for offline and deterministic runs.
+		Cassette       string `mapstructure:"cassette"`        // path of the cassette file
+		CassetteMode   string `mapstructure:"cassette_mode"`   // replay|record
+		CassetteMatch  string `mapstructure:"cassette_match"`  // strict|fuzzy
+		RecordProvider string `mapstructure:"record_provider"` // provider wrapped while recording
 	} `mapstructure:"model"`
 
 	Agent struct {
-		Mode           string        `mapstructure:"mode"` // vibe|sdk|custom
+		Mode           string        `mapstructure:"mode"` // vibe|sdk|custom|goal|orchestrate
 		ActiveCustom   string        `mapstructure:"active_custom"`
 		CustomAgents   []CustomAgent `mapstructure:"custom_agents"`
 		UserConfigured bool          `mapstructure:"user_configured"`
+		// LoopSimilarity is the word overlap at which repeated responses count as a loop (0 disables).
+		LoopSimilarity float64     `mapstructure:"loop_similarity"`
+		Limits         AgentLimits `mapstructure:"limits"`
+		// ReviewWrites holds file writes, edits and deletes for review as a diff before they apply.
+		ReviewWrites bool `mapstructure:"review_writes"`
+		// WorkspaceRoots lists directories file tools may use besides the working directory.
+		// Anything else needs the user's approval.
+		WorkspaceRoots []string `mapstructure:"workspace_roots"`
 	} `mapstructure:"agent"`
 
 	Prompt struct {
@@ -59,6 +146,31 @@ type Config struct {
 		ScreenshotDir string `mapstructure:"screenshot_dir"`
 	} `mapstructure:"ui"`
 
+	// Sandbox confines the commands of sys_shell_exec. Zero limits are not enforced.
+	Sandbox struct {
+		Enabled  bool     `mapstructure:"enabled"`
+		ReadOnly bool     `mapstructure:"read_only"` // mount everything outside the project read-only
+		Writable []string `mapstructure:"writable"`  // extra writable paths with read_only, e.g. build caches
+		Network  bool     `mapstructure:"network"`   // allow network access
+
+		MaxMemoryMB    int           `mapstructure:"max_memory_mb"`
+		MaxCPUTime     time.Duration `mapstructure:"max_cpu_time"`
+		MaxProcs       int           `mapstructure:"max_procs"`
+		MaxOutputBytes int           `mapstructure:"max_output_bytes"`
+		Env            []string      `mapstructure:"env"` // variables passed through besides the defaults; globs allowed
+	} `mapstructure:"sandbox"`
+
+	// Cache memoizes background model calls (project indexing, recommendations).
+	Cache struct {
+		Enabled   bool          `mapstructure:"enabled"`
+		TTL       time.Duration `mapstructure:"ttl"`
+		MaxSizeMB int           `mapstructure:"max_size_mb"`
+	} `mapstructure:"cache"`
+
+	// Pricing is the price table used for cost accounting: the user's rows
+	// followed by DefaultPricing. See PriceFor for how a row is picked.
+	Pricing []ModelPrice `mapstructure:"pricing"`
+
 	DataDir string `mapstructure:"-"`
 
 	Health struct {
@@ -91,7 +203,32 @@ func NewConfigManager() (*ConfigManager, error) {
 	v.SetDefault("model.provider", "ollama")
 	v.SetDefault("model.endpoint", "http://localhost:11434")
 	v.SetDefault("model.name", "llama3")
+	v.SetDefault("model.fallbacks", []ModelFallback{})
+	v.SetDefault("model.fallback_threshold", 3)
+	v.SetDefault("model.fallback_cooldown", "30s")
+	v.SetDefault("model.cassette_mode", "replay")
+	v.SetDefault("model.cassette_match", "fuzzy")
 	v.SetDefault("agent.mode", "vibe")
+	v.SetDefault("agent.loop_similarity", 0.9)
+	v.SetDefault("agent.review_writes", false)
+	v.SetDefault("agent.workspace_roots", []string{})
+	v.SetDefault("agent.limits.max_turns", 10)
+	v.SetDefault("agent.limits.timeout", "30m")
+	v.SetDefault("agent.limits.max_tool_calls", 100)
+	v.SetDefault("agent.limits.max_output_bytes", 64*1024)
+	v.SetDefault("agent.limits.max_retry_time", "1m")
+	v.SetDefault("sandbox.enabled", true)
+	v.SetDefault("sandbox.read_only", false)
+	v.SetDefault("sandbox.writable", []string{})
+	v.SetDefault("sandbox.network", false)
+	v.SetDefault("sandbox.max_memory_mb", 2048)
+	v.SetDefault("sandbox.max_cpu_time", "10m")
+	v.SetDefault("sandbox.max_procs", 512)
+	v.SetDefault("sandbox.max_output_bytes", 1<<20)
+	v.SetDefault("sandbox.env", []string{})
+	v.SetDefault("cache.enabled", true)
+	v.SetDefault("cache.ttl", "168h")
+	v.SetDefault("cache.max_size_mb", 50)
 	v.SetDefault("ui.theme", "dark")
 
 	// Prompt system defaults
@@ -147,6 +284,7 @@ func (cm *ConfigManager) Load() (*Config, error) {
 
 	home, _ := os.UserHomeDir()
 	cfg.DataDir = filepath.Join(home, ".vibeauracle")
+	cfg.Pricing = append(userPricing(cfg.Pricing), DefaultPricing...)
 
 	// Opinionated Detection: If both go and git are installed, this is a developer environment.
 	_, errGo := exec.LookPath("go")
@@ -170,10 +308,25 @@ func (cm *ConfigManager) Save(cfg *Config) error {
 	cm.v.Set("model.endpoint", cfg.Model.Endpoint)
 	cm.v.Set("model.name", cfg.Model.Name)
 	cm.v.Set("model.user_configured", cfg.Model.UserConfigured)
+	cm.v.Set("model.fallbacks", cfg.Model.Fallbacks)
+	cm.v.Set("model.fallback_threshold", cfg.Model.FallbackThreshold)
+	cm.v.Set("model.fallback_cooldown", cfg.Model.FallbackCooldown.String())
+	cm.v.Set("model.cassette", cfg.Model.Cassette)
+	cm.v.Set("model.cassette_mode", cfg.Model.CassetteMode)
+	cm.v.Set("model.cassette_match", cfg.Model.CassetteMatch)
+	cm.v.Set("model.record_provider", cfg.Model.RecordProvider)
 	cm.v.Set("agent.mode", cfg.Agent.Mode)
 	cm.v.Set("agent.active_custom", cfg.Agent.ActiveCustom)
 	cm.v.Set("agent.custom_agents", cfg.Agent.CustomAgents)
 	cm.v.Set("agent.user_configured", cfg.Agent.UserConfigured)
+	cm.v.Set("agent.loop_similarity", cfg.Agent.LoopSimilarity)
+	cm.v.Set("agent.limits.max_turns", cfg.Agent.Limits.MaxTurns)
+	cm.v.Set("agent.limits.timeout", cfg.Agent.Limits.Timeout.String())
+	cm.v.Set("agent.limits.max_tool_calls", cfg.Agent.Limits.MaxToolCalls)
+	cm.v.Set("agent.limits.max_output_bytes", cfg.Agent.Limits.MaxOutputBytes)
+	cm.v.Set("agent.limits.max_retry_time", cfg.Agent.Limits.MaxRetryTime.String())
+	cm.v.Set("agent.review_writes", cfg.Agent.ReviewWrites)
+	cm.v.Set("agent.workspace_roots", cfg.Agent.WorkspaceRoots)
 	cm.v.Set("prompt.enabled", cfg.Prompt.Enabled)
 	cm.v.Set("prompt.mode", cfg.Prompt.Mode)
 	cm.v.Set("prompt.project_instructions", cfg.Prompt.ProjectInstructions)
@@ -186,6 +339,19 @@ func (cm *ConfigManager) Save(cfg *Config) error {
 	cm.v.Set("update.auto_update", cfg.Update.AutoUpdate)
 	cm.v.Set("update.verbose", cfg.Update.Verbose)
 	cm.v.Set("update.failed_commits", cfg.Update.FailedCommits)
+	cm.v.Set("sandbox.enabled", cfg.Sandbox.Enabled)
+	cm.v.Set("sandbox.read_only", cfg.Sandbox.ReadOnly)
+	cm.v.Set("sandbox.writable", cfg.Sandbox.Writable)
+	cm.v.Set("sandbox.network", cfg.Sandbox.Network)
+	cm.v.Set("sandbox.max_memory_mb", cfg.Sandbox.MaxMemoryMB)
+	cm.v.Set("sandbox.max_cpu_time", cfg.Sandbox.MaxCPUTime.String())
+	cm.v.Set("sandbox.max_procs", cfg.Sandbox.MaxProcs)
+	cm.v.Set("sandbox.max_output_bytes", cfg.Sandbox.MaxOutputBytes)
+	cm.v.Set("sandbox.env", cfg.Sandbox.Env)
+	cm.v.Set("cache.enabled", cfg.Cache.Enabled)
+	cm.v.Set("cache.ttl", cfg.Cache.TTL.String())
+	cm.v.Set("cache.max_size_mb", cfg.Cache.MaxSizeMB)
+	cm.v.Set("pricing", userPricing(cfg.Pricing))
 	cm.v.Set("ui.theme", cfg.UI.Theme)
 	cm.v.Set("ui.screenshot_dir", cfg.UI.ScreenshotDir)
 	cm.v.Set("health.crash_count", cfg.Health.CrashCount)
@@ -194,6 +360,39 @@ func (cm *ConfigManager) Save(cfg *Config) error {
 	return cm.v.WriteConfig()
 }
 
+// userPricing returns the rows of a price table that are not in DefaultPricing.
+func userPricing(table []ModelPrice) []ModelPrice {
+	rows := []ModelPrice{}
+	for _, p := range table {
+		if !slices.Contains(DefaultPricing, p) {
+			rows = append(rows, p)
+		}
+	}
+	return rows
+}
+
+// PriceFor looks up the price of a provider/model pair. A row whose model
+// matches exactly is tried first, then rows whose model glob matches; within
+// each pass the earlier row wins. ok is false when nothing in the table matches.
+func (c *Config) PriceFor(provider, model string) (price ModelPrice, ok bool) {
+	for _, exact := range []bool{true, false} {
+		for _, p := range c.Pricing {
+			if p.Provider != "" && p.Provider != "*" && p.Provider != provider {
+				continue
+			}
+			if exact && p.Model == model {
+				return p, true
+			}
+			if !exact {
+				if matched, _ := path.Match(p.Model, model); matched {
+					return p, true
+				}
+			}
+		}
+	}
+	return ModelPrice{}, false
+}
+
 // GetDataPath returns a path inside the .vibeauracle directory
 func (cm *ConfigManager) GetDataPath(subpath string) string {
 	home, _ := os.UserHomeDir()
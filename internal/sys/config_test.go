@@ -3,7 +3,9 @@ package sys
 import (
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestConfigManager(t *testing.T) {
@@ -39,6 +41,25 @@ func TestConfigManager(t *testing.T) {
 	if cfg.Prompt.Mode != "auto" {
 		t.Errorf("got prompt mode %q, want 'auto'", cfg.Prompt.Mode)
 	}
+	if cfg.Model.FallbackThreshold != 3 || cfg.Model.FallbackCooldown != 30*time.Second {
+		t.Errorf("got fallback breaker %d/%s, want 3/30s", cfg.Model.FallbackThreshold, cfg.Model.FallbackCooldown)
+	}
+
+	if !cfg.Cache.Enabled || cfg.Cache.TTL != 168*time.Hour || cfg.Cache.MaxSizeMB != 50 {
+		t.Errorf("unexpected cache defaults: %+v", cfg.Cache)
+	}
+	if cfg.Agent.LoopSimilarity != 0.9 {
+		t.Errorf("got loop similarity %v, want 0.9", cfg.Agent.LoopSimilarity)
+	}
+	if l := cfg.Agent.Limits; l.MaxTurns != 10 || l.Timeout != 30*time.Minute || l.MaxRetryTime != time.Minute {
+		t.Errorf("unexpected agent limit defaults: %+v", l)
+	}
+	if p, ok := cfg.PriceFor("openai", "gpt-4o-mini-2024-07-18"); !ok || p.Input != 0.15 {
+		t.Errorf("got price %+v (ok=%v), want the gpt-4o-mini row", p, ok)
+	}
+	if p, ok := cfg.PriceFor("ollama", "llama3"); !ok || p.Cost(1000, 1000) != 0 {
+		t.Errorf("local models should be free, got %+v (ok=%v)", p, ok)
+	}
 
 	// Verify file existence
 	dataDir := filepath.Join(tmpHome, ".vibeauracle")
@@ -50,6 +71,11 @@ func TestConfigManager(t *testing.T) {
 	// Test Save/Update
 	cfg.Model.Name = "custom-model"
 	cfg.Prompt.Mode = "ask"
+	cfg.Model.Fallbacks = []ModelFallback{{Provider: "openai", Name: "gpt-4o"}, {Provider: "ollama", Name: "llama3", Endpoint: "http://localhost:11434"}}
+	cfg.Model.FallbackCooldown = time.Minute
+	cfg.Pricing = append([]ModelPrice{{Provider: "openai", Model: "gpt-4o", Input: 1, Output: 2}}, cfg.Pricing...)
+	cfg.Agent.Limits.Timeout = time.Hour
+	cfg.Agent.CustomAgents = []CustomAgent{{Name: "long", Limits: AgentLimits{MaxTurns: 40, Timeout: 2 * time.Hour}}}
 	if err := cm.Save(cfg); err != nil {
 		t.Fatalf("Save failed: %v", err)
 	}
@@ -63,5 +89,32 @@ func TestConfigManager(t *testing.T) {
 	if cfg2.Prompt.Mode != "ask" {
 		t.Errorf("got prompt mode %q, want 'ask'", cfg2.Prompt.Mode)
 	}
+	if len(cfg2.Model.Fallbacks) != 2 || cfg2.Model.Fallbacks[1].Provider != "ollama" || cfg2.Model.Fallbacks[1].Endpoint == "" {
+		t.Errorf("fallback chain did not round-trip: %+v", cfg2.Model.Fallbacks)
+	}
+	if p, ok := cfg2.PriceFor("openai", "gpt-4o"); !ok || p.Cost(1_000_000, 500_000) != 2 {
+		t.Errorf("custom price did not round-trip: %+v (ok=%v)", p, ok)
+	}
+	if p, ok := cfg2.PriceFor("anthropic", "claude-opus-4-1"); !ok || p.Input != 15 {
+		t.Errorf("default prices should still apply after a reload: %+v (ok=%v)", p, ok)
+	}
+	if data, _ := os.ReadFile(configPath); strings.Contains(string(data), "claude-opus-4*") || !strings.Contains(string(data), "gpt-4o") {
+		t.Errorf("only the user's price rows should be saved, got:\n%s", data)
+	}
+	if cfg2.Model.FallbackCooldown != time.Minute {
+		t.Errorf("got fallback cooldown %s, want 1m", cfg2.Model.FallbackCooldown)
+	}
+	if cfg2.Agent.Limits.Timeout != time.Hour || cfg2.Agent.Limits.MaxTurns != 10 {
+		t.Errorf("agent limits did not round-trip: %+v", cfg2.Agent.Limits)
+	}
+	if len(cfg2.Agent.CustomAgents) != 1 || cfg2.Agent.CustomAgents[0].Limits.MaxTurns != 40 || cfg2.Agent.CustomAgents[0].Limits.Timeout != 2*time.Hour {
+		t.Errorf("custom agent limits did not round-trip: %+v", cfg2.Agent.CustomAgents)
+	}
+
+	// Per-agent and per-invocation overrides only replace the fields they set.
+	merged := cfg2.Agent.Limits.Merge(cfg2.Agent.CustomAgents[0].Limits).Merge(AgentLimits{MaxTurns: 3})
+	if merged.MaxTurns != 3 || merged.Timeout != 2*time.Hour || merged.MaxToolCalls != 100 {
+		t.Errorf("unexpected merged limits: %+v", merged)
+	}
 }
 
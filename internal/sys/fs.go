@@ -17,6 +17,8 @@ type FS interface {
 	Edit(path string, oldStr, newStr string) error
 	// Batch executes multiple file operations at once
 	Batch(ops []BatchOp) error
+	// Stat returns information about a file or directory
+	Stat(path string) (os.FileInfo, error)
 }
 
 // BatchOpType defines the type of operation in a batch
@@ -125,13 +127,25 @@ func (l *LocalFS) Batch(ops []BatchOp) error {
 	return nil
 }
 
+// Stat returns information about a file or directory
+func (l *LocalFS) Stat(path string) (os.FileInfo, error) {
+	return os.Stat(l.resolvePath(path))
+}
+
+// Abs returns the absolute path a relative path resolves to.
+func (l *LocalFS) Abs(path string) string {
+	return l.resolvePath(path)
+}
+
 // resolvePath ensures paths are handled relative to the base directory and sanitized.
+// Absolute paths are taken as they are: LocalFS does not confine access, callers that
+// need confinement wrap it (see tooling.WorkspaceFS).
 func (l *LocalFS) resolvePath(path string) string {
 	if path == "" {
 		return l.baseDir
 	}
 	if filepath.IsAbs(path) {
-		return path // User knows what they are doing with absolute paths
+		return filepath.Clean(path)
 	}
 	// Force join with CWD/baseDir
 	abs, err := filepath.Abs(filepath.Join(l.baseDir, path))
@@ -47,6 +47,14 @@ func TestLocalFS(t *testing.T) {
 		t.Errorf("file %q not found in list %v", testFile, files)
 	}
 
+	// Test Stat
+	info, err := fs.Stat(testFile)
+	if err != nil {
+		t.Errorf("Stat failed: %v", err)
+	} else if info.Size() != int64(len(content)) || info.IsDir() {
+		t.Errorf("Stat got size %d dir %v, want %d and a file", info.Size(), info.IsDir(), len(content))
+	}
+
 	// Test Delete
 	if err := fs.DeleteFile(testFile); err != nil {
 		t.Errorf("DeleteFile failed: %v", err)
@@ -0,0 +1,384 @@
+package tooling
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultAuditMaxSize = 10 << 20 // bytes at which the log is rotated
+	defaultAuditBackups = 5        // rotated logs kept
+)
+
+// AuditPath is where the enclave keeps its audit log inside the data directory.
+func AuditPath(appDataDir string) string {
+	return filepath.Join(appDataDir, "enclave", "audit.log")
+}
+
+// AuditEntry is one decision of the enclave. Entries are hash-chained: Hash covers the
+// whole entry, including Prev, the hash of the entry before it, so that editing,
+// removing or reordering entries breaks the chain.
+type AuditEntry struct {
+	Timestamp string `json:"timestamp"`
+	Tool      string `json:"tool"`
+	Args      string `json:"args"`
+	Risk      string `json:"risk"`
+	Decision  string `json:"decision"`       // Approved, Denied
+	Scope     string `json:"scope"`          // Local, System
+	Rule      string `json:"rule,omitempty"` // policy rule behind the decision
+	Session   string `json:"session,omitempty"`
+	Thread    string `json:"thread,omitempty"`
+	// Status is the result of the tool (success, error, or intervention when it raised
+	// another one); empty when the decision kept it from running.
+	Status string `json:"status,omitempty"`
+	Prev   string `json:"prev,omitempty"`
+	Hash   string `json:"hash,omitempty"`
+}
+
+// Time parses the entry's timestamp.
+func (e AuditEntry) Time() time.Time {
+	t, _ := time.Parse(time.RFC3339, e.Timestamp)
+	return t
+}
+
+// digest is the hash of the entry with Hash left out.
+func (e AuditEntry) digest() string {
+	e.Hash = ""
+	data, _ := json.Marshal(e)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// AuditLogger maintains a secure ledger of all agent actions. Once the log reaches
+// maxSize it is renamed to audit.log.1, the previous audit.log.1 to audit.log.2 and so
+// on, up to maxBackups files; the chain continues across them.
+type AuditLogger struct {
+	path       string
+	maxSize    int64
+	maxBackups int
+
+	session, thread string
+	mu              sync.Mutex
+}
+
+func NewAuditLogger(path string) *AuditLogger {
+	return &AuditLogger{path: path, maxSize: defaultAuditMaxSize, maxBackups: defaultAuditBackups}
+}
+
+// SetRotation changes the size at which the log is rotated and how many rotated logs
+// are kept.
+func (l *AuditLogger) SetRotation(maxSize int64, maxBackups int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.maxSize, l.maxBackups = maxSize, maxBackups
+}
+
+// SetThread ties the entries that follow to a session and one of its threads.
+func (l *AuditLogger) SetThread(session, thread string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.session, l.thread = session, thread
+}
+
+// Entry builds an entry stamped with the current time, session and thread, for Record.
+func (l *AuditLogger) Entry(tool string, args json.RawMessage, risk, decision, scope, rule string) AuditEntry {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return AuditEntry{
+		Timestamp: time.Now().Format(time.RFC3339),
+		Tool:      tool,
+		Args:      stableJSON(args),
+		Risk:      risk,
+		Decision:  decision,
+		Scope:     scope,
+		Rule:      rule,
+		Session:   l.session,
+		Thread:    l.thread,
+	}
+}
+
+// Log records a decision that did not run the tool, or whose result is not known.
+func (l *AuditLogger) Log(tool string, args json.RawMessage, risk, decision, scope, rule string) {
+	_ = l.Record(l.Entry(tool, args, risk, decision, scope, rule))
+}
+
+// Record chains e to the last entry of the log and appends it, rotating the log first
+// when it is full.
+func (l *AuditLogger) Record(e AuditEntry) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	// The last hash is read from the file rather than remembered, so that several
+	// processes sharing the log keep one chain.
+	prev, err := lastAuditHash(l.path)
+	if errors.Is(err, os.ErrNotExist) {
+		prev, err = lastAuditHash(l.path + ".1")
+	}
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	if info, err := os.Stat(l.path); err == nil && l.maxSize > 0 && info.Size() >= l.maxSize {
+		if err := l.rotate(); err != nil {
+			return err
+		}
+	}
+
+	e.Prev = prev
+	e.Hash = e.digest()
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(l.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600) // 0600 = Secure
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = f.Write(append(data, '\n'))
+	return err
+}
+
+// rotate shifts the rotated logs up by one, dropping the oldest, and moves the log to
+// audit.log.1.
+func (l *AuditLogger) rotate() error {
+	if l.maxBackups <= 0 {
+		return os.Remove(l.path)
+	}
+	_ = os.Remove(fmt.Sprintf("%s.%d", l.path, l.maxBackups))
+	for i := l.maxBackups - 1; i >= 1; i-- {
+		_ = os.Rename(fmt.Sprintf("%s.%d", l.path, i), fmt.Sprintf("%s.%d", l.path, i+1))
+	}
+	return os.Rename(l.path, l.path+".1")
+}
+
+// lastAuditHash returns the hash of the last entry of a log file, "" when it is empty
+// or predates the chain.
+func lastAuditHash(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return "", err
+	}
+
+	// Read backwards until the chunk holds a whole last line; entries with large
+	// arguments can span many chunks.
+	const chunk = 64 << 10
+	var tail []byte
+	for end := info.Size(); end > 0; {
+		start := max(0, end-chunk)
+		buf := make([]byte, end-start)
+		if _, err := f.ReadAt(buf, start); err != nil && err != io.EOF {
+			return "", err
+		}
+		tail = append(buf, tail...)
+		end = start
+		if i := bytes.LastIndexByte(bytes.TrimRight(tail, "\n"), '\n'); i >= 0 || start == 0 {
+			tail = tail[i+1:]
+			break
+		}
+	}
+
+	var last AuditEntry
+	if len(bytes.TrimSpace(tail)) == 0 || json.Unmarshal(tail, &last) != nil {
+		return "", nil
+	}
+	return last.Hash, nil
+}
+
+// files returns the log files oldest first: the rotated ones, then the current log.
+func (l *AuditLogger) files() []string {
+	matches, _ := filepath.Glob(l.path + ".*")
+	type rotated struct {
+		path string
+		n    int
+	}
+	var old []rotated
+	for _, m := range matches {
+		if n, err := strconv.Atoi(strings.TrimPrefix(m, l.path+".")); err == nil {
+			old = append(old, rotated{m, n})
+		}
+	}
+	sort.Slice(old, func(i, j int) bool { return old[i].n > old[j].n })
+
+	var files []string
+	for _, r := range old {
+		files = append(files, r.path)
+	}
+	if _, err := os.Stat(l.path); err == nil {
+		files = append(files, l.path)
+	}
+	return files
+}
+
+// auditLine is one line of a log file, parsed.
+type auditLine struct {
+	pos   string // file:line
+	entry AuditEntry
+	err   error
+}
+
+// lines reads every line of the log and its rotated files, oldest first.
+func (l *AuditLogger) lines() ([]auditLine, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var lines []auditLine
+	for _, path := range l.files() {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		r := bufio.NewReader(f)
+		for n := 1; ; n++ {
+			data, err := r.ReadBytes('\n')
+			if len(bytes.TrimSpace(data)) > 0 {
+				line := auditLine{pos: fmt.Sprintf("%s:%d", filepath.Base(path), n)}
+				line.err = json.Unmarshal(data, &line.entry)
+				lines = append(lines, line)
+			}
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				f.Close()
+				return nil, err
+			}
+		}
+		f.Close()
+	}
+	return lines, nil
+}
+
+// Entries returns the entries of the log and its rotated files, oldest first. Lines
+// that are not valid entries are skipped; Verify reports them.
+func (l *AuditLogger) Entries() ([]AuditEntry, error) {
+	lines, err := l.lines()
+	if err != nil {
+		return nil, err
+	}
+	var entries []AuditEntry
+	for _, line := range lines {
+		if line.err == nil {
+			entries = append(entries, line.entry)
+		}
+	}
+	return entries, nil
+}
+
+// AuditVerification is the outcome of checking the chain of an audit log.
+type AuditVerification struct {
+	Entries int // entries checked
+	// Legacy counts entries written before the log was chained, which cannot be checked.
+	Legacy int
+	// Problems describe the entries that break the chain, by file and line.
+	Problems []string
+}
+
+// OK reports whether the chain is intact.
+func (v *AuditVerification) OK() bool {
+	return len(v.Problems) == 0
+}
+
+// Verify checks that every entry matches its hash and follows the one before it.
+// The first chained entry is trusted as the anchor, as the entries before it may have
+// been rotated away, and entries removed from the very end cannot be detected.
+func (l *AuditLogger) Verify() (*AuditVerification, error) {
+	lines, err := l.lines()
+	if err != nil {
+		return nil, err
+	}
+
+	v := &AuditVerification{}
+	prev, chained := "", false
+	for _, line := range lines {
+		v.Entries++
+		e := line.entry
+		switch {
+		case line.err != nil:
+			v.Problems = append(v.Problems, fmt.Sprintf("%s: not a valid entry: %v", line.pos, line.err))
+			continue
+		case e.Hash == "" && !chained:
+			v.Legacy++
+			continue
+		case e.Hash == "":
+			v.Problems = append(v.Problems, fmt.Sprintf("%s: entry has no hash", line.pos))
+			continue
+		case e.digest() != e.Hash:
+			v.Problems = append(v.Problems, fmt.Sprintf("%s: entry was modified (%s %s)", line.pos, e.Timestamp, e.Tool))
+		case chained && e.Prev != prev:
+			v.Problems = append(v.Problems, fmt.Sprintf("%s: entry does not follow the one before it; entries were removed, reordered or inserted", line.pos))
+		}
+		// The next entry is checked against the hash it was chained to, so that one bad
+		// entry is reported once.
+		prev, chained = e.Hash, true
+	}
+	return v, nil
+}
+
+// AuditFilter selects audit entries. Empty fields match everything.
+type AuditFilter struct {
+	Tool     string // glob, e.g. sys_*
+	Risk     string
+	Decision string // case-insensitive substring, e.g. "denied" or "session"
+	Session  string // session ID or part of it
+	Thread   string // thread ID or a prefix of it
+	Since    time.Time
+	Until    time.Time
+}
+
+// Match reports whether e is selected by the filter.
+func (f AuditFilter) Match(e AuditEntry) bool {
+	if f.Tool != "" && !globMatch(f.Tool, e.Tool, false) {
+		return false
+	}
+	if f.Risk != "" && !strings.EqualFold(f.Risk, e.Risk) {
+		return false
+	}
+	if f.Decision != "" && !strings.Contains(strings.ToLower(e.Decision), strings.ToLower(f.Decision)) {
+		return false
+	}
+	if f.Session != "" && !strings.Contains(e.Session, f.Session) {
+		return false
+	}
+	if f.Thread != "" && !strings.HasPrefix(e.Thread, f.Thread) {
+		return false
+	}
+	if !f.Since.IsZero() || !f.Until.IsZero() {
+		t := e.Time()
+		if (!f.Since.IsZero() && t.Before(f.Since)) || (!f.Until.IsZero() && t.After(f.Until)) {
+			return false
+		}
+	}
+	return true
+}
+
+// resultStatus is the status an audit entry records for a tool that ran.
+func resultStatus(res *ToolResult, err error) string {
+	var iv *InterventionError
+	switch {
+	case errors.As(err, &iv):
+		return "intervention"
+	case err != nil:
+		return "error"
+	case res != nil && res.Status != "":
+		return res.Status
+	}
+	return "success"
+}
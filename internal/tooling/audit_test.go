@@ -0,0 +1,230 @@
+package tooling
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestAudit returns a logger with n entries written to a temporary log.
+func newTestAudit(t *testing.T, n int) (*AuditLogger, string) {
+	t.Helper()
+	path := AuditPath(t.TempDir())
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		t.Fatal(err)
+	}
+	l := NewAuditLogger(path)
+	l.SetThread("session-1", "thread-1")
+	for i := 0; i < n; i++ {
+		l.Log("sys_shell_exec", json.RawMessage(fmt.Sprintf(`{"command":"echo %d"}`, i)), "low", "Approved (Session)", "Local", "")
+	}
+	return l, path
+}
+
+// auditLines reads the lines of a log file.
+func auditLines(t *testing.T, path string) []string {
+	t.Helper()
+	return strings.Split(strings.TrimSuffix(readTestFile(t, path), "\n"), "\n")
+}
+
+func writeAuditLines(t *testing.T, path string, lines []string) {
+	t.Helper()
+	writeTestFile(t, path, strings.Join(lines, "\n")+"\n", 0600)
+}
+
+func TestAuditLogger_Chain(t *testing.T) {
+	l, path := newTestAudit(t, 4)
+	if err := l.Record(AuditEntry{Tool: "fs_grep", Decision: "Approved", Status: resultStatus(nil, errors.New("boom"))}); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := l.Entries()
+	if err != nil || len(entries) != 5 {
+		t.Fatalf("expected five entries, got %d, %v", len(entries), err)
+	}
+	for i, e := range entries {
+		if e.Hash == "" || (i > 0 && e.Prev != entries[i-1].Hash) {
+			t.Errorf("entry %d is not chained: %+v", i, e)
+		}
+	}
+	if e := entries[0]; e.Prev != "" || e.Session != "session-1" || e.Thread != "thread-1" || e.Args != `{"command":"echo 0"}` {
+		t.Errorf("unexpected first entry: %+v", e)
+	}
+	if entries[4].Status != "error" {
+		t.Errorf("expected the result status to be kept, got %+v", entries[4])
+	}
+	if info, _ := os.Stat(path); info.Mode().Perm() != 0600 {
+		t.Errorf("the log must only be readable by its owner, got %v", info.Mode().Perm())
+	}
+
+	v, err := l.Verify()
+	if err != nil || !v.OK() || v.Entries != 5 || v.Legacy != 0 {
+		t.Fatalf("expected an intact chain, got %+v, %v", v, err)
+	}
+}
+
+func TestAuditLogger_VerifyDetectsTampering(t *testing.T) {
+	forged := func(lines []string) []string {
+		var e AuditEntry
+		json.Unmarshal([]byte(lines[1]), &e)
+		e.Tool, e.Prev = "sys_write_file", "0000"
+		e.Hash = e.digest()
+		data, _ := json.Marshal(e)
+		return append(lines[:2:2], append([]string{string(data)}, lines[2:]...)...)
+	}
+	tests := []struct {
+		name   string
+		tamper func(lines []string) []string
+		want   string
+	}{
+		{"edited field", func(l []string) []string {
+			l[2] = strings.Replace(l[2], "Approved (Session)", "Denied (User)", 1)
+			return l
+		}, "audit.log:3: entry was modified"},
+		{"edited arguments", func(l []string) []string {
+			l[1] = strings.Replace(l[1], "echo 1", "rm -rf /", 1)
+			return l
+		}, "audit.log:2: entry was modified"},
+		{"truncated line", func(l []string) []string {
+			l[3] = l[3][:len(l[3])/2]
+			return l
+		}, "audit.log:4: not a valid entry"},
+		{"removed line", func(l []string) []string {
+			return append(l[:1:1], l[2:]...)
+		}, "audit.log:2: entry does not follow"},
+		{"swapped lines", func(l []string) []string {
+			l[1], l[2] = l[2], l[1]
+			return l
+		}, "audit.log:2: entry does not follow"},
+		{"inserted entry", forged, "audit.log:3: entry does not follow"},
+		{"hash stripped", func(l []string) []string {
+			var e AuditEntry
+			json.Unmarshal([]byte(l[2]), &e)
+			e.Hash = ""
+			data, _ := json.Marshal(e)
+			l[2] = string(data)
+			return l
+		}, "audit.log:3: entry has no hash"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l, path := newTestAudit(t, 5)
+			writeAuditLines(t, path, tt.tamper(auditLines(t, path)))
+			v, err := l.Verify()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if v.OK() || !strings.Contains(strings.Join(v.Problems, "\n"), tt.want) {
+				t.Errorf("expected a problem %q, got %q", tt.want, v.Problems)
+			}
+			// A swap breaks three links; nothing should break the rest of the chain.
+			if len(v.Problems) > 3 {
+				t.Errorf("one change should not cascade through the rest of the chain: %q", v.Problems)
+			}
+		})
+	}
+
+	// Entries from before the log was chained are counted but cannot be checked.
+	l, path := newTestAudit(t, 2)
+	legacy := `{"timestamp":"2024-01-01T00:00:00Z","tool":"sys_read_file","args":"{}","risk":"low","decision":"Approved","scope":"Local"}`
+	writeAuditLines(t, path, append([]string{legacy, legacy}, auditLines(t, path)...))
+	if v, _ := l.Verify(); !v.OK() || v.Legacy != 2 || v.Entries != 4 {
+		t.Errorf("expected legacy entries to be accepted, got %+v", v)
+	}
+}
+
+func TestAuditLogger_Rotation(t *testing.T) {
+	l, path := newTestAudit(t, 0)
+	size := auditEntrySize(l)
+	l.SetRotation(3*size, 2)
+	for i := 0; i < 20; i++ {
+		l.Log("sys_read_file", json.RawMessage(`{"path":"a.txt"}`), "low", "Approved", "Local", "")
+	}
+
+	files := l.files()
+	if len(files) != 3 || files[0] != path+".2" || files[1] != path+".1" || files[2] != path {
+		t.Fatalf("expected the log and two rotated files, got %q", files)
+	}
+	for _, f := range files {
+		info, _ := os.Stat(f)
+		if info.Size() > 3*size+size/2 {
+			t.Errorf("%s grew to %d bytes past the %d byte threshold", f, info.Size(), 3*size)
+		}
+	}
+
+	// The chain continues across files, and the oldest kept entry is the anchor.
+	v, err := l.Verify()
+	if err != nil || !v.OK() || v.Entries < 7 {
+		t.Fatalf("expected the chain to hold across rotated files, got %+v, %v", v, err)
+	}
+	first := auditLines(t, path)[0]
+	var e AuditEntry
+	json.Unmarshal([]byte(first), &e)
+	rotated := auditLines(t, path+".1")
+	var last AuditEntry
+	json.Unmarshal([]byte(rotated[len(rotated)-1]), &last)
+	if e.Prev != last.Hash {
+		t.Error("the first entry after rotation must chain to the last rotated one")
+	}
+
+	// Tampering in a rotated file is reported with its file name.
+	rotated[0] = strings.Replace(rotated[0], "a.txt", "b.txt", 1)
+	writeAuditLines(t, path+".1", rotated)
+	if v, _ := l.Verify(); v.OK() || !strings.Contains(strings.Join(v.Problems, "\n"), "audit.log.1:1: entry was modified") {
+		t.Errorf("expected the rotated file to fail verification, got %q", v.Problems)
+	}
+
+	// With the current log gone, e.g. rotated by another process, the chain continues
+	// from the newest rotated file.
+	writeAuditLines(t, path+".1", append(rotated[:0:0], auditLines(t, path)...))
+	os.Remove(path)
+	os.Remove(path + ".2")
+	l.Log("sys_read_file", nil, "low", "Approved", "Local", "")
+	if v, _ := l.Verify(); !v.OK() {
+		t.Errorf("expected the chain to continue from audit.log.1, got %q", v.Problems)
+	}
+}
+
+// auditEntrySize returns the size of the log line of a chained sys_read_file entry.
+func auditEntrySize(l *AuditLogger) int64 {
+	e := l.Entry("sys_read_file", json.RawMessage(`{"path":"a.txt"}`), "low", "Approved", "Local", "")
+	e.Prev, e.Hash = strings.Repeat("0", 64), strings.Repeat("0", 64)
+	data, _ := json.Marshal(e)
+	return int64(len(data) + 1)
+}
+
+func TestAuditFilter_Match(t *testing.T) {
+	at := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	e := AuditEntry{
+		Timestamp: at.Format(time.RFC3339), Tool: "sys_shell_exec", Risk: "high",
+		Decision: "Approved (Session)", Session: "abc123", Thread: "t-42",
+	}
+	tests := []struct {
+		filter AuditFilter
+		want   bool
+	}{
+		{AuditFilter{}, true},
+		{AuditFilter{Tool: "sys_*"}, true},
+		{AuditFilter{Tool: "fs_*"}, false},
+		{AuditFilter{Risk: "HIGH"}, true},
+		{AuditFilter{Risk: "low"}, false},
+		{AuditFilter{Decision: "session"}, true},
+		{AuditFilter{Decision: "denied"}, false},
+		{AuditFilter{Session: "c12"}, true},
+		{AuditFilter{Thread: "t-4"}, true},
+		{AuditFilter{Thread: "42"}, false},
+		{AuditFilter{Since: at.Add(-time.Hour), Until: at.Add(time.Hour)}, true},
+		{AuditFilter{Since: at.Add(time.Minute)}, false},
+		{AuditFilter{Until: at.Add(-time.Minute)}, false},
+	}
+	for _, tt := range tests {
+		if got := tt.filter.Match(e); got != tt.want {
+			t.Errorf("%+v: got %v, want %v", tt.filter, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,203 @@
+package tooling
+
+import (
+	"fmt"
+	"strings"
+)
+
+// diffContext is the number of unchanged lines shown around each change.
+const diffContext = 3
+
+// maxDiffCells bounds the LCS table; larger inputs are diffed as one replacement.
+const maxDiffCells = 4_000_000
+
+// DiffLine is one line of a hunk. Kind is " " for context, "-" for a removed and "+"
+// for an added line; Text keeps its line ending.
+type DiffLine struct {
+	Kind string `json:"kind"`
+	Text string `json:"text"`
+}
+
+// DiffHunk is a group of nearby changes with their context, as in a unified diff.
+type DiffHunk struct {
+	OldStart int        `json:"old_start"`
+	OldLines int        `json:"old_lines"`
+	NewStart int        `json:"new_start"`
+	NewLines int        `json:"new_lines"`
+	Lines    []DiffLine `json:"lines"`
+}
+
+// Header is the "@@ -a,b +c,d @@" line of the hunk.
+func (h DiffHunk) Header() string {
+	return fmt.Sprintf("@@ -%d,%d +%d,%d @@", h.OldStart, h.OldLines, h.NewStart, h.NewLines)
+}
+
+// String renders the hunk in unified diff format.
+func (h DiffHunk) String() string {
+	var sb strings.Builder
+	sb.WriteString(h.Header() + "\n")
+	for _, l := range h.Lines {
+		sb.WriteString(l.Kind)
+		sb.WriteString(strings.TrimRight(l.Text, "\r\n") + "\n")
+	}
+	return sb.String()
+}
+
+// applyHunks returns old with only the hunks selected by accept applied.
+func applyHunks(old string, hunks []DiffHunk, accept []bool) string {
+	lines := splitLines(old)
+	var sb strings.Builder
+	next := 0 // index of the next old line to copy
+	for i, h := range hunks {
+		start := h.OldStart - 1
+		if h.OldLines == 0 {
+			start = h.OldStart // an insertion after line OldStart
+		}
+		for ; next < start; next++ {
+			sb.WriteString(lines[next])
+		}
+		for _, l := range h.Lines {
+			switch {
+			case l.Kind == " ", l.Kind == "-" && !accept[i], l.Kind == "+" && accept[i]:
+				sb.WriteString(l.Text)
+			}
+		}
+		next = start + h.OldLines
+	}
+	for ; next < len(lines); next++ {
+		sb.WriteString(lines[next])
+	}
+	return sb.String()
+}
+
+// diffHunks computes the unified diff hunks turning old into new.
+func diffHunks(old, new string) []DiffHunk {
+	ops := diffOps(splitLines(old), splitLines(new))
+
+	// Locate each change and grow it by the context, merging hunks whose context overlaps.
+	var ranges [][2]int
+	for i, op := range ops {
+		if op.Kind == " " {
+			continue
+		}
+		start, end := max(0, i-diffContext), min(len(ops), i+diffContext+1)
+		if n := len(ranges); n > 0 && start <= ranges[n-1][1] {
+			ranges[n-1][1] = end
+		} else {
+			ranges = append(ranges, [2]int{start, end})
+		}
+	}
+
+	var hunks []DiffHunk
+	oldLine, newLine, pos := 0, 0, 0
+	for _, r := range ranges {
+		for ; pos < r[0]; pos++ {
+			oldLine, newLine = oldLine+1, newLine+1 // only context lies between hunks
+		}
+		h := DiffHunk{OldStart: oldLine + 1, NewStart: newLine + 1, Lines: ops[r[0]:r[1]]}
+		for ; pos < r[1]; pos++ {
+			if ops[pos].Kind != "+" {
+				h.OldLines++
+				oldLine++
+			}
+			if ops[pos].Kind != "-" {
+				h.NewLines++
+				newLine++
+			}
+		}
+		// Unified diff numbers an empty side by the line it follows.
+		if h.OldLines == 0 {
+			h.OldStart--
+		}
+		if h.NewLines == 0 {
+			h.NewStart--
+		}
+		hunks = append(hunks, h)
+	}
+	return hunks
+}
+
+// diffOps returns the line edit script from a to b, based on their longest common subsequence.
+func diffOps(a, b []string) []DiffLine {
+	// Common prefix and suffix need no table.
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	var ops []DiffLine
+	for _, l := range a[:prefix] {
+		ops = append(ops, DiffLine{Kind: " ", Text: l})
+	}
+	ops = append(ops, lcsOps(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix])...)
+	for _, l := range a[len(a)-suffix:] {
+		ops = append(ops, DiffLine{Kind: " ", Text: l})
+	}
+	return ops
+}
+
+func lcsOps(a, b []string) []DiffLine {
+	var ops []DiffLine
+	if len(a)*len(b) > maxDiffCells {
+		for _, l := range a {
+			ops = append(ops, DiffLine{Kind: "-", Text: l})
+		}
+		for _, l := range b {
+			ops = append(ops, DiffLine{Kind: "+", Text: l})
+		}
+		return ops
+	}
+
+	// lcs[i][j] is the LCS length of a[i:] and b[j:].
+	lcs := make([][]int32, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int32, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			ops = append(ops, DiffLine{Kind: " ", Text: a[i]})
+			i, j = i+1, j+1
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			ops = append(ops, DiffLine{Kind: "-", Text: a[i]})
+			i++
+		default:
+			ops = append(ops, DiffLine{Kind: "+", Text: b[j]})
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		ops = append(ops, DiffLine{Kind: "-", Text: a[i]})
+	}
+	for ; j < len(b); j++ {
+		ops = append(ops, DiffLine{Kind: "+", Text: b[j]})
+	}
+	return ops
+}
+
+// splitLines splits s after each newline, so joining the lines gives s back.
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
@@ -0,0 +1,135 @@
+package tooling
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// numbered returns n lines "line 1\n" ... "line n\n".
+func numbered(n int) []string {
+	lines := make([]string, n)
+	for i := range lines {
+		lines[i] = fmt.Sprintf("line %d\n", i+1)
+	}
+	return lines
+}
+
+// replaced returns lines with the given 1-based lines replaced.
+func replaced(lines []string, with map[int]string) string {
+	out := append([]string(nil), lines...)
+	for n, s := range with {
+		out[n-1] = s
+	}
+	return strings.Join(out, "")
+}
+
+func TestDiffHunks(t *testing.T) {
+	base := numbered(20)
+	old := strings.Join(base, "")
+	tests := []struct {
+		name    string
+		old     string
+		new     string
+		headers []string
+	}{
+		{"identical", old, old, nil},
+		{"one change", old, replaced(base, map[int]string{10: "changed\n"}), []string{"@@ -7,7 +7,7 @@"}},
+		{"far apart changes split", old, replaced(base, map[int]string{3: "a\n", 17: "b\n"}), []string{"@@ -1,6 +1,6 @@", "@@ -14,7 +14,7 @@"}},
+		{"overlapping context merges", old, replaced(base, map[int]string{8: "a\n", 14: "b\n"}), []string{"@@ -5,13 +5,13 @@"}},
+		{"insertion at the start", "b\nc\n", "a\nb\nc\n", []string{"@@ -1,2 +1,3 @@"}},
+		{"deletion at the end", "a\nb\nc\n", "a\nb\n", []string{"@@ -1,3 +1,2 @@"}},
+		{"new file", "", "a\nb\n", []string{"@@ -0,0 +1,2 @@"}},
+		{"emptied file", "a\nb\n", "", []string{"@@ -1,2 +0,0 @@"}},
+		{"missing final newline", "a\nb", "a\nb\n", []string{"@@ -1,2 +1,2 @@"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hunks := diffHunks(tt.old, tt.new)
+			var headers []string
+			for _, h := range hunks {
+				headers = append(headers, h.Header())
+			}
+			if strings.Join(headers, " ") != strings.Join(tt.headers, " ") {
+				t.Fatalf("got hunks %q, want %q", headers, tt.headers)
+			}
+			for _, h := range hunks {
+				old, new := 0, 0
+				for _, l := range h.Lines {
+					if l.Kind != "+" {
+						old++
+					}
+					if l.Kind != "-" {
+						new++
+					}
+				}
+				if old != h.OldLines || new != h.NewLines {
+					t.Errorf("%s counts %d old and %d new lines", h.Header(), old, new)
+				}
+			}
+			all := make([]bool, len(hunks))
+			for i := range all {
+				all[i] = true
+			}
+			if got := applyHunks(tt.old, hunks, all); got != tt.new {
+				t.Errorf("applying every hunk gave %q, want %q", got, tt.new)
+			}
+			if got := applyHunks(tt.old, hunks, make([]bool, len(hunks))); got != tt.old {
+				t.Errorf("applying no hunk gave %q, want the original", got)
+			}
+		})
+	}
+}
+
+func TestApplyHunks_Partial(t *testing.T) {
+	base := numbered(60)
+	old := strings.Join(base, "")
+	new := replaced(base, map[int]string{2: "two\n", 25: "twenty-five\n", 55: "fifty-five\n"})
+	// An inserted and a removed line shift the numbers of later hunks.
+	new = strings.Replace(new, "line 12\n", "line 12\ninserted\n", 1)
+	new = strings.Replace(new, "line 38\n", "", 1)
+
+	hunks := diffHunks(old, new)
+	if len(hunks) != 5 {
+		t.Fatalf("expected five hunks, got %d:\n%s", len(hunks), (&ChangeReview{Files: []FileChange{{Path: "f", Hunks: hunks}}}).String())
+	}
+	for mask := 0; mask < 1<<len(hunks); mask++ {
+		accept := make([]bool, len(hunks))
+		want := append([]string(nil), base...)
+		for i := range accept {
+			accept[i] = mask&(1<<i) != 0
+		}
+		if accept[0] {
+			want[1] = "two\n"
+		}
+		if accept[1] {
+			want[11] = "line 12\ninserted\n"
+		}
+		if accept[2] {
+			want[24] = "twenty-five\n"
+		}
+		if accept[3] {
+			want[37] = ""
+		}
+		if accept[4] {
+			want[54] = "fifty-five\n"
+		}
+		if got := applyHunks(old, hunks, accept); got != strings.Join(want, "") {
+			t.Errorf("accepting %v gave:\n%s", accept, got)
+		}
+	}
+}
+
+func TestDiffHunk_String(t *testing.T) {
+	hunks := diffHunks("a\r\nb\r\n", "a\r\nc\r\n")
+	if len(hunks) != 1 {
+		t.Fatalf("expected one hunk, got %d", len(hunks))
+	}
+	want := "@@ -1,2 +1,2 @@\n a\n-b\n+c\n"
+	if got := hunks[0].String(); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if got := applyHunks("a\r\nb\r\n", hunks, []bool{true}); got != "a\r\nc\r\n" {
+		t.Errorf("line endings must be kept, got %q", got)
+	}
+}
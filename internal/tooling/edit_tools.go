@@ -0,0 +1,316 @@
+package tooling
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/nathfavour/vibeauracle/sys"
+)
+
+// fileEdit is one exact search/replace of sys_edit_file and sys_multi_edit.
+type fileEdit struct {
+	Path       string `json:"path"`
+	OldString  string `json:"old_string"`
+	NewString  string `json:"new_string"`
+	ReplaceAll bool   `json:"replace_all"`
+}
+
+// apply replaces the edit's old string in content. Unless ReplaceAll is set, the old
+// string must occur exactly once; errors show the model where to look.
+func (e fileEdit) apply(content string) (string, int, error) {
+	if e.OldString == "" {
+		return "", 0, fmt.Errorf("old_string is empty; use sys_write_file to create a file")
+	}
+	if e.OldString == e.NewString {
+		return "", 0, fmt.Errorf("old_string and new_string are identical; nothing to change")
+	}
+
+	lines := strings.Split(content, "\n")
+	count := strings.Count(content, e.OldString)
+	switch {
+	case count == 0:
+		return "", 0, notFoundError(e.Path, content, e.OldString, lines)
+	case count > 1 && !e.ReplaceAll:
+		var at []string
+		for i, off := 0, 0; i < count && i < 10; i++ {
+			idx := strings.Index(content[off:], e.OldString) + off
+			at = append(at, fmt.Sprint(strings.Count(content[:idx], "\n")+1))
+			off = idx + len(e.OldString)
+		}
+		return "", 0, fmt.Errorf("old_string matched %d times in %s (lines %s); include more surrounding lines to make it unique, or set replace_all to change every occurrence",
+			count, e.Path, strings.Join(at, ", "))
+	}
+	return strings.ReplaceAll(content, e.OldString, e.NewString), count, nil
+}
+
+// notFoundError explains a missing old string, pointing at the closest candidate.
+func notFoundError(path, content, old string, lines []string) error {
+	msg := fmt.Sprintf("old_string was not found in %s", path)
+
+	// The same text with different indentation or spacing is the most common miss.
+	normalize := func(s string) string { return strings.Join(strings.Fields(s), " ") }
+	first := strings.TrimSpace(strings.SplitN(strings.TrimSpace(old), "\n", 2)[0])
+	for i, l := range lines {
+		if first != "" && normalize(l) == normalize(first) {
+			if strings.Contains(normalize(content), normalize(old)) {
+				msg += " (it matches only when whitespace is ignored; copy the exact indentation)"
+			} else {
+				msg += " (its first line matches, but the lines after it differ)"
+			}
+			return fmt.Errorf("%s.\n%s", msg, excerpt(lines, i, "Closest match"))
+		}
+	}
+	for i, l := range lines {
+		if first != "" && strings.Contains(l, first) {
+			return fmt.Errorf("%s.\n%s", msg, excerpt(lines, i, "Closest match"))
+		}
+	}
+	return fmt.Errorf("%s; re-read the file, it may have changed since you last saw it", msg)
+}
+
+// EditFileTool performs an exact search/replace in a file.
+type EditFileTool struct {
+	fs sys.FS
+}
+
+func NewEditFileTool(f sys.FS) *EditFileTool {
+	return &EditFileTool{fs: f}
+}
+
+func (t *EditFileTool) Metadata() ToolMetadata {
+	return ToolMetadata{
+		Name:        "sys_edit_file",
+		Description: "Replace an exact string in a file. old_string must match the file exactly (including indentation) and be unique unless replace_all is set. Prefer this over rewriting whole files.",
+		Source:      "system",
+		Category:    CategoryFileSystem,
+		Roles:       []AgentRole{RoleCoder, RoleEngineer},
+		Complexity:  4,
+		Permissions: []Permission{PermWrite},
+		Parameters: json.RawMessage(`{
+			"type": "object",
+			"properties": {
+				"path": {"type": "string", "description": "Path to the file to edit"},
+				"old_string": {"type": "string", "description": "Exact text to replace; include enough surrounding lines to make it unique"},
+				"new_string": {"type": "string", "description": "Replacement text"},
+				"replace_all": {"type": "boolean", "description": "Replace every occurrence instead of requiring a unique match"}
+			},
+			"required": ["path", "old_string", "new_string"]
+		}`),
+	}
+}
+
+func (t *EditFileTool) Execute(ctx context.Context, args json.RawMessage) (*ToolResult, error) {
+	var input fileEdit
+	if err := json.Unmarshal(args, &input); err != nil {
+		return nil, err
+	}
+
+	ReportStatus("✏️", "exec", fmt.Sprintf("Editing file: %s", input.Path))
+
+	content, err := t.fs.ReadFile(input.Path)
+	if err != nil {
+		return &ToolResult{Status: "error", Error: err}, err
+	}
+	_, count, err := input.apply(string(content))
+	if err != nil {
+		ReportStatus("❌", "exec", fmt.Sprintf("Edit of %s failed", input.Path))
+		return &ToolResult{Status: "error", Error: err}, err
+	}
+
+	// Edit re-reads the file, so changes made since the read above are not clobbered.
+	if err := t.fs.Edit(input.Path, input.OldString, input.NewString); err != nil {
+		var intervention *InterventionError
+		if errors.As(err, &intervention) {
+			return nil, err
+		}
+		return &ToolResult{Status: "error", Error: err}, err
+	}
+
+	ReportStatus("✅", "exec", fmt.Sprintf("Edited %s", input.Path))
+	return &ToolResult{
+		Status:    "success",
+		Content:   fmt.Sprintf("Edited %s: replaced %d occurrence(s)", input.Path, count),
+		Artifacts: []string{input.Path},
+	}, nil
+}
+
+// MultiEditTool applies several search/replace edits, across one or more files, all or nothing.
+type MultiEditTool struct {
+	fs sys.FS
+}
+
+func NewMultiEditTool(f sys.FS) *MultiEditTool {
+	return &MultiEditTool{fs: f}
+}
+
+func (t *MultiEditTool) Metadata() ToolMetadata {
+	return ToolMetadata{
+		Name:        "sys_multi_edit",
+		Description: "Apply several exact search/replace edits in order, across one or more files. Each edit sees the result of the previous ones; if any edit fails, nothing is written.",
+		Source:      "system",
+		Category:    CategoryFileSystem,
+		Roles:       []AgentRole{RoleCoder, RoleEngineer},
+		Complexity:  5,
+		Permissions: []Permission{PermWrite},
+		Parameters: json.RawMessage(`{
+			"type": "object",
+			"properties": {
+				"edits": {
+					"type": "array",
+					"items": {
+						"type": "object",
+						"properties": {
+							"path": {"type": "string"},
+							"old_string": {"type": "string"},
+							"new_string": {"type": "string"},
+							"replace_all": {"type": "boolean"}
+						},
+						"required": ["path", "old_string", "new_string"]
+					}
+				}
+			},
+			"required": ["edits"]
+		}`),
+	}
+}
+
+func (t *MultiEditTool) Execute(ctx context.Context, args json.RawMessage) (*ToolResult, error) {
+	var input struct {
+		Edits []fileEdit `json:"edits"`
+	}
+	if err := json.Unmarshal(args, &input); err != nil {
+		return nil, err
+	}
+	if len(input.Edits) == 0 {
+		err := fmt.Errorf("no edits given")
+		return &ToolResult{Status: "error", Error: err}, err
+	}
+
+	ReportStatus("✏️", "exec", fmt.Sprintf("Applying %d edits", len(input.Edits)))
+
+	var order []string
+	contents := map[string]string{}
+	for i, e := range input.Edits {
+		content, ok := contents[e.Path]
+		if !ok {
+			data, err := t.fs.ReadFile(e.Path)
+			if err != nil {
+				err = fmt.Errorf("edit %d of %d: %w; no changes were written", i+1, len(input.Edits), err)
+				return &ToolResult{Status: "error", Error: err}, err
+			}
+			content = string(data)
+			order = append(order, e.Path)
+		}
+		updated, _, err := e.apply(content)
+		if err != nil {
+			err = fmt.Errorf("edit %d of %d: %w\nNo changes were written.", i+1, len(input.Edits), err)
+			ReportStatus("❌", "exec", fmt.Sprintf("Edit %d of %d failed", i+1, len(input.Edits)))
+			return &ToolResult{Status: "error", Error: err}, err
+		}
+		contents[e.Path] = updated
+	}
+
+	ops := make([]sys.BatchOp, 0, len(order))
+	for _, path := range order {
+		ops = append(ops, sys.BatchOp{Type: sys.OpWrite, Path: path, Content: []byte(contents[path])})
+	}
+	return batchResult(t.fs, ops, fmt.Sprintf("Applied %d edits to %s", len(input.Edits), strings.Join(order, ", ")))
+}
+
+// ApplyPatchTool applies a unified diff, tolerating shifted lines and small context drift.
+type ApplyPatchTool struct {
+	fs sys.FS
+}
+
+func NewApplyPatchTool(f sys.FS) *ApplyPatchTool {
+	return &ApplyPatchTool{fs: f}
+}
+
+func (t *ApplyPatchTool) Metadata() ToolMetadata {
+	return ToolMetadata{
+		Name:        "sys_apply_patch",
+		Description: "Apply a unified diff (diff -u / git diff format) to one or more files. Hunks are matched at their line numbers, then nearby, then ignoring whitespace and with small context differences. /dev/null creates or deletes files. If any hunk fails, nothing is written.",
+		Source:      "system",
+		Category:    CategoryFileSystem,
+		Roles:       []AgentRole{RoleCoder, RoleEngineer},
+		Complexity:  6,
+		Permissions: []Permission{PermWrite},
+		Parameters: json.RawMessage(`{
+			"type": "object",
+			"properties": {
+				"patch": {"type": "string", "description": "Unified diff with ---/+++ file headers and @@ hunks"}
+			},
+			"required": ["patch"]
+		}`),
+	}
+}
+
+func (t *ApplyPatchTool) Execute(ctx context.Context, args json.RawMessage) (*ToolResult, error) {
+	var input struct {
+		Patch string `json:"patch"`
+	}
+	if err := json.Unmarshal(args, &input); err != nil {
+		return nil, err
+	}
+
+	files, err := parsePatch(input.Patch)
+	if err != nil {
+		return &ToolResult{Status: "error", Error: err}, err
+	}
+	ReportStatus("🩹", "exec", fmt.Sprintf("Applying patch to %d file(s)", len(files)))
+
+	var ops []sys.BatchOp
+	var touched []string
+	for _, f := range files {
+		var content string
+		if f.oldPath != "" {
+			data, err := t.fs.ReadFile(f.oldPath)
+			if err != nil {
+				err = fmt.Errorf("%w; no changes were written", err)
+				return &ToolResult{Status: "error", Error: err}, err
+			}
+			content = string(data)
+		}
+
+		if f.newPath == "" {
+			ops = append(ops, sys.BatchOp{Type: sys.OpDelete, Path: f.oldPath})
+			touched = append(touched, f.oldPath+" (deleted)")
+			continue
+		}
+		updated, err := applyPatchHunks(f.newPath, content, f.hunks)
+		if err != nil {
+			err = fmt.Errorf("%w\nNo changes were written.", err)
+			ReportStatus("❌", "exec", fmt.Sprintf("Patch for %s did not apply", f.newPath))
+			return &ToolResult{Status: "error", Error: err}, err
+		}
+		ops = append(ops, sys.BatchOp{Type: sys.OpWrite, Path: f.newPath, Content: []byte(updated)})
+		if f.oldPath != "" && f.oldPath != f.newPath {
+			ops = append(ops, sys.BatchOp{Type: sys.OpDelete, Path: f.oldPath})
+			touched = append(touched, f.oldPath+" → "+f.newPath)
+		} else {
+			touched = append(touched, f.newPath)
+		}
+	}
+	return batchResult(t.fs, ops, "Patched "+strings.Join(touched, ", "))
+}
+
+// batchResult writes ops in one batch, so a review shows them together.
+func batchResult(f sys.FS, ops []sys.BatchOp, summary string) (*ToolResult, error) {
+	if err := f.Batch(ops); err != nil {
+		var intervention *InterventionError
+		if errors.As(err, &intervention) {
+			return nil, err
+		}
+		return &ToolResult{Status: "error", Error: err}, err
+	}
+
+	var artifacts []string
+	for _, op := range ops {
+		artifacts = append(artifacts, op.Path)
+	}
+	ReportStatus("✅", "exec", summary)
+	return &ToolResult{Status: "success", Content: summary, Artifacts: artifacts}, nil
+}
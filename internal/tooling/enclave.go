@@ -6,18 +6,21 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
-	"regexp"
 	"strings"
 	"sync"
-	"time"
 )
 
 // InterventionError is returned when a tool needs user selection/approval.
-// The UI should render the choices and then call Resume(selectedOption).
+// The UI should render the choices and then call Resume with its context and the
+// selected option; an approved call runs under that context.
 type InterventionError struct {
 	Title   string
 	Choices []string
-	Resume  func(choice string) (*ToolResult, error)
+	// Risk is the enclave's assessment (low|medium|high), when it raised the intervention.
+	Risk string
+	// Review is set when the intervention asks to review file changes as a diff.
+	Review *ChangeReview
+	Resume func(ctx context.Context, choice string) (*ToolResult, error)
 }
 
 func (e *InterventionError) Error() string {
@@ -27,17 +30,22 @@ func (e *InterventionError) Error() string {
 // Enclave provides a secure policy layer for tool execution.
 // It supports approvals scoped to: once (caller-handled), session, or forever (persisted).
 type Enclave struct {
-	store *ApprovalStore
-	audit *AuditLogger
+	store  *ApprovalStore
+	audit  *AuditLogger
+	policy *Policy
 
 	mu           sync.Mutex
 	sessionAllow map[string]bool
 	sessionDeny  map[string]bool
+	session      string // ID of the current session, for session-scoped approvals
+	// pending holds the audit entries of approved calls until their result is known,
+	// by call (see Complete).
+	pending map[string][]AuditEntry
 }
 
 func NewEnclave(appDataDir string) (*Enclave, error) {
-	storePath := filepath.Join(appDataDir, "enclave", "approvals.json")
-	auditPath := filepath.Join(appDataDir, "enclave", "audit.log")
+	storePath := ApprovalsPath(appDataDir)
+	auditPath := AuditPath(appDataDir)
 
 	// Ensure dir exists
 	os.MkdirAll(filepath.Dir(storePath), 0755)
@@ -51,9 +59,149 @@ func NewEnclave(appDataDir string) (*Enclave, error) {
 		audit:        NewAuditLogger(auditPath),
 		sessionAllow: map[string]bool{},
 		sessionDeny:  map[string]bool{},
+		pending:      map[string][]AuditEntry{},
 	}, nil
 }
 
+// ApprovalsPath is where the enclave persists approvals inside the data directory.
+func ApprovalsPath(appDataDir string) string {
+	return filepath.Join(appDataDir, "enclave", "approvals.json")
+}
+
+// Approvals returns the store of persisted approvals.
+func (e *Enclave) Approvals() *ApprovalStore {
+	return e.store
+}
+
+// Audit returns the enclave's audit log.
+func (e *Enclave) Audit() *AuditLogger {
+	return e.audit
+}
+
+// SetThread ties the audit entries and session approvals that follow to a session and
+// one of its threads.
+func (e *Enclave) SetThread(session, thread string) {
+	e.mu.Lock()
+	e.session = session
+	e.mu.Unlock()
+	e.audit.SetThread(session, thread)
+}
+
+// approved holds the audit entry of an approved call until Complete records its result.
+func (e *Enclave) approved(tool string, args json.RawMessage, risk, decision, scope, rule string) {
+	entry := e.audit.Entry(tool, args, risk, decision, scope, rule)
+	key := tool + "\x00" + entry.Args
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.pending[key] = append(e.pending[key], entry)
+}
+
+// Complete is meant to be installed into SecurityGuard.SetResultHook. It records the
+// audit entry of an approved call together with the status of its result.
+func (e *Enclave) Complete(tool Tool, args json.RawMessage, res *ToolResult, err error) {
+	key := tool.Metadata().Name + "\x00" + stableJSON(args)
+	e.mu.Lock()
+	queue := e.pending[key]
+	if len(queue) == 0 {
+		e.mu.Unlock()
+		return
+	}
+	entry := queue[0]
+	if len(queue) == 1 {
+		delete(e.pending, key)
+	} else {
+		e.pending[key] = queue[1:]
+	}
+	e.mu.Unlock()
+
+	entry.Status = resultStatus(res, err)
+	_ = e.audit.Record(entry)
+}
+
+// execute runs an approved tool and records the decision with the result.
+func (e *Enclave) execute(ctx context.Context, tool Tool, args json.RawMessage, req ApprovalRequest, risk, decision, scope, rule string) (*ToolResult, error) {
+	entry := e.audit.Entry(req.ToolName, args, risk, decision, scope, rule)
+	res, err := tool.Execute(ctx, args)
+	entry.Status = resultStatus(res, err)
+	_ = e.audit.Record(entry)
+	return res, err
+}
+
+// approveAndExecute stores the user's approval in approval scope and runs the call.
+// The call still runs when the approval cannot be saved, since the user allowed it,
+// but the user is warned and the audit log records that nothing was saved.
+func (e *Enclave) approveAndExecute(ctx context.Context, tool Tool, args json.RawMessage, req ApprovalRequest, risk, scope, rule string, approval ApprovalScope, label string) (*ToolResult, error) {
+	decision := "Approved (" + label + ")"
+	if err := e.persist(req, args, ApprovalAllow, approval); err != nil {
+		decision = "Approved (" + label + ", not saved)"
+		ReportStatus("⚠️", "security", fmt.Sprintf("Approval not saved, it applies to this call only: %v", err))
+	}
+	return e.execute(ctx, tool, args, req, risk, decision, scope, rule)
+}
+
+// SetPolicy installs declarative rules that are consulted before any stored approval.
+func (e *Enclave) SetPolicy(p *Policy) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.policy = p
+}
+
+// Policy is meant to be installed into SecurityGuard.SetPolicy. It hard-blocks dangerous
+// commands, then applies the first matching policy rule: allow and deny are decided
+// here, and ask raises an InterventionError even for previously approved actions.
+// Calls no rule matches are decided by persisted approvals, so that a persisted deny
+// holds even for permissions that are approved automatically.
+func (e *Enclave) Policy(tool Tool, args json.RawMessage) (PolicyDecision, error) {
+	e.mu.Lock()
+	policy := e.policy
+	e.mu.Unlock()
+
+	key, req, risk, err := buildApprovalRequest(tool, args, policy.CommandRules())
+	if err != nil {
+		return PolicyNone, err
+	}
+	req.Key = key
+	req.Risk = risk
+	scope := resolveScope(args)
+
+	if risk == "blocked" {
+		e.audit.Log(req.ToolName, args, risk, "Blocked", scope, "")
+		return PolicyDeny, fmt.Errorf("security: blocked action: %s", req.Summary)
+	}
+
+	decision, rule := policy.Evaluate(tool, args)
+	switch decision {
+	case PolicyAllow:
+		e.approved(req.ToolName, args, risk, "Approved (Policy)", scope, rule.Name)
+		return decision, nil
+	case PolicyDeny:
+		e.audit.Log(req.ToolName, args, risk, "Denied (Policy)", scope, rule.Name)
+		return decision, fmt.Errorf("security: denied by policy rule %q: %s", rule.Name, req.Summary)
+	case PolicyAsk:
+		return decision, e.intervention(tool, args, key, req, risk, scope, rule.Name)
+	}
+	return e.persisted(args, req, risk, scope)
+}
+
+// persisted applies the stored approval that matches a call, if any.
+func (e *Enclave) persisted(args json.RawMessage, req ApprovalRequest, risk, scope string) (PolicyDecision, error) {
+	cwd, _ := os.Getwd()
+	e.mu.Lock()
+	call := ApprovalCall{Tool: req.ToolName, Key: req.Key, Subject: approvalSubject(req, args), Commands: approvalCommands(req, args), Dir: cwd, Session: e.session}
+	e.mu.Unlock()
+	a, ok := e.store.Match(call)
+	switch {
+	case !ok:
+		return PolicyNone, nil
+	case a.Decision == ApprovalDeny:
+		e.audit.Log(req.ToolName, args, risk, "Denied (Persisted)", scope, "approval:"+a.ID)
+		return PolicyDeny, fmt.Errorf("security: denied (persisted, approval %s): %s", a.ID, req.Summary)
+	default:
+		e.approved(req.ToolName, args, risk, "Approved (Persisted)", scope, "approval:"+a.ID)
+		return PolicyAllow, nil
+	}
+}
+
 // ApproveSession allows a request key for the rest of the current session.
 func (e *Enclave) ApproveSession(key string) {
 	e.mu.Lock()
@@ -70,21 +218,42 @@ func (e *Enclave) DenySession(key string) {
 	delete(e.sessionAllow, key)
 }
 
-// ApproveForever persists an allow decision.
-func (e *Enclave) ApproveForever(key string) error {
-	return e.store.Set(key, decisionAllow)
-}
+// persist stores a decision about the call of req in scope. Session approvals stay in
+// memory when no session is known.
+func (e *Enclave) persist(req ApprovalRequest, args json.RawMessage, decision ApprovalDecision, scope ApprovalScope) error {
+	e.mu.Lock()
+	session := e.session
+	e.mu.Unlock()
 
-// DenyForever persists a deny decision.
-func (e *Enclave) DenyForever(key string) error {
-	return e.store.Set(key, decisionDeny)
+	a := Approval{Tool: req.ToolName, Key: req.Key, Summary: approvalSubject(req, args), Decision: decision, Scope: scope}
+	switch scope {
+	case ScopeSession:
+		if session == "" {
+			if decision == ApprovalAllow {
+				e.ApproveSession(req.Key)
+			} else {
+				e.DenySession(req.Key)
+			}
+			return nil
+		}
+		a.Session = session
+	case ScopeProject:
+		cwd, _ := os.Getwd()
+		a.Root = ProjectRoot(cwd)
+	}
+	_, err := e.store.Add(a)
+	return err
 }
 
 // Interceptor is meant to be installed into SecurityGuard.SetInterceptor.
 // It returns true if approved; otherwise returns a NeedsApprovalError.
 func (e *Enclave) Interceptor(tool Tool, args json.RawMessage) (bool, error) {
+	e.mu.Lock()
+	policy := e.policy
+	e.mu.Unlock()
+
 	// Normalize and build a stable key.
-	key, req, risk, err := buildApprovalRequest(tool, args)
+	key, req, risk, err := buildApprovalRequest(tool, args, policy.CommandRules())
 	if err != nil {
 		return false, err
 	}
@@ -93,7 +262,7 @@ func (e *Enclave) Interceptor(tool Tool, args json.RawMessage) (bool, error) {
 
 	// Hard-block rules
 	if risk == "blocked" {
-		e.audit.Log(req.ToolName, args, risk, "Blocked", resolveScope(args))
+		e.audit.Log(req.ToolName, args, risk, "Blocked", resolveScope(args), "")
 		return false, fmt.Errorf("security: blocked action: %s", req.Summary)
 	}
 
@@ -103,57 +272,58 @@ func (e *Enclave) Interceptor(tool Tool, args json.RawMessage) (bool, error) {
 	e.mu.Lock()
 	if e.sessionDeny[key] {
 		e.mu.Unlock()
-		e.audit.Log(req.ToolName, args, risk, "Denied (Session)", scope)
+		e.audit.Log(req.ToolName, args, risk, "Denied (Session)", scope, "")
 		return false, fmt.Errorf("security: denied for session: %s", req.Summary)
 	}
 	if e.sessionAllow[key] {
 		e.mu.Unlock()
-		e.audit.Log(req.ToolName, args, risk, "Approved (Session)", scope)
+		e.approved(req.ToolName, args, risk, "Approved (Session)", scope, "")
 		return true, nil
 	}
 	e.mu.Unlock()
 
 	// Persisted checks
-	if rec, ok := e.store.Get(key); ok {
-		switch rec.Decision {
-		case decisionAllow:
-			e.audit.Log(req.ToolName, args, risk, "Approved (Persisted)", scope)
-			return true, nil
-		case decisionDeny:
-			e.audit.Log(req.ToolName, args, risk, "Denied (Persisted)", scope)
-			return false, fmt.Errorf("security: denied (persisted): %s", req.Summary)
-		}
+	switch decision, err := e.persisted(args, req, risk, scope); decision {
+	case PolicyAllow:
+		return true, nil
+	case PolicyDeny:
+		return false, err
 	}
 
-	// Create resumption closure
-	resumeFunc := func(choice string) (*ToolResult, error) {
+	return false, e.intervention(tool, args, key, req, risk, scope, "")
+}
+
+// intervention asks the user about a call. rule names the policy rule that demanded
+// the prompt, if any, and is recorded with the user's answer.
+func (e *Enclave) intervention(tool Tool, args json.RawMessage, key string, req ApprovalRequest, risk, scope, rule string) error {
+	resumeFunc := func(ctx context.Context, choice string) (*ToolResult, error) {
 		switch choice {
 		case "Approve Once":
-			e.audit.Log(req.ToolName, args, risk, "Approved (Once)", scope)
-			return tool.Execute(context.TODO(), args) // Execute directly
+			return e.execute(ctx, tool, args, req, risk, "Approved (Once)", scope, rule) // Execute directly
 		case "Approve Session":
-			e.ApproveSession(key)
-			e.audit.Log(req.ToolName, args, risk, "Approved (Session)", scope)
-			return tool.Execute(context.TODO(), args)
+			return e.approveAndExecute(ctx, tool, args, req, risk, scope, rule, ScopeSession, "Session")
+		case "Approve in Project":
+			return e.approveAndExecute(ctx, tool, args, req, risk, scope, rule, ScopeProject, "Project")
 		case "Approve Forever":
-			e.ApproveForever(key)
-			e.audit.Log(req.ToolName, args, risk, "Approved (Forever)", scope)
-			return tool.Execute(context.TODO(), args)
+			return e.approveAndExecute(ctx, tool, args, req, risk, scope, rule, ScopeGlobal, "Forever")
 		default:
-			e.audit.Log(req.ToolName, args, risk, "Denied (User)", scope)
+			e.audit.Log(req.ToolName, args, risk, "Denied (User)", scope, rule)
 			return nil, fmt.Errorf("security: user denied %s", req.Summary)
 		}
 	}
 
-	return false, &InterventionError{
+	return &InterventionError{
 		Title:   fmt.Sprintf("Allow action? %s", req.Summary),
-		Choices: []string{"Approve Once", "Approve Session", "Approve Forever", "Deny"},
+		Choices: []string{"Approve Once", "Approve Session", "Approve in Project", "Approve Forever", "Deny"},
+		Risk:    risk,
 		Resume:  resumeFunc,
 	}
 }
 
 // buildApprovalRequest inspects a tool call and returns a stable key and description.
-func buildApprovalRequest(tool Tool, args json.RawMessage) (string, ApprovalRequest, string, error) {
+// Shell commands are parsed and rated with rules; their summary lists the parsed
+// commands when there is more than one or any of them is risky.
+func buildApprovalRequest(tool Tool, args json.RawMessage, rules []CommandRule) (string, ApprovalRequest, string, error) {
 	m := tool.Metadata()
 	name := m.Name
 	req := ApprovalRequest{ToolName: name}
@@ -191,14 +361,20 @@ func buildApprovalRequest(tool Tool, args json.RawMessage) (string, ApprovalRequ
 		if err := json.Unmarshal(args, &input); err != nil {
 			return "", ApprovalRequest{}, "", err
 		}
-		cmdline := strings.TrimSpace(input.Command + " " + strings.Join(input.Args, " "))
-		summary = "exec: " + cmdline
-		preview = cmdline
+		analysis := analyzeCommand(input.Command, input.Args, rules)
+		summary = "exec: " + analysis.line
+		if structure := analysis.String(); structure != "" {
+			summary += "\n" + structure
+		}
+		preview = analysis.line
 		key = "sys_shell_exec:" + normalizeCmdKey(input.Command, input.Args)
 
-		// Sanitization: block truly dangerous commands.
-		if r := commandRisk(input.Command, input.Args); r == "blocked" {
-			risk = "blocked"
+		// Commands only matched by rules get their rating; anything else keeps at
+		// least the risk of the tool's permissions.
+		if analysis.unrated {
+			risk = maxRisk(risk, analysis.risk)
+		} else {
+			risk = analysis.risk
 		}
 	}
 
@@ -225,115 +401,9 @@ func normalizeCmdKey(command string, args []string) string {
 	return strings.Join(parts, "\u0000")
 }
 
-var dangerousExact = map[string]bool{
-	"mkfs":      true,
-	"mkfs.ext4": true,
-	"mkfs.xfs":  true,
-	"dd":        true,
-	"shutdown":  true,
-	"reboot":    true,
-	"poweroff":  true,
-}
-
-func commandRisk(command string, args []string) string {
-	c := strings.ToLower(strings.TrimSpace(command))
-	if dangerousExact[c] {
-		return "blocked"
-	}
-
-	// Block shells that execute arbitrary strings.
-	if (c == "sh" || c == "bash" || c == "zsh") && contains(args, "-c") {
-		return "blocked"
-	}
-
-	// Block curl|sh patterns.
-	joined := strings.ToLower(strings.Join(args, " "))
-	if strings.Contains(joined, "| sh") || strings.Contains(joined, "|bash") {
-		return "blocked"
-	}
-
-	// Block rm -rf / (and close variants)
-	if c == "rm" {
-		if contains(args, "-rf") || contains(args, "-fr") {
-			for _, a := range args {
-				if strings.TrimSpace(a) == "/" {
-					return "blocked"
-				}
-			}
-		}
-	}
-
-	// Block writing raw to block devices
-	if c == "dd" {
-		// dd is already blocked above, but keep defense-in-depth
-		return "blocked"
-	}
-
-	// Detect obvious device paths
-	devRe := regexp.MustCompile(`^/dev/(sd|nvme|mmcblk|loop)`) // conservative
-	for _, a := range args {
-		if devRe.MatchString(strings.TrimSpace(a)) {
-			return "blocked"
-		}
-	}
-
-	return "ok"
-}
-
-func contains(xs []string, target string) bool {
-	for _, x := range xs {
-		if strings.TrimSpace(x) == target {
-			return true
-		}
-	}
-	return false
-}
-
 // Ensure Enclave can be used where context is needed (future).
 var _ = context.Background
 
-// --- Audit Logging ---
-
-type AuditEntry struct {
-	Timestamp string `json:"timestamp"`
-	Tool      string `json:"tool"`
-	Args      string `json:"args"`
-	Risk      string `json:"risk"`
-	Decision  string `json:"decision"` // Approved, Denied
-	Scope     string `json:"scope"`    // Local, System
-}
-
-// AuditLogger maintains a secure ledger of all agent actions
-type AuditLogger struct {
-	path string
-	mu   sync.Mutex
-}
-
-func NewAuditLogger(path string) *AuditLogger {
-	return &AuditLogger{path: path}
-}
-
-func (l *AuditLogger) Log(tool string, args json.RawMessage, risk, decision, scope string) {
-	entry := AuditEntry{
-		Timestamp: time.Now().Format(time.RFC3339),
-		Tool:      tool,
-		Args:      stableJSON(args),
-		Risk:      risk,
-		Decision:  decision,
-		Scope:     scope,
-	}
-
-	l.mu.Lock()
-	defer l.mu.Unlock()
-
-	f, err := os.OpenFile(l.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600) // 0600 = Secure
-	if err == nil {
-		bytes, _ := json.Marshal(entry)
-		f.WriteString(string(bytes) + "\n")
-		f.Close()
-	}
-}
-
 // --- Scoped Security ---
 
 // resolveScope determines if an operation is Local (safe-ish) or System (dangerous)
@@ -0,0 +1,682 @@
+package tooling
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"mvdan.cc/sh/v3/syntax"
+)
+
+// Command risks, from least to most severe. A blocked command is never run.
+var riskLevels = map[string]int{"low": 1, "medium": 2, "high": 3, "blocked": 4}
+
+// maxRisk returns the more severe of two risks.
+func maxRisk(a, b string) string {
+	if riskLevels[b] > riskLevels[a] {
+		return b
+	}
+	return a
+}
+
+// maxShellDepth bounds how deep scripts nested in "sh -c" and similar are analyzed.
+const maxShellDepth = 6
+
+// CommandRule rates the simple commands of a shell command line. Every field that is
+// set must match: Commands are globs on the program's base name, Subcommands globs on
+// its first operand, each Args glob must match some argument, one AnyArgs glob must
+// match some argument, one Redirects glob must match a file the command's output is
+// redirected to (globs without a slash match the file name), Piped requires input from a previous pipeline stage and NoArgs a
+// command without operands. A command gets the highest risk of all matching rules.
+type CommandRule struct {
+	Name        string   `yaml:"name"`
+	Commands    []string `yaml:"commands"`
+	Subcommands []string `yaml:"subcommands"`
+	Args        []string `yaml:"args"`
+	AnyArgs     []string `yaml:"any_args"`
+	Redirects   []string `yaml:"redirects"`
+	Piped       bool     `yaml:"piped"`
+	NoArgs      bool     `yaml:"no_args"`
+	Risk        string   `yaml:"risk"`
+	Reason      string   `yaml:"reason"`
+
+	// trusted rules are built in or from the user's own policy; a command matched only
+	// by a project's rules is not rated below the tool's default risk.
+	trusted bool
+}
+
+var (
+	shells       = []string{"sh", "bash", "zsh", "dash", "ksh", "mksh", "ash", "fish"}
+	interpreters = []string{"python", "python[0-9]*", "perl", "ruby", "node", "nodejs", "php", "lua", "deno", "bun"}
+	blockDevices = []string{"/dev/sd*", "/dev/hd*", "/dev/vd*", "/dev/xvd*", "/dev/nvme*", "/dev/mmcblk*", "/dev/loop*", "/dev/disk*", "/dev/mapper/*"}
+	// \* matches a literal "*", as in "rm -rf /*"; /home/* matches any user's home.
+	criticalDirs = []string{"/", `/\*`, "~", `~/\*`, "$HOME", `$HOME/\*`, "${HOME}", `${HOME}/\*`, "/home", "/home/*", "/root", "/etc", "/usr", "/bin", "/sbin", "/lib", "/lib64", "/boot", "/var", "/opt", "/System", "/Users", "/Users/*"}
+)
+
+// defaultCommandRules is the built-in rule table. Policy files can add rules, and the
+// user's own policy can replace a built-in rule by using its name.
+var defaultCommandRules = []CommandRule{
+	{Name: "format-disk", Commands: []string{"mkfs", "mkfs.*", "mke2fs", "mkswap", "wipefs", "fdisk", "sfdisk", "gdisk", "parted"}, Risk: "blocked", Reason: "formats or repartitions a disk"},
+	{Name: "power", Commands: []string{"shutdown", "reboot", "poweroff", "halt"}, Risk: "blocked", Reason: "shuts down or restarts the machine"},
+	{Name: "raw-disk-write", Commands: []string{"dd"}, AnyArgs: prefixAll("of=", blockDevices), Risk: "blocked", Reason: "writes raw data to a block device"},
+	{Name: "block-device-redirect", Redirects: blockDevices, Risk: "blocked", Reason: "redirects output onto a block device"},
+	{Name: "block-device", AnyArgs: blockDevices, Risk: "high", Reason: "operates on a block device"},
+	{Name: "delete-critical", Commands: []string{"rm"}, AnyArgs: criticalDirs, Risk: "blocked", Reason: "deletes the root, home or a system directory"},
+	{Name: "chmod-critical", Commands: []string{"chmod", "chown", "chgrp"}, Args: []string{"-*R*"}, AnyArgs: criticalDirs, Risk: "blocked", Reason: "recursively changes permissions of a system directory"},
+	{Name: "pipe-to-shell", Commands: shells, Piped: true, NoArgs: true, Risk: "blocked", Reason: "runs a script piped in from another command"},
+	{Name: "pipe-to-interpreter", Commands: interpreters, Piped: true, NoArgs: true, Risk: "high", Reason: "runs code piped in from another command"},
+	{Name: "inline-code", Commands: interpreters, AnyArgs: []string{"-c", "-*e", "-E", "--eval", "-r", "-p", "--print"}, Risk: "high", Reason: "runs inline code that cannot be inspected"},
+	{Name: "privileged", Commands: []string{"sudo", "doas", "su", "pkexec"}, Risk: "high", Reason: "runs with elevated privileges"},
+	{Name: "find-delete", Commands: []string{"find"}, AnyArgs: []string{"-delete"}, Risk: "high", Reason: "deletes the files it finds"},
+	{Name: "recursive-delete", Commands: []string{"rm"}, AnyArgs: []string{"-r*", "-[!-]*r*", "-R*", "-[!-]*R*", "--recursive"}, Risk: "high", Reason: "deletes directories recursively"},
+	{Name: "kill-processes", Commands: []string{"killall", "pkill"}, Risk: "high", Reason: "kills processes by name"},
+	{Name: "git-force-push", Commands: []string{"git"}, Subcommands: []string{"push"}, AnyArgs: []string{"-f", "--force", "--force-with-lease", "+*"}, Risk: "high", Reason: "rewrites remote history"},
+	{Name: "git-discard", Commands: []string{"git"}, Subcommands: []string{"reset", "checkout", "restore", "clean"}, AnyArgs: []string{"--hard", "-f", "--force", "-fd", "-fdx", "-xdf", "."}, Risk: "high", Reason: "discards uncommitted changes"},
+	{Name: "install-packages", Commands: []string{"npm", "pnpm", "yarn", "pip", "pip3", "apt", "apt-get", "brew", "dnf", "yum", "gem", "cargo"}, Subcommands: []string{"install", "i", "add"}, Risk: "high", Reason: "installs packages"},
+	{Name: "eval", Commands: []string{"eval", "source", "."}, Risk: "high", Reason: "runs shell code from a string or file"},
+	{Name: "network", Commands: []string{"curl", "wget"}, Risk: "medium", Reason: "makes network requests"},
+	{Name: "build-and-test", Commands: []string{"go", "cargo", "npm", "pnpm", "yarn", "make", "pytest", "mvn", "gradle", "dotnet"}, Risk: "medium", Reason: "builds or runs project code"},
+	{Name: "writes-file", Redirects: []string{"*"}, Risk: "medium", Reason: "redirects output into a file"},
+	{Name: "read-only", Commands: []string{"ls", "cat", "head", "tail", "grep", "egrep", "rg", "wc", "pwd", "echo", "printf", "which", "whoami", "date", "stat", "file", "tree", "du", "df", "sort", "uniq", "cut", "tr", "diff", "true", "false", "test", "[", "basename", "dirname", "realpath", "uname", "ps"}, Risk: "low", Reason: "only reads"},
+	{Name: "git-read", Commands: []string{"git"}, Subcommands: []string{"status", "diff", "log", "show", "rev-parse", "blame", "ls-files", "describe"}, Risk: "low", Reason: "only reads the repository"},
+}
+
+func prefixAll(prefix string, xs []string) []string {
+	out := make([]string, len(xs))
+	for i, x := range xs {
+		out[i] = prefix + x
+	}
+	return out
+}
+
+// validate checks a rule read from a policy file.
+func (r *CommandRule) validate() error {
+	if _, ok := riskLevels[r.Risk]; !ok {
+		return fmt.Errorf("risk must be low, medium, high or blocked, got %q", r.Risk)
+	}
+	for _, list := range [][]string{r.Commands, r.Subcommands, r.Args, r.AnyArgs, r.Redirects} {
+		for _, p := range list {
+			if _, err := filepath.Match(p, ""); err != nil {
+				return fmt.Errorf("bad pattern %q: %w", p, err)
+			}
+		}
+	}
+	return nil
+}
+
+func (r *CommandRule) matches(c *shellCommand) bool {
+	if len(c.args) == 0 {
+		return false
+	}
+	operands := c.operands()
+	if len(r.Commands) > 0 && !matchAny(r.Commands, filepath.Base(c.args[0])) {
+		return false
+	}
+	if len(r.Subcommands) > 0 && (len(operands) == 0 || !matchAny(r.Subcommands, operands[0])) {
+		return false
+	}
+	for _, p := range r.Args {
+		if !matchSome([]string{p}, c.args[1:]) {
+			return false
+		}
+	}
+	if len(r.AnyArgs) > 0 && !matchSome(r.AnyArgs, c.args[1:]) {
+		return false
+	}
+	if len(r.Redirects) > 0 && !r.redirectMatches(c.outputs) {
+		return false
+	}
+	if r.Piped && !c.piped {
+		return false
+	}
+	if r.NoArgs && len(operands) > 0 {
+		return false
+	}
+	return true
+}
+
+func (r *CommandRule) redirectMatches(targets []string) bool {
+	for _, t := range targets {
+		if matchAny(r.Redirects, t) || matchAny(r.Redirects, filepath.Base(t)) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchAny(patterns []string, s string) bool {
+	for _, p := range patterns {
+		if ok, _ := filepath.Match(p, s); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// matchSome reports whether any pattern matches any value. Values are also tried in
+// cleaned form, so "//" and "/." count as "/".
+func matchSome(patterns, values []string) bool {
+	for _, v := range values {
+		if v == "" {
+			continue
+		}
+		if matchAny(patterns, v) || matchAny(patterns, filepath.Clean(v)) {
+			return true
+		}
+	}
+	return false
+}
+
+// shellCommand is one simple command found in a command line.
+type shellCommand struct {
+	args    []string // word values, as far as they are known before running
+	text    string   // the command as written
+	depth   int
+	piped   bool     // reads the output of a previous pipeline stage
+	context string   // subshell, substitution, background, or the command that runs it
+	outputs []string // files output is redirected to
+	redirs  []string // redirections as written
+
+	risk    string
+	reasons []string // why the rules at risk rate the command
+}
+
+// operands are the arguments that are not options, up to a "--".
+func (c *shellCommand) operands() []string {
+	var out []string
+	for _, a := range c.args[1:] {
+		if a == "--" {
+			break
+		}
+		if !strings.HasPrefix(a, "-") || a == "-" {
+			out = append(out, a)
+		}
+	}
+	return out
+}
+
+// commandAnalysis is the parsed structure of a shell command line, with the risk of
+// each simple command in it.
+type commandAnalysis struct {
+	line       string
+	commands   []*shellCommand
+	risk       string // highest command risk; empty if no command matched a trusted rule
+	unrated    bool   // some command matched no trusted rule
+	parseError string
+}
+
+// analyzeCommand parses a sys_shell_exec call. Without args the command is shell
+// source; with args it is an argv, in which only wrapped scripts ("bash -c …") are parsed.
+func analyzeCommand(command string, args []string, rules []CommandRule) *commandAnalysis {
+	a := &commandAnalysis{}
+	command = strings.TrimSpace(command)
+	switch {
+	case len(args) == 0:
+		a.line = command
+		a.script(a.line, shellContext{})
+	case strings.ContainsAny(command, " \t|;&<>()$`"):
+		// Shell syntax in the command itself: rate it as if a shell ran the whole line.
+		a.line = command + " " + quoteArgv(args)
+		a.script(a.line, shellContext{})
+	default:
+		argv := append([]string{command}, args...)
+		a.line = quoteArgv(argv)
+		a.argv(argv, a.line, shellContext{})
+	}
+
+	for _, c := range a.commands {
+		if c.risk != "" {
+			a.risk = maxRisk(a.risk, c.risk) // already rated as uninspectable
+			continue
+		}
+		var matched []*CommandRule
+		trusted := false
+		for i := range rules {
+			if r := &rules[i]; r.matches(c) {
+				matched = append(matched, r)
+				c.risk = maxRisk(c.risk, r.Risk)
+				trusted = trusted || r.trusted
+			}
+		}
+		for _, r := range matched {
+			if r.Risk == c.risk && r.Reason != "" {
+				c.reasons = append(c.reasons, r.Reason)
+			}
+		}
+		if !trusted {
+			a.unrated = true
+		}
+		a.risk = maxRisk(a.risk, c.risk)
+	}
+	if a.parseError != "" {
+		a.unrated = true
+	}
+	return a
+}
+
+// shellContext is where a command sits in the command line.
+type shellContext struct {
+	depth   int
+	piped   bool
+	label   string
+	outputs []string
+	redirs  []string
+}
+
+// nested is the context of commands run by another command. They share its input
+// and output.
+func (ctx shellContext) nested(label string) shellContext {
+	ctx.depth++
+	ctx.label = label
+	return ctx
+}
+
+// script parses shell source and records its commands.
+func (a *commandAnalysis) script(src string, ctx shellContext) {
+	if ctx.depth > maxShellDepth {
+		a.dynamic(src, ctx, "nests scripts too deeply to inspect")
+		return
+	}
+	file, err := syntax.NewParser(syntax.Variant(syntax.LangBash)).Parse(strings.NewReader(src), "")
+	if err != nil {
+		if a.parseError == "" {
+			a.parseError = err.Error()
+		}
+		a.dynamic(src, ctx, "could not be parsed")
+		return
+	}
+	for _, stmt := range file.Stmts {
+		a.stmt(stmt, ctx)
+	}
+}
+
+// dynamic records text that cannot be inspected as a command rated high.
+func (a *commandAnalysis) dynamic(text string, ctx shellContext, reason string) {
+	a.commands = append(a.commands, &shellCommand{
+		text: text, depth: ctx.depth, context: ctx.label,
+		risk: "high", reasons: []string{reason},
+	})
+}
+
+func (a *commandAnalysis) stmt(s *syntax.Stmt, ctx shellContext) {
+	if s.Background {
+		ctx.label = "background"
+	}
+	for _, r := range s.Redirs {
+		text := r.Op.String() + printNode(r.Word)
+		if r.N != nil {
+			text = r.N.Value + text
+		}
+		ctx.redirs = append(ctx.redirs, text)
+		switch r.Op {
+		case syntax.RdrOut, syntax.AppOut, syntax.ClbOut, syntax.RdrAll, syntax.AppAll, syntax.RdrInOut:
+			if target, _ := wordValue(r.Word); !isStdStream(target) {
+				ctx.outputs = append(ctx.outputs, target)
+			}
+		}
+	}
+	// Substitutions are listed after the command they belong to.
+	defer func() {
+		for _, r := range s.Redirs {
+			a.substitutions(r.Word, ctx)
+		}
+	}()
+
+	switch cmd := s.Cmd.(type) {
+	case nil:
+	case *syntax.CallExpr:
+		defer func() {
+			for _, as := range cmd.Assigns {
+				a.substitutions(as, ctx)
+			}
+			for _, w := range cmd.Args {
+				a.substitutions(w, ctx)
+			}
+		}()
+		if len(cmd.Args) == 0 {
+			return // only variable assignments
+		}
+		var argv, words []string
+		for _, w := range cmd.Args {
+			v, _ := wordValue(w)
+			argv = append(argv, v)
+			words = append(words, printNode(w))
+		}
+		if _, ok := wordValue(cmd.Args[0]); !ok {
+			a.dynamic(strings.Join(words, " "), ctx, "the program to run is only known at run time")
+			return
+		}
+		a.argv(argv, strings.Join(words, " "), ctx)
+	case *syntax.BinaryCmd:
+		a.stmt(cmd.X, ctx)
+		if cmd.Op == syntax.Pipe || cmd.Op == syntax.PipeAll {
+			ctx.piped = true
+		}
+		a.stmt(cmd.Y, ctx)
+	default:
+		// Compound commands: visit the statements they contain.
+		label := ctx.label
+		if _, ok := cmd.(*syntax.Subshell); ok {
+			label = "subshell"
+		}
+		syntax.Walk(cmd, func(n syntax.Node) bool {
+			switch n := n.(type) {
+			case *syntax.Stmt:
+				inner := ctx
+				inner.label = label
+				a.stmt(n, inner)
+				return false
+			case *syntax.CmdSubst, *syntax.ProcSubst:
+				a.substitutions(n, ctx)
+				return false
+			}
+			return true
+		})
+	}
+}
+
+// substitutions records the commands of $(…), `…` and <(…) inside a node.
+func (a *commandAnalysis) substitutions(node syntax.Node, ctx shellContext) {
+	if node == nil {
+		return
+	}
+	syntax.Walk(node, func(n syntax.Node) bool {
+		var stmts []*syntax.Stmt
+		switch n := n.(type) {
+		case *syntax.CmdSubst:
+			stmts = n.Stmts
+		case *syntax.ProcSubst:
+			stmts = n.Stmts
+		default:
+			return true
+		}
+		inner := ctx.nested("substitution")
+		inner.piped, inner.outputs, inner.redirs = false, nil, nil
+		for _, s := range stmts {
+			a.stmt(s, inner)
+		}
+		return false
+	})
+}
+
+// argv records a simple command and, for commands that run another command or a
+// script given in their arguments, what they run. Expansions in a wrapped script are
+// kept as written, so the script is rated as the shell would see it after expansion
+// of anything static.
+func (a *commandAnalysis) argv(argv []string, text string, ctx shellContext) {
+	a.commands = append(a.commands, &shellCommand{
+		args: argv, text: text, depth: ctx.depth, piped: ctx.piped, context: ctx.label,
+		outputs: ctx.outputs, redirs: ctx.redirs,
+	})
+	if ctx.depth >= maxShellDepth {
+		return
+	}
+
+	name := filepath.Base(argv[0])
+	inner, script, ok := unwrapCommand(name, argv[1:])
+	if !ok {
+		return
+	}
+	nested := ctx.nested(name)
+	if script != "" {
+		a.script(script, nested)
+		return
+	}
+	for _, cmd := range inner {
+		if len(cmd) > 0 {
+			a.argv(cmd, quoteArgv(cmd), nested)
+		}
+	}
+}
+
+// commandWrapper describes a command that runs the command in its arguments.
+type commandWrapper struct {
+	valueFlags  []string // options taking the next argument as value
+	positionals int      // arguments before the wrapped command
+	assignments bool     // NAME=value arguments may precede the command
+}
+
+var commandWrappers = map[string]commandWrapper{
+	"sudo":     {valueFlags: []string{"-u", "-g", "-h", "-p", "-C", "-D", "-r", "-t", "-U", "-T", "-R", "--user", "--group", "--chdir"}},
+	"doas":     {valueFlags: []string{"-u", "-C"}},
+	"env":      {valueFlags: []string{"-u", "-C", "--unset", "--chdir"}, assignments: true},
+	"nohup":    {},
+	"setsid":   {},
+	"time":     {valueFlags: []string{"-f", "-o", "--format", "--output"}},
+	"exec":     {valueFlags: []string{"-a"}},
+	"builtin":  {},
+	"nice":     {valueFlags: []string{"-n", "--adjustment"}},
+	"ionice":   {valueFlags: []string{"-c", "-n", "-t", "--class", "--classdata"}},
+	"timeout":  {valueFlags: []string{"-s", "-k", "--signal", "--kill-after"}, positionals: 1},
+	"stdbuf":   {valueFlags: []string{"-i", "-o", "-e"}},
+	"chrt":     {positionals: 1},
+	"taskset":  {positionals: 1},
+	"xargs":    {valueFlags: []string{"-I", "-n", "-P", "-L", "-s", "-d", "-E", "-a", "--max-args", "--max-procs", "--delimiter", "--arg-file"}},
+	"busybox":  {},
+	"unbuffer": {},
+}
+
+// unwrapCommand returns what a command runs: argvs of wrapped commands, or shell
+// source. ok is false for commands that run nothing of their own.
+func unwrapCommand(name string, args []string) ([][]string, string, bool) {
+	switch {
+	case matchAny(shells, name):
+		script, ok := shellScriptArg(args)
+		return nil, script, ok
+	case name == "eval":
+		return nil, strings.Join(args, " "), len(args) > 0
+	case name == "watch":
+		rest := skipOptions(args, commandWrapper{valueFlags: []string{"-n", "-d", "--interval"}})
+		return nil, strings.Join(rest, " "), len(rest) > 0
+	case name == "command":
+		if len(args) > 0 && (args[0] == "-v" || args[0] == "-V") {
+			return nil, "", false // only looks the command up
+		}
+	case name == "find":
+		var cmds [][]string
+		for i := 0; i < len(args); i++ {
+			switch args[i] {
+			case "-exec", "-execdir", "-ok", "-okdir":
+				j := i + 1
+				for j < len(args) && args[j] != ";" && args[j] != "+" {
+					j++
+				}
+				cmds = append(cmds, args[i+1:j])
+				i = j
+			}
+		}
+		return cmds, "", len(cmds) > 0
+	case name == "env":
+		// env -S splits its argument into a command line.
+		for i, arg := range args {
+			if (arg == "-S" || arg == "--split-string") && i+1 < len(args) {
+				return nil, strings.Join(args[i+1:], " "), true
+			}
+			if s, ok := strings.CutPrefix(arg, "--split-string="); ok {
+				return nil, strings.Join(append([]string{s}, args[i+1:]...), " "), true
+			}
+		}
+	}
+
+	w, ok := commandWrappers[name]
+	if !ok {
+		return nil, "", false
+	}
+	rest := skipOptions(args, w)
+	if len(rest) == 0 {
+		return nil, "", false
+	}
+	return [][]string{rest}, "", true
+}
+
+// skipOptions drops a wrapper's options and leading arguments.
+func skipOptions(args []string, w commandWrapper) []string {
+	i := 0
+	for i < len(args) {
+		a := args[i]
+		if a == "--" {
+			i++
+			break
+		}
+		if w.assignments && !strings.HasPrefix(a, "-") && strings.Contains(a, "=") {
+			i++
+			continue
+		}
+		if !strings.HasPrefix(a, "-") || a == "-" {
+			break
+		}
+		i++
+		for _, f := range w.valueFlags {
+			if a == f {
+				i++
+				break
+			}
+		}
+	}
+	i += w.positionals
+	if i >= len(args) {
+		return nil
+	}
+	return args[i:]
+}
+
+// shellScriptArg finds the script of "sh -c script", including combined flags like -lc.
+func shellScriptArg(args []string) (string, bool) {
+	hasC := false
+	for i := 0; i < len(args); i++ {
+		a := args[i]
+		switch {
+		case a == "--":
+			if hasC && i+1 < len(args) {
+				return args[i+1], true
+			}
+			return "", false
+		case a == "-o" || a == "+o" || a == "-O" || a == "+O" || a == "--rcfile" || a == "--init-file":
+			i++
+		case strings.HasPrefix(a, "--"):
+		case (strings.HasPrefix(a, "-") || strings.HasPrefix(a, "+")) && len(a) > 1:
+			if strings.Contains(a[1:], "c") {
+				hasC = true
+			}
+		default:
+			return a, hasC
+		}
+	}
+	return "", false
+}
+
+// wordValue returns what a word expands to when it has no expansions, and otherwise
+// its source text with ok false.
+func wordValue(w *syntax.Word) (string, bool) {
+	if w == nil {
+		return "", false
+	}
+	var sb strings.Builder
+	literal := true
+	for _, part := range w.Parts {
+		switch p := part.(type) {
+		case *syntax.Lit:
+			sb.WriteString(unescape(p.Value, ""))
+		case *syntax.SglQuoted:
+			if p.Dollar {
+				sb.WriteString(printNode(p))
+				literal = false
+			} else {
+				sb.WriteString(p.Value)
+			}
+		case *syntax.DblQuoted:
+			for _, inner := range p.Parts {
+				if lit, ok := inner.(*syntax.Lit); ok {
+					sb.WriteString(unescape(lit.Value, "$`\"\\\n"))
+				} else {
+					sb.WriteString(printNode(inner))
+					literal = false
+				}
+			}
+		default:
+			sb.WriteString(printNode(p))
+			literal = false
+		}
+	}
+	return sb.String(), literal
+}
+
+// unescape removes shell backslash escapes. In double quotes only the characters in
+// special are escapable; unquoted, every character is.
+func unescape(s, special string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) && (special == "" || strings.IndexByte(special, s[i+1]) >= 0) {
+			i++
+		}
+		sb.WriteByte(s[i])
+	}
+	return sb.String()
+}
+
+func printNode(n syntax.Node) string {
+	var sb strings.Builder
+	if err := syntax.NewPrinter().Print(&sb, n); err != nil {
+		return "?"
+	}
+	return strings.TrimSpace(sb.String())
+}
+
+func isStdStream(target string) bool {
+	switch target {
+	case "/dev/null", "/dev/stdout", "/dev/stderr", "/dev/tty":
+		return true
+	}
+	return false
+}
+
+// quoteArgv renders an argv as shell source.
+func quoteArgv(argv []string) string {
+	parts := make([]string, len(argv))
+	for i, a := range argv {
+		q, err := syntax.Quote(a, syntax.LangBash)
+		if err != nil {
+			q = fmt.Sprintf("%q", a)
+		}
+		parts[i] = q
+	}
+	return strings.Join(parts, " ")
+}
+
+// String lists the commands of the analysis with their risks, one per line and
+// indented by nesting. It is empty for a single plain command.
+func (a *commandAnalysis) String() string {
+	if len(a.commands) == 1 && a.parseError == "" && len(a.commands[0].redirs) == 0 &&
+		riskLevels[a.commands[0].risk] < riskLevels["high"] {
+		return ""
+	}
+	var sb strings.Builder
+	for _, c := range a.commands {
+		sb.WriteString(strings.Repeat("  ", c.depth+1))
+		if c.piped {
+			sb.WriteString("| ")
+		}
+		sb.WriteString(c.text)
+		for _, r := range c.redirs {
+			sb.WriteString(" " + r)
+		}
+		var notes []string
+		if c.context != "" {
+			notes = append(notes, c.context)
+		}
+		if c.risk != "" {
+			notes = append(notes, c.risk+": "+strings.Join(c.reasons, "; "))
+		}
+		if len(notes) > 0 {
+			sb.WriteString("  [" + strings.Join(notes, ", ") + "]")
+		}
+		sb.WriteString("\n")
+	}
+	if a.parseError != "" {
+		sb.WriteString("  (parse error: " + a.parseError + ")\n")
+	}
+	return strings.TrimRight(sb.String(), "\n")
+}
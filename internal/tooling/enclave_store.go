@@ -1,33 +1,133 @@
 package tooling
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 )
 
-type approvalDecision string
+// ApprovalDecision is what a persisted approval decides.
+type ApprovalDecision string
 
 const (
-	decisionAllow approvalDecision = "allow"
-	decisionDeny  approvalDecision = "deny"
+	ApprovalAllow ApprovalDecision = "allow"
+	ApprovalDeny  ApprovalDecision = "deny"
 )
 
-type approvalRecord struct {
-	Decision  approvalDecision `json:"decision"`
+// ApprovalScope is where a persisted approval applies.
+type ApprovalScope string
+
+const (
+	ScopeGlobal  ApprovalScope = "global"  // in every directory
+	ScopeProject ApprovalScope = "project" // inside Root and below
+	ScopeSession ApprovalScope = "session" // in the session with ID Session
+)
+
+// Approval is a persisted decision about tool calls. It matches calls of Tool either
+// exactly, by the request Key of the approved call, or by Pattern, a glob ("*" is any
+// text) on the commands of sys_shell_exec calls and on the arguments of others.
+type Approval struct {
+	ID       string           `json:"id"`
+	Tool     string           `json:"tool"`
+	Key      string           `json:"key,omitempty"`
+	Pattern  string           `json:"pattern,omitempty"`
+	Summary  string           `json:"summary,omitempty"` // what was approved, for display
+	Decision ApprovalDecision `json:"decision"`
+	Scope    ApprovalScope    `json:"scope"`
+	Root     string           `json:"root,omitempty"`
+	Session  string           `json:"session,omitempty"`
+	// ExpiresAt ends the approval; zero means it does not expire.
+	ExpiresAt time.Time `json:"expires_at,omitzero"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Count     int       `json:"count"`
+}
+
+// Expired reports whether the approval has expired at now.
+func (a *Approval) Expired(now time.Time) bool {
+	return !a.ExpiresAt.IsZero() && !now.Before(a.ExpiresAt)
+}
+
+// ApprovalCall describes a tool call to match against approvals.
+type ApprovalCall struct {
+	Tool    string
+	Key     string // request key, see buildApprovalRequest
+	Subject string // command line or arguments, matched by patterns
+	// Commands are the simple commands of a shell command line, with their
+	// redirections. An allow pattern must match every one of them, a deny pattern any.
+	Commands []string
+	Dir      string // working directory, for project approvals
+	Session  string
+}
+
+// matches reports whether the approval applies to c.
+func (a *Approval) matches(c ApprovalCall, now time.Time) bool {
+	if a.Tool != c.Tool || a.Expired(now) {
+		return false
+	}
+	switch a.Scope {
+	case ScopeProject:
+		if !withinAny(c.Dir, []string{a.Root}) {
+			return false
+		}
+	case ScopeSession:
+		if a.Session != c.Session {
+			return false
+		}
+	}
+	if a.Pattern != "" && len(c.Commands) > 0 {
+		// Allowing "go test *" must not allow "go test ./... && rm -rf ~", and denying
+		// "rm *" must deny it.
+		deny := a.Decision == ApprovalDeny
+		for _, cmd := range c.Commands {
+			if globMatch(a.Pattern, cmd, false) == deny {
+				return deny // a denied command, or one the allow does not cover
+			}
+		}
+		return !deny
+	}
+	if a.Pattern != "" {
+		return globMatch(a.Pattern, c.Subject, false)
+	}
+	return a.Key == c.Key
+}
+
+// approvalID derives a short stable ID from what an approval matches and where, so
+// that approving the same thing again updates it.
+func approvalID(a Approval) string {
+	sum := sha256.Sum256([]byte(strings.Join([]string{a.Tool, a.Key, a.Pattern, string(a.Scope), a.Root, a.Session}, "\x00")))
+	return hex.EncodeToString(sum[:4])
+}
+
+// approvalFile is the format of approvals.json.
+type approvalFile struct {
+	Version   int        `json:"version"`
+	Approvals []Approval `json:"approvals"`
+}
+
+// legacyApproval is an entry of the original approvals.json, a map from request key to
+// decision that applied everywhere.
+type legacyApproval struct {
+	Decision  ApprovalDecision `json:"decision"`
 	UpdatedAt time.Time        `json:"updated_at"`
 	Count     int              `json:"count"`
 }
 
 // ApprovalStore persists allow/deny rules across runs.
-// Stored as a single JSON file in the app data dir.
+// Stored as a single JSON file in the app data dir, which is re-read when another
+// process changes it.
 type ApprovalStore struct {
-	path string
-	mu   sync.Mutex
-	m    map[string]approvalRecord
+	path    string
+	mu      sync.Mutex
+	list    []Approval
+	modTime time.Time
 }
 
 func NewApprovalStore(path string) (*ApprovalStore, error) {
@@ -38,47 +138,272 @@ func NewApprovalStore(path string) (*ApprovalStore, error) {
 		return nil, fmt.Errorf("creating approvals dir: %w", err)
 	}
 
-	s := &ApprovalStore{path: path, m: map[string]approvalRecord{}}
+	s := &ApprovalStore{path: path}
 	_ = s.load()
 	return s, nil
 }
 
+// load reads the file if it changed since it was last read.
 func (s *ApprovalStore) load() error {
+	info, err := os.Stat(s.path)
+	if os.IsNotExist(err) {
+		s.list, s.modTime = nil, time.Time{}
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if info.ModTime().Equal(s.modTime) {
+		return nil
+	}
 	b, err := os.ReadFile(s.path)
 	if err != nil {
-		if os.IsNotExist(err) {
-			return nil
-		}
 		return err
 	}
+	s.modTime = info.ModTime()
+	s.list = nil
 	if len(b) == 0 {
 		return nil
 	}
-	return json.Unmarshal(b, &s.m)
+
+	var doc approvalFile
+	if err := json.Unmarshal(b, &doc); err == nil && doc.Version > 0 {
+		s.list = doc.Approvals
+		return nil
+	}
+	var legacy map[string]legacyApproval
+	if err := json.Unmarshal(b, &legacy); err != nil {
+		return err
+	}
+	for key, rec := range legacy {
+		tool, _, _ := strings.Cut(key, ":")
+		a := Approval{
+			Tool:      tool,
+			Key:       key,
+			Summary:   strings.ReplaceAll(strings.TrimPrefix(key, tool+":"), "\x00", " "),
+			Decision:  rec.Decision,
+			Scope:     ScopeGlobal,
+			CreatedAt: rec.UpdatedAt,
+			UpdatedAt: rec.UpdatedAt,
+			Count:     rec.Count,
+		}
+		a.ID = approvalID(a)
+		s.list = append(s.list, a)
+	}
+	sortApprovals(s.list)
+	return nil
 }
 
+// save writes the approvals to a temporary file that replaces the store, so that a
+// crash cannot leave it half written. Only the user can read it.
 func (s *ApprovalStore) save() error {
-	b, err := json.MarshalIndent(s.m, "", "  ")
+	b, err := json.MarshalIndent(approvalFile{Version: 2, Approvals: s.list}, "", "  ")
 	if err != nil {
 		return err
 	}
-	return os.WriteFile(s.path, b, 0644)
+	f, err := os.CreateTemp(filepath.Dir(s.path), ".approvals-*.json")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if err := f.Chmod(0600); err != nil {
+		f.Close()
+		return err
+	}
+	if _, err := f.Write(b); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(f.Name(), s.path); err != nil {
+		return err
+	}
+	if info, err := os.Stat(s.path); err == nil {
+		s.modTime = info.ModTime()
+	}
+	return nil
 }
 
-func (s *ApprovalStore) Get(key string) (approvalRecord, bool) {
+func sortApprovals(list []Approval) {
+	sort.SliceStable(list, func(i, j int) bool { return list[i].CreatedAt.Before(list[j].CreatedAt) })
+}
+
+// Match returns the approval that decides c. A matching deny wins over any allow.
+func (s *ApprovalStore) Match(c ApprovalCall) (Approval, bool) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	rec, ok := s.m[key]
-	return rec, ok
+	_ = s.load()
+
+	now := time.Now()
+	var found *Approval
+	for i := range s.list {
+		a := &s.list[i]
+		if !a.matches(c, now) {
+			continue
+		}
+		if found == nil || a.Decision == ApprovalDeny {
+			found = a
+		}
+		if a.Decision == ApprovalDeny {
+			break
+		}
+	}
+	if found == nil {
+		return Approval{}, false
+	}
+	return *found, true
+}
+
+// Add persists an approval. One for the same calls in the same place replaces it and
+// keeps counting.
+func (s *ApprovalStore) Add(a Approval) (Approval, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_ = s.load()
+
+	if a.Scope == "" {
+		a.Scope = ScopeGlobal
+	}
+	now := time.Now()
+	a.ID = approvalID(a)
+	a.CreatedAt, a.UpdatedAt, a.Count = now, now, 1
+	list := append([]Approval(nil), s.list...)
+	replaced := false
+	for i, old := range list {
+		if old.ID == a.ID {
+			a.CreatedAt, a.Count = old.CreatedAt, old.Count+1
+			list[i] = a
+			replaced = true
+			break
+		}
+	}
+	if !replaced {
+		list = append(list, a)
+	}
+
+	previous := s.list
+	s.list = list
+	if err := s.save(); err != nil {
+		// An approval that was not saved must not apply either.
+		s.list = previous
+		return a, err
+	}
+	return a, nil
+}
+
+// List returns the approvals, oldest first.
+func (s *ApprovalStore) List() []Approval {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_ = s.load()
+	return append([]Approval(nil), s.list...)
 }
 
-func (s *ApprovalStore) Set(key string, decision approvalDecision) error {
+// Revoke removes the approvals whose IDs start with the given prefixes and returns
+// them. A prefix that matches no approval or several is an error.
+func (s *ApprovalStore) Revoke(ids ...string) ([]Approval, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	rec := s.m[key]
-	rec.Decision = decision
-	rec.UpdatedAt = time.Now()
-	rec.Count++
-	s.m[key] = rec
-	return s.save()
+	_ = s.load()
+
+	drop := map[string]bool{}
+	for _, id := range ids {
+		var found []string
+		for _, a := range s.list {
+			if id != "" && strings.HasPrefix(a.ID, id) {
+				found = append(found, a.ID)
+			}
+		}
+		switch len(found) {
+		case 0:
+			return nil, fmt.Errorf("no approval %q", id)
+		case 1:
+			drop[found[0]] = true
+		default:
+			return nil, fmt.Errorf("approval ID %q is ambiguous: %s", id, strings.Join(found, ", "))
+		}
+	}
+	return s.remove(func(a Approval) bool { return drop[a.ID] })
+}
+
+// Prune removes approvals that expired and project approvals whose root no longer
+// exists, and returns them.
+func (s *ApprovalStore) Prune() ([]Approval, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_ = s.load()
+
+	now := time.Now()
+	return s.remove(func(a Approval) bool {
+		if a.Expired(now) {
+			return true
+		}
+		if a.Scope == ScopeProject {
+			if _, err := os.Stat(a.Root); os.IsNotExist(err) {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// remove drops the approvals drop selects and saves the rest.
+func (s *ApprovalStore) remove(drop func(Approval) bool) ([]Approval, error) {
+	var kept, removed []Approval
+	for _, a := range s.list {
+		if drop(a) {
+			removed = append(removed, a)
+		} else {
+			kept = append(kept, a)
+		}
+	}
+	if len(removed) == 0 {
+		return nil, nil
+	}
+	s.list = kept
+	return removed, s.save()
+}
+
+// ProjectRoot is the top of the git repository dir is in, or dir itself outside one.
+func ProjectRoot(dir string) string {
+	for d := dir; d != ""; d = parentDir(d) {
+		if _, err := os.Stat(filepath.Join(d, ".git")); err == nil {
+			return d
+		}
+	}
+	return dir
+}
+
+// approvalSubject is the text approval patterns match for a call: the command line of
+// a shell command, the arguments of anything else.
+func approvalSubject(req ApprovalRequest, args json.RawMessage) string {
+	if req.ToolName == "sys_shell_exec" {
+		return req.ArgsPreview
+	}
+	return stableJSON(args)
+}
+
+// approvalCommands lists the simple commands of a shell call for ApprovalCall.Commands.
+func approvalCommands(req ApprovalRequest, args json.RawMessage) []string {
+	if req.ToolName != "sys_shell_exec" {
+		return nil
+	}
+	var input struct {
+		Command string   `json:"command"`
+		Args    []string `json:"args"`
+	}
+	if err := json.Unmarshal(args, &input); err != nil {
+		return nil
+	}
+	var cmds []string
+	for _, c := range analyzeCommand(input.Command, input.Args, nil).commands {
+		cmds = append(cmds, strings.Join(append([]string{c.text}, c.redirs...), " "))
+	}
+	return cmds
 }
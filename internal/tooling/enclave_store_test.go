@@ -0,0 +1,251 @@
+package tooling
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestStore(t *testing.T) (*ApprovalStore, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "enclave", "approvals.json")
+	s, err := NewApprovalStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s, path
+}
+
+// shellCall describes a sys_shell_exec call of command for matching.
+func shellCall(command, dir, session string) ApprovalCall {
+	args, _ := json.Marshal(map[string]string{"command": command})
+	req := ApprovalRequest{ToolName: "sys_shell_exec", ArgsPreview: command}
+	return ApprovalCall{
+		Tool:     "sys_shell_exec",
+		Key:      "sys_shell_exec:" + normalizeCmdKey(command, nil),
+		Subject:  approvalSubject(req, args),
+		Commands: approvalCommands(req, args),
+		Dir:      dir,
+		Session:  session,
+	}
+}
+
+func TestApproval_Matches(t *testing.T) {
+	now := time.Now()
+	project := t.TempDir()
+	tests := []struct {
+		name     string
+		approval Approval
+		call     ApprovalCall
+		want     bool
+	}{
+		{"exact key", Approval{Tool: "sys_shell_exec", Key: shellCall("go test ./...", "", "").Key}, shellCall("go test ./...", "", ""), true},
+		{"exact key, other command", Approval{Tool: "sys_shell_exec", Key: shellCall("go test ./...", "", "").Key}, shellCall("go test ./a", "", ""), false},
+		{"glob", Approval{Tool: "sys_shell_exec", Pattern: "go test *"}, shellCall("go test ./a", "", ""), true},
+		{"glob, other command", Approval{Tool: "sys_shell_exec", Pattern: "go test *"}, shellCall("go build ./a", "", ""), false},
+		{"glob allow must cover every command", Approval{Tool: "sys_shell_exec", Pattern: "go test *"}, shellCall("go test ./... && rm -rf ~", "", ""), false},
+		{"glob deny catches any command", Approval{Tool: "sys_shell_exec", Pattern: "rm *", Decision: ApprovalDeny}, shellCall("go test ./... && rm -rf ~", "", ""), true},
+		{"glob on a substitution", Approval{Tool: "sys_shell_exec", Pattern: "rm *", Decision: ApprovalDeny}, shellCall("echo $(rm -rf ~)", "", ""), true},
+		{"glob on arguments", Approval{Tool: "sys_read_file", Pattern: `*"path":"docs/*`}, ApprovalCall{Tool: "sys_read_file", Subject: `{"path":"docs/a.md"}`}, true},
+		{"other tool", Approval{Tool: "sys_write_file", Pattern: "*"}, shellCall("ls", "", ""), false},
+		{"not expired", Approval{Tool: "sys_shell_exec", Pattern: "*", ExpiresAt: now.Add(time.Hour)}, shellCall("ls", "", ""), true},
+		{"expired", Approval{Tool: "sys_shell_exec", Pattern: "*", ExpiresAt: now.Add(-time.Second)}, shellCall("ls", "", ""), false},
+		{"project, inside", Approval{Tool: "sys_shell_exec", Pattern: "*", Scope: ScopeProject, Root: project}, shellCall("ls", filepath.Join(project, "sub"), ""), true},
+		{"project, elsewhere", Approval{Tool: "sys_shell_exec", Pattern: "*", Scope: ScopeProject, Root: project}, shellCall("ls", project+"-other", ""), false},
+		{"session, same", Approval{Tool: "sys_shell_exec", Pattern: "*", Scope: ScopeSession, Session: "s1"}, shellCall("ls", "", "s1"), true},
+		{"session, other", Approval{Tool: "sys_shell_exec", Pattern: "*", Scope: ScopeSession, Session: "s1"}, shellCall("ls", "", "s2"), false},
+		{"global, anywhere", Approval{Tool: "sys_shell_exec", Pattern: "*", Scope: ScopeGlobal}, shellCall("ls", "/tmp", "s9"), true},
+	}
+	for _, tt := range tests {
+		if tt.approval.Decision == "" {
+			tt.approval.Decision = ApprovalAllow
+		}
+		if got := tt.approval.matches(tt.call, now); got != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestApprovalStore_Match(t *testing.T) {
+	s, _ := newTestStore(t)
+	project := t.TempDir()
+	add := func(a Approval) Approval {
+		t.Helper()
+		a, err := s.Add(a)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return a
+	}
+	add(Approval{Tool: "sys_shell_exec", Pattern: "git *", Decision: ApprovalAllow, Scope: ScopeProject, Root: project})
+	add(Approval{Tool: "sys_shell_exec", Pattern: "git push *", Decision: ApprovalDeny})
+	add(Approval{Tool: "sys_shell_exec", Pattern: "make *", Decision: ApprovalAllow, ExpiresAt: time.Now().Add(-time.Minute)})
+
+	if a, ok := s.Match(shellCall("git status", project, "")); !ok || a.Decision != ApprovalAllow {
+		t.Errorf("expected the project approval to allow, got %+v, %v", a, ok)
+	}
+	if a, ok := s.Match(shellCall("git push --force origin", project, "")); !ok || a.Decision != ApprovalDeny {
+		t.Errorf("expected a deny to win over an allow, got %+v, %v", a, ok)
+	}
+	if _, ok := s.Match(shellCall("git status", t.TempDir(), "")); ok {
+		t.Error("a project approval must not apply in another directory")
+	}
+	if _, ok := s.Match(shellCall("make test", project, "")); ok {
+		t.Error("an expired approval must be ignored")
+	}
+}
+
+func TestApprovalStore_SurvivesReload(t *testing.T) {
+	s, path := newTestStore(t)
+	first, err := s.Add(Approval{Tool: "sys_shell_exec", Pattern: "go test *", Summary: "go test", Decision: ApprovalAllow, Scope: ScopeSession, Session: "s1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, _ := s.Add(Approval{Tool: "sys_shell_exec", Pattern: "go test *", Decision: ApprovalAllow, Scope: ScopeSession, Session: "s1"})
+	if again.ID != first.ID || again.Count != 2 || !again.CreatedAt.Equal(first.CreatedAt) {
+		t.Errorf("approving the same calls again should update the approval, got %+v", again)
+	}
+	expires := time.Now().Add(time.Hour).Truncate(time.Second)
+	if _, err := s.Add(Approval{Tool: "sys_shell_exec", Key: "sys_shell_exec:ls", Decision: ApprovalDeny, ExpiresAt: expires}); err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil || info.Mode().Perm() != 0600 {
+		t.Fatalf("expected the store to be written with 0600, got %v, %v", info.Mode().Perm(), err)
+	}
+	if leftovers, _ := filepath.Glob(filepath.Join(filepath.Dir(path), ".approvals-*")); len(leftovers) != 0 {
+		t.Errorf("temporary files were left behind: %v", leftovers)
+	}
+
+	reloaded, err := NewApprovalStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	list := reloaded.List()
+	if len(list) != 2 {
+		t.Fatalf("expected two approvals after reload, got %+v", list)
+	}
+	if a := list[0]; a.ID != first.ID || a.Scope != ScopeSession || a.Session != "s1" || a.Count != 2 || a.Summary != "" {
+		t.Errorf("unexpected first approval: %+v", a)
+	}
+	if a := list[1]; a.Scope != ScopeGlobal || !a.ExpiresAt.Equal(expires) || a.Decision != ApprovalDeny {
+		t.Errorf("unexpected second approval: %+v", a)
+	}
+	if _, ok := reloaded.Match(shellCall("go test ./...", "", "s1")); !ok {
+		t.Error("expected the reloaded approval to match")
+	}
+
+	// A store notices changes made by another process.
+	if _, err := reloaded.Revoke(first.ID[:4]); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := s.Match(shellCall("go test ./...", "", "s1")); ok {
+		t.Error("expected the revoked approval to be gone from the other store")
+	}
+}
+
+func TestApprovalStore_RevokeAndPrune(t *testing.T) {
+	s, _ := newTestStore(t)
+	gone := filepath.Join(t.TempDir(), "removed-project")
+	var ids []string
+	for _, a := range []Approval{
+		{Tool: "sys_shell_exec", Pattern: "a *", Decision: ApprovalAllow},
+		{Tool: "sys_shell_exec", Pattern: "b *", Decision: ApprovalAllow, ExpiresAt: time.Now().Add(-time.Hour)},
+		{Tool: "sys_shell_exec", Pattern: "c *", Decision: ApprovalAllow, Scope: ScopeProject, Root: gone},
+		{Tool: "sys_shell_exec", Pattern: "d *", Decision: ApprovalAllow, ExpiresAt: time.Now().Add(time.Hour)},
+	} {
+		a, err := s.Add(a)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, a.ID)
+	}
+
+	if _, err := s.Revoke("zzzz"); err == nil || !strings.Contains(err.Error(), "no approval") {
+		t.Errorf("expected an unknown ID to be reported, got %v", err)
+	}
+	if _, err := s.Revoke(""); err == nil {
+		t.Error("expected an empty ID to match nothing")
+	}
+
+	removed, err := s.Prune()
+	if err != nil || len(removed) != 2 || removed[0].ID != ids[1] || removed[1].ID != ids[2] {
+		t.Fatalf("expected the expired and the orphaned project approval to be pruned, got %+v, %v", removed, err)
+	}
+	if removed, _ := s.Prune(); len(removed) != 0 {
+		t.Errorf("a second prune should remove nothing, got %+v", removed)
+	}
+
+	removed, err = s.Revoke(ids[0], ids[3][:6])
+	if err != nil || len(removed) != 2 || len(s.List()) != 0 {
+		t.Errorf("expected both approvals to be revoked, got %+v, %v, left %+v", removed, err, s.List())
+	}
+}
+
+func TestApprovalStore_LegacyFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "approvals.json")
+	writeTestFile(t, path, `{"sys_shell_exec:make\u0000test":{"decision":"allow","updated_at":"2024-05-01T10:00:00Z","count":3}}`, 0600)
+	s, err := NewApprovalStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	list := s.List()
+	if len(list) != 1 {
+		t.Fatalf("expected the legacy approval to be read, got %+v", list)
+	}
+	if a := list[0]; a.Tool != "sys_shell_exec" || a.Scope != ScopeGlobal || a.Summary != "make test" || a.Count != 3 || a.ID == "" {
+		t.Errorf("unexpected legacy approval: %+v", a)
+	}
+	if _, ok := s.Match(ApprovalCall{Tool: "sys_shell_exec", Key: "sys_shell_exec:" + normalizeCmdKey("make", []string{"test"})}); !ok {
+		t.Error("expected the legacy approval to match its exact call")
+	}
+	if _, ok := s.Match(shellCall("make test", "", "")); ok {
+		t.Error("a legacy key must not match a different call")
+	}
+}
+
+func TestEnclave_ApprovalNotSaved(t *testing.T) {
+	appData := t.TempDir()
+	e, err := NewEnclave(appData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A directory in place of the store makes every save fail.
+	if err := os.Mkdir(ApprovalsPath(appData), 0700); err != nil {
+		t.Fatal(err)
+	}
+	var warnings []string
+	previous := StatusReporter
+	StatusReporter = func(icon, step, msg string) { warnings = append(warnings, msg) }
+	t.Cleanup(func() { StatusReporter = previous })
+
+	shell := &testTool{name: "sys_shell_exec", perms: []Permission{PermExecute}}
+	args := json.RawMessage(`{"command": "make"}`)
+	ask := func() *InterventionError {
+		t.Helper()
+		_, err := e.Interceptor(shell, args)
+		var iv *InterventionError
+		if !errors.As(err, &iv) {
+			t.Fatalf("expected to be asked about the call, got %v", err)
+		}
+		return iv
+	}
+
+	if _, err := ask().Resume(context.Background(), "Approve Forever"); err != nil || len(shell.ran) != 1 {
+		t.Fatalf("the approved call should still run, got %v, ran %v", err, shell.ran)
+	}
+	if len(warnings) != 1 || !strings.Contains(warnings[0], "Approval not saved") {
+		t.Errorf("expected the user to be warned, got %q", warnings)
+	}
+	entries, err := e.Audit().Entries()
+	if err != nil || len(entries) != 1 || entries[0].Decision != "Approved (Forever, not saved)" {
+		t.Errorf("expected the audit log to record the unsaved approval, got %+v, %v", entries, err)
+	}
+	ask() // nothing was saved, so the next call is asked about again
+}
@@ -4,7 +4,6 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"os"
 
 	"github.com/nathfavour/vibeauracle/sys"
 )
@@ -61,35 +60,6 @@ func (t *ListDirTool) Execute(ctx context.Context, args json.RawMessage) (*ToolR
 	}, nil
 }
 
-// GrepTool searches for patterns inside files.
-type GrepTool struct{}
-
-func (t *GrepTool) Metadata() ToolMetadata {
-	return ToolMetadata{
-		Name:        "fs_grep",
-		Description: "Search for regex patterns in files within a directory.",
-		Source:      "system",
-		Category:    CategoryAnalysis,
-		Roles:       []AgentRole{RoleResearcher, RoleEngineer},
-		Complexity:  5,
-		Permissions: []Permission{PermRead},
-		Parameters: json.RawMessage(`{
-			"type": "object",
-			"properties": {
-				"path": {"type": "string", "description": "Directory to search"},
-				"pattern": {"type": "string", "description": "Regex pattern"},
-				"recursive": {"type": "boolean", "description": "Search recursively"}
-			},
-			"required": ["path", "pattern"]
-		}`),
-	}
-}
-
-func (t *GrepTool) Execute(ctx context.Context, args json.RawMessage) (*ToolResult, error) {
-	// Implementation placeholder for granular capability
-	return &ToolResult{Status: "error", Content: "Not implemented yet"}, nil
-}
-
 // FileStatsTool provides detailed inode information.
 type FileStatsTool struct {
 	fs sys.FS
@@ -126,7 +96,7 @@ func (t *FileStatsTool) Execute(ctx context.Context, args json.RawMessage) (*Too
 		return nil, err
 	}
 
-	info, err := os.Stat(input.Path)
+	info, err := t.fs.Stat(input.Path)
 	if err != nil {
 		return &ToolResult{Status: "error", Error: err}, err
 	}
@@ -0,0 +1,130 @@
+package tooling
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// ignoreRule is one pattern of a .gitignore file.
+type ignoreRule struct {
+	re      *regexp.Regexp
+	negate  bool
+	dirOnly bool
+}
+
+// gitignore holds the .gitignore rules that apply inside a directory: those of its own
+// file, chained to the rules of its parent directories. A nil *gitignore ignores nothing.
+type gitignore struct {
+	parent *gitignore
+	dir    string // slash-separated absolute directory the rules are relative to
+	rules  []ignoreRule
+}
+
+// loadGitignore returns the rules that apply to the contents of the ancestors of root,
+// from the top of its git repository down to root's parent. Rules of root itself and
+// below are added by child while walking.
+func loadGitignore(root string) *gitignore {
+	isRepo := func(dir string) bool {
+		_, err := os.Stat(filepath.Join(dir, ".git"))
+		return err == nil
+	}
+	if isRepo(root) {
+		return nil // the walk starts at the top of the repository
+	}
+
+	var dirs []string
+	for dir := filepath.Dir(root); ; dir = filepath.Dir(dir) {
+		dirs = append(dirs, dir)
+		if isRepo(dir) {
+			break
+		}
+		if filepath.Dir(dir) == dir {
+			return nil // not inside a repository: only .gitignore files below root apply
+		}
+	}
+
+	var g *gitignore
+	for i := len(dirs) - 1; i >= 0; i-- {
+		g = g.child(dirs[i])
+	}
+	return g
+}
+
+// child returns the rules for directory dir, whose parent's rules are g.
+func (g *gitignore) child(dir string) *gitignore {
+	data, err := os.ReadFile(filepath.Join(dir, ".gitignore"))
+	if err != nil {
+		return g
+	}
+	rules := parseGitignore(data)
+	if len(rules) == 0 {
+		return g
+	}
+	return &gitignore{parent: g, dir: filepath.ToSlash(dir), rules: rules}
+}
+
+// ignored reports whether path is ignored. As in git, the last matching pattern of the
+// deepest .gitignore decides, and a "!" pattern re-includes.
+func (g *gitignore) ignored(path string, isDir bool) bool {
+	path = filepath.ToSlash(path)
+	for s := g; s != nil; s = s.parent {
+		rel, ok := strings.CutPrefix(path, s.dir+"/")
+		if !ok {
+			continue
+		}
+		for i := len(s.rules) - 1; i >= 0; i-- {
+			r := s.rules[i]
+			if r.dirOnly && !isDir {
+				continue
+			}
+			if r.re.MatchString(rel) {
+				return !r.negate
+			}
+		}
+	}
+	return false
+}
+
+// parseGitignore compiles the patterns of a .gitignore file.
+func parseGitignore(data []byte) []ignoreRule {
+	var rules []ignoreRule
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), " \t\r")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		var r ignoreRule
+		if strings.HasPrefix(line, "!") {
+			r.negate, line = true, line[1:]
+		} else if strings.HasPrefix(line, `\`) {
+			line = line[1:] // \# and \! escape a leading # or !
+		}
+		if strings.HasSuffix(line, "/") {
+			r.dirOnly, line = true, strings.TrimRight(line, "/")
+		}
+		if line == "" {
+			continue
+		}
+
+		// A pattern with a slash is relative to the .gitignore's directory; one without
+		// matches a name at any depth.
+		if strings.Contains(line, "/") {
+			line = strings.TrimPrefix(line, "/")
+		} else {
+			line = "**/" + line
+		}
+		re, err := regexp.Compile(globRegexp(line, true))
+		if err != nil {
+			continue
+		}
+		r.re = re
+		rules = append(rules, r)
+	}
+	return rules
+}
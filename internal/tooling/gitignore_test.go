@@ -0,0 +1,99 @@
+package tooling
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestGitignore_Ignored(t *testing.T) {
+	g := &gitignore{dir: "/repo", rules: parseGitignore([]byte(`# comment
+*.o
+!keep.o
+logs/
+/build
+doc/*.txt
+**/gen/*.go
+\#literal
+trailing
+`))}
+
+	tests := []struct {
+		path  string
+		isDir bool
+		want  bool
+	}{
+		{"/repo/main.o", false, true},
+		{"/repo/deep/dir/main.o", false, true},
+		{"/repo/keep.o", false, false}, // negated after *.o
+		{"/repo/sub/keep.o", false, false},
+		{"/repo/logs", true, true},
+		{"/repo/logs", false, false}, // directory-only pattern
+		{"/repo/src/logs", true, true},
+		{"/repo/build", true, true},
+		{"/repo/build", false, true},
+		{"/repo/src/build", true, false}, // anchored to the .gitignore's directory
+		{"/repo/doc/a.txt", false, true},
+		{"/repo/doc/sub/a.txt", false, false}, // * does not cross a slash
+		{"/repo/src/doc/a.txt", false, false},
+		{"/repo/gen/x.go", false, true},
+		{"/repo/a/b/gen/x.go", false, true},
+		{"/repo/#literal", false, true},
+		{"/repo/trailing", false, true},
+		{"/repo/main.go", false, false},
+		{"/other/main.o", false, false}, // outside the .gitignore's directory
+	}
+	for _, tt := range tests {
+		if got := g.ignored(tt.path, tt.isDir); got != tt.want {
+			t.Errorf("ignored(%q, dir=%v) = %v, want %v", tt.path, tt.isDir, got, tt.want)
+		}
+	}
+
+	var none *gitignore
+	if none.ignored("/repo/main.o", false) {
+		t.Error("a nil gitignore must ignore nothing")
+	}
+}
+
+func TestGitignore_Nested(t *testing.T) {
+	root := t.TempDir()
+	if err := os.Mkdir(filepath.Join(root, ".git"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeTestFile(t, filepath.Join(root, ".gitignore"), "*.tmp\nsecret.txt\n", 0644)
+	writeTestFile(t, filepath.Join(root, "sub", ".gitignore"), "!*.tmp\nlocal/\n", 0644)
+	sub := filepath.Join(root, "sub")
+
+	top := loadGitignore(root)
+	if top != nil {
+		t.Fatal("the walk starts at the top of the repository, so no rules apply above it")
+	}
+	top = top.child(root)
+	nested := top.child(sub)
+
+	tests := []struct {
+		g     *gitignore
+		path  string
+		isDir bool
+		want  bool
+	}{
+		{top, filepath.Join(root, "a.tmp"), false, true},
+		{nested, filepath.Join(sub, "a.tmp"), false, false}, // the deeper file re-includes
+		{nested, filepath.Join(sub, "secret.txt"), false, true},
+		{nested, filepath.Join(sub, "local"), true, true},
+		{top, filepath.Join(root, "local"), true, false},
+	}
+	for _, tt := range tests {
+		if got := tt.g.ignored(tt.path, tt.isDir); got != tt.want {
+			t.Errorf("ignored(%q) = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+
+	// Starting below the top of the repository picks up the rules above.
+	if g := loadGitignore(sub); !g.ignored(filepath.Join(sub, "secret.txt"), false) {
+		t.Error("expected the repository's .gitignore to apply to a subdirectory")
+	}
+	if g := loadGitignore(t.TempDir()); g != nil {
+		t.Error("outside a repository only .gitignore files below the root apply")
+	}
+}
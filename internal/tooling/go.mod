@@ -2,7 +2,13 @@ module github.com/nathfavour/vibeauracle/tooling
 
 go 1.24.0
 
-require github.com/nathfavour/vibeauracle/sys v0.0.0
+require (
+	github.com/nathfavour/vibeauracle/sys v0.0.0
+	github.com/nathfavour/vibeauracle/watcher v0.0.0
+	golang.org/x/sys v0.39.0
+	gopkg.in/yaml.v3 v3.0.1
+	mvdan.cc/sh/v3 v3.12.0
+)
 
 require (
 	github.com/fsnotify/fsnotify v1.9.0 // indirect
@@ -24,8 +30,9 @@ require (
 	github.com/tklauser/numcpus v0.10.0 // indirect
 	github.com/yusufpapurcu/wmi v1.2.4 // indirect
 	go.yaml.in/yaml/v3 v3.0.4 // indirect
-	golang.org/x/sys v0.39.0 // indirect
 	golang.org/x/text v0.32.0 // indirect
 )
 
 replace github.com/nathfavour/vibeauracle/sys => ../sys
+
+replace github.com/nathfavour/vibeauracle/watcher => ../watcher
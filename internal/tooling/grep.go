@@ -0,0 +1,453 @@
+package tooling
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"unicode/utf8"
+
+	"github.com/nathfavour/vibeauracle/sys"
+	"github.com/nathfavour/vibeauracle/watcher"
+)
+
+const (
+	defaultGrepResults = 200
+	maxGrepResults     = 2000
+	maxGrepContext     = 10
+	maxGrepFileSize    = 8 << 20 // larger files are skipped, they are rarely source
+	binarySniffLen     = 8000    // bytes checked for NUL, as git does
+	maxGrepLineLen     = 300     // runes of a line shown in results
+)
+
+// GrepMatch is one matching line found by fs_grep. Line and Column are 1-based; Column
+// counts runes.
+type GrepMatch struct {
+	File   string   `json:"file"`
+	Line   int      `json:"line"`
+	Column int      `json:"column"`
+	Match  string   `json:"match"`
+	Text   string   `json:"text"`
+	Before []string `json:"before,omitempty"`
+	After  []string `json:"after,omitempty"`
+}
+
+// GrepTool searches for patterns inside files.
+type GrepTool struct {
+	fs    sys.FS
+	guard *SecurityGuard // when set, blocked files are left out of the search
+}
+
+func NewGrepTool(f sys.FS, guard *SecurityGuard) *GrepTool {
+	return &GrepTool{fs: f, guard: guard}
+}
+
+func (t *GrepTool) Metadata() ToolMetadata {
+	return ToolMetadata{
+		Name:        "fs_grep",
+		Description: "Search file contents for a regex (RE2 syntax) or literal string. Skips binary files, .gitignore'd paths and build/vendor directories. Returns file:line:column matches with optional context lines.",
+		Source:      "system",
+		Category:    CategoryAnalysis,
+		Roles:       []AgentRole{RoleResearcher, RoleEngineer},
+		Complexity:  5,
+		Permissions: []Permission{PermRead},
+		Parameters: json.RawMessage(`{
+			"type": "object",
+			"properties": {
+				"path": {"type": "string", "description": "Directory or file to search (default: current directory)"},
+				"pattern": {"type": "string", "description": "Regex pattern, or the exact text when literal is set"},
+				"literal": {"type": "boolean", "description": "Treat pattern as plain text instead of a regex"},
+				"ignore_case": {"type": "boolean", "description": "Match case-insensitively"},
+				"include": {"type": "string", "description": "Only search files matching this glob, e.g. *.go or src/**/*.ts"},
+				"context": {"type": "integer", "description": "Lines of context to show before and after each match (max 10)"},
+				"max_results": {"type": "integer", "description": "Maximum number of matches to return (default 200, max 2000)"},
+				"recursive": {"type": "boolean", "description": "Search subdirectories (default true)"}
+			},
+			"required": ["pattern"]
+		}`),
+	}
+}
+
+func (t *GrepTool) Execute(ctx context.Context, args json.RawMessage) (*ToolResult, error) {
+	var input struct {
+		Path       string `json:"path"`
+		Pattern    string `json:"pattern"`
+		Literal    bool   `json:"literal"`
+		IgnoreCase bool   `json:"ignore_case"`
+		Include    string `json:"include"`
+		Context    int    `json:"context"`
+		MaxResults int    `json:"max_results"`
+		Recursive  *bool  `json:"recursive"`
+	}
+	if err := json.Unmarshal(args, &input); err != nil {
+		return nil, err
+	}
+	if input.Path == "" {
+		input.Path = "."
+	}
+
+	s, err := newGrepSearch(ctx, input.Pattern, input.Literal, input.IgnoreCase, input.Include)
+	if err != nil {
+		return &ToolResult{Status: "error", Error: err}, err
+	}
+	s.context = max(0, min(input.Context, maxGrepContext))
+	s.max = defaultGrepResults
+	if input.MaxResults > 0 {
+		s.max = min(input.MaxResults, maxGrepResults)
+	}
+	s.recursive = input.Recursive == nil || *input.Recursive
+
+	root, err := filepath.Abs(input.Path)
+	if err != nil {
+		return &ToolResult{Status: "error", Error: err}, err
+	}
+	info, err := t.fs.Stat(root)
+	if err != nil {
+		return &ToolResult{Status: "error", Error: err}, err
+	}
+	if t.guard != nil {
+		s.blocked = func(path string) bool { return t.guard.CheckPath(path) != nil }
+	}
+
+	ReportStatus("🔍", "exec", fmt.Sprintf("Searching %s for %q", input.Path, input.Pattern))
+
+	if info.IsDir() {
+		s.run(root)
+	} else {
+		s.searchFile(root) // an explicitly named file is searched whatever the ignore rules say
+	}
+	if err := ctx.Err(); err != nil {
+		return &ToolResult{Status: "error", Error: err}, err
+	}
+
+	matches, truncated := s.results()
+	for i := range matches {
+		// Report paths the way the caller named the search root.
+		if rel, err := filepath.Rel(root, matches[i].File); err == nil && info.IsDir() {
+			matches[i].File = filepath.Join(input.Path, rel)
+		} else {
+			matches[i].File = input.Path
+		}
+	}
+
+	ReportStatus("✅", "exec", fmt.Sprintf("Found %d matches in %d files", len(matches), countFiles(matches)))
+	return &ToolResult{
+		Status:  "success",
+		Content: formatGrep(matches, truncated, s.max, s.searched.Load(), s.skipped.Load()),
+		Data:    matches,
+	}, nil
+}
+
+// grepSearch is one fs_grep run. Directories are read by up to GOMAXPROCS walkers at a
+// time, which feed files to as many searchers.
+type grepSearch struct {
+	ctx       context.Context
+	re        *regexp.Regexp
+	prefilter func([]byte) bool // cheap whole-file test that rules out files without a match
+	include   *regexp.Regexp
+	includeBy func(path string) string
+	ignore    []string
+	blocked   func(path string) bool
+	context   int
+	max       int
+	recursive bool
+
+	walkers  sync.WaitGroup
+	dirSlots chan struct{}
+	files    chan string
+
+	mu       sync.Mutex
+	matches  []GrepMatch
+	found    atomic.Int64
+	searched atomic.Int64
+	skipped  atomic.Int64
+}
+
+func newGrepSearch(ctx context.Context, pattern string, literal, ignoreCase bool, include string) (*grepSearch, error) {
+	if pattern == "" {
+		return nil, fmt.Errorf("pattern is empty")
+	}
+
+	expr := pattern
+	if literal {
+		expr = regexp.QuoteMeta(pattern)
+	}
+	if ignoreCase {
+		expr = "(?i)" + expr
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid regex %q: %v (set literal to search for the text as written)", pattern, err)
+	}
+
+	s := &grepSearch{ctx: ctx, re: re, ignore: watcher.DefaultIgnorePatterns()}
+	switch {
+	case literal && !ignoreCase:
+		needle := []byte(pattern)
+		s.prefilter = func(data []byte) bool { return bytes.Contains(data, needle) }
+	case !strings.ContainsAny(pattern, `\$`):
+		// In multi-line mode ^ matches at line starts, so a file with a matching line
+		// always matches as a whole. $ would miss before \r\n, and \A, \z anchor
+		// differently, so patterns with either are matched line by line only.
+		whole := regexp.MustCompile("(?m)" + expr)
+		s.prefilter = whole.Match
+	}
+
+	if include != "" {
+		// Like .gitignore: a glob with a slash matches the path, one without the name.
+		glob := include
+		s.includeBy = filepath.Base
+		if strings.Contains(glob, "/") {
+			glob = strings.TrimPrefix(glob, "/")
+			s.includeBy = func(path string) string { return path }
+		}
+		s.include, err = regexp.Compile(globRegexp(glob, true))
+		if err != nil {
+			return nil, fmt.Errorf("invalid include glob %q: %v", include, err)
+		}
+	}
+	return s, nil
+}
+
+// run searches the directory tree at root.
+func (s *grepSearch) run(root string) {
+	workers := runtime.GOMAXPROCS(0)
+	s.dirSlots = make(chan struct{}, workers)
+	s.files = make(chan string, 256)
+
+	var searchers sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		searchers.Add(1)
+		go func() {
+			defer searchers.Done()
+			for path := range s.files {
+				if !s.done() {
+					s.searchFile(path)
+				}
+			}
+		}()
+	}
+
+	s.walkers.Add(1)
+	go s.walk(root, root, loadGitignore(root))
+	s.walkers.Wait()
+	close(s.files)
+	searchers.Wait()
+}
+
+// done reports whether the search should stop early. One match past max is collected,
+// so that results can tell whether the cap cut anything off.
+func (s *grepSearch) done() bool {
+	return s.found.Load() > int64(s.max) || s.ctx.Err() != nil
+}
+
+// walk lists dir, queueing its files for search and starting a walker for each
+// subdirectory. parent holds the .gitignore rules that apply above dir.
+func (s *grepSearch) walk(root, dir string, parent *gitignore) {
+	defer s.walkers.Done()
+	if s.done() {
+		return
+	}
+
+	s.dirSlots <- struct{}{}
+	entries, err := os.ReadDir(dir)
+	<-s.dirSlots
+	if err != nil {
+		s.skipped.Add(1)
+		return
+	}
+
+	rules := parent.child(dir)
+	for _, e := range entries {
+		if s.done() {
+			return
+		}
+		// Symlinks are not followed, which also keeps the walk out of cycles.
+		if e.Type()&fs.ModeSymlink != 0 || s.ignoredName(e.Name()) {
+			continue
+		}
+		path := filepath.Join(dir, e.Name())
+		if rules.ignored(path, e.IsDir()) {
+			continue
+		}
+
+		switch {
+		case e.IsDir():
+			if s.recursive {
+				s.walkers.Add(1)
+				go s.walk(root, path, rules)
+			}
+		case e.Type().IsRegular():
+			if s.blocked != nil && s.blocked(path) {
+				continue
+			}
+			if s.include != nil {
+				rel, _ := filepath.Rel(root, path)
+				if !s.include.MatchString(s.includeBy(filepath.ToSlash(rel))) {
+					continue
+				}
+			}
+			s.files <- path
+		}
+	}
+}
+
+// ignoredName reports whether a file or directory name matches the watcher's ignore patterns.
+func (s *grepSearch) ignoredName(name string) bool {
+	for _, pattern := range s.ignore {
+		if ok, _ := filepath.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// searchFile collects the matching lines of one file.
+func (s *grepSearch) searchFile(path string) {
+	info, err := os.Stat(path)
+	if err != nil || info.Size() > maxGrepFileSize {
+		s.skipped.Add(1)
+		return
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		s.skipped.Add(1)
+		return
+	}
+	if bytes.IndexByte(data[:min(len(data), binarySniffLen)], 0) >= 0 {
+		return // binary
+	}
+	s.searched.Add(1)
+	if s.prefilter != nil && !s.prefilter(data) {
+		return
+	}
+
+	lines := bytes.Split(data, []byte("\n"))
+	if len(lines) > 0 && len(lines[len(lines)-1]) == 0 {
+		lines = lines[:len(lines)-1]
+	}
+	var found []GrepMatch
+	for i, line := range lines {
+		line = bytes.TrimSuffix(line, []byte("\r"))
+		loc := s.re.FindIndex(line)
+		if loc == nil {
+			continue
+		}
+		m := GrepMatch{
+			File:   path,
+			Line:   i + 1,
+			Column: utf8.RuneCount(line[:loc[0]]) + 1,
+			Match:  clipLine(string(line[loc[0]:loc[1]])),
+			Text:   clipLine(string(line)),
+		}
+		for j := max(0, i-s.context); j < i; j++ {
+			m.Before = append(m.Before, clipLine(strings.TrimSuffix(string(lines[j]), "\r")))
+		}
+		for j := i + 1; j <= min(len(lines)-1, i+s.context); j++ {
+			m.After = append(m.After, clipLine(strings.TrimSuffix(string(lines[j]), "\r")))
+		}
+		found = append(found, m)
+		if s.found.Add(1) > int64(s.max) {
+			break
+		}
+	}
+
+	if len(found) > 0 {
+		s.mu.Lock()
+		s.matches = append(s.matches, found...)
+		s.mu.Unlock()
+	}
+}
+
+// results returns the matches in file and line order, capped at max. Searches run in
+// parallel, so once the cap is hit which files made it in is not deterministic.
+func (s *grepSearch) results() ([]GrepMatch, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sort.Slice(s.matches, func(i, j int) bool {
+		if s.matches[i].File != s.matches[j].File {
+			return s.matches[i].File < s.matches[j].File
+		}
+		return s.matches[i].Line < s.matches[j].Line
+	})
+	truncated := len(s.matches) > s.max
+	if truncated {
+		s.matches = s.matches[:s.max]
+	}
+	return s.matches, truncated
+}
+
+// clipLine shortens minified or generated lines so they do not flood the context.
+func clipLine(line string) string {
+	if utf8.RuneCountInString(line) <= maxGrepLineLen {
+		return line
+	}
+	return string([]rune(line)[:maxGrepLineLen]) + "…"
+}
+
+func countFiles(matches []GrepMatch) int {
+	n := 0
+	for i, m := range matches {
+		if i == 0 || matches[i-1].File != m.File {
+			n++
+		}
+	}
+	return n
+}
+
+// formatGrep renders matches grouped by file, as "line:col: text" with context lines as
+// "line- text". Overlapping context is printed once.
+func formatGrep(matches []GrepMatch, truncated bool, limit int, searched, skipped int64) string {
+	if len(matches) == 0 {
+		return fmt.Sprintf("No matches (searched %d files)", searched)
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("Found %d matches in %d files (searched %d files", len(matches), countFiles(matches), searched))
+	if skipped > 0 {
+		sb.WriteString(fmt.Sprintf(", %d unreadable or too large", skipped))
+	}
+	sb.WriteString(")")
+	if truncated {
+		sb.WriteString(fmt.Sprintf("; stopped at %d matches, narrow the pattern, path or include to see the rest", limit))
+	}
+	sb.WriteString("\n")
+
+	last := 0 // last line printed in the current file
+	for i, m := range matches {
+		if i == 0 || matches[i-1].File != m.File {
+			sb.WriteString("\n" + m.File + "\n")
+			last = 0
+		} else if m.Line-len(m.Before) > last+1 && (len(m.Before) > 0 || len(matches[i-1].After) > 0) {
+			sb.WriteString("  --\n")
+		}
+		for j, text := range m.Before {
+			if n := m.Line - len(m.Before) + j; n > last {
+				sb.WriteString(fmt.Sprintf("  %d- %s\n", n, text))
+				last = n
+			}
+		}
+		if m.Line > last {
+			sb.WriteString(fmt.Sprintf("  %d:%d: %s\n", m.Line, m.Column, m.Text))
+			last = m.Line
+		}
+		for j, text := range m.After {
+			n := m.Line + 1 + j
+			if i+1 < len(matches) && matches[i+1].File == m.File && n >= matches[i+1].Line-len(matches[i+1].Before) {
+				break // the next match prints these
+			}
+			sb.WriteString(fmt.Sprintf("  %d- %s\n", n, text))
+			last = n
+		}
+	}
+	return strings.TrimRight(sb.String(), "\n")
+}
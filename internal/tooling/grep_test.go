@@ -0,0 +1,125 @@
+package tooling
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/nathfavour/vibeauracle/sys"
+)
+
+// runGrep runs fs_grep with the given arguments and returns its matches.
+func runGrep(t *testing.T, guard *SecurityGuard, args map[string]interface{}) ([]GrepMatch, *ToolResult) {
+	t.Helper()
+	data, _ := json.Marshal(args)
+	res, err := NewGrepTool(sys.NewLocalFS(""), guard).Execute(context.Background(), data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	matches, _ := res.Data.([]GrepMatch)
+	return matches, res
+}
+
+func matchedFiles(root string, matches []GrepMatch) []string {
+	var files []string
+	for i, m := range matches {
+		if i == 0 || matches[i-1].File != m.File {
+			rel, _ := filepath.Rel(root, m.File)
+			files = append(files, filepath.ToSlash(rel))
+		}
+	}
+	return files
+}
+
+func TestGrepTool_Filters(t *testing.T) {
+	root := t.TempDir()
+	if err := os.Mkdir(filepath.Join(root, ".git"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeTestFile(t, filepath.Join(root, ".gitignore"), "*.gen.go\nout/\n", 0644)
+	writeTestFile(t, filepath.Join(root, "main.go"), "package main // needle\n", 0644)
+	writeTestFile(t, filepath.Join(root, "api.gen.go"), "needle\n", 0644)
+	writeTestFile(t, filepath.Join(root, "out", "a.txt"), "needle\n", 0644)
+	writeTestFile(t, filepath.Join(root, "node_modules", "x.js"), "needle\n", 0644)
+	writeTestFile(t, filepath.Join(root, "debug.log"), "needle\n", 0644)
+	writeTestFile(t, filepath.Join(root, "blob.bin"), "needle\x00\x01", 0644)
+	writeTestFile(t, filepath.Join(root, "pkg", ".gitignore"), "!*.gen.go\n", 0644)
+	writeTestFile(t, filepath.Join(root, "pkg", "kept.gen.go"), "needle\n", 0644)
+	writeTestFile(t, filepath.Join(root, "pkg", "deep", "c.md"), "NEEDLE\n", 0644)
+
+	tests := []struct {
+		name string
+		args map[string]interface{}
+		want string
+	}{
+		{"ignores and binaries", map[string]interface{}{"pattern": "needle"}, "main.go pkg/kept.gen.go"},
+		{"ignore case", map[string]interface{}{"pattern": "needle", "ignore_case": true}, "main.go pkg/deep/c.md pkg/kept.gen.go"},
+		{"include by name", map[string]interface{}{"pattern": "needle", "ignore_case": true, "include": "*.md"}, "pkg/deep/c.md"},
+		{"include by path", map[string]interface{}{"pattern": "needle", "include": "pkg/*.go"}, "pkg/kept.gen.go"},
+		{"not recursive", map[string]interface{}{"pattern": "needle", "recursive": false}, "main.go"},
+		{"regex", map[string]interface{}{"pattern": `^package \w+`}, "main.go"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.args["path"] = root
+			matches, _ := runGrep(t, nil, tt.args)
+			if got := strings.Join(matchedFiles(root, matches), " "); got != tt.want {
+				t.Errorf("matched %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	// A file named explicitly is searched whatever the ignore rules say.
+	if matches, _ := runGrep(t, nil, map[string]interface{}{"pattern": "needle", "path": filepath.Join(root, "api.gen.go")}); len(matches) != 1 {
+		t.Errorf("expected the named file to be searched, got %+v", matches)
+	}
+
+	writeTestFile(t, filepath.Join(root, "credentials.txt"), "needle\n", 0644)
+	if matches, _ := runGrep(t, NewSecurityGuard(), map[string]interface{}{"pattern": "needle", "path": root}); strings.Join(matchedFiles(root, matches), " ") != "main.go pkg/kept.gen.go" {
+		t.Errorf("blocked files must be left out, got %+v", matches)
+	}
+}
+
+func TestGrepTool_MatchesAndLimits(t *testing.T) {
+	root := t.TempDir()
+	var lines []string
+	for i := 1; i <= 30; i++ {
+		lines = append(lines, "line")
+	}
+	lines[4] = "héllo TODO: first"
+	lines[9] = "TODO second"
+	writeTestFile(t, filepath.Join(root, "a.txt"), strings.Join(lines, "\r\n")+"\r\n", 0644)
+	writeTestFile(t, filepath.Join(root, "b.txt"), strings.Repeat("TODO\n", 50), 0644)
+
+	matches, res := runGrep(t, nil, map[string]interface{}{"pattern": "TODO", "literal": true, "context": 1, "path": filepath.Join(root, "a.txt")})
+	if len(matches) != 2 {
+		t.Fatalf("expected two matches, got %+v", matches)
+	}
+	m := matches[0]
+	if m.Line != 5 || m.Column != 7 || m.Match != "TODO" || m.Text != "héllo TODO: first" {
+		t.Errorf("unexpected match: %+v", m)
+	}
+	if len(m.Before) != 1 || m.Before[0] != "line" || len(m.After) != 1 || m.After[0] != "line" {
+		t.Errorf("expected one line of context without \\r, got %q %q", m.Before, m.After)
+	}
+	if !strings.Contains(res.Content, "5:7: héllo TODO: first") || !strings.Contains(res.Content, "4- line") {
+		t.Errorf("unexpected output:\n%s", res.Content)
+	}
+
+	matches, res = runGrep(t, nil, map[string]interface{}{"pattern": "TODO", "max_results": 10, "path": root})
+	if len(matches) != 10 || !strings.Contains(res.Content, "stopped at 10 matches") {
+		t.Errorf("expected the results to be capped at 10, got %d:\n%s", len(matches), res.Content)
+	}
+	matches, res = runGrep(t, nil, map[string]interface{}{"pattern": "TODO", "max_results": 52, "path": root})
+	if len(matches) != 52 || strings.Contains(res.Content, "stopped at") {
+		t.Errorf("exactly max_results matches is not truncated, got %d:\n%s", len(matches), res.Content)
+	}
+
+	data, _ := json.Marshal(map[string]interface{}{"pattern": "(", "path": root})
+	if _, err := NewGrepTool(sys.NewLocalFS(""), nil).Execute(context.Background(), data); err == nil || !strings.Contains(err.Error(), "set literal") {
+		t.Errorf("expected an invalid regex to suggest literal, got %v", err)
+	}
+}
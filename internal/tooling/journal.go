@@ -0,0 +1,290 @@
+package tooling
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nathfavour/vibeauracle/sys"
+)
+
+// JournalEntry holds the contents a file had before the first change of a thread, and
+// the hash it had after the last one ("" once deleted).
+type JournalEntry struct {
+	Path      string      `json:"path"`
+	Existed   bool        `json:"existed"`
+	Before    []byte      `json:"before,omitempty"`
+	Mode      os.FileMode `json:"mode,omitempty"`
+	AfterHash string      `json:"after_hash"`
+}
+
+// JournalRecord lists the files changed by one thread (one agent turn) of a session.
+type JournalRecord struct {
+	Session string         `json:"session"`
+	Thread  string         `json:"thread"`
+	Prompt  string         `json:"prompt"`
+	Started time.Time      `json:"started"`
+	Entries []JournalEntry `json:"entries"`
+}
+
+// UndoResult reports an undo. Conflicts are files changed outside the agent since its
+// last write; they are left alone unless the undo is forced.
+type UndoResult struct {
+	Thread    string   `json:"thread"`
+	Restored  []string `json:"restored"`
+	Conflicts []string `json:"conflicts,omitempty"`
+}
+
+// ChangeJournal snapshots files before the agent changes them, so a turn can be undone.
+// Records live under <appDataDir>/journal/<session>/<thread>.json.
+type ChangeJournal struct {
+	dir string
+
+	mu      sync.Mutex
+	current *JournalRecord
+}
+
+func NewChangeJournal(appDataDir string) *ChangeJournal {
+	return &ChangeJournal{dir: filepath.Join(appDataDir, "journal")}
+}
+
+// Begin starts journaling changes for a thread. Changes made outside a thread are not journaled.
+func (j *ChangeJournal) Begin(session, thread, prompt string) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if rec, err := j.load(session, thread); err == nil {
+		j.current = rec
+		return
+	}
+	j.current = &JournalRecord{Session: session, Thread: thread, Prompt: prompt, Started: time.Now()}
+}
+
+// record runs mutate on path, snapshotting the file first if the thread has not touched it yet.
+func (j *ChangeJournal) record(path string, mutate func() error) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.current == nil {
+		return mutate()
+	}
+
+	idx := -1
+	for i, e := range j.current.Entries {
+		if e.Path == path {
+			idx = i
+			break
+		}
+	}
+	if idx < 0 {
+		entry := JournalEntry{Path: path}
+		if info, err := os.Stat(path); err == nil {
+			before, err := os.ReadFile(path)
+			if err != nil {
+				return fmt.Errorf("journal snapshot: %w", err)
+			}
+			entry.Existed, entry.Before, entry.Mode = true, before, info.Mode().Perm()
+		}
+		if err := mutate(); err != nil {
+			return err
+		}
+		j.current.Entries = append(j.current.Entries, entry)
+		idx = len(j.current.Entries) - 1
+	} else if err := mutate(); err != nil {
+		return err
+	}
+
+	j.current.Entries[idx].AfterHash = fileHash(path)
+	if err := j.save(j.current); err != nil {
+		ReportStatus("⚠️", "journal", fmt.Sprintf("Could not save undo snapshot: %v", err))
+	}
+	return nil
+}
+
+// Records returns the journaled threads of a session, newest first.
+func (j *ChangeJournal) Records(session string) ([]*JournalRecord, error) {
+	files, err := filepath.Glob(filepath.Join(j.dir, safeName(session), "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	var records []*JournalRecord
+	for _, f := range files {
+		data, err := os.ReadFile(f)
+		if err != nil {
+			continue
+		}
+		var rec JournalRecord
+		if json.Unmarshal(data, &rec) == nil && len(rec.Entries) > 0 {
+			records = append(records, &rec)
+		}
+	}
+	sort.Slice(records, func(a, b int) bool { return records[a].Started.After(records[b].Started) })
+	return records, nil
+}
+
+// Undo restores the files changed by a thread of the session: the newest one when
+// thread is empty, otherwise the one whose ID starts with thread. Fully undone threads
+// are dropped from the journal, so repeated undos walk back through earlier turns.
+func (j *ChangeJournal) Undo(session, thread string, force bool) (*UndoResult, error) {
+	records, err := j.Records(session)
+	if err != nil {
+		return nil, err
+	}
+	var rec *JournalRecord
+	for _, r := range records {
+		if thread == "" || strings.HasPrefix(r.Thread, thread) {
+			rec = r
+			break
+		}
+	}
+	if rec == nil {
+		if thread == "" {
+			return nil, fmt.Errorf("nothing to undo")
+		}
+		return nil, fmt.Errorf("no changes recorded for thread %q", thread)
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	res := &UndoResult{Thread: rec.Thread}
+	var kept []JournalEntry
+	for i := len(rec.Entries) - 1; i >= 0; i-- {
+		e := rec.Entries[i]
+		if fileHash(e.Path) != e.AfterHash && !force {
+			res.Conflicts = append(res.Conflicts, e.Path)
+			kept = append([]JournalEntry{e}, kept...)
+			continue
+		}
+		if err := restoreEntry(e); err != nil {
+			return res, fmt.Errorf("restoring %s: %w", e.Path, err)
+		}
+		res.Restored = append(res.Restored, e.Path)
+	}
+
+	// Files left alone stay in the record, so a forced undo can still restore them.
+	rec.Entries = kept
+	if len(kept) == 0 {
+		_ = os.Remove(j.path(rec.Session, rec.Thread))
+	} else if err := j.save(rec); err != nil {
+		return res, err
+	}
+	if j.current != nil && j.current.Session == rec.Session && j.current.Thread == rec.Thread {
+		j.current = nil
+	}
+	return res, nil
+}
+
+func restoreEntry(e JournalEntry) error {
+	if !e.Existed {
+		if err := os.Remove(e.Path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(e.Path), 0755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(e.Path, e.Before, e.Mode); err != nil {
+		return err
+	}
+	return os.Chmod(e.Path, e.Mode)
+}
+
+func (j *ChangeJournal) path(session, thread string) string {
+	return filepath.Join(j.dir, safeName(session), safeName(thread)+".json")
+}
+
+func (j *ChangeJournal) load(session, thread string) (*JournalRecord, error) {
+	data, err := os.ReadFile(j.path(session, thread))
+	if err != nil {
+		return nil, err
+	}
+	var rec JournalRecord
+	if err := json.Unmarshal(data, &rec); err != nil {
+		return nil, err
+	}
+	return &rec, nil
+}
+
+func (j *ChangeJournal) save(rec *JournalRecord) error {
+	file := j.path(rec.Session, rec.Thread)
+	if err := os.MkdirAll(filepath.Dir(file), 0700); err != nil {
+		return err
+	}
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(file, data, 0600)
+}
+
+// fileHash is the sha256 of a file's contents, or "" if it does not exist.
+func fileHash(path string) string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// safeName maps an ID to a portable file name.
+func safeName(id string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_', r == '.':
+			return r
+		}
+		return '_'
+	}, id)
+}
+
+// JournalFS records every write, edit and delete made through it in a ChangeJournal.
+type JournalFS struct {
+	sys.FS
+	journal *ChangeJournal
+}
+
+func NewJournalFS(f sys.FS, j *ChangeJournal) *JournalFS {
+	return &JournalFS{FS: f, journal: j}
+}
+
+func (f *JournalFS) abs(path string) string {
+	if r, ok := f.FS.(interface{ Abs(string) string }); ok {
+		return r.Abs(path)
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return path
+	}
+	return abs
+}
+
+func (f *JournalFS) WriteFile(path string, content []byte) error {
+	return f.journal.record(f.abs(path), func() error { return f.FS.WriteFile(path, content) })
+}
+
+func (f *JournalFS) DeleteFile(path string) error {
+	return f.journal.record(f.abs(path), func() error { return f.FS.DeleteFile(path) })
+}
+
+func (f *JournalFS) Edit(path string, oldStr, newStr string) error {
+	return f.journal.record(f.abs(path), func() error { return f.FS.Edit(path, oldStr, newStr) })
+}
+
+// Batch journals each operation on its own, so the operations done before a failure can be undone.
+func (f *JournalFS) Batch(ops []sys.BatchOp) error {
+	for _, op := range ops {
+		op := op
+		if err := f.journal.record(f.abs(op.Path), func() error { return f.FS.Batch([]sys.BatchOp{op}) }); err != nil {
+			return err
+		}
+	}
+	return nil
+}
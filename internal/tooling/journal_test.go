@@ -0,0 +1,194 @@
+package tooling
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/nathfavour/vibeauracle/sys"
+)
+
+// newTestJournal returns a journaling FS rooted in a temporary project directory.
+func newTestJournal(t *testing.T) (*JournalFS, *ChangeJournal, string) {
+	t.Helper()
+	root := t.TempDir()
+	j := NewChangeJournal(t.TempDir())
+	return NewJournalFS(sys.NewLocalFS(root), j), j, root
+}
+
+func writeTestFile(t *testing.T, path, content string, mode os.FileMode) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), mode); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func readTestFile(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestChangeJournal_UndoRestoresThread(t *testing.T) {
+	fs, j, root := newTestJournal(t)
+	a, b, c, d := filepath.Join(root, "a.txt"), filepath.Join(root, "new", "b.txt"), filepath.Join(root, "c.txt"), filepath.Join(root, "d.txt")
+	writeTestFile(t, a, "alpha\n", 0600)
+	writeTestFile(t, c, "gamma\n", 0644)
+
+	j.Begin("s1", "t1", "change things")
+	steps := []error{
+		fs.WriteFile("a.txt", []byte("first\n")),
+		fs.Edit("a.txt", "first", "second"),
+		fs.WriteFile("new/b.txt", []byte("beta\n")),
+		fs.DeleteFile("c.txt"),
+		fs.Batch([]sys.BatchOp{{Type: sys.OpWrite, Path: "d.txt", Content: []byte("delta\n")}}),
+	}
+	for i, err := range steps {
+		if err != nil {
+			t.Fatalf("step %d: %v", i, err)
+		}
+	}
+
+	records, err := j.Records("s1")
+	if err != nil || len(records) != 1 {
+		t.Fatalf("expected one record, got %v, %v", records, err)
+	}
+	if rec := records[0]; rec.Thread != "t1" || rec.Prompt != "change things" || len(rec.Entries) != 4 {
+		t.Fatalf("a file changed twice must be snapshotted once, got %+v", rec)
+	}
+
+	res, err := j.Undo("s1", "", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Thread != "t1" || len(res.Restored) != 4 || len(res.Conflicts) != 0 {
+		t.Errorf("unexpected undo result: %+v", res)
+	}
+	if got := readTestFile(t, a); got != "alpha\n" {
+		t.Errorf("a.txt = %q, want the original", got)
+	}
+	if info, _ := os.Stat(a); info.Mode().Perm() != 0600 {
+		t.Errorf("a.txt mode = %v, want 0600", info.Mode().Perm())
+	}
+	if got := readTestFile(t, c); got != "gamma\n" {
+		t.Errorf("the deleted c.txt should be back, got %q", got)
+	}
+	for _, path := range []string{b, d} {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("%s was created by the thread and should be removed", path)
+		}
+	}
+
+	if _, err := j.Undo("s1", "", false); err == nil || !strings.Contains(err.Error(), "nothing to undo") {
+		t.Errorf("a fully undone thread should leave the journal, got %v", err)
+	}
+}
+
+func TestChangeJournal_UndoWalksBackThroughThreads(t *testing.T) {
+	fs, j, root := newTestJournal(t)
+	a := filepath.Join(root, "a.txt")
+	writeTestFile(t, a, "v0", 0644)
+
+	for i, content := range []string{"v1", "v2", "v3"} {
+		j.Begin("s1", "thread-"+string(rune('a'+i)), "turn")
+		if err := fs.WriteFile("a.txt", []byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// A thread can be picked by a prefix of its ID, out of order.
+	if _, err := j.Undo("s1", "thread-z", false); err == nil {
+		t.Error("expected an unknown thread to be reported")
+	}
+	res, err := j.Undo("s1", "", false)
+	if err != nil || res.Thread != "thread-c" || readTestFile(t, a) != "v2" {
+		t.Fatalf("expected the newest thread to be undone first, got %+v, %v, %q", res, err, readTestFile(t, a))
+	}
+	if res, err := j.Undo("s1", "thread-b", false); err != nil || res.Thread != "thread-b" || readTestFile(t, a) != "v1" {
+		t.Fatalf("expected thread-b to be undone, got %+v, %v", res, err)
+	}
+	if _, err := j.Undo("s1", "", false); err != nil || readTestFile(t, a) != "v0" {
+		t.Fatalf("expected the first thread to restore the original, got %v, %q", err, readTestFile(t, a))
+	}
+}
+
+func TestChangeJournal_ConflictsNeedForce(t *testing.T) {
+	fs, j, root := newTestJournal(t)
+	a, b := filepath.Join(root, "a.txt"), filepath.Join(root, "b.txt")
+	writeTestFile(t, a, "original a", 0644)
+	writeTestFile(t, b, "original b", 0644)
+
+	j.Begin("s1", "t1", "edit both")
+	if err := fs.WriteFile("a.txt", []byte("agent a")); err != nil {
+		t.Fatal(err)
+	}
+	if err := fs.WriteFile("b.txt", []byte("agent b")); err != nil {
+		t.Fatal(err)
+	}
+	// The user edits a.txt after the agent did.
+	writeTestFile(t, a, "user a", 0644)
+
+	res, err := j.Undo("s1", "", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Conflicts) != 1 || res.Conflicts[0] != a || len(res.Restored) != 1 || res.Restored[0] != b {
+		t.Fatalf("expected a.txt to conflict and b.txt to be restored, got %+v", res)
+	}
+	if readTestFile(t, a) != "user a" || readTestFile(t, b) != "original b" {
+		t.Errorf("the conflicting file must be left alone")
+	}
+
+	res, err = j.Undo("s1", "", true)
+	if err != nil || len(res.Restored) != 1 || readTestFile(t, a) != "original a" {
+		t.Fatalf("a forced undo should restore the conflicting file, got %+v, %v", res, err)
+	}
+	if records, _ := j.Records("s1"); len(records) != 0 {
+		t.Errorf("expected the journal to be empty, got %+v", records)
+	}
+}
+
+func TestChangeJournal_ReplaysSavedRecord(t *testing.T) {
+	root, data := t.TempDir(), t.TempDir()
+	a := filepath.Join(root, "a.txt")
+	writeTestFile(t, a, "original", 0644)
+
+	// Changes made without a thread are not journaled.
+	first := NewChangeJournal(data)
+	fs := NewJournalFS(sys.NewLocalFS(root), first)
+	if err := fs.WriteFile("untracked.txt", []byte("x")); err != nil {
+		t.Fatal(err)
+	}
+	if records, _ := first.Records("s1"); len(records) != 0 {
+		t.Fatalf("expected nothing journaled outside a thread, got %+v", records)
+	}
+
+	first.Begin("s1", "t1", "edit")
+	if err := fs.WriteFile("a.txt", []byte("first run")); err != nil {
+		t.Fatal(err)
+	}
+
+	// A new process continuing the same thread picks up its saved record, so the
+	// snapshot still holds the contents from before the thread.
+	second := NewChangeJournal(data)
+	second.Begin("s1", "t1", "edit")
+	fs = NewJournalFS(sys.NewLocalFS(root), second)
+	if err := fs.WriteFile("a.txt", []byte("second run")); err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := NewChangeJournal(data).Undo("s1", "t1", false)
+	if err != nil || len(res.Restored) != 1 {
+		t.Fatalf("undo failed: %+v, %v", res, err)
+	}
+	if got := readTestFile(t, a); got != "original" {
+		t.Errorf("a.txt = %q, want the contents from before the thread", got)
+	}
+}
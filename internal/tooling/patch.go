@@ -0,0 +1,275 @@
+package tooling
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// maxPatchFuzz is how many context lines may be dropped from each end of a hunk that
+// does not apply as written, like patch's --fuzz.
+const maxPatchFuzz = 2
+
+var hunkHeaderPattern = regexp.MustCompile(`^@@ -(\d+)(?:,(\d+))? \+(\d+)(?:,(\d+))? @@`)
+
+// filePatch is the part of a unified diff that changes one file. Paths are empty for
+// /dev/null, i.e. on creation and deletion.
+type filePatch struct {
+	oldPath, newPath string
+	hunks            []patchHunk
+}
+
+type patchHunk struct {
+	header   string
+	oldStart int
+	lines    []DiffLine // Text without line ending
+}
+
+func (h patchHunk) pureInsertion() bool {
+	for _, l := range h.lines {
+		if l.Kind != "+" {
+			return false
+		}
+	}
+	return true
+}
+
+// parsePatch reads a unified diff, as produced by diff -u or git diff. Hunk line counts
+// are not trusted: a hunk runs until the next hunk or file header.
+func parsePatch(text string) ([]filePatch, error) {
+	var files []filePatch
+	var file *filePatch
+	var hunk *patchHunk
+
+	lines := strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		switch {
+		case strings.HasPrefix(line, "--- ") && i+1 < len(lines) && strings.HasPrefix(lines[i+1], "+++ "):
+			files = append(files, filePatch{oldPath: patchPath(line[4:]), newPath: patchPath(lines[i+1][4:])})
+			file, hunk = &files[len(files)-1], nil
+			i++
+		case strings.HasPrefix(line, "@@"):
+			if file == nil {
+				return nil, fmt.Errorf("patch line %d: hunk before any ---/+++ file header", i+1)
+			}
+			m := hunkHeaderPattern.FindStringSubmatch(line)
+			if m == nil {
+				return nil, fmt.Errorf("patch line %d: malformed hunk header %q (want @@ -a,b +c,d @@)", i+1, line)
+			}
+			start, _ := strconv.Atoi(m[1])
+			file.hunks = append(file.hunks, patchHunk{header: m[0], oldStart: start})
+			hunk = &file.hunks[len(file.hunks)-1]
+		case hunk == nil:
+			// diff --git, index, mode lines and commentary between files
+		case strings.HasPrefix(line, `\`):
+			// "\ No newline at end of file": the file keeps its own ending
+		case line == "":
+			hunk.lines = append(hunk.lines, DiffLine{Kind: " "})
+		case line[0] == ' ' || line[0] == '-' || line[0] == '+':
+			hunk.lines = append(hunk.lines, DiffLine{Kind: line[:1], Text: line[1:]})
+		default:
+			hunk = nil
+		}
+	}
+
+	for i := range files {
+		for j := range files[i].hunks {
+			h := &files[i].hunks[j]
+			// A blank line ending a hunk is usually the end of the patch text.
+			for len(h.lines) > 0 && h.lines[len(h.lines)-1] == (DiffLine{Kind: " "}) {
+				h.lines = h.lines[:len(h.lines)-1]
+			}
+		}
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no file headers found: the patch must be a unified diff with ---/+++ lines")
+	}
+	return files, nil
+}
+
+// patchPath strips the a/ b/ prefixes and timestamps of a diff header path.
+func patchPath(p string) string {
+	if i := strings.IndexByte(p, '\t'); i >= 0 {
+		p = p[:i]
+	}
+	p = strings.TrimSpace(p)
+	if p == "/dev/null" {
+		return ""
+	}
+	if strings.HasPrefix(p, "a/") || strings.HasPrefix(p, "b/") {
+		p = p[2:]
+	}
+	return p
+}
+
+// patchPaths lists the files a patch touches, for policy checks.
+func patchPaths(text string) []string {
+	files, err := parsePatch(text)
+	if err != nil {
+		return nil
+	}
+	var paths []string
+	for _, f := range files {
+		if f.oldPath != "" {
+			paths = append(paths, f.oldPath)
+		}
+		if f.newPath != "" && f.newPath != f.oldPath {
+			paths = append(paths, f.newPath)
+		}
+	}
+	return paths
+}
+
+// applyPatchHunks applies hunks to content. A hunk is looked for at its stated line
+// first, then at the nearest offset, then ignoring whitespace, and finally with up to
+// maxPatchFuzz context lines dropped from each end.
+func applyPatchHunks(path, content string, hunks []patchHunk) (string, error) {
+	eol := "\n"
+	if strings.Contains(content, "\r\n") {
+		eol = "\r\n"
+	}
+	trailing := content == "" || strings.HasSuffix(content, "\n")
+	var lines []string
+	if content != "" {
+		for _, l := range strings.Split(strings.TrimSuffix(content, "\n"), "\n") {
+			lines = append(lines, strings.TrimSuffix(l, "\r"))
+		}
+	}
+
+	delta, floor := 0, 0
+	for n, h := range hunks {
+		hint := h.oldStart - 1 + delta
+		if h.pureInsertion() {
+			hint++ // "@@ -5,0 ..." inserts after line 5
+		}
+		pos, body, ok := locateHunk(lines, h, hint, floor)
+		if !ok {
+			return "", hunkError(path, n+1, len(hunks), h, lines, hint)
+		}
+
+		var replaced []string
+		at := pos
+		for _, l := range body {
+			switch l.Kind {
+			case " ":
+				replaced = append(replaced, lines[at]) // keep the file's version of context
+				at++
+			case "-":
+				at++
+			case "+":
+				replaced = append(replaced, l.Text)
+			}
+		}
+		lines = append(lines[:pos], append(replaced, lines[at:]...)...)
+		delta += len(replaced) - (at - pos)
+		floor = pos + len(replaced)
+	}
+
+	out := strings.Join(lines, eol)
+	if trailing && len(lines) > 0 {
+		out += eol
+	}
+	return out, nil
+}
+
+// locateHunk finds where the old side of h occurs in lines, at or after floor, and
+// returns that position with the hunk lines that matched (fuzz may trim context).
+func locateHunk(lines []string, h patchHunk, hint, floor int) (int, []DiffLine, bool) {
+	for fuzz := 0; fuzz <= maxPatchFuzz; fuzz++ {
+		body, lead, ok := trimContext(h.lines, fuzz)
+		if !ok {
+			break
+		}
+		var old []string
+		for _, l := range body {
+			if l.Kind != "+" {
+				old = append(old, l.Text)
+			}
+		}
+		for _, equal := range []func(a, b string) bool{
+			func(a, b string) bool { return a == b },
+			func(a, b string) bool {
+				return strings.Join(strings.Fields(a), " ") == strings.Join(strings.Fields(b), " ")
+			},
+		} {
+			if pos, ok := nearestMatch(lines, old, hint+lead, floor, equal); ok {
+				return pos, body, true
+			}
+		}
+	}
+	return 0, nil, false
+}
+
+// trimContext drops up to fuzz context lines from both ends of a hunk, reporting how
+// many were dropped at the start. It fails once no context is left to drop.
+func trimContext(lines []DiffLine, fuzz int) ([]DiffLine, int, bool) {
+	lead := 0
+	for lead < fuzz && lead < len(lines) && lines[lead].Kind == " " {
+		lead++
+	}
+	end := len(lines)
+	for trail := 0; trail < fuzz && end > lead && lines[end-1].Kind == " "; trail++ {
+		end--
+	}
+	if fuzz > 0 && lead == 0 && end == len(lines) {
+		return nil, 0, false
+	}
+	return lines[lead:end], lead, true
+}
+
+// nearestMatch finds old in lines at the position closest to hint, not before floor.
+func nearestMatch(lines, old []string, hint, floor int, equal func(a, b string) bool) (int, bool) {
+	matchAt := func(pos int) bool {
+		if pos < floor || pos+len(old) > len(lines) {
+			return false
+		}
+		for i, o := range old {
+			if !equal(lines[pos+i], o) {
+				return false
+			}
+		}
+		return true
+	}
+	if len(old) == 0 {
+		// Pure insertion: trust the header, clamped to the file.
+		return max(floor, min(hint, len(lines))), true
+	}
+	hint = max(0, min(hint, len(lines)))
+	for d := 0; d <= len(lines); d++ {
+		if matchAt(hint - d) {
+			return hint - d, true
+		}
+		if d > 0 && matchAt(hint+d) {
+			return hint + d, true
+		}
+	}
+	return 0, false
+}
+
+func hunkError(path string, n, total int, h patchHunk, lines []string, hint int) error {
+	var expected strings.Builder
+	for _, l := range h.lines {
+		if l.Kind != "+" {
+			expected.WriteString("  " + l.Text + "\n")
+		}
+	}
+	return fmt.Errorf("hunk %d of %d (%s) does not apply to %s: these lines were not found, even ignoring whitespace:\n%s%s\nRe-read the file and regenerate the patch against its current contents.",
+		n, total, h.header, path, expected.String(), excerpt(lines, hint, "Lines near there"))
+}
+
+// excerpt shows the lines around index center with 1-based line numbers.
+func excerpt(lines []string, center int, title string) string {
+	if len(lines) == 0 {
+		return title + ": (the file is empty)"
+	}
+	center = max(0, min(center, len(lines)-1))
+	start, end := max(0, center-3), min(len(lines), center+4)
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("%s (%d-%d):\n", title, start+1, end))
+	for i := start; i < end; i++ {
+		sb.WriteString(fmt.Sprintf("%5d | %s\n", i+1, lines[i]))
+	}
+	return strings.TrimRight(sb.String(), "\n")
+}
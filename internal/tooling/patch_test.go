@@ -0,0 +1,221 @@
+package tooling
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/nathfavour/vibeauracle/sys"
+)
+
+func TestFileEdit_Apply(t *testing.T) {
+	content := "func a() {\n\treturn 1\n}\n\nfunc b() {\n\treturn 1\n}\n"
+	tests := []struct {
+		name string
+		edit fileEdit
+		want string
+		err  string
+	}{
+		{"unique", fileEdit{OldString: "func a() {\n\treturn 1", NewString: "func a() {\n\treturn 2"}, strings.Replace(content, "return 1", "return 2", 1), ""},
+		{"ambiguous", fileEdit{OldString: "return 1", NewString: "return 2"}, "", "matched 2 times in f.go (lines 2, 6)"},
+		{"replace all", fileEdit{OldString: "return 1", NewString: "return 2", ReplaceAll: true}, strings.ReplaceAll(content, "return 1", "return 2"), ""},
+		{"missing", fileEdit{OldString: "func c()", NewString: "x"}, "", "re-read the file"},
+		{"whitespace drift", fileEdit{OldString: "func b() {\n    return 1", NewString: "x"}, "", "matches only when whitespace is ignored"},
+		{"first line only", fileEdit{OldString: "func b() {\n\treturn 3", NewString: "x"}, "", "its first line matches"},
+		{"empty old string", fileEdit{NewString: "x"}, "", "old_string is empty"},
+		{"no change", fileEdit{OldString: "func a", NewString: "func a"}, "", "identical"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.edit.Path = "f.go"
+			got, _, err := tt.edit.apply(content)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("expected an error containing %q, got %v", tt.err, err)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Fatalf("got %q, %v; want %q", got, err, tt.want)
+			}
+		})
+	}
+
+	// A near miss points at the lines around it.
+	_, _, err := fileEdit{Path: "f.go", OldString: "func b() {\n\treturn 3", NewString: "x"}.apply(content)
+	if err == nil || !strings.Contains(err.Error(), "Closest match (2-8):") || !strings.Contains(err.Error(), "    5 | func b() {") {
+		t.Errorf("expected nearby lines in the error, got %v", err)
+	}
+}
+
+func TestApplyPatchHunks(t *testing.T) {
+	base := strings.Join(numbered(12), "")
+	tests := []struct {
+		name    string
+		content string
+		patch   string
+		want    string
+		err     string
+	}{
+		{
+			name:    "exact",
+			content: base,
+			patch:   "@@ -4,3 +4,3 @@\n line 4\n-line 5\n+five\n line 6\n",
+			want:    strings.Replace(base, "line 5\n", "five\n", 1),
+		},
+		{
+			name:    "shifted lines",
+			content: "extra\nextra\n" + base,
+			patch:   "@@ -4,3 +4,3 @@\n line 4\n-line 5\n+five\n line 6\n",
+			want:    "extra\nextra\n" + strings.Replace(base, "line 5\n", "five\n", 1),
+		},
+		{
+			name:    "whitespace drift",
+			content: strings.Replace(base, "line 4\n", "  line   4\t\n", 1),
+			patch:   "@@ -4,3 +4,3 @@\n line 4\n-line 5\n+five\n line 6\n",
+			want:    strings.Replace(base, "line 4\nline 5\n", "  line   4\t\nfive\n", 1),
+		},
+		{
+			name:    "context drift within fuzz",
+			content: strings.Replace(base, "line 3\n", "changed 3\n", 1),
+			patch:   "@@ -3,4 +3,4 @@\n line 3\n line 4\n-line 5\n+five\n line 6\n",
+			want:    strings.Replace(strings.Replace(base, "line 3\n", "changed 3\n", 1), "line 5\n", "five\n", 1),
+		},
+		{
+			name:    "nearest of several matches",
+			content: "x\ny\nx\ny\nx\ny\n",
+			patch:   "@@ -5,2 +5,2 @@\n x\n-y\n+z\n",
+			want:    "x\ny\nx\ny\nx\nz\n",
+		},
+		{
+			name:    "pure insertion",
+			content: "a\nb\n",
+			patch:   "@@ -1,0 +2,1 @@\n+between\n",
+			want:    "a\nbetween\nb\n",
+		},
+		{
+			name:    "CRLF file",
+			content: "a\r\nb\r\nc\r\n",
+			patch:   "@@ -1,3 +1,3 @@\n a\n-b\n+B\n c\n",
+			want:    "a\r\nB\r\nc\r\n",
+		},
+		{
+			name:    "missing anchor",
+			content: base,
+			patch:   "@@ -4,3 +4,3 @@\n line 4\n-gone\n+five\n line 6\n",
+			err:     "hunk 1 of 1 (@@ -4,3 +4,3 @@) does not apply to f.txt",
+		},
+		{
+			name:    "later hunk fails",
+			content: base,
+			patch:   "@@ -2,1 +2,1 @@\n-line 2\n+two\n@@ -10,1 +10,1 @@\n-line 99\n+x\n",
+			err:     "hunk 2 of 2",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			files, err := parsePatch("--- a/f.txt\n+++ b/f.txt\n" + tt.patch)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := applyPatchHunks("f.txt", tt.content, files[0].hunks)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) || !strings.Contains(err.Error(), "Lines near there") {
+					t.Fatalf("expected an error containing %q with nearby lines, got %v", tt.err, err)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Fatalf("got %q, %v; want %q", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestParsePatch(t *testing.T) {
+	files, err := parsePatch("diff --git a/x b/y\nindex 1..2\n--- a/x\t2024-01-01\n+++ b/y\n@@ -1 +1 @@\n-a\n+b\n\\ No newline at end of file\n--- /dev/null\n+++ b/new\n@@ -0,0 +1,1 @@\n+n\n\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 2 || files[0].oldPath != "x" || files[0].newPath != "y" || files[1].oldPath != "" || files[1].newPath != "new" {
+		t.Fatalf("unexpected files: %+v", files)
+	}
+	if got := files[1].hunks[0].lines; len(got) != 1 {
+		t.Errorf("a trailing blank line should not become context, got %+v", got)
+	}
+	if got := patchPaths("--- a/x\n+++ b/y\n@@ -1 +1 @@\n-a\n+b\n"); strings.Join(got, " ") != "x y" {
+		t.Errorf("patchPaths = %v", got)
+	}
+
+	for _, bad := range []string{"just text", "@@ -1 +1 @@\n-a\n+b\n", "--- a/x\n+++ b/x\n@@ broken @@\n"} {
+		if _, err := parsePatch(bad); err == nil {
+			t.Errorf("expected %q to be rejected", bad)
+		}
+	}
+}
+
+func TestApplyPatchTool_AllOrNothing(t *testing.T) {
+	root := t.TempDir()
+	writeTestFile(t, filepath.Join(root, "a.txt"), "one\ntwo\n", 0644)
+	writeTestFile(t, filepath.Join(root, "b.txt"), "three\n", 0644)
+	writeTestFile(t, filepath.Join(root, "old.txt"), "bye\n", 0644)
+	tool := NewApplyPatchTool(sys.NewLocalFS(root))
+
+	run := func(patch string) error {
+		args, _ := json.Marshal(map[string]string{"patch": patch})
+		_, err := tool.Execute(context.Background(), args)
+		return err
+	}
+
+	err := run("--- a/a.txt\n+++ b/a.txt\n@@ -1,2 +1,2 @@\n-one\n+ONE\n two\n--- a/b.txt\n+++ b/b.txt\n@@ -1 +1 @@\n-missing\n+x\n")
+	if err == nil || !strings.Contains(err.Error(), "No changes were written") {
+		t.Fatalf("expected the second file to fail the patch, got %v", err)
+	}
+	if got := readTestFile(t, filepath.Join(root, "a.txt")); got != "one\ntwo\n" {
+		t.Errorf("a.txt must be left alone when another file fails, got %q", got)
+	}
+
+	err = run("--- a/a.txt\n+++ b/a.txt\n@@ -1,2 +1,2 @@\n-one\n+ONE\n two\n--- /dev/null\n+++ b/new.txt\n@@ -0,0 +1 @@\n+hello\n--- a/old.txt\n+++ /dev/null\n@@ -1 +0,0 @@\n-bye\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := readTestFile(t, filepath.Join(root, "a.txt")); got != "ONE\ntwo\n" {
+		t.Errorf("a.txt = %q", got)
+	}
+	if got := readTestFile(t, filepath.Join(root, "new.txt")); got != "hello\n" {
+		t.Errorf("new.txt = %q", got)
+	}
+	if _, err := os.Stat(filepath.Join(root, "old.txt")); !os.IsNotExist(err) {
+		t.Error("old.txt should be deleted")
+	}
+}
+
+func TestMultiEditTool_AllOrNothing(t *testing.T) {
+	root := t.TempDir()
+	writeTestFile(t, filepath.Join(root, "a.txt"), "x = 1\n", 0644)
+	tool := NewMultiEditTool(sys.NewLocalFS(root))
+
+	run := func(edits ...fileEdit) error {
+		args, _ := json.Marshal(map[string][]fileEdit{"edits": edits})
+		_, err := tool.Execute(context.Background(), args)
+		return err
+	}
+
+	err := run(fileEdit{Path: "a.txt", OldString: "x = 1", NewString: "x = 2"}, fileEdit{Path: "a.txt", OldString: "x = 1", NewString: "x = 3"})
+	if err == nil || !strings.Contains(err.Error(), "edit 2 of 2") {
+		t.Fatalf("the second edit should not find what the first replaced, got %v", err)
+	}
+	if got := readTestFile(t, filepath.Join(root, "a.txt")); got != "x = 1\n" {
+		t.Errorf("a failed batch must not write, got %q", got)
+	}
+
+	if err := run(fileEdit{Path: "a.txt", OldString: "x = 1", NewString: "x = 2"}, fileEdit{Path: "a.txt", OldString: "x = 2", NewString: "y = 2"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := readTestFile(t, filepath.Join(root, "a.txt")); got != "y = 2\n" {
+		t.Errorf("each edit should see the previous one, got %q", got)
+	}
+}
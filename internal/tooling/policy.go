@@ -0,0 +1,280 @@
+package tooling
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// PolicyDecision is the outcome of evaluating a tool call against policy rules.
+type PolicyDecision string
+
+const (
+	PolicyNone  PolicyDecision = ""      // no rule matched
+	PolicyAllow PolicyDecision = "allow" // run without asking
+	PolicyDeny  PolicyDecision = "deny"  // refuse without asking
+	PolicyAsk   PolicyDecision = "ask"   // always ask, even if approved before
+)
+
+// PolicyFile is the file name looked up in a project's .vibeaura directory and in the app data dir.
+const PolicyFile = "policy.yaml"
+
+// PolicyRule matches tool calls and decides them. Every field that is set must match:
+// Tool is a glob on the tool name, Permission one of the tool's permissions, Command
+// a glob on the full shell command line, and Path a glob on the paths a call touches
+// ("**" spans directories; relative patterns are relative to the project root).
+// Allow rules need every path of a call to match, deny and ask rules just one.
+type PolicyRule struct {
+	Name       string         `yaml:"name"`
+	Tool       string         `yaml:"tool"`
+	Permission Permission     `yaml:"permission"`
+	Command    string         `yaml:"command"`
+	Path       string         `yaml:"path"`
+	Decision   PolicyDecision `yaml:"decision"`
+}
+
+// Policy is an ordered list of rules; the first matching rule decides. Commands extend
+// the table that rates shell commands (see CommandRule).
+type Policy struct {
+	Rules    []PolicyRule  `yaml:"rules"`
+	Commands []CommandRule `yaml:"commands"`
+	root     string
+}
+
+// LoadPolicy reads the user's policy from appDataDir and the project's from the
+// .vibeaura directory of root or its closest parent that has one. User rules are
+// evaluated first, so a checked-out repository cannot override them.
+func LoadPolicy(appDataDir, root string) (*Policy, error) {
+	p := &Policy{root: root}
+	if err := p.load(filepath.Join(appDataDir, PolicyFile), true); err != nil {
+		return nil, err
+	}
+	for dir := root; dir != ""; dir = parentDir(dir) {
+		file := filepath.Join(dir, ".vibeaura", PolicyFile)
+		if _, err := os.Stat(file); err == nil {
+			if err := p.load(file, false); err != nil {
+				return nil, err
+			}
+			break
+		}
+	}
+	return p, nil
+}
+
+func parentDir(dir string) string {
+	parent := filepath.Dir(dir)
+	if parent == dir {
+		return ""
+	}
+	return parent
+}
+
+// load appends the rules of a policy file. Command rules of a trusted (the user's) file
+// may replace built-in ones of the same name.
+func (p *Policy) load(file string, trusted bool) error {
+	data, err := os.ReadFile(file)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("reading policy: %w", err)
+	}
+
+	var doc Policy
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return fmt.Errorf("parsing %s: %w", file, err)
+	}
+	for i, r := range doc.Rules {
+		switch r.Decision {
+		case PolicyAllow, PolicyDeny, PolicyAsk:
+		default:
+			return fmt.Errorf("%s: rule %d: decision must be allow, deny or ask, got %q", file, i+1, r.Decision)
+		}
+		if r.Name == "" {
+			r.Name = fmt.Sprintf("%s#%d", file, i+1)
+		}
+		p.Rules = append(p.Rules, r)
+	}
+	for i, r := range doc.Commands {
+		if err := r.validate(); err != nil {
+			return fmt.Errorf("%s: command rule %d: %w", file, i+1, err)
+		}
+		if r.Name == "" {
+			r.Name = fmt.Sprintf("%s#commands.%d", file, i+1)
+		}
+		r.trusted = trusted
+		p.Commands = append(p.Commands, r)
+	}
+	return nil
+}
+
+// CommandRules returns the built-in command rules extended by the policy's. A command
+// rule of the user's policy replaces the built-in rule with the same name.
+func (p *Policy) CommandRules() []CommandRule {
+	rules := make([]CommandRule, len(defaultCommandRules))
+	copy(rules, defaultCommandRules)
+	for i := range rules {
+		rules[i].trusted = true
+	}
+	if p == nil {
+		return rules
+	}
+	for _, r := range p.Commands {
+		replaced := false
+		if r.trusted {
+			for i := range rules {
+				if rules[i].Name == r.Name && i < len(defaultCommandRules) {
+					rules[i], replaced = r, true
+					break
+				}
+			}
+		}
+		if !replaced {
+			rules = append(rules, r)
+		}
+	}
+	return rules
+}
+
+// Evaluate returns the decision of the first rule matching the call, and that rule.
+func (p *Policy) Evaluate(tool Tool, args json.RawMessage) (PolicyDecision, *PolicyRule) {
+	if p == nil || len(p.Rules) == 0 {
+		return PolicyNone, nil
+	}
+	meta := tool.Metadata()
+	command, paths := callTargets(meta.Name, args)
+
+	for i := range p.Rules {
+		r := &p.Rules[i]
+		if r.Tool != "" && !globMatch(r.Tool, meta.Name, false) {
+			continue
+		}
+		if r.Permission != "" && !hasPermission(meta.Permissions, r.Permission) {
+			continue
+		}
+		if r.Command != "" && (command == "" || !globMatch(r.Command, command, false)) {
+			continue
+		}
+		if r.Path != "" && !p.pathsMatch(r, paths) {
+			continue
+		}
+		return r.Decision, r
+	}
+	return PolicyNone, nil
+}
+
+func (p *Policy) pathsMatch(r *PolicyRule, paths []string) bool {
+	if len(paths) == 0 {
+		return false
+	}
+	pattern := r.Path
+	if strings.HasPrefix(pattern, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			pattern = filepath.Join(home, pattern[2:])
+		}
+	}
+	if !filepath.IsAbs(pattern) && p.root != "" {
+		pattern = filepath.Join(p.root, pattern)
+	}
+
+	matched := 0
+	for _, path := range paths {
+		if !filepath.IsAbs(path) && p.root != "" {
+			path = filepath.Join(p.root, path)
+		}
+		if globMatch(filepath.ToSlash(pattern), filepath.ToSlash(filepath.Clean(path)), true) {
+			matched++
+		}
+	}
+	if r.Decision == PolicyAllow {
+		return matched == len(paths)
+	}
+	return matched > 0
+}
+
+// callTargets extracts the shell command line and the paths a tool call touches.
+func callTargets(tool string, args json.RawMessage) (string, []string) {
+	var input struct {
+		Command string     `json:"command"`
+		Args    []string   `json:"args"`
+		Path    string     `json:"path"`
+		Paths   []string   `json:"paths"`
+		Edits   []fileEdit `json:"edits"`
+		Patch   string     `json:"patch"`
+	}
+	_ = json.Unmarshal(args, &input)
+	for _, e := range input.Edits {
+		input.Paths = append(input.Paths, e.Path)
+	}
+	if input.Patch != "" {
+		input.Paths = append(input.Paths, patchPaths(input.Patch)...)
+	}
+
+	var command string
+	if tool == "sys_shell_exec" {
+		command = strings.TrimSpace(input.Command + " " + strings.Join(input.Args, " "))
+	}
+	var paths []string
+	if input.Path != "" {
+		paths = append(paths, input.Path)
+	}
+	for _, p := range input.Paths {
+		if p != "" {
+			paths = append(paths, p)
+		}
+	}
+	return command, paths
+}
+
+func hasPermission(perms []Permission, want Permission) bool {
+	for _, p := range perms {
+		if p == want {
+			return true
+		}
+	}
+	return false
+}
+
+// globMatch matches s against a glob where "?" is any character and "*" any run of
+// characters. With pathSep, "*" stops at "/" and "**" spans directories.
+func globMatch(pattern, s string, pathSep bool) bool {
+	ok, _ := regexp.MatchString(globRegexp(pattern, pathSep), s)
+	return ok
+}
+
+// globRegexp translates a glob into an anchored regular expression, as globMatch uses it.
+func globRegexp(pattern string, pathSep bool) string {
+	var re strings.Builder
+	re.WriteString("^")
+	runes := []rune(pattern)
+	for i := 0; i < len(runes); i++ {
+		switch c := runes[i]; c {
+		case '*':
+			if pathSep && i+1 < len(runes) && runes[i+1] == '*' {
+				i++
+				if i+1 < len(runes) && runes[i+1] == '/' {
+					// "**/" also matches no directory at all
+					i++
+					re.WriteString("(?:.*/)?")
+				} else {
+					re.WriteString(".*")
+				}
+			} else if pathSep {
+				re.WriteString("[^/]*")
+			} else {
+				re.WriteString(".*")
+			}
+		case '?':
+			re.WriteString(".")
+		default:
+			re.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	re.WriteString("$")
+	return re.String()
+}
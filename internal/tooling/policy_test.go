@@ -0,0 +1,291 @@
+package tooling
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// testTool is a tool with a name and permissions that records the arguments it ran with.
+type testTool struct {
+	name  string
+	perms []Permission
+	ran   []string
+}
+
+func (t *testTool) Metadata() ToolMetadata {
+	return ToolMetadata{Name: t.name, Permissions: t.perms}
+}
+
+func (t *testTool) Execute(ctx context.Context, args json.RawMessage) (*ToolResult, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	t.ran = append(t.ran, string(args))
+	return &ToolResult{Status: "success", Content: "ran"}, nil
+}
+
+func TestPolicy_Evaluate(t *testing.T) {
+	shell := &testTool{name: "sys_shell_exec", perms: []Permission{PermExecute}}
+	read := &testTool{name: "fs_read_file", perms: []Permission{PermRead}}
+	write := &testTool{name: "fs_write_file", perms: []Permission{PermWrite}}
+	multi := &testTool{name: "fs_multi_edit", perms: []Permission{PermWrite}}
+
+	tests := []struct {
+		name     string
+		rules    []PolicyRule
+		tool     Tool
+		args     string
+		decision PolicyDecision
+		rule     string
+	}{
+		{"no rules", nil, read, `{"path": "a.go"}`, PolicyNone, ""},
+		{"tool glob", []PolicyRule{{Name: "sys", Tool: "sys_*", Decision: PolicyAsk}}, shell, `{"command": "ls"}`, PolicyAsk, "sys"},
+		{"tool glob misses", []PolicyRule{{Name: "sys", Tool: "sys_*", Decision: PolicyAsk}}, read, `{"path": "a.go"}`, PolicyNone, ""},
+		{"permission", []PolicyRule{{Name: "writes", Permission: PermWrite, Decision: PolicyDeny}}, write, `{"path": "a.go"}`, PolicyDeny, "writes"},
+		{"permission misses", []PolicyRule{{Name: "writes", Permission: PermWrite, Decision: PolicyDeny}}, read, `{"path": "a.go"}`, PolicyNone, ""},
+		{"command glob", []PolicyRule{{Name: "tests", Command: "go test *", Decision: PolicyAllow}}, shell, `{"command": "go test ./..."}`, PolicyAllow, "tests"},
+		{"command glob on argv", []PolicyRule{{Name: "tests", Command: "go test *", Decision: PolicyAllow}}, shell, `{"command": "go", "args": ["test", "./..."]}`, PolicyAllow, "tests"},
+		{"command glob is anchored", []PolicyRule{{Name: "tests", Command: "go test *", Decision: PolicyAllow}}, shell, `{"command": "sudo go test ./..."}`, PolicyNone, ""},
+		{"command only applies to the shell", []PolicyRule{{Name: "tests", Command: "go test *", Decision: PolicyAllow}}, write, `{"command": "go test ./...", "path": "a.go"}`, PolicyNone, ""},
+		{"path glob", []PolicyRule{{Name: "secrets", Path: "secrets/**", Decision: PolicyDeny}}, read, `{"path": "secrets/prod/key.pem"}`, PolicyDeny, "secrets"},
+		{"single star stays in its directory", []PolicyRule{{Name: "docs", Path: "docs/*.md", Decision: PolicyAllow}}, write, `{"path": "docs/api/index.md"}`, PolicyNone, ""},
+		{"double star spans no directory", []PolicyRule{{Name: "go", Path: "**/*.go", Decision: PolicyAllow}}, write, `{"path": "main.go"}`, PolicyAllow, "go"},
+		{"path glob without paths", []PolicyRule{{Name: "secrets", Path: "secrets/**", Decision: PolicyDeny}}, shell, `{"command": "cat secrets/key"}`, PolicyNone, ""},
+		{"deny needs one path", []PolicyRule{{Name: "secrets", Path: "secrets/**", Decision: PolicyDeny}}, multi, `{"edits": [{"path": "README.md"}, {"path": "secrets/key"}]}`, PolicyDeny, "secrets"},
+		{"allow needs every path", []PolicyRule{{Name: "docs", Path: "docs/*.md", Decision: PolicyAllow}}, multi, `{"edits": [{"path": "docs/a.md"}, {"path": "README.md"}]}`, PolicyNone, ""},
+		{"allow with every path", []PolicyRule{{Name: "docs", Path: "docs/*.md", Decision: PolicyAllow}}, multi, `{"edits": [{"path": "docs/a.md"}, {"path": "docs/b.md"}]}`, PolicyAllow, "docs"},
+		{"every field must match", []PolicyRule{{Name: "go-writes", Tool: "fs_*", Permission: PermWrite, Path: "**/*.go", Decision: PolicyAllow}}, read, `{"path": "main.go"}`, PolicyNone, ""},
+		{
+			"first match wins",
+			[]PolicyRule{
+				{Name: "keep-out", Path: "secrets/**", Decision: PolicyDeny},
+				{Name: "reads", Permission: PermRead, Decision: PolicyAllow},
+			},
+			read, `{"path": "secrets/key"}`, PolicyDeny, "keep-out",
+		},
+		{
+			"later rule when the first misses",
+			[]PolicyRule{
+				{Name: "keep-out", Path: "secrets/**", Decision: PolicyDeny},
+				{Name: "reads", Permission: PermRead, Decision: PolicyAllow},
+			},
+			read, `{"path": "main.go"}`, PolicyAllow, "reads",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &Policy{Rules: tt.rules, root: "/project"}
+			decision, rule := p.Evaluate(tt.tool, json.RawMessage(tt.args))
+			if decision != tt.decision {
+				t.Fatalf("decision = %q, want %q", decision, tt.decision)
+			}
+			name := ""
+			if rule != nil {
+				name = rule.Name
+			}
+			if name != tt.rule {
+				t.Errorf("rule = %q, want %q", name, tt.rule)
+			}
+		})
+	}
+
+	var nilPolicy *Policy
+	if decision, rule := nilPolicy.Evaluate(read, json.RawMessage(`{}`)); decision != PolicyNone || rule != nil {
+		t.Errorf("a nil policy must not decide, got %q", decision)
+	}
+}
+
+func TestGlobMatch(t *testing.T) {
+	tests := []struct {
+		pattern, s string
+		pathSep    bool
+		want       bool
+	}{
+		{"go test *", "go test ./...", false, true},
+		{"go test *", "go test", false, false},
+		{"git ?ush", "git push", false, true},
+		{"a.b", "axb", false, false},
+		{"*", "a/b", false, true},
+		{"*", "a/b", true, false},
+		{"/p/**", "/p/a/b/c", true, true},
+		{"/p/**/x.go", "/p/x.go", true, true},
+		{"/p/**/x.go", "/p/a/b/x.go", true, true},
+		{"/p/*.go", "/p/a/x.go", true, false},
+	}
+	for _, tt := range tests {
+		if got := globMatch(tt.pattern, tt.s, tt.pathSep); got != tt.want {
+			t.Errorf("globMatch(%q, %q, %v) = %v, want %v", tt.pattern, tt.s, tt.pathSep, got, tt.want)
+		}
+	}
+}
+
+func writePolicy(t *testing.T, dir, content string) {
+	t.Helper()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, PolicyFile), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoadPolicy(t *testing.T) {
+	appData, root := t.TempDir(), t.TempDir()
+	writePolicy(t, appData, `rules:
+  - name: user-deny
+    tool: sys_shell_exec
+    decision: deny
+commands:
+  - name: privileged
+    commands: [sudo]
+    risk: medium
+`)
+	// The project policy is found from a subdirectory of the project.
+	writePolicy(t, filepath.Join(root, ".vibeaura"), `rules:
+  - tool: sys_shell_exec
+    decision: allow
+commands:
+  - name: privileged
+    commands: [doas]
+    risk: low
+`)
+	sub := filepath.Join(root, "pkg", "api")
+	if err := os.MkdirAll(sub, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := LoadPolicy(appData, sub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(p.Rules) != 2 || p.Rules[0].Name != "user-deny" {
+		t.Fatalf("expected the user's rules first, got %+v", p.Rules)
+	}
+	if want := filepath.Join(root, ".vibeaura", PolicyFile) + "#1"; p.Rules[1].Name != want {
+		t.Errorf("unnamed rules are named after their file, got %q, want %q", p.Rules[1].Name, want)
+	}
+	shell := &testTool{name: "sys_shell_exec", perms: []Permission{PermExecute}}
+	if decision, rule := p.Evaluate(shell, json.RawMessage(`{"command": "ls"}`)); decision != PolicyDeny || rule.Name != "user-deny" {
+		t.Errorf("a project rule must not override the user's, got %q", decision)
+	}
+
+	rules := p.CommandRules()
+	if len(rules) != len(defaultCommandRules)+1 {
+		t.Fatalf("expected the user's rule to replace the built-in one and the project's to be added, got %d rules", len(rules))
+	}
+	for _, r := range rules {
+		if r.Name == "privileged" && r.Risk == "high" {
+			t.Error("the built-in privileged rule should have been replaced by the user's")
+		}
+	}
+	if last := rules[len(rules)-1]; last.Name != "privileged" || last.trusted || last.Risk != "low" {
+		t.Errorf("the project's rule must be appended untrusted, got %+v", last)
+	}
+
+	if p, err := LoadPolicy(t.TempDir(), t.TempDir()); err != nil || len(p.Rules) != 0 {
+		t.Errorf("missing policy files should give an empty policy, got %+v, %v", p, err)
+	}
+}
+
+func TestLoadPolicy_Invalid(t *testing.T) {
+	tests := []struct {
+		name, content, want string
+	}{
+		{"decision", "rules:\n  - tool: x\n    decision: maybe\n", "decision must be allow, deny or ask"},
+		{"risk", "commands:\n  - commands: [x]\n    risk: extreme\n", "risk must be"},
+		{"pattern", "commands:\n  - commands: ['[']\n    risk: low\n", "bad pattern"},
+		{"yaml", "rules: [", "parsing"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			appData := t.TempDir()
+			writePolicy(t, appData, tt.content)
+			if _, err := LoadPolicy(appData, ""); err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("expected an error containing %q, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestEnclave_ResumeRunsUnderCallerContext(t *testing.T) {
+	e, err := NewEnclave(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	shell := &testTool{name: "sys_shell_exec", perms: []Permission{PermExecute}}
+	_, err = e.Interceptor(shell, json.RawMessage(`{"command": "make"}`))
+	var intervention *InterventionError
+	if !errors.As(err, &intervention) {
+		t.Fatalf("expected to be asked about the call, got %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := intervention.Resume(ctx, "Approve Once"); !errors.Is(err, context.Canceled) || len(shell.ran) != 0 {
+		t.Errorf("a cancelled caller must stop the approved call, got %v, ran %v", err, shell.ran)
+	}
+	if _, err := intervention.Resume(context.Background(), "Approve Once"); err != nil || len(shell.ran) != 1 {
+		t.Errorf("expected the approved call to run, got %v, ran %v", err, shell.ran)
+	}
+}
+
+func TestEnclave_PolicyRuleIsAudited(t *testing.T) {
+	e, err := NewEnclave(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	e.SetPolicy(&Policy{Rules: []PolicyRule{
+		{Name: "no-secrets", Path: "secrets/**", Decision: PolicyDeny},
+		{Name: "docs", Tool: "fs_write_file", Path: "docs/**", Decision: PolicyAllow},
+		{Name: "confirm", Tool: "fs_write_file", Decision: PolicyAsk},
+	}, root: "/project"})
+	write := &testTool{name: "fs_write_file", perms: []Permission{PermWrite}}
+
+	denyArgs := json.RawMessage(`{"path": "secrets/key"}`)
+	if decision, err := e.Policy(write, denyArgs); decision != PolicyDeny || err == nil || !strings.Contains(err.Error(), `"no-secrets"`) {
+		t.Errorf("expected the deny rule to refuse the call, got %q, %v", decision, err)
+	}
+
+	allowArgs := json.RawMessage(`{"path": "docs/a.md"}`)
+	if decision, err := e.Policy(write, allowArgs); decision != PolicyAllow || err != nil {
+		t.Fatalf("expected the allow rule to approve the call, got %q, %v", decision, err)
+	}
+	res, err := write.Execute(context.Background(), allowArgs)
+	e.Complete(write, allowArgs, res, err)
+
+	askArgs := json.RawMessage(`{"path": "main.go"}`)
+	decision, err := e.Policy(write, askArgs)
+	var intervention *InterventionError
+	if decision != PolicyAsk || !errors.As(err, &intervention) {
+		t.Fatalf("expected the ask rule to raise an intervention, got %q, %v", decision, err)
+	}
+	if _, err := intervention.Resume(context.Background(), "Deny"); err == nil {
+		t.Error("a denied intervention must fail")
+	}
+
+	entries, err := e.Audit().Entries()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []struct{ decision, rule, status string }{
+		{"Denied (Policy)", "no-secrets", ""},
+		{"Approved (Policy)", "docs", "success"},
+		{"Denied (User)", "confirm", ""},
+	}
+	if len(entries) != len(want) {
+		t.Fatalf("expected %d audit entries, got %+v", len(want), entries)
+	}
+	for i, w := range want {
+		if got := entries[i]; got.Decision != w.decision || got.Rule != w.rule || got.Status != w.status {
+			t.Errorf("entry %d: got %s/%s/%s, want %s/%s/%s", i, got.Decision, got.Rule, got.Status, w.decision, w.rule, w.status)
+		}
+	}
+	if len(write.ran) != 1 {
+		t.Errorf("only the allowed call should have run, got %v", write.ran)
+	}
+}
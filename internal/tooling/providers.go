@@ -11,24 +11,33 @@ type SystemProvider struct {
 	fs      sys.FS
 	monitor *sys.Monitor
 	guard   *SecurityGuard
+	sandbox func() *SandboxPolicy
 }
 
-func NewSystemProvider(f sys.FS, m *sys.Monitor, guard *SecurityGuard) *SystemProvider {
-	return &SystemProvider{fs: f, monitor: m, guard: guard}
+func NewSystemProvider(f sys.FS, m *sys.Monitor, guard *SecurityGuard, sandbox func() *SandboxPolicy) *SystemProvider {
+	return &SystemProvider{fs: f, monitor: m, guard: guard, sandbox: sandbox}
 }
 
 func (p *SystemProvider) Name() string { return "system" }
 
 func (p *SystemProvider) Provide(ctx context.Context) ([]Tool, error) {
+	f := p.fs
+	if p.guard != nil {
+		// File tools are confined to the workspace whatever path they are given.
+		f = NewWorkspaceFS(f, p.guard)
+	}
 	tools := []Tool{
-		NewReadFileTool(p.fs),
-		NewWriteFileTool(p.fs),
-		NewListFilesTool(p.fs),
-		NewListDirTool(p.fs),
-		NewFileStatsTool(p.fs),
-		NewTraversalTool(p.fs),
-		&ShellExecTool{},
-		&GrepTool{},
+		NewReadFileTool(f),
+		NewWriteFileTool(f),
+		NewEditFileTool(f),
+		NewMultiEditTool(f),
+		NewApplyPatchTool(f),
+		NewListFilesTool(f),
+		NewListDirTool(f),
+		NewFileStatsTool(f),
+		NewTraversalTool(f),
+		NewShellExecTool(p.sandbox),
+		NewGrepTool(f, p.guard),
 		&SCMStatusTool{},
 		&SCMAddTool{},
 		&SCMCommitTool{},
@@ -68,11 +77,11 @@ func (p *VibeProvider) Provide(ctx context.Context) ([]Tool, error) {
 }
 
 // Global Registry Setup
-func Setup(f sys.FS, m *sys.Monitor, guard *SecurityGuard) *Registry {
+func Setup(f sys.FS, m *sys.Monitor, guard *SecurityGuard, sandbox func() *SandboxPolicy) *Registry {
 	r := NewRegistry()
 
 	// Register Providers
-	r.RegisterProvider(NewSystemProvider(f, m, guard))
+	r.RegisterProvider(NewSystemProvider(f, m, guard, sandbox))
 	r.RegisterProvider(NewVibeProvider())
 
 	// Explicitly Register the Wand (Discovery Tool) which needs the registry itself
@@ -0,0 +1,272 @@
+package tooling
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/nathfavour/vibeauracle/sys"
+)
+
+// Choices of a write review. A partial selection is sent as AcceptHunksChoice(accepted).
+const (
+	ReviewAcceptAll = "Accept all"
+	ReviewRejectAll = "Reject all"
+)
+
+const acceptHunksPrefix = "Accept hunks "
+
+// FileChange is the proposed change to one file of a review.
+type FileChange struct {
+	Path    string     `json:"path"`
+	Created bool       `json:"created,omitempty"`
+	Deleted bool       `json:"deleted,omitempty"`
+	Hunks   []DiffHunk `json:"hunks"`
+
+	old string
+}
+
+// ChangeReview holds file changes for approval as a diff. Hunks are numbered from 1
+// across all files, in order.
+type ChangeReview struct {
+	Files []FileChange `json:"files"`
+}
+
+// HunkCount is the number of hunks over all files.
+func (r *ChangeReview) HunkCount() int {
+	n := 0
+	for _, f := range r.Files {
+		n += len(f.Hunks)
+	}
+	return n
+}
+
+// String renders the review as a unified diff.
+func (r *ChangeReview) String() string {
+	var sb strings.Builder
+	for _, f := range r.Files {
+		from, to := "a/"+f.Path, "b/"+f.Path
+		if f.Created {
+			from = "/dev/null"
+		}
+		if f.Deleted {
+			to = "/dev/null"
+		}
+		sb.WriteString(fmt.Sprintf("--- %s\n+++ %s\n", from, to))
+		for _, h := range f.Hunks {
+			sb.WriteString(h.String())
+		}
+	}
+	return sb.String()
+}
+
+// AcceptHunksChoice is the intervention choice that applies only the accepted hunks.
+func AcceptHunksChoice(accepted []bool) string {
+	var picked []string
+	for i, ok := range accepted {
+		if ok {
+			picked = append(picked, strconv.Itoa(i+1))
+		}
+	}
+	if len(picked) == 0 {
+		return ReviewRejectAll
+	}
+	if len(picked) == len(accepted) {
+		return ReviewAcceptAll
+	}
+	return acceptHunksPrefix + strings.Join(picked, ",")
+}
+
+// acceptedHunks parses a review choice into the accepted state of each of n hunks.
+func acceptedHunks(choice string, n int) ([]bool, error) {
+	accepted := make([]bool, n)
+	switch {
+	case choice == ReviewAcceptAll:
+		for i := range accepted {
+			accepted[i] = true
+		}
+	case choice == ReviewRejectAll:
+	case strings.HasPrefix(choice, acceptHunksPrefix):
+		for _, field := range strings.Split(strings.TrimPrefix(choice, acceptHunksPrefix), ",") {
+			i, err := strconv.Atoi(strings.TrimSpace(field))
+			if err != nil || i < 1 || i > n {
+				return nil, fmt.Errorf("invalid hunk %q (have %d)", field, n)
+			}
+			accepted[i-1] = true
+		}
+	default:
+		return nil, fmt.Errorf("unknown review choice %q", choice)
+	}
+	return accepted, nil
+}
+
+// ReviewFS holds writes, edits and deletes for review while enabled reports true: instead
+// of changing files it returns an InterventionError with the diff, whose Resume applies
+// the accepted hunks through the wrapped FS.
+type ReviewFS struct {
+	sys.FS
+	enabled func() bool
+}
+
+func NewReviewFS(f sys.FS, enabled func() bool) *ReviewFS {
+	return &ReviewFS{FS: f, enabled: enabled}
+}
+
+func (f *ReviewFS) WriteFile(path string, content []byte) error {
+	if !f.enabled() {
+		return f.FS.WriteFile(path, content)
+	}
+	return f.review([]sys.BatchOp{{Type: sys.OpWrite, Path: path, Content: content}})
+}
+
+func (f *ReviewFS) DeleteFile(path string) error {
+	if !f.enabled() {
+		return f.FS.DeleteFile(path)
+	}
+	return f.review([]sys.BatchOp{{Type: sys.OpDelete, Path: path}})
+}
+
+func (f *ReviewFS) Edit(path string, oldStr, newStr string) error {
+	if !f.enabled() {
+		return f.FS.Edit(path, oldStr, newStr)
+	}
+	content, err := f.FS.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if !strings.Contains(string(content), oldStr) {
+		return fmt.Errorf("string not found in file")
+	}
+	updated := strings.ReplaceAll(string(content), oldStr, newStr)
+	return f.review([]sys.BatchOp{{Type: sys.OpWrite, Path: path, Content: []byte(updated)}})
+}
+
+func (f *ReviewFS) Batch(ops []sys.BatchOp) error {
+	if !f.enabled() {
+		return f.FS.Batch(ops)
+	}
+	return f.review(ops)
+}
+
+// review turns ops into a ChangeReview. Later ops on the same path build on earlier ones.
+func (f *ReviewFS) review(ops []sys.BatchOp) error {
+	type proposal struct {
+		old, new        string
+		existed, delete bool
+	}
+	var order []string
+	proposals := map[string]*proposal{}
+	for _, op := range ops {
+		p, ok := proposals[op.Path]
+		if !ok {
+			data, err := f.FS.ReadFile(op.Path)
+			if err != nil && !errors.Is(err, os.ErrNotExist) {
+				return err
+			}
+			p = &proposal{old: string(data), new: string(data), existed: err == nil}
+			proposals[op.Path] = p
+			order = append(order, op.Path)
+		}
+		switch op.Type {
+		case sys.OpWrite:
+			p.new, p.delete = string(op.Content), false
+		case sys.OpDelete:
+			p.new, p.delete = "", true
+		}
+	}
+
+	review := &ChangeReview{}
+	risk := "medium"
+	for _, path := range order {
+		p := proposals[path]
+		if p.delete && !p.existed {
+			return fmt.Errorf("remove %s: %w", path, os.ErrNotExist)
+		}
+		change := FileChange{Path: path, Created: !p.existed, Deleted: p.delete, Hunks: diffHunks(p.old, p.new), old: p.old}
+		if len(change.Hunks) == 0 {
+			if !change.Created && !change.Deleted {
+				continue // nothing would change
+			}
+			// Creating or removing an empty file still needs a hunk to accept or reject.
+			change.Hunks = []DiffHunk{{}}
+		}
+		if change.Deleted {
+			risk = "high"
+		}
+		review.Files = append(review.Files, change)
+	}
+	if len(review.Files) == 0 {
+		return nil
+	}
+
+	title := fmt.Sprintf("Review changes to %s (%d hunks)", review.Files[0].Path, review.HunkCount())
+	if len(review.Files) > 1 {
+		title = fmt.Sprintf("Review changes to %d files (%d hunks)", len(review.Files), review.HunkCount())
+	}
+	return &InterventionError{
+		Title:   title,
+		Choices: []string{ReviewAcceptAll, ReviewRejectAll},
+		Risk:    risk,
+		Review:  review,
+		Resume: func(ctx context.Context, choice string) (*ToolResult, error) {
+			accepted, err := acceptedHunks(choice, review.HunkCount())
+			if err != nil {
+				return nil, err
+			}
+			return f.apply(review, accepted)
+		},
+	}
+}
+
+// apply writes the accepted hunks of each file of a review through the wrapped FS.
+func (f *ReviewFS) apply(review *ChangeReview, accepted []bool) (*ToolResult, error) {
+	var summary, artifacts []string
+	applied, offset := 0, 0
+	for _, file := range review.Files {
+		accept := accepted[offset : offset+len(file.Hunks)]
+		offset += len(file.Hunks)
+
+		n := 0
+		for _, ok := range accept {
+			if ok {
+				n++
+			}
+		}
+		summary = append(summary, fmt.Sprintf("%s (%d/%d hunks)", file.Path, n, len(file.Hunks)))
+		if n == 0 {
+			continue
+		}
+
+		var err error
+		if file.Deleted {
+			err = f.FS.DeleteFile(file.Path)
+		} else {
+			err = f.FS.WriteFile(file.Path, []byte(applyHunks(file.old, file.Hunks, accept)))
+		}
+		if err != nil {
+			return &ToolResult{Status: "error", Error: err}, err
+		}
+		applied += n
+		artifacts = append(artifacts, file.Path)
+	}
+
+	if applied == 0 {
+		return nil, fmt.Errorf("user rejected the changes to %s", strings.Join(reviewPaths(review), ", "))
+	}
+	return &ToolResult{
+		Status:    "success",
+		Content:   fmt.Sprintf("Applied %d of %d hunks after review: %s", applied, len(accepted), strings.Join(summary, ", ")),
+		Artifacts: artifacts,
+	}, nil
+}
+
+func reviewPaths(review *ChangeReview) []string {
+	paths := make([]string, len(review.Files))
+	for i, f := range review.Files {
+		paths[i] = f.Path
+	}
+	return paths
+}
@@ -0,0 +1,181 @@
+package tooling
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/nathfavour/vibeauracle/sys"
+)
+
+func TestAcceptedHunks(t *testing.T) {
+	tests := []struct {
+		choice string
+		want   []bool
+		err    bool
+	}{
+		{ReviewAcceptAll, []bool{true, true, true}, false},
+		{ReviewRejectAll, []bool{false, false, false}, false},
+		{"Accept hunks 1,3", []bool{true, false, true}, false},
+		{"Accept hunks 2, 3", []bool{false, true, true}, false},
+		{"Accept hunks 0", nil, true},
+		{"Accept hunks 4", nil, true},
+		{"Accept hunks x", nil, true},
+		{"Approve Once", nil, true},
+	}
+	for _, tt := range tests {
+		got, err := acceptedHunks(tt.choice, 3)
+		if (err != nil) != tt.err {
+			t.Errorf("%q: error = %v", tt.choice, err)
+			continue
+		}
+		if !tt.err && boolsString(got) != boolsString(tt.want) {
+			t.Errorf("%q: got %v, want %v", tt.choice, got, tt.want)
+		}
+	}
+
+	for _, accepted := range [][]bool{{true, true, true}, {false, false, false}, {true, false, true}, {false, true, false}} {
+		got, err := acceptedHunks(AcceptHunksChoice(accepted), len(accepted))
+		if err != nil || boolsString(got) != boolsString(accepted) {
+			t.Errorf("%v does not round-trip through %q: %v, %v", accepted, AcceptHunksChoice(accepted), got, err)
+		}
+	}
+}
+
+func boolsString(b []bool) string {
+	var sb strings.Builder
+	for _, v := range b {
+		if v {
+			sb.WriteByte('1')
+		} else {
+			sb.WriteByte('0')
+		}
+	}
+	return sb.String()
+}
+
+// newTestReview returns a reviewing FS over a temporary directory, with reviews on.
+func newTestReview(t *testing.T) (*ReviewFS, string) {
+	t.Helper()
+	root := t.TempDir()
+	return NewReviewFS(sys.NewLocalFS(root), func() bool { return true }), root
+}
+
+// heldForReview returns the intervention a change raised.
+func heldForReview(t *testing.T, err error) *InterventionError {
+	t.Helper()
+	var iv *InterventionError
+	if !errors.As(err, &iv) || iv.Review == nil {
+		t.Fatalf("expected the change to be held for review, got %v", err)
+	}
+	return iv
+}
+
+func TestReviewFS_PartialAcceptance(t *testing.T) {
+	fs, root := newTestReview(t)
+	path := filepath.Join(root, "main.go")
+	base := numbered(30)
+	writeTestFile(t, path, strings.Join(base, ""), 0644)
+
+	err := fs.WriteFile("main.go", []byte(replaced(base, map[int]string{3: "three\n", 25: "twenty-five\n"})))
+	iv := heldForReview(t, err)
+	if got := readTestFile(t, path); got != strings.Join(base, "") {
+		t.Fatal("a reviewed change must not touch the file before it is answered")
+	}
+	if iv.Review.HunkCount() != 2 || iv.Risk != "medium" || !strings.Contains(iv.Title, "main.go (2 hunks)") {
+		t.Fatalf("unexpected review: %s %+v", iv.Title, iv.Review)
+	}
+	if diff := iv.Review.String(); !strings.HasPrefix(diff, "--- a/main.go\n+++ b/main.go\n@@ -1,6 +1,6 @@") || !strings.Contains(diff, "+twenty-five") {
+		t.Errorf("unexpected diff:\n%s", diff)
+	}
+
+	res, err := iv.Resume(context.Background(), AcceptHunksChoice([]bool{false, true}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(res.Content, "Applied 1 of 2 hunks") || len(res.Artifacts) != 1 {
+		t.Errorf("unexpected result: %+v", res)
+	}
+	if got := readTestFile(t, path); got != replaced(base, map[int]string{25: "twenty-five\n"}) {
+		t.Errorf("only the second hunk should be applied, got:\n%s", got)
+	}
+}
+
+func TestReviewFS_RejectAll(t *testing.T) {
+	fs, root := newTestReview(t)
+	path := filepath.Join(root, "a.txt")
+	writeTestFile(t, path, "keep\n", 0644)
+
+	iv := heldForReview(t, fs.Edit("a.txt", "keep", "drop"))
+	if _, err := iv.Resume(context.Background(), ReviewRejectAll); err == nil || !strings.Contains(err.Error(), "rejected the changes to a.txt") {
+		t.Errorf("expected a rejection error, got %v", err)
+	}
+	if got := readTestFile(t, path); got != "keep\n" {
+		t.Errorf("a rejected change must not be applied, got %q", got)
+	}
+	if _, err := iv.Resume(context.Background(), "maybe"); err == nil {
+		t.Error("expected an unknown choice to be refused")
+	}
+	if err := fs.Edit("a.txt", "missing", "x"); err == nil || errors.As(err, new(*InterventionError)) {
+		t.Errorf("an edit that cannot apply should fail before review, got %v", err)
+	}
+}
+
+func TestReviewFS_BatchAcrossFiles(t *testing.T) {
+	fs, root := newTestReview(t)
+	writeTestFile(t, filepath.Join(root, "old.txt"), "bye\n", 0644)
+	writeTestFile(t, filepath.Join(root, "same.txt"), "same\n", 0644)
+
+	err := fs.Batch([]sys.BatchOp{
+		{Type: sys.OpWrite, Path: "new.txt", Content: []byte("draft\n")},
+		{Type: sys.OpWrite, Path: "new.txt", Content: []byte("final\n")}, // builds on the op before it
+		{Type: sys.OpWrite, Path: "empty.txt"},
+		{Type: sys.OpWrite, Path: "same.txt", Content: []byte("same\n")}, // no change, left out
+		{Type: sys.OpDelete, Path: "old.txt"},
+	})
+	iv := heldForReview(t, err)
+	files := iv.Review.Files
+	if len(files) != 3 || iv.Review.HunkCount() != 3 || iv.Risk != "high" {
+		t.Fatalf("unexpected review (risk %s): %+v", iv.Risk, files)
+	}
+	if !files[0].Created || !files[1].Created || !files[2].Deleted {
+		t.Errorf("expected two created files and a deleted one, got %+v", files)
+	}
+	if diff := iv.Review.String(); !strings.Contains(diff, "--- /dev/null\n+++ b/new.txt\n@@ -0,0 +1,1 @@\n+final\n") || !strings.Contains(diff, "--- a/old.txt\n+++ /dev/null\n") {
+		t.Errorf("unexpected diff:\n%s", diff)
+	}
+
+	// Hunks are numbered across files: accept the new file and the deletion only.
+	if _, err := iv.Resume(context.Background(), "Accept hunks 1,3"); err != nil {
+		t.Fatal(err)
+	}
+	if got := readTestFile(t, filepath.Join(root, "new.txt")); got != "final\n" {
+		t.Errorf("new.txt = %q", got)
+	}
+	for _, name := range []string{"empty.txt", "old.txt"} {
+		if _, err := os.Stat(filepath.Join(root, name)); !os.IsNotExist(err) {
+			t.Errorf("%s should not exist", name)
+		}
+	}
+
+	if err := fs.Batch([]sys.BatchOp{{Type: sys.OpDelete, Path: "missing.txt"}}); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("deleting a missing file should fail, got %v", err)
+	}
+	if err := fs.WriteFile("same.txt", []byte("same\n")); err != nil {
+		t.Errorf("a write that changes nothing needs no review, got %v", err)
+	}
+}
+
+func TestReviewFS_Disabled(t *testing.T) {
+	root := t.TempDir()
+	fs := NewReviewFS(sys.NewLocalFS(root), func() bool { return false })
+	if err := fs.WriteFile("a.txt", []byte("direct\n")); err != nil {
+		t.Fatal(err)
+	}
+	if got := readTestFile(t, filepath.Join(root, "a.txt")); got != "direct\n" {
+		t.Errorf("writes should pass through while review is off, got %q", got)
+	}
+}
@@ -0,0 +1,237 @@
+package tooling
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// SandboxMode is how strongly a sandboxed command was confined.
+type SandboxMode string
+
+const (
+	// SandboxIsolated runs the command in its own user, mount, PID and (unless the
+	// network is allowed) network namespaces, on top of everything SandboxRestricted does.
+	SandboxIsolated SandboxMode = "isolated"
+	// SandboxRestricted is the fallback when namespaces are unavailable (e.g. unprivileged
+	// user namespaces are disabled, or inside some containers): rlimits, a seccomp filter,
+	// cgroup limits where delegated, a scrubbed environment and output caps still apply,
+	// but the network stays reachable and ReadOnly is not enforced.
+	SandboxRestricted SandboxMode = "restricted"
+	// SandboxBasic is used on platforms other than Linux: only the scrubbed environment,
+	// the timeout and the output cap apply.
+	SandboxBasic SandboxMode = "basic"
+)
+
+// sandboxSetupExit is the exit status of a sandbox that failed before running the command.
+const sandboxSetupExit = 125
+
+// DefaultSandboxEnv lists the environment variables passed into a sandbox. Globs are
+// allowed; everything else, notably tokens and credentials, is dropped.
+var DefaultSandboxEnv = []string{
+	"PATH", "HOME", "USER", "LOGNAME", "SHELL", "TERM", "LANG", "LC_*", "TZ", "TMPDIR",
+	"GOPATH", "GOROOT", "GOCACHE", "GOMODCACHE", "GOFLAGS", "GOPROXY", "CARGO_HOME", "RUSTUP_HOME",
+}
+
+// SandboxPolicy describes how to confine a command. Zero limits are not enforced.
+type SandboxPolicy struct {
+	// Root is the project directory. Commands start in it and it stays writable.
+	Root string
+	// ReadOnly mounts the filesystem outside Root, the temporary directory and
+	// Writable read-only.
+	ReadOnly bool
+	// Writable lists extra paths that stay writable when ReadOnly is set, e.g. build caches.
+	Writable []string
+	// Network allows network access; otherwise the command gets an empty network
+	// namespace with only loopback.
+	Network bool
+
+	MaxMemory  int64         // bytes of data memory (cgroup memory.max, else RLIMIT_DATA)
+	MaxCPUTime time.Duration // CPU time (RLIMIT_CPU)
+	MaxProcs   int           // processes (cgroup pids.max, else RLIMIT_NPROC on top of the user's own)
+	MaxOutput  int           // bytes of combined output kept
+	Timeout    time.Duration // wall-clock time
+
+	// Env lists the environment variables passed through, as names or globs.
+	Env []string
+}
+
+// DefaultSandboxPolicy confines commands to root: no network, 2GB of memory, 10
+// minutes of CPU, 512 processes and 1MB of output.
+func DefaultSandboxPolicy(root string) SandboxPolicy {
+	return SandboxPolicy{
+		Root:       root,
+		MaxMemory:  2 << 30,
+		MaxCPUTime: 10 * time.Minute,
+		MaxProcs:   512,
+		MaxOutput:  1 << 20,
+		Env:        DefaultSandboxEnv,
+	}
+}
+
+// SandboxCommand is a command to run in a sandbox.
+type SandboxCommand struct {
+	Path  string   // program, looked up in PATH unless it contains a slash
+	Args  []string // arguments, without the program name
+	Dir   string   // working directory, relative to the policy's Root; Root if empty
+	Env   []string // KEY=VALUE pairs added after scrubbing
+	Stdin io.Reader
+}
+
+// SandboxResult is the outcome of a sandboxed command.
+type SandboxResult struct {
+	Output    string // combined stdout and stderr, capped at MaxOutput
+	Truncated bool
+	ExitCode  int
+	Mode      SandboxMode
+	// Notes explain protections that could not be applied.
+	Notes    []string
+	Duration time.Duration
+}
+
+// Run executes c under the policy. Like exec.Cmd.CombinedOutput, it returns the output
+// together with an error when the command could not start, failed or timed out.
+func (p SandboxPolicy) Run(ctx context.Context, c SandboxCommand) (*SandboxResult, error) {
+	root := p.Root
+	if root == "" {
+		root, _ = os.Getwd()
+	}
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+	p.Root = root
+	dir := root
+	if c.Dir != "" {
+		dir = c.Dir
+		if !filepath.IsAbs(dir) {
+			dir = filepath.Join(root, dir)
+		}
+	}
+
+	env := append(scrubEnv(os.Environ(), p.Env), c.Env...)
+	path := c.Path
+	if !strings.Contains(path, "/") {
+		if path, err = lookPath(c.Path, env); err != nil {
+			return nil, err
+		}
+	}
+
+	if p.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.Timeout)
+		defer cancel()
+	}
+
+	out := &cappedBuffer{limit: p.MaxOutput}
+	x := sandboxExec{path: path, args: append([]string{c.Path}, c.Args...), dir: dir}
+	x.command = func(name string, args ...string) *exec.Cmd {
+		cmd := exec.CommandContext(ctx, name, args...)
+		cmd.Dir = dir
+		cmd.Env = env
+		cmd.Stdin = c.Stdin
+		cmd.Stdout = out
+		cmd.Stderr = out
+		// Grandchildren holding the output open must not hang the caller after a kill.
+		cmd.WaitDelay = 2 * time.Second
+		return cmd
+	}
+
+	start := time.Now()
+	res := &SandboxResult{}
+	state, err := p.run(x, res)
+	res.Duration = time.Since(start)
+	res.Output, res.Truncated = out.String(), out.dropped > 0
+	if state != nil {
+		res.ExitCode = state.ExitCode()
+	}
+
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) && p.Timeout > 0 {
+		return res, fmt.Errorf("command timed out after %v", p.Timeout)
+	}
+	if res.ExitCode == sandboxSetupExit && strings.HasPrefix(res.Output, sandboxErrPrefix) {
+		return res, errors.New(strings.TrimSpace(res.Output))
+	}
+	return res, err
+}
+
+// sandboxExec is a resolved command for the platform runner, which starts it, or the
+// helper that sets up the sandbox, through command.
+type sandboxExec struct {
+	path    string
+	args    []string // including the program name
+	dir     string
+	command func(name string, args ...string) *exec.Cmd
+}
+
+// sandboxErrPrefix starts the message of a sandbox that could not be set up.
+const sandboxErrPrefix = "vibeaura sandbox: "
+
+// scrubEnv keeps the variables of env whose names match one of the allowed globs.
+func scrubEnv(env, allowed []string) []string {
+	var kept []string
+	for _, kv := range env {
+		name, _, _ := strings.Cut(kv, "=")
+		for _, pattern := range allowed {
+			if ok, _ := filepath.Match(pattern, name); ok {
+				kept = append(kept, kv)
+				break
+			}
+		}
+	}
+	return kept
+}
+
+// lookPath finds a program in the PATH of env, which may differ from our own.
+func lookPath(name string, env []string) (string, error) {
+	var pathEnv string
+	for _, kv := range env {
+		if v, ok := strings.CutPrefix(kv, "PATH="); ok {
+			pathEnv = v
+		}
+	}
+	for _, dir := range filepath.SplitList(pathEnv) {
+		if dir == "" {
+			dir = "."
+		}
+		candidate := filepath.Join(dir, name)
+		if info, err := os.Stat(candidate); err == nil && !info.IsDir() && info.Mode()&0111 != 0 {
+			return candidate, nil
+		}
+	}
+	return "", &exec.Error{Name: name, Err: exec.ErrNotFound}
+}
+
+// cappedBuffer keeps the first limit bytes written to it and counts the rest.
+type cappedBuffer struct {
+	buf     bytes.Buffer
+	limit   int // 0 keeps everything
+	dropped int
+}
+
+func (b *cappedBuffer) Write(p []byte) (int, error) {
+	n := len(p)
+	if b.limit > 0 {
+		room := max(0, b.limit-b.buf.Len())
+		if len(p) > room {
+			b.dropped += len(p) - room
+			p = p[:room]
+		}
+	}
+	b.buf.Write(p)
+	return n, nil
+}
+
+func (b *cappedBuffer) String() string {
+	if b.dropped == 0 {
+		return b.buf.String()
+	}
+	return fmt.Sprintf("%s\n[output truncated: %d more bytes]", b.buf.String(), b.dropped)
+}
@@ -0,0 +1,584 @@
+package tooling
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// The sandbox is set up by a copy of the running binary: it is started in the new
+// namespaces with the spec in its environment, and the init function below applies
+// mounts, limits and the seccomp filter before it execs the command. Go cannot run code
+// between fork and exec, and the mounts must happen inside the mount namespace.
+const (
+	sandboxSpecEnv = "VIBEAURA_SANDBOX_SPEC"
+	sandboxArg0    = "vibeaura-sandbox"
+)
+
+// sandboxSpec is what the helper needs to set up the sandbox.
+type sandboxSpec struct {
+	Path string   `json:"path"`
+	Args []string `json:"args"`
+	Dir  string   `json:"dir"`
+
+	MountNS  bool     `json:"mount_ns,omitempty"`
+	PIDNS    bool     `json:"pid_ns,omitempty"`
+	Loopback bool     `json:"loopback,omitempty"` // bring up lo in a new network namespace
+	ReadOnly bool     `json:"read_only,omitempty"`
+	Writable []string `json:"writable,omitempty"`
+
+	Memory     uint64 `json:"memory,omitempty"`      // RLIMIT_DATA, when no cgroup limits memory
+	CPUSeconds uint64 `json:"cpu_seconds,omitempty"` // RLIMIT_CPU
+	Procs      uint64 `json:"procs,omitempty"`       // RLIMIT_NPROC, when no cgroup limits processes
+}
+
+func init() {
+	encoded, ok := os.LookupEnv(sandboxSpecEnv)
+	if !ok || len(os.Args) == 0 || os.Args[0] != sandboxArg0 {
+		return
+	}
+	// The seccomp filter applies to the thread that installs it, which must be the
+	// one that execs.
+	runtime.LockOSThread()
+	os.Unsetenv(sandboxSpecEnv)
+	err := sandboxInit(encoded)
+	fmt.Fprintf(os.Stderr, "%s%v\n", sandboxErrPrefix, err)
+	os.Exit(sandboxSetupExit)
+}
+
+// sandboxInit sets up the sandbox described by encoded and execs its command. It only
+// returns on failure.
+func sandboxInit(encoded string) error {
+	var spec sandboxSpec
+	if err := json.Unmarshal([]byte(encoded), &spec); err != nil {
+		return fmt.Errorf("reading spec: %w", err)
+	}
+	if spec.Loopback {
+		if err := loopbackUp(); err != nil {
+			return fmt.Errorf("bringing up loopback: %w", err)
+		}
+	}
+	if spec.MountNS {
+		if err := spec.mount(); err != nil {
+			return err
+		}
+	}
+	// Re-enter the directory so it resolves through the new mounts.
+	if err := os.Chdir(spec.Dir); err != nil {
+		return err
+	}
+	if err := spec.setLimits(); err != nil {
+		return err
+	}
+	if err := installSeccomp(); err != nil {
+		return fmt.Errorf("installing seccomp filter: %w", err)
+	}
+	if err := syscall.Exec(spec.Path, spec.Args, os.Environ()); err != nil {
+		return fmt.Errorf("exec %s: %w", spec.Path, err)
+	}
+	return nil
+}
+
+// mount makes the mounts of the sandbox private and, for ReadOnly, remounts everything
+// but the writable paths read-only.
+func (s *sandboxSpec) mount() error {
+	if err := unix.Mount("", "/", "", unix.MS_REC|unix.MS_PRIVATE, ""); err != nil {
+		return fmt.Errorf("making mounts private: %w", err)
+	}
+	if s.PIDNS {
+		// Best effort: it fails where parts of /proc are masked, as in Docker.
+		_ = unix.Mount("proc", "/proc", "proc", unix.MS_NOSUID|unix.MS_NODEV|unix.MS_NOEXEC, "")
+	}
+	if !s.ReadOnly {
+		return nil
+	}
+
+	// Writable paths get their own bind mounts first, so remounting the filesystems
+	// that contain them read-only leaves them alone.
+	for _, p := range s.Writable {
+		if err := unix.Mount(p, p, "", unix.MS_BIND|unix.MS_REC, ""); err != nil {
+			return fmt.Errorf("binding %s: %w", p, err)
+		}
+	}
+	mounts, err := readMountinfo()
+	if err != nil {
+		return err
+	}
+	keep := append([]string{"/proc", "/sys", "/dev"}, s.Writable...)
+	for _, m := range mounts {
+		if withinAny(m.point, keep) {
+			continue
+		}
+		// Flags locked by the parent namespace (nosuid, nodev, ...) must be kept.
+		if err := unix.Mount("", m.point, "", unix.MS_REMOUNT|unix.MS_BIND|unix.MS_RDONLY|m.flags, ""); err != nil {
+			if errors.Is(err, unix.ENOENT) {
+				continue // hidden under a later mount
+			}
+			return fmt.Errorf("remounting %s read-only: %w", m.point, err)
+		}
+	}
+	return nil
+}
+
+type mountPoint struct {
+	point string
+	flags uintptr
+}
+
+// readMountinfo lists the mount points of the current mount namespace with the flags
+// of their per-mount options.
+func readMountinfo() ([]mountPoint, error) {
+	data, err := os.ReadFile("/proc/self/mountinfo")
+	if err != nil {
+		return nil, err
+	}
+	optionFlags := map[string]uintptr{
+		"nosuid": unix.MS_NOSUID, "nodev": unix.MS_NODEV, "noexec": unix.MS_NOEXEC,
+		"noatime": unix.MS_NOATIME, "nodiratime": unix.MS_NODIRATIME, "relatime": unix.MS_RELATIME,
+		"strictatime": unix.MS_STRICTATIME,
+	}
+	var mounts []mountPoint
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 6 {
+			continue
+		}
+		m := mountPoint{point: unescapeMountinfo(fields[4])}
+		for _, opt := range strings.Split(fields[5], ",") {
+			m.flags |= optionFlags[opt]
+		}
+		mounts = append(mounts, m)
+	}
+	return mounts, nil
+}
+
+// unescapeMountinfo decodes the octal escapes (\040 for a space) of a mountinfo path.
+func unescapeMountinfo(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+3 < len(s) {
+			if n, err := strconv.ParseUint(s[i+1:i+4], 8, 8); err == nil {
+				b.WriteByte(byte(n))
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// loopbackUp brings up lo, which starts down in a new network namespace, so local
+// servers and tests still work without a network.
+func loopbackUp() error {
+	fd, err := unix.Socket(unix.AF_INET, unix.SOCK_DGRAM|unix.SOCK_CLOEXEC, 0)
+	if err != nil {
+		return err
+	}
+	defer unix.Close(fd)
+	ifr, err := unix.NewIfreq("lo")
+	if err != nil {
+		return err
+	}
+	if err := unix.IoctlIfreq(fd, unix.SIOCGIFFLAGS, ifr); err != nil {
+		return err
+	}
+	ifr.SetUint16(ifr.Uint16() | unix.IFF_UP)
+	return unix.IoctlIfreq(fd, unix.SIOCSIFFLAGS, ifr)
+}
+
+// setLimits applies the rlimits of the spec, never raising a hard limit.
+func (s *sandboxSpec) setLimits() error {
+	for _, l := range []struct {
+		resource int
+		name     string
+		value    uint64
+	}{
+		{unix.RLIMIT_DATA, "memory", s.Memory},
+		{unix.RLIMIT_CPU, "CPU time", s.CPUSeconds},
+		{unix.RLIMIT_NPROC, "process", s.Procs},
+	} {
+		if l.value == 0 {
+			continue
+		}
+		var cur unix.Rlimit
+		if err := unix.Getrlimit(l.resource, &cur); err != nil {
+			return fmt.Errorf("reading %s limit: %w", l.name, err)
+		}
+		v := min(l.value, cur.Max)
+		if err := unix.Setrlimit(l.resource, &unix.Rlimit{Cur: v, Max: v}); err != nil {
+			return fmt.Errorf("setting %s limit: %w", l.name, err)
+		}
+	}
+	return nil
+}
+
+// seccompBlocked are the system calls a sandboxed command may not make: those that
+// could undo the read-only mounts or affect the whole machine. They fail with EPERM.
+var seccompBlocked = []uint32{
+	unix.SYS_MOUNT, unix.SYS_UMOUNT2, unix.SYS_PIVOT_ROOT, unix.SYS_MOUNT_SETATTR,
+	unix.SYS_OPEN_TREE, unix.SYS_MOVE_MOUNT, unix.SYS_FSOPEN, unix.SYS_FSCONFIG,
+	unix.SYS_FSMOUNT, unix.SYS_FSPICK,
+	unix.SYS_KEXEC_LOAD, unix.SYS_INIT_MODULE, unix.SYS_FINIT_MODULE, unix.SYS_DELETE_MODULE,
+	unix.SYS_REBOOT, unix.SYS_SWAPON, unix.SYS_SWAPOFF, unix.SYS_ACCT,
+}
+
+// seccompArchs maps GOARCH to the audit architecture the filter checks. On other
+// architectures no filter is installed.
+var seccompArchs = map[string]uint32{
+	"amd64":   unix.AUDIT_ARCH_X86_64,
+	"arm64":   unix.AUDIT_ARCH_AARCH64,
+	"riscv64": unix.AUDIT_ARCH_RISCV64,
+	"ppc64le": unix.AUDIT_ARCH_PPC64LE,
+	"s390x":   unix.AUDIT_ARCH_S390X,
+}
+
+// installSeccomp installs the filter of seccompFilter for this architecture.
+func installSeccomp() error {
+	filter := seccompFilter(runtime.GOARCH)
+	if filter == nil {
+		return nil
+	}
+	// Without no_new_privs an unprivileged process may not install a filter; it also
+	// keeps setuid binaries such as sudo from gaining privileges inside the sandbox.
+	if err := unix.Prctl(unix.PR_SET_NO_NEW_PRIVS, 1, 0, 0, 0); err != nil {
+		return err
+	}
+	prog := unix.SockFprog{Len: uint16(len(filter)), Filter: &filter[0]}
+	return unix.Prctl(unix.PR_SET_SECCOMP, unix.SECCOMP_MODE_FILTER, uintptr(unsafe.Pointer(&prog)), 0, 0)
+}
+
+// seccompFilter returns a filter that denies seccompBlocked, and every system call of
+// a foreign architecture (e.g. 32-bit calls on amd64), which would bypass the list. It
+// is nil for architectures not in seccompArchs.
+func seccompFilter(goarch string) []unix.SockFilter {
+	arch, ok := seccompArchs[goarch]
+	if !ok {
+		return nil
+	}
+	stmt := func(code uint16, k uint32) unix.SockFilter { return unix.SockFilter{Code: code, K: k} }
+	jump := func(code uint16, k uint32, jt, jf uint8) unix.SockFilter {
+		return unix.SockFilter{Code: code, Jt: jt, Jf: jf, K: k}
+	}
+	n := len(seccompBlocked)
+	deny := unix.SECCOMP_RET_ERRNO | uint32(unix.EPERM)&unix.SECCOMP_RET_DATA
+
+	filter := []unix.SockFilter{
+		stmt(unix.BPF_LD|unix.BPF_W|unix.BPF_ABS, 4), // seccomp_data.arch
+		jump(unix.BPF_JMP|unix.BPF_JEQ|unix.BPF_K, arch, 1, 0),
+		stmt(unix.BPF_RET|unix.BPF_K, deny),
+		stmt(unix.BPF_LD|unix.BPF_W|unix.BPF_ABS, 0), // seccomp_data.nr
+	}
+	if goarch == "amd64" {
+		// x32 calls share the architecture but set bit 30 of the number.
+		filter = append(filter, jump(unix.BPF_JMP|unix.BPF_JGE|unix.BPF_K, 0x40000000, uint8(n+1), 0))
+	}
+	for i, nr := range seccompBlocked {
+		filter = append(filter, jump(unix.BPF_JMP|unix.BPF_JEQ|unix.BPF_K, nr, uint8(n-i), 0))
+	}
+	return append(filter,
+		stmt(unix.BPF_RET|unix.BPF_K, unix.SECCOMP_RET_ALLOW),
+		stmt(unix.BPF_RET|unix.BPF_K, deny),
+	)
+}
+
+var (
+	// namespaceFailure records why namespaces could not be created, so later commands
+	// go straight to the restricted mode.
+	namespaceFailure atomic.Pointer[string]
+	namespaceReport  sync.Once
+)
+
+// ExpectedMode is the mode the next command run under p gets: isolated, unless creating
+// namespaces has already failed and commands fall back to the restricted mode.
+func (p SandboxPolicy) ExpectedMode() SandboxMode {
+	if namespaceFailure.Load() != nil {
+		return SandboxRestricted
+	}
+	return SandboxIsolated
+}
+
+// run starts the sandbox helper in new namespaces, falling back to the restricted mode
+// when they cannot be created.
+func (p SandboxPolicy) run(x sandboxExec, res *SandboxResult) (*os.ProcessState, error) {
+	spec := sandboxSpec{Path: x.path, Args: x.args, Dir: x.dir, ReadOnly: p.ReadOnly}
+	if p.MaxCPUTime > 0 {
+		spec.CPUSeconds = uint64(max(1, p.MaxCPUTime.Seconds()))
+	}
+	if p.ReadOnly {
+		for _, w := range append([]string{p.Root, os.TempDir()}, p.Writable...) {
+			if real, err := filepath.EvalSymlinks(w); err == nil {
+				spec.Writable = append(spec.Writable, real)
+			}
+		}
+	}
+
+	cg, err := newSandboxCgroup(p)
+	if err != nil {
+		res.Notes = append(res.Notes, fmt.Sprintf("cgroup limits unavailable (%v); using rlimits", err))
+	}
+	if cg != nil {
+		defer cg.remove()
+	}
+	rlimitFallback := func() {
+		spec.Memory = uint64(max(0, p.MaxMemory))
+		if p.MaxProcs > 0 {
+			// RLIMIT_NPROC counts every process of the user, not just the sandbox's.
+			spec.Procs = uint64(userTasks() + p.MaxProcs)
+		}
+	}
+	if cg == nil {
+		rlimitFallback()
+	}
+
+	flags := uintptr(unix.CLONE_NEWUSER | unix.CLONE_NEWNS | unix.CLONE_NEWPID)
+	if !p.Network {
+		flags |= unix.CLONE_NEWNET
+	}
+	if reason := namespaceFailure.Load(); reason != nil {
+		flags = 0
+		res.Notes = append(res.Notes, *reason)
+	}
+
+	for {
+		spec.MountNS = flags&unix.CLONE_NEWNS != 0
+		spec.PIDNS = flags&unix.CLONE_NEWPID != 0
+		spec.Loopback = flags&unix.CLONE_NEWNET != 0
+		cmd, err := startSandbox(x, spec, flags, cg)
+		if err == nil {
+			res.Mode = SandboxRestricted
+			if flags != 0 {
+				res.Mode = SandboxIsolated
+			}
+			err = cmd.Wait()
+			return cmd.ProcessState, err
+		}
+
+		switch nextSandboxRetry(err, flags, cg != nil, os.Geteuid() == 0) {
+		case retryWithoutCgroup:
+			res.Notes = append(res.Notes, fmt.Sprintf("cgroup limits unavailable (%v); using rlimits", err))
+			cg.remove()
+			cg = nil
+			rlimitFallback()
+		case retryWithoutUserNS:
+			flags &^= unix.CLONE_NEWUSER
+		case retryWithoutNamespaces:
+			reason := fmt.Sprintf("namespaces unavailable (%v): the network is reachable and the filesystem is not read-only", err)
+			namespaceFailure.Store(&reason)
+			namespaceReport.Do(func() { ReportStatus("⚠️", "sandbox", "Running commands with rlimits only: "+reason) })
+			res.Notes = append(res.Notes, reason)
+			flags = 0
+		default:
+			return nil, err
+		}
+	}
+}
+
+// sandboxRetry is what run gives up after the helper failed to start.
+type sandboxRetry int
+
+const (
+	retryNone sandboxRetry = iota // the failure is not the sandbox's
+	retryWithoutCgroup
+	retryWithoutUserNS
+	retryWithoutNamespaces
+)
+
+// nextSandboxRetry decides how to retry a helper that failed to start with err under
+// the given clone flags. The cgroup goes first, as clone3, which places the process in
+// it, may be unsupported; root can do without a user namespace; and errors of a kernel
+// or container refusing namespaces fall back to the restricted mode.
+func nextSandboxRetry(err error, flags uintptr, cgroup, root bool) sandboxRetry {
+	var errno syscall.Errno
+	switch {
+	case cgroup:
+		return retryWithoutCgroup
+	case flags&unix.CLONE_NEWUSER != 0 && root:
+		return retryWithoutUserNS
+	case flags != 0 && errors.As(err, &errno) &&
+		(errno == unix.EPERM || errno == unix.EINVAL || errno == unix.ENOSPC || errno == unix.EUSERS || errno == unix.EACCES):
+		return retryWithoutNamespaces
+	}
+	return retryNone
+}
+
+// startSandbox starts the helper for spec with the given namespaces.
+func startSandbox(x sandboxExec, spec sandboxSpec, flags uintptr, cg *sandboxCgroup) (*exec.Cmd, error) {
+	encoded, err := json.Marshal(spec)
+	if err != nil {
+		return nil, err
+	}
+	// /proc/self/exe still works when the binary was replaced by an update.
+	cmd := x.command("/proc/self/exe")
+	cmd.Args = []string{sandboxArg0}
+	cmd.Env = append(cmd.Env[:len(cmd.Env):len(cmd.Env)], sandboxSpecEnv+"="+string(encoded))
+
+	attr := &syscall.SysProcAttr{Setpgid: true, Cloneflags: flags}
+	if flags&unix.CLONE_NEWUSER != 0 {
+		// Keep our IDs inside, so files keep their owners.
+		attr.UidMappings = []syscall.SysProcIDMap{{ContainerID: os.Getuid(), HostID: os.Getuid(), Size: 1}}
+		attr.GidMappings = []syscall.SysProcIDMap{{ContainerID: os.Getgid(), HostID: os.Getgid(), Size: 1}}
+	}
+	if cg != nil {
+		attr.UseCgroupFD = true
+		attr.CgroupFD = cg.fd
+	}
+	cmd.SysProcAttr = attr
+	// Kill the whole process group; with a PID namespace, killing its init kills the rest.
+	cmd.Cancel = func() error { return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL) }
+
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	return cmd, nil
+}
+
+// userTasks counts the processes and threads of our real user, which RLIMIT_NPROC
+// limits together.
+func userTasks() int {
+	uid := strconv.Itoa(os.Getuid())
+	entries, _ := os.ReadDir("/proc")
+	total := 0
+	for _, e := range entries {
+		if _, err := strconv.Atoi(e.Name()); err != nil {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join("/proc", e.Name(), "status"))
+		if err != nil {
+			continue
+		}
+		var owned bool
+		threads := 1
+		for _, line := range strings.Split(string(data), "\n") {
+			if v, ok := strings.CutPrefix(line, "Uid:"); ok {
+				fields := strings.Fields(v)
+				owned = len(fields) > 0 && fields[0] == uid
+			} else if v, ok := strings.CutPrefix(line, "Threads:"); ok {
+				threads, _ = strconv.Atoi(strings.TrimSpace(v))
+			}
+		}
+		if owned {
+			total += threads
+		}
+	}
+	return total
+}
+
+// sandboxCgroup is a cgroup v2 created for one command.
+type sandboxCgroup struct {
+	dir string
+	fd  int
+}
+
+var cgroupSeq atomic.Int64
+
+// newSandboxCgroup creates a child of our own cgroup with the memory and process limits
+// of p. It needs cgroup v2 with the controllers delegated to us, as systemd does for
+// user services; it returns nil when p sets neither limit.
+func newSandboxCgroup(p SandboxPolicy) (*sandboxCgroup, error) {
+	if p.MaxMemory <= 0 && p.MaxProcs <= 0 {
+		return nil, nil
+	}
+	parent, err := ownCgroupDir()
+	if err != nil {
+		return nil, err
+	}
+	controllers, err := os.ReadFile(filepath.Join(parent, "cgroup.subtree_control"))
+	if err != nil {
+		return nil, err
+	}
+	enabled := strings.Fields(string(controllers))
+	for _, need := range []struct {
+		name string
+		used bool
+	}{{"memory", p.MaxMemory > 0}, {"pids", p.MaxProcs > 0}} {
+		if need.used && !slices.Contains(enabled, need.name) {
+			return nil, fmt.Errorf("the %s controller is not delegated to %s", need.name, parent)
+		}
+	}
+
+	dir := filepath.Join(parent, fmt.Sprintf("vibeaura-sandbox-%d-%d", os.Getpid(), cgroupSeq.Add(1)))
+	if err := os.Mkdir(dir, 0755); err != nil {
+		return nil, err
+	}
+	cg := &sandboxCgroup{dir: dir, fd: -1}
+	write := func(file, value string) error {
+		return os.WriteFile(filepath.Join(dir, file), []byte(value), 0644)
+	}
+	if p.MaxMemory > 0 {
+		if err := write("memory.max", strconv.FormatInt(p.MaxMemory, 10)); err != nil {
+			cg.remove()
+			return nil, err
+		}
+		_ = write("memory.swap.max", "0") // absent without swap accounting
+	}
+	if p.MaxProcs > 0 {
+		if err := write("pids.max", strconv.Itoa(p.MaxProcs)); err != nil {
+			cg.remove()
+			return nil, err
+		}
+	}
+	if cg.fd, err = unix.Open(dir, unix.O_RDONLY|unix.O_DIRECTORY|unix.O_CLOEXEC, 0); err != nil {
+		cg.remove()
+		return nil, err
+	}
+	return cg, nil
+}
+
+func (c *sandboxCgroup) remove() {
+	if c.fd >= 0 {
+		unix.Close(c.fd)
+		c.fd = -1
+	}
+	os.Remove(c.dir)
+}
+
+// ownCgroupDir finds the cgroup v2 directory of this process.
+func ownCgroupDir() (string, error) {
+	data, err := os.ReadFile("/proc/self/cgroup")
+	if err != nil {
+		return "", err
+	}
+	var path string
+	for _, line := range strings.Split(string(data), "\n") {
+		if p, ok := strings.CutPrefix(line, "0::"); ok {
+			path = p
+		}
+	}
+	if path == "" {
+		return "", errors.New("no cgroup v2 hierarchy")
+	}
+
+	info, err := os.ReadFile("/proc/self/mountinfo")
+	if err != nil {
+		return "", err
+	}
+	for _, line := range strings.Split(string(info), "\n") {
+		// The filesystem type follows the " - " separator.
+		pre, post, ok := strings.Cut(line, " - ")
+		fields := strings.Fields(pre)
+		if ok && strings.HasPrefix(post, "cgroup2 ") && len(fields) >= 5 {
+			return filepath.Join(unescapeMountinfo(fields[4]), path), nil
+		}
+	}
+	return "", errors.New("cgroup2 is not mounted")
+}
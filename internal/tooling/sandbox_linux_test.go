@@ -0,0 +1,194 @@
+package tooling
+
+import (
+	"context"
+	"errors"
+	"os/exec"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// runFilter evaluates a seccomp filter for a system call, supporting the instructions
+// seccompFilter emits.
+func runFilter(t *testing.T, filter []unix.SockFilter, arch, nr uint32) uint32 {
+	t.Helper()
+	var acc uint32
+	for pc := 0; pc < len(filter); pc++ {
+		ins := filter[pc]
+		switch ins.Code {
+		case unix.BPF_LD | unix.BPF_W | unix.BPF_ABS:
+			switch ins.K {
+			case 0:
+				acc = nr
+			case 4:
+				acc = arch
+			default:
+				t.Fatalf("load of unknown offset %d", ins.K)
+			}
+		case unix.BPF_JMP | unix.BPF_JEQ | unix.BPF_K, unix.BPF_JMP | unix.BPF_JGE | unix.BPF_K:
+			match := acc == ins.K
+			if ins.Code&0xf0 == unix.BPF_JGE {
+				match = acc >= ins.K
+			}
+			if match {
+				pc += int(ins.Jt)
+			} else {
+				pc += int(ins.Jf)
+			}
+		case unix.BPF_RET | unix.BPF_K:
+			return ins.K
+		default:
+			t.Fatalf("unexpected instruction %#x", ins.Code)
+		}
+	}
+	t.Fatal("filter ran off its end")
+	return 0
+}
+
+func TestSeccompFilter(t *testing.T) {
+	deny := unix.SECCOMP_RET_ERRNO | uint32(unix.EPERM)
+	for goarch, arch := range seccompArchs {
+		filter := seccompFilter(goarch)
+		if len(filter) == 0 {
+			t.Fatalf("%s: no filter", goarch)
+		}
+		for _, nr := range seccompBlocked {
+			if got := runFilter(t, filter, arch, nr); got != deny {
+				t.Errorf("%s: system call %d returns %#x, want EPERM", goarch, nr, got)
+			}
+		}
+		for _, nr := range []uint32{unix.SYS_READ, unix.SYS_WRITE, unix.SYS_EXECVE, unix.SYS_CLONE} {
+			if got := runFilter(t, filter, arch, nr); got != unix.SECCOMP_RET_ALLOW {
+				t.Errorf("%s: system call %d returns %#x, want it allowed", goarch, nr, got)
+			}
+		}
+		if got := runFilter(t, filter, unix.AUDIT_ARCH_I386, unix.SYS_READ); got != deny {
+			t.Errorf("%s: calls of a foreign architecture must be denied, got %#x", goarch, got)
+		}
+	}
+
+	amd64 := seccompFilter("amd64")
+	if got := runFilter(t, amd64, unix.AUDIT_ARCH_X86_64, 0x40000000|unix.SYS_READ); got != deny {
+		t.Errorf("x32 calls must be denied on amd64, got %#x", got)
+	}
+	if seccompFilter("mips") != nil {
+		t.Error("expected no filter for an architecture without an audit arch")
+	}
+}
+
+func TestNextSandboxRetry(t *testing.T) {
+	all := uintptr(unix.CLONE_NEWUSER | unix.CLONE_NEWNS | unix.CLONE_NEWPID | unix.CLONE_NEWNET)
+	notFound := &exec.Error{Name: "x", Err: exec.ErrNotFound}
+	tests := []struct {
+		name   string
+		err    error
+		flags  uintptr
+		cgroup bool
+		root   bool
+		want   sandboxRetry
+	}{
+		{"cgroup first", syscall.EPERM, all, true, false, retryWithoutCgroup},
+		{"root drops the user namespace", syscall.EPERM, all, false, true, retryWithoutUserNS},
+		{"root without user namespace", syscall.EPERM, all &^ unix.CLONE_NEWUSER, false, true, retryWithoutNamespaces},
+		{"EPERM", syscall.EPERM, all, false, false, retryWithoutNamespaces},
+		{"EINVAL", syscall.EINVAL, all, false, false, retryWithoutNamespaces},
+		{"ENOSPC", syscall.ENOSPC, all, false, false, retryWithoutNamespaces},
+		{"EUSERS", syscall.EUSERS, all, false, false, retryWithoutNamespaces},
+		{"EACCES", syscall.EACCES, all, false, false, retryWithoutNamespaces},
+		{"other errno", syscall.ENOMEM, all, false, false, retryNone},
+		{"not a sandbox error", notFound, all, false, false, retryNone},
+		{"already restricted", syscall.EPERM, 0, false, false, retryNone},
+	}
+	for _, tt := range tests {
+		if got := nextSandboxRetry(tt.err, tt.flags, tt.cgroup, tt.root); got != tt.want {
+			t.Errorf("%s: got %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+// withoutNamespaces makes sandboxes run in the restricted mode, as after a failure
+// to create namespaces.
+func withoutNamespaces(t *testing.T) {
+	t.Helper()
+	reason := "namespaces unavailable (test)"
+	previous := namespaceFailure.Swap(&reason)
+	t.Cleanup(func() { namespaceFailure.Store(previous) })
+}
+
+func TestSandboxPolicy_Restricted(t *testing.T) {
+	withoutNamespaces(t)
+	t.Setenv("VIBEAURA_TEST_TOKEN", "secret")
+
+	p := DefaultSandboxPolicy(t.TempDir())
+	if p.ExpectedMode() != SandboxRestricted {
+		t.Errorf("expected commands to fall back to the restricted mode, got %s", p.ExpectedMode())
+	}
+	p.MaxCPUTime = 90 * time.Second
+	script := `ulimit -t; ulimit -d; grep -E '^(Seccomp|NoNewPrivs):' /proc/self/status; echo "token=$VIBEAURA_TEST_TOKEN extra=$EXTRA"; pwd`
+	res, err := p.Run(context.Background(), SandboxCommand{Path: "sh", Args: []string{"-c", script}, Env: []string{"EXTRA=1"}})
+	if err != nil {
+		t.Fatalf("%v\n%s", err, res.Output)
+	}
+	if res.Mode != SandboxRestricted || !strings.Contains(strings.Join(res.Notes, "\n"), "namespaces unavailable (test)") {
+		t.Errorf("expected the restricted mode with a note, got %s %q", res.Mode, res.Notes)
+	}
+
+	lines := strings.Split(strings.TrimSpace(res.Output), "\n")
+	if len(lines) != 6 {
+		t.Fatalf("unexpected output:\n%s", res.Output)
+	}
+	if lines[0] != "90" {
+		t.Errorf("CPU limit = %s, want 90", lines[0])
+	}
+	// Without a delegated cgroup, memory is limited by RLIMIT_DATA.
+	if strings.Contains(strings.Join(res.Notes, "\n"), "cgroup limits unavailable") {
+		if want := strconv.FormatInt(p.MaxMemory/1024, 10); lines[1] != want {
+			t.Errorf("data limit = %s KB, want %s", lines[1], want)
+		}
+	}
+	if !strings.HasSuffix(lines[2], "\t2") && !strings.HasSuffix(lines[3], "\t2") {
+		t.Errorf("expected a seccomp filter, got %q", lines[2:4])
+	}
+	if !strings.Contains(res.Output, "NoNewPrivs:\t1") {
+		t.Error("expected no_new_privs to be set")
+	}
+	if lines[4] != "token= extra=1" {
+		t.Errorf("expected the environment to be scrubbed, got %q", lines[4])
+	}
+	if lines[5] != p.Root {
+		t.Errorf("expected to start in the root, got %q", lines[5])
+	}
+}
+
+func TestSandboxPolicy_OutputAndTimeout(t *testing.T) {
+	withoutNamespaces(t)
+	p := DefaultSandboxPolicy(t.TempDir())
+	p.MaxOutput = 16
+
+	res, err := p.Run(context.Background(), SandboxCommand{Path: "sh", Args: []string{"-c", "printf '%0100d' 0; exit 3"}})
+	var exit *exec.ExitError
+	if !errors.As(err, &exit) || res.ExitCode != 3 {
+		t.Fatalf("expected exit status 3, got %v (%d)", err, res.ExitCode)
+	}
+	if !res.Truncated || !strings.HasPrefix(res.Output, strings.Repeat("0", 16)+"\n[output truncated: 84 more bytes]") {
+		t.Errorf("expected the output to be capped, got %q", res.Output)
+	}
+
+	p.Timeout = 200 * time.Millisecond
+	start := time.Now()
+	if _, err := p.Run(context.Background(), SandboxCommand{Path: "sh", Args: []string{"-c", "sleep 5 & sleep 5"}}); err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Errorf("expected a timeout, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 4*time.Second {
+		t.Errorf("the process group should be killed on timeout, took %v", elapsed)
+	}
+
+	if _, err := p.Run(context.Background(), SandboxCommand{Path: "no-such-program-vibeaura"}); !errors.Is(err, exec.ErrNotFound) {
+		t.Errorf("expected a missing program to be reported, got %v", err)
+	}
+}
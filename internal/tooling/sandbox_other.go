@@ -0,0 +1,20 @@
+//go:build !linux
+
+package tooling
+
+import "os"
+
+// ExpectedMode is the mode the next command run under p gets, always basic here.
+func (p SandboxPolicy) ExpectedMode() SandboxMode {
+	return SandboxBasic
+}
+
+// run starts the command directly: without namespaces, rlimits or seccomp, only the
+// scrubbed environment, the timeout and the output cap of Run apply.
+func (p SandboxPolicy) run(x sandboxExec, res *SandboxResult) (*os.ProcessState, error) {
+	res.Mode = SandboxBasic
+	res.Notes = append(res.Notes, "process isolation is only available on Linux")
+	cmd := x.command(x.path, x.args[1:]...)
+	err := cmd.Run()
+	return cmd.ProcessState, err
+}
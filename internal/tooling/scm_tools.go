@@ -106,7 +106,7 @@ func (t *SCMPullRequestTool) Metadata() ToolMetadata {
 		Description: "Manage Pull/Merge Requests. Auto-detects CLI (gh for GitHub, glab for GitLab).",
 		Source:      "system",
 		Category:    CategoryDevOps,
-		Roles:       []AgentRole{RoleEngineer, RoleArchitect},
+		Roles:       []AgentRole{RoleEngineer},
 		Complexity:  6,
 		Permissions: []Permission{PermNetwork, PermExecute},
 		Parameters: json.RawMessage(`{
@@ -278,7 +278,7 @@ func (t *GitHubRemoteTaskTool) Metadata() ToolMetadata {
 		Description: "Trigger a remote agent task on GitHub using 'gh agent-task create'.",
 		Source:      "system",
 		Category:    CategoryDevOps,
-		Roles:       []AgentRole{RoleArchitect, RoleEngineer},
+		Roles:       []AgentRole{RoleEngineer},
 		Complexity:  7,
 		Permissions: []Permission{PermNetwork, PermExecute},
 		Parameters: json.RawMessage(`{
@@ -25,7 +25,14 @@ type SecurityGuard struct {
 	deniedPermissions  map[Permission]bool
 
 	interceptor func(tool Tool, args json.RawMessage) (bool, error)
-	mu          sync.RWMutex
+	policy      func(tool Tool, args json.RawMessage) (PolicyDecision, error)
+	result      func(tool Tool, args json.RawMessage, res *ToolResult, err error)
+
+	// Workspace confinement, see ConfinePath
+	workspace    func() []string
+	allowedPaths []string       // allowed for the session
+	granted      map[string]int // allowed once, for the calls in flight
+	mu           sync.RWMutex
 }
 
 func NewSecurityGuard() *SecurityGuard {
@@ -57,6 +64,22 @@ func (s *SecurityGuard) SetInterceptor(fn func(tool Tool, args json.RawMessage)
 	s.interceptor = fn
 }
 
+// SetPolicy installs declarative rules that are consulted for every call, after the
+// hard denials but before the permission policy and the interceptor.
+func (s *SecurityGuard) SetPolicy(fn func(tool Tool, args json.RawMessage) (PolicyDecision, error)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.policy = fn
+}
+
+// SetResultHook installs a function that is told the result of every call the guard let
+// through, e.g. to audit it.
+func (s *SecurityGuard) SetResultHook(fn func(tool Tool, args json.RawMessage, res *ToolResult, err error)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.result = fn
+}
+
 // SetPermissionPolicy sets whether a specific permission is globally allowed or denied.
 func (s *SecurityGuard) SetPermissionPolicy(p Permission, allowed bool) {
 	s.mu.Lock()
@@ -96,6 +119,17 @@ func (s *SecurityGuard) ValidateRequest(t Tool, args json.RawMessage) error {
 		}
 	}
 
+	// 4. Declarative policy rules decide before the permission policy
+	if s.policy != nil {
+		decision, err := s.policy(t, args)
+		if err != nil {
+			return err
+		}
+		if decision == PolicyAllow {
+			return nil
+		}
+	}
+
 	// If all permissions are allowed, we're good
 	if !requiresManualApproval {
 		return nil
@@ -117,7 +151,8 @@ func (s *SecurityGuard) ValidateRequest(t Tool, args json.RawMessage) error {
 	return nil
 }
 
-// CheckPath verifies if a path is safe to access (remains for compatibility or internal checks).
+// CheckPath verifies that a path is not one of the blocked sensitive files. File tools
+// go through ConfinePath, which also applies it.
 func (s *SecurityGuard) CheckPath(path string) error {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
@@ -152,9 +187,33 @@ func (st *SecureTool) Metadata() ToolMetadata {
 }
 
 // Execute performs security validation before delegating to the underlying Tool.
+// Paths outside the workspace are confirmed with the user first.
 func (st *SecureTool) Execute(ctx context.Context, args json.RawMessage) (*ToolResult, error) {
+	outside, err := st.guard.checkWorkspace(st.Tool, args)
+	if err != nil {
+		return &ToolResult{Status: "error", Error: err}, err
+	}
+	if len(outside) > 0 {
+		return nil, st.outsideWorkspace(args, outside)
+	}
+	return st.execute(ctx, args)
+}
+
+func (st *SecureTool) execute(ctx context.Context, args json.RawMessage) (*ToolResult, error) {
 	if err := st.guard.ValidateRequest(st.Tool, args); err != nil {
 		return &ToolResult{Status: "error", Error: err}, err
 	}
-	return st.Tool.Execute(ctx, args)
+	res, err := st.Tool.Execute(ctx, args)
+	st.guard.mu.RLock()
+	hook := st.guard.result
+	st.guard.mu.RUnlock()
+	if hook != nil {
+		hook(st.Tool, args, res, err)
+	}
+	// A path the arguments did not reveal, e.g. a symlink met on the way
+	var ow *OutsideWorkspaceError
+	if errors.As(err, &ow) {
+		return nil, st.outsideWorkspace(args, []string{ow.Resolved})
+	}
+	return res, err
 }
@@ -19,16 +19,30 @@ type Thread struct {
 	Prompt    string                 `json:"prompt"`
 	Response  string                 `json:"response"`
 	ToolCalls []ToolCall             `json:"tool_calls"`
+	Usage     *UsageReport           `json:"usage,omitempty"`
 	Metadata  map[string]interface{} `json:"metadata"`
 	Timestamp time.Time              `json:"timestamp"`
+
+	// Role, Children and Transcript describe sub-agent threads of an orchestrated request.
+	Role       string            `json:"role,omitempty"`
+	Children   []*Thread         `json:"children,omitempty"`
+	Transcript []TranscriptEntry `json:"transcript,omitempty"`
+}
+
+// TranscriptEntry is one message of a sub-agent conversation.
+type TranscriptEntry struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+	Tool    string `json:"tool,omitempty"`
 }
 
 // Session represents a "process" containing multiple threads.
 type Session struct {
-	ID        string    `json:"id"`
-	Threads   []*Thread `json:"threads"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID        string      `json:"id"`
+	Threads   []*Thread   `json:"threads"`
+	Usage     UsageReport `json:"usage"`
+	CreatedAt time.Time   `json:"created_at"`
+	UpdatedAt time.Time   `json:"updated_at"`
 }
 
 func NewSession(id string) *Session {
@@ -49,6 +63,7 @@ func (s *Session) Export() map[string]interface{} {
 	return map[string]interface{}{
 		"id":         s.ID,
 		"threads":    s.Threads,
+		"usage":      s.Usage,
 		"created_at": s.CreatedAt,
 		"updated_at": s.UpdatedAt,
 	}
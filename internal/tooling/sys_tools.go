@@ -3,6 +3,7 @@ package tooling
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -35,7 +36,7 @@ func (t *ReadFileTool) Metadata() ToolMetadata {
 		Description: "Read the content of a file from the filesystem.",
 		Source:      "system",
 		Category:    CategoryFileSystem,
-		Roles:       []AgentRole{RoleCoder, RoleEngineer},
+		Roles:       []AgentRole{RoleCoder, RoleEngineer, RoleQA, RoleArchitect},
 		Complexity:  2,
 		Permissions: []Permission{PermRead},
 		Parameters: json.RawMessage(`{
@@ -113,6 +114,11 @@ func (t *WriteFileTool) Execute(ctx context.Context, args json.RawMessage) (*Too
 	ReportStatus("💾", "exec", fmt.Sprintf("Writing to file: %s", input.Path))
 
 	err := t.fs.WriteFile(input.Path, []byte(input.Content))
+	var intervention *InterventionError
+	if errors.As(err, &intervention) {
+		// Held for review; Resume applies the accepted changes.
+		return nil, err
+	}
 	if err != nil {
 		ReportStatus("❌", "exec", fmt.Sprintf("Failed to write %s: %v", input.Path, err))
 		return &ToolResult{Status: "error", Error: err}, err
@@ -127,7 +133,15 @@ func (t *WriteFileTool) Execute(ctx context.Context, args json.RawMessage) (*Too
 }
 
 // ShellExecTool runs a shell command.
-type ShellExecTool struct{}
+type ShellExecTool struct {
+	sandbox func() *SandboxPolicy
+}
+
+// NewShellExecTool confines commands with the policy sandbox returns at the time of
+// each call; a nil sandbox or policy runs them with the user's full privileges.
+func NewShellExecTool(sandbox func() *SandboxPolicy) *ShellExecTool {
+	return &ShellExecTool{sandbox: sandbox}
+}
 
 func (t *ShellExecTool) Metadata() ToolMetadata {
 	return ToolMetadata{
@@ -135,7 +149,7 @@ func (t *ShellExecTool) Metadata() ToolMetadata {
 		Description: "Execute a shell command.",
 		Source:      "system",
 		Category:    CategorySystem,
-		Roles:       []AgentRole{RoleEngineer},
+		Roles:       []AgentRole{RoleEngineer, RoleQA}, // QA runs the test suite
 		Complexity:  8,
 		Permissions: []Permission{PermExecute},
 		Parameters: json.RawMessage(`{
@@ -160,8 +174,27 @@ func (t *ShellExecTool) Execute(ctx context.Context, args json.RawMessage) (*Too
 
 	ReportStatus("🐚", "exec", fmt.Sprintf("Running: %s %v", input.Command, input.Args))
 
-	cmd := exec.CommandContext(ctx, input.Command, input.Args...)
-	output, err := cmd.CombinedOutput()
+	var policy *SandboxPolicy
+	if t.sandbox != nil {
+		policy = t.sandbox()
+	}
+	if policy == nil {
+		cmd := exec.CommandContext(ctx, input.Command, input.Args...)
+		output, err := cmd.CombinedOutput()
+		return shellResult(input.Command, string(output), err, nil), nil
+	}
+
+	res, err := policy.Run(ctx, SandboxCommand{Path: input.Command, Args: input.Args})
+	if res == nil {
+		return shellResult(input.Command, "", err, nil), nil // it did not start
+	}
+	return shellResult(input.Command, res.Output, err, res), nil
+}
+
+// shellResult reports a finished command. We return a nil error alongside it because
+// the *execution* succeeded, even if the command failed, but we populate Error in the
+// struct.
+func shellResult(command, output string, err error, sandbox *SandboxResult) *ToolResult {
 	status := "success"
 	if err != nil {
 		status = "error"
@@ -170,12 +203,19 @@ func (t *ShellExecTool) Execute(ctx context.Context, args json.RawMessage) (*Too
 		ReportStatus("✅", "exec", "Command completed successfully")
 	}
 
+	meta := map[string]interface{}{"command": command}
+	if sandbox != nil {
+		meta["sandbox"] = string(sandbox.Mode)
+		if len(sandbox.Notes) > 0 {
+			meta["sandbox_notes"] = sandbox.Notes
+		}
+	}
 	return &ToolResult{
 		Status:  status,
-		Content: string(output),
-		Meta:    map[string]interface{}{"command": input.Command},
+		Content: output,
+		Meta:    meta,
 		Error:   err,
-	}, nil // We return nil error here because the *execution* succeeded, even if the command failed, but we populate Error in struct
+	}
 }
 
 // SystemInfoTool provides a snapshot of system resources.
@@ -227,7 +267,7 @@ func (t *ListFilesTool) Metadata() ToolMetadata {
 		Description: "List files and directories in a given path.",
 		Source:      "system",
 		Category:    CategoryFileSystem,
-		Roles:       []AgentRole{RoleCoder, RoleEngineer},
+		Roles:       []AgentRole{RoleCoder, RoleEngineer, RoleQA, RoleArchitect},
 		Complexity:  2,
 		Permissions: []Permission{PermRead},
 		Parameters: json.RawMessage(`{
@@ -270,7 +310,7 @@ func (t *FetchURLTool) Metadata() ToolMetadata {
 		Description: "Fetch the content of a public URL (HTTP/HTTPS).",
 		Source:      "system",
 		Category:    CategoryNetwork,
-		Roles:       []AgentRole{RoleEngineer, RoleArchitect},
+		Roles:       []AgentRole{RoleEngineer},
 		Complexity:  4,
 		Permissions: []Permission{PermNetwork},
 		Parameters: json.RawMessage(`{
@@ -57,6 +57,37 @@ type ToolMetadata struct {
 	Complexity int          `json:"complexity"` // 1-10 estimation of cognitive load
 }
 
+// readOnlyRoles may only use read-only tools, whatever roles a tool declares.
+var readOnlyRoles = map[AgentRole]bool{RoleArchitect: true}
+
+// AllowsRole reports whether the tool is meant for role. Tools that declare no roles
+// are available to every role, except that read-only roles such as the architect only
+// get tools that are ReadOnly.
+func (m ToolMetadata) AllowsRole(role AgentRole) bool {
+	if readOnlyRoles[role] && !m.ReadOnly() {
+		return false
+	}
+	if len(m.Roles) == 0 || role == RoleAll {
+		return true
+	}
+	for _, r := range m.Roles {
+		if r == role || r == RoleAll {
+			return true
+		}
+	}
+	return false
+}
+
+// ReadOnly reports whether the tool needs no permission beyond PermRead.
+func (m ToolMetadata) ReadOnly() bool {
+	for _, p := range m.Permissions {
+		if p != PermRead {
+			return false
+		}
+	}
+	return len(m.Permissions) > 0
+}
+
 // ToolResult is a structured response enabling agentic reflection.
 type ToolResult struct {
 	Content   string                 `json:"content"`             // The primary textual output
@@ -133,6 +164,21 @@ func (r *Registry) Get(name string) (Tool, bool) {
 	return t, ok
 }
 
+// ForRole returns a registry holding only the tools role may use. Providers are not
+// copied, so the subset is a snapshot of the current tools.
+func (r *Registry) ForRole(role AgentRole) *Registry {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	sub := NewRegistry()
+	for name, t := range r.tools {
+		if t.Metadata().AllowsRole(role) {
+			sub.tools[name] = t
+		}
+	}
+	return sub
+}
+
 func (r *Registry) List() []Tool {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
@@ -163,13 +209,16 @@ func ToMCP(t Tool) MCPTool {
 // DefaultRegistry creates a registry populated with core system tools.
 func DefaultRegistry(f sys.FS, m *sys.Monitor, guard *SecurityGuard) *Registry {
 	r := NewRegistry()
+	if guard != nil {
+		f = NewWorkspaceFS(f, guard)
+	}
 
 	tools := []Tool{
 		NewReadFileTool(f),
 		NewWriteFileTool(f),
 		NewListFilesTool(f),
 		NewTraversalTool(f),
-		&ShellExecTool{},
+		NewShellExecTool(nil),
 		NewSystemInfoTool(m),
 		&FetchURLTool{},
 	}
@@ -256,6 +305,7 @@ func CoreTools() []string {
 	return []string{
 		"sys_read_file",
 		"sys_write_file",
+		"sys_edit_file",
 		"sys_shell_exec", // Engineers need this
 		"sys_tool_wand",  // The Handshake
 		"sys_info",       // Situational awareness
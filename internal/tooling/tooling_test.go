@@ -0,0 +1,66 @@
+package tooling
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/nathfavour/vibeauracle/sys"
+)
+
+// unscopedTool declares no roles, as tools from MCP servers usually do.
+type unscopedTool struct {
+	name  string
+	perms []Permission
+}
+
+func (t *unscopedTool) Metadata() ToolMetadata {
+	return ToolMetadata{Name: t.name, Permissions: t.perms}
+}
+
+func (t *unscopedTool) Execute(ctx context.Context, args json.RawMessage) (*ToolResult, error) {
+	return &ToolResult{Status: "success"}, nil
+}
+
+func toolNames(r *Registry) string {
+	var names []string
+	for _, t := range r.List() {
+		names = append(names, t.Metadata().Name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ",")
+}
+
+func TestRegistry_ForRole(t *testing.T) {
+	r := NewRegistry()
+	r.RegisterProvider(NewSystemProvider(sys.NewLocalFS(t.TempDir()), nil, NewSecurityGuard(), nil))
+	if err := r.Sync(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	r.Register(&unscopedTool{name: "mcp_lookup", perms: []Permission{PermRead}})
+	r.Register(&unscopedTool{name: "mcp_deploy", perms: []Permission{PermExecute, PermNetwork}})
+
+	// The architect plans; it must not be able to change anything, even through tools
+	// that list it or declare no roles at all.
+	architect := r.ForRole(RoleArchitect)
+	if got := toolNames(architect); got != "fs_list_dir,mcp_lookup,sys_info,sys_list_files,sys_read_file,traverse_source" {
+		t.Errorf("unexpected architect tools: %s", got)
+	}
+	for _, tool := range architect.List() {
+		if !tool.Metadata().ReadOnly() {
+			t.Errorf("the architect must only get read-only tools, got %s", tool.Metadata().Name)
+		}
+	}
+
+	engineer := toolNames(r.ForRole(RoleEngineer))
+	for _, name := range []string{"scm_pr_manage", "gh_remote_task", "sys_shell_exec", "mcp_deploy"} {
+		if !strings.Contains(engineer, name) {
+			t.Errorf("expected the engineer to keep %s, got %s", name, engineer)
+		}
+	}
+	if strings.Contains(toolNames(r.ForRole(RoleQA)), "sys_write_file") {
+		t.Error("QA must not be able to write files")
+	}
+}
@@ -47,9 +47,16 @@ func (t *TraversalTool) Execute(ctx context.Context, args json.RawMessage) (*Too
 	}
 
 	root, _ := os.Getwd()
-	if input.Path != "" {
+	if filepath.IsAbs(input.Path) {
+		root = input.Path
+	} else if input.Path != "" {
 		root = filepath.Join(root, input.Path)
 	}
+	// Goes through the FS so that the root is confined like any other path; the walk
+	// itself does not follow symlinks.
+	if _, err := t.fs.Stat(root); err != nil {
+		return &ToolResult{Status: "error", Error: err}, err
+	}
 
 	var results []string
 	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
@@ -0,0 +1,68 @@
+package tooling
+
+import "time"
+
+// Usage accumulates token counts and spend.
+type Usage struct {
+	PromptTokens     int     `json:"prompt_tokens"`
+	CompletionTokens int     `json:"completion_tokens"`
+	Requests         int     `json:"requests"`
+	Cost             float64 `json:"cost"`
+
+	// Estimated is set once any contributing count was approximated locally.
+	Estimated bool `json:"estimated,omitempty"`
+	// Unpriced is set once any contributing request had no price table entry.
+	Unpriced bool `json:"unpriced,omitempty"`
+}
+
+// TotalTokens returns prompt plus completion tokens.
+func (u Usage) TotalTokens() int {
+	return u.PromptTokens + u.CompletionTokens
+}
+
+// Add folds another usage into u.
+func (u *Usage) Add(o Usage) {
+	u.PromptTokens += o.PromptTokens
+	u.CompletionTokens += o.CompletionTokens
+	u.Requests += o.Requests
+	u.Cost += o.Cost
+	u.Estimated = u.Estimated || o.Estimated
+	u.Unpriced = u.Unpriced || o.Unpriced
+}
+
+// UsageReport aggregates usage overall and per "provider/model".
+type UsageReport struct {
+	Total   Usage            `json:"total"`
+	ByModel map[string]Usage `json:"by_model,omitempty"`
+}
+
+// Record adds a single request's usage under provider/model.
+func (r *UsageReport) Record(provider, model string, u Usage) {
+	if r.ByModel == nil {
+		r.ByModel = make(map[string]Usage)
+	}
+	key := provider + "/" + model
+	m := r.ByModel[key]
+	m.Add(u)
+	r.ByModel[key] = m
+	r.Total.Add(u)
+}
+
+// Merge folds another report into r.
+func (r *UsageReport) Merge(o UsageReport) {
+	if r.ByModel == nil && len(o.ByModel) > 0 {
+		r.ByModel = make(map[string]Usage)
+	}
+	for key, u := range o.ByModel {
+		m := r.ByModel[key]
+		m.Add(u)
+		r.ByModel[key] = m
+	}
+	r.Total.Add(o.Total)
+}
+
+// RecordUsage adds a request's usage to the session totals.
+func (s *Session) RecordUsage(provider, model string, u Usage) {
+	s.Usage.Record(provider, model, u)
+	s.UpdatedAt = time.Now()
+}
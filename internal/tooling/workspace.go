@@ -0,0 +1,270 @@
+package tooling
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/nathfavour/vibeauracle/sys"
+)
+
+// OutsideWorkspaceError reports a path that resolves outside the workspace roots.
+type OutsideWorkspaceError struct {
+	Path     string // as requested
+	Resolved string // absolute, with symlinks resolved
+}
+
+func (e *OutsideWorkspaceError) Error() string {
+	if e.Resolved != e.Path {
+		return fmt.Sprintf("security: %s resolves to %s, outside the workspace", e.Path, e.Resolved)
+	}
+	return fmt.Sprintf("security: %s is outside the workspace", e.Path)
+}
+
+// SetWorkspace confines file access to the directories returned by roots, which is
+// consulted on every check so that it can follow the working directory. Without a
+// workspace, only blocked paths are refused.
+func (s *SecurityGuard) SetWorkspace(roots func() []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.workspace = roots
+}
+
+// AllowPath lets path, and everything below it when it is a directory, be accessed
+// for the rest of the session although it is outside the workspace.
+func (s *SecurityGuard) AllowPath(path string) {
+	resolved, err := resolvePath(path)
+	if err != nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.allowedPaths = append(s.allowedPaths, resolved)
+}
+
+// grant allows paths until the returned function is called. It backs "Allow Once".
+func (s *SecurityGuard) grant(paths []string) (revoke func()) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.granted == nil {
+		s.granted = make(map[string]int)
+	}
+	for _, p := range paths {
+		s.granted[p]++
+	}
+	return func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		for _, p := range paths {
+			if s.granted[p]--; s.granted[p] <= 0 {
+				delete(s.granted, p)
+			}
+		}
+	}
+}
+
+// ConfinePath resolves path, relative to the working directory, following symlinks
+// and "..", and returns the result if it may be accessed. Blocked paths fail with
+// ErrBlockedAccess; paths outside the workspace with *OutsideWorkspaceError.
+func (s *SecurityGuard) ConfinePath(path string) (string, error) {
+	resolved, err := resolvePath(path)
+	if err != nil {
+		return "", err
+	}
+	// A symlink must not smuggle a blocked file in under another name.
+	for _, p := range []string{path, resolved} {
+		if err := s.CheckPath(p); err != nil {
+			return "", err
+		}
+	}
+
+	s.mu.RLock()
+	workspace := s.workspace
+	allowed := append([]string(nil), s.allowedPaths...)
+	for p := range s.granted {
+		allowed = append(allowed, p)
+	}
+	s.mu.RUnlock()
+
+	if workspace == nil {
+		return resolved, nil
+	}
+	for _, root := range workspace() {
+		if root, err := resolvePath(root); err == nil {
+			allowed = append(allowed, root)
+		}
+	}
+	if !withinAny(resolved, allowed) {
+		return "", &OutsideWorkspaceError{Path: path, Resolved: resolved}
+	}
+	return resolved, nil
+}
+
+// resolvePath makes path absolute and resolves the symlinks of its longest existing
+// prefix, so that paths of files yet to be created resolve too.
+func resolvePath(path string) (string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	var rest []string
+	for dir := abs; ; dir = filepath.Dir(dir) {
+		real, err := filepath.EvalSymlinks(dir)
+		if err == nil {
+			return filepath.Join(append([]string{real}, rest...)...), nil
+		}
+		if !errors.Is(err, os.ErrNotExist) || filepath.Dir(dir) == dir {
+			return abs, nil
+		}
+		rest = append([]string{filepath.Base(dir)}, rest...)
+	}
+}
+
+// withinAny reports whether path is one of dirs or inside one of them.
+func withinAny(path string, dirs []string) bool {
+	for _, d := range dirs {
+		if path == d || strings.HasPrefix(path, strings.TrimSuffix(d, string(filepath.Separator))+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkWorkspace confines the paths a file tool call names. Paths outside the workspace
+// are returned for the user to allow; a blocked path is an error.
+func (s *SecurityGuard) checkWorkspace(t Tool, args json.RawMessage) ([]string, error) {
+	switch t.Metadata().Category {
+	case CategoryFileSystem, CategoryAnalysis:
+	default:
+		return nil, nil
+	}
+	_, paths := callTargets(t.Metadata().Name, args)
+	var outside []string
+	for _, p := range paths {
+		_, err := s.ConfinePath(p)
+		var ow *OutsideWorkspaceError
+		switch {
+		case errors.As(err, &ow):
+			if !slices.Contains(outside, ow.Resolved) {
+				outside = append(outside, ow.Resolved)
+			}
+		case err != nil:
+			return nil, err
+		}
+	}
+	return outside, nil
+}
+
+// outsideWorkspace asks whether a tool call may access paths outside the workspace.
+// "Allow Once" lets this call through, "Allow Session" every later one too.
+func (st *SecureTool) outsideWorkspace(args json.RawMessage, outside []string) error {
+	m := st.Tool.Metadata()
+	risk := "medium"
+	if hasPermission(m.Permissions, PermWrite) {
+		risk = "high"
+	}
+	return &InterventionError{
+		Title:   fmt.Sprintf("Allow %s outside the workspace? %s", m.Name, strings.Join(outside, ", ")),
+		Choices: []string{"Allow Once", "Allow Session", "Deny"},
+		Risk:    risk,
+		Resume: func(ctx context.Context, choice string) (*ToolResult, error) {
+			switch choice {
+			case "Allow Once":
+				return st.guard.runGranted(outside, func() (*ToolResult, error) {
+					return st.execute(ctx, args)
+				})
+			case "Allow Session":
+				for _, p := range outside {
+					st.guard.AllowPath(p)
+				}
+				return st.execute(ctx, args)
+			default:
+				err := fmt.Errorf("security: user denied access to %s", strings.Join(outside, ", "))
+				return &ToolResult{Status: "error", Error: err}, err
+			}
+		},
+	}
+}
+
+// runGranted runs fn with paths granted. An intervention fn raises, such as an approval
+// or a review of the changes, keeps the grant when it is resumed.
+func (s *SecurityGuard) runGranted(paths []string, fn func() (*ToolResult, error)) (*ToolResult, error) {
+	revoke := s.grant(paths)
+	res, err := fn()
+	revoke()
+	var iv *InterventionError
+	if errors.As(err, &iv) && iv.Resume != nil {
+		resume := iv.Resume
+		iv.Resume = func(ctx context.Context, choice string) (*ToolResult, error) {
+			return s.runGranted(paths, func() (*ToolResult, error) { return resume(ctx, choice) })
+		}
+	}
+	return res, err
+}
+
+// WorkspaceFS confines every operation of an FS to the guard's workspace.
+type WorkspaceFS struct {
+	sys.FS
+	guard *SecurityGuard
+}
+
+func NewWorkspaceFS(f sys.FS, guard *SecurityGuard) *WorkspaceFS {
+	return &WorkspaceFS{FS: f, guard: guard}
+}
+
+func (f *WorkspaceFS) ReadFile(path string) ([]byte, error) {
+	if _, err := f.guard.ConfinePath(path); err != nil {
+		return nil, err
+	}
+	return f.FS.ReadFile(path)
+}
+
+func (f *WorkspaceFS) WriteFile(path string, content []byte) error {
+	if _, err := f.guard.ConfinePath(path); err != nil {
+		return err
+	}
+	return f.FS.WriteFile(path, content)
+}
+
+func (f *WorkspaceFS) DeleteFile(path string) error {
+	if _, err := f.guard.ConfinePath(path); err != nil {
+		return err
+	}
+	return f.FS.DeleteFile(path)
+}
+
+func (f *WorkspaceFS) ListFiles(path string) ([]string, error) {
+	if _, err := f.guard.ConfinePath(path); err != nil {
+		return nil, err
+	}
+	return f.FS.ListFiles(path)
+}
+
+func (f *WorkspaceFS) Edit(path string, oldStr, newStr string) error {
+	if _, err := f.guard.ConfinePath(path); err != nil {
+		return err
+	}
+	return f.FS.Edit(path, oldStr, newStr)
+}
+
+func (f *WorkspaceFS) Stat(path string) (os.FileInfo, error) {
+	if _, err := f.guard.ConfinePath(path); err != nil {
+		return nil, err
+	}
+	return f.FS.Stat(path)
+}
+
+// Batch checks every path before applying any operation.
+func (f *WorkspaceFS) Batch(ops []sys.BatchOp) error {
+	for _, op := range ops {
+		if _, err := f.guard.ConfinePath(op.Path); err != nil {
+			return err
+		}
+	}
+	return f.FS.Batch(ops)
+}
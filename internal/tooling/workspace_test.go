@@ -0,0 +1,244 @@
+package tooling
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/nathfavour/vibeauracle/sys"
+)
+
+// realTempDir returns a temporary directory with its symlinks resolved, as ConfinePath
+// reports paths.
+func realTempDir(t *testing.T) string {
+	t.Helper()
+	dir, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+// newTestWorkspace returns a guard confined to a new workspace, and a directory outside it.
+func newTestWorkspace(t *testing.T) (*SecurityGuard, string, string) {
+	t.Helper()
+	root, outside := realTempDir(t), realTempDir(t)
+	guard := NewSecurityGuard()
+	guard.SetWorkspace(func() []string { return []string{root} })
+	return guard, root, outside
+}
+
+func symlink(t *testing.T, target, link string) {
+	t.Helper()
+	if err := os.Symlink(target, link); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestConfinePath(t *testing.T) {
+	guard, root, outside := newTestWorkspace(t)
+	writeTestFile(t, filepath.Join(root, "a.txt"), "a", 0644)
+	writeTestFile(t, filepath.Join(root, ".env"), "KEY=1", 0644)
+	writeTestFile(t, filepath.Join(outside, "x.txt"), "x", 0644)
+	writeTestFile(t, filepath.Join(outside, "id_rsa"), "key", 0600)
+	symlink(t, outside, filepath.Join(root, "out"))
+	symlink(t, filepath.Join(root, ".env"), filepath.Join(root, "config"))
+	symlink(t, filepath.Join(outside, "id_rsa"), filepath.Join(root, "notes.txt"))
+	symlink(t, "a.txt", filepath.Join(root, "alias.txt"))
+
+	tests := []struct {
+		name     string
+		path     string
+		resolved string // empty when refused
+		blocked  bool
+	}{
+		{"inside", filepath.Join(root, "a.txt"), filepath.Join(root, "a.txt"), false},
+		{"root itself", root, root, false},
+		{"dot-dot staying inside", filepath.Join(root, "sub", "..", "a.txt"), filepath.Join(root, "a.txt"), false},
+		{"file yet to be created", filepath.Join(root, "new", "dir", "f.go"), filepath.Join(root, "new", "dir", "f.go"), false},
+		{"symlink inside", filepath.Join(root, "alias.txt"), filepath.Join(root, "a.txt"), false},
+		{"dot-dot escape", root + "/../" + filepath.Base(outside) + "/x.txt", "", false},
+		{"symlink out", filepath.Join(root, "out", "x.txt"), "", false},
+		{"new file under a symlink out", filepath.Join(root, "out", "new.txt"), "", false},
+		{"absolute path outside", filepath.Join(outside, "x.txt"), "", false},
+		{"system file", "/etc/passwd", "", false},
+		{"blocked name", filepath.Join(root, ".env"), "", true},
+		{"symlink to a blocked file", filepath.Join(root, "config"), "", true},
+		{"symlink to a blocked file outside", filepath.Join(root, "notes.txt"), "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := guard.ConfinePath(tt.path)
+			var ow *OutsideWorkspaceError
+			switch {
+			case tt.resolved != "":
+				if err != nil || got != tt.resolved {
+					t.Errorf("got %q, %v; want %q", got, err, tt.resolved)
+				}
+			case tt.blocked:
+				if !errors.Is(err, ErrBlockedAccess) {
+					t.Errorf("expected the path to be blocked, got %q, %v", got, err)
+				}
+			case !errors.As(err, &ow):
+				t.Errorf("expected the path to be outside the workspace, got %q, %v", got, err)
+			case withinAny(ow.Resolved, []string{root}):
+				t.Errorf("the error should name where the path resolves to, got %q", ow.Resolved)
+			}
+		})
+	}
+
+	// Relative paths resolve against the working directory.
+	t.Chdir(root)
+	if got, err := guard.ConfinePath("a.txt"); err != nil || got != filepath.Join(root, "a.txt") {
+		t.Errorf("relative path: got %q, %v", got, err)
+	}
+	if _, err := guard.ConfinePath("../" + filepath.Base(outside)); !errors.As(err, new(*OutsideWorkspaceError)) {
+		t.Errorf("relative escape: got %v", err)
+	}
+
+	// Without a workspace only blocked paths are refused.
+	open := NewSecurityGuard()
+	if _, err := open.ConfinePath(filepath.Join(outside, "x.txt")); err != nil {
+		t.Errorf("expected no confinement without a workspace, got %v", err)
+	}
+	if _, err := open.ConfinePath(filepath.Join(root, "config")); !errors.Is(err, ErrBlockedAccess) {
+		t.Errorf("expected blocked paths to stay blocked, got %v", err)
+	}
+}
+
+func TestSecurityGuard_AllowPath(t *testing.T) {
+	guard, _, outside := newTestWorkspace(t)
+	sibling := outside + "-sibling"
+	if err := os.Mkdir(sibling, 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(sibling) })
+
+	guard.AllowPath(outside)
+	if _, err := guard.ConfinePath(filepath.Join(outside, "deep", "x.txt")); err != nil {
+		t.Errorf("expected an allowed directory to cover its contents, got %v", err)
+	}
+	if _, err := guard.ConfinePath(filepath.Join(sibling, "x.txt")); err == nil {
+		t.Error("a directory sharing the allowed path's prefix must stay outside")
+	}
+
+	// Allowing a symlink allows what it points to.
+	link := filepath.Join(realTempDir(t), "link")
+	symlink(t, sibling, link)
+	guard.AllowPath(link)
+	if _, err := guard.ConfinePath(filepath.Join(sibling, "x.txt")); err != nil {
+		t.Errorf("expected the symlink target to be allowed, got %v", err)
+	}
+}
+
+func TestSecurityGuard_GrantCounts(t *testing.T) {
+	guard, _, outside := newTestWorkspace(t)
+	path := filepath.Join(outside, "x.txt")
+	allowed := func() bool {
+		_, err := guard.ConfinePath(path)
+		return err == nil
+	}
+
+	first := guard.grant([]string{outside})
+	second := guard.grant([]string{outside})
+	if !allowed() {
+		t.Fatal("expected the grant to allow the path")
+	}
+	first()
+	if !allowed() {
+		t.Error("the path must stay allowed while another grant holds it")
+	}
+	second()
+	if allowed() {
+		t.Error("the path must be refused once every grant is revoked")
+	}
+	if len(guard.granted) != 0 {
+		t.Errorf("expected revoked grants to be removed, got %v", guard.granted)
+	}
+}
+
+// fileTestTool is a testTool that declares itself a file system tool.
+type fileTestTool struct{ *testTool }
+
+func (t fileTestTool) Metadata() ToolMetadata {
+	m := t.testTool.Metadata()
+	m.Category = CategoryFileSystem
+	return m
+}
+
+func TestSecureTool_OutsideWorkspace(t *testing.T) {
+	guard, _, outside := newTestWorkspace(t)
+	path := filepath.Join(outside, "x.txt")
+	writeTestFile(t, path, "outside", 0644)
+	tool := WrapWithSecurity(NewReadFileTool(sys.NewLocalFS("")), guard)
+	args, _ := json.Marshal(map[string]string{"path": path})
+
+	ask := func() *InterventionError {
+		t.Helper()
+		_, err := tool.Execute(context.Background(), args)
+		var iv *InterventionError
+		if !errors.As(err, &iv) || iv.Risk != "medium" {
+			t.Fatalf("expected to be asked about the path, got %v", err)
+		}
+		return iv
+	}
+
+	if _, err := ask().Resume(context.Background(), "Deny"); err == nil {
+		t.Error("expected a denial to fail the call")
+	}
+
+	// The allowed call runs under the context of whoever answered.
+	read := &testTool{name: "sys_read_file", perms: []Permission{PermRead}}
+	_, err := WrapWithSecurity(fileTestTool{read}, guard).Execute(context.Background(), args)
+	var iv *InterventionError
+	if !errors.As(err, &iv) {
+		t.Fatalf("expected to be asked about the path, got %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := iv.Resume(ctx, "Allow Once"); !errors.Is(err, context.Canceled) || len(read.ran) != 0 {
+		t.Errorf("a cancelled caller must stop the allowed call, got %v, ran %v", err, read.ran)
+	}
+	res, err := ask().Resume(context.Background(), "Allow Once")
+	if err != nil || res.Content == "" {
+		t.Fatalf("Allow Once should run the call, got %+v, %v", res, err)
+	}
+	if len(guard.granted) != 0 {
+		t.Errorf("Allow Once must not outlive the call, got %v", guard.granted)
+	}
+	if _, err := ask().Resume(context.Background(), "Allow Session"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tool.Execute(context.Background(), args); err != nil {
+		t.Errorf("Allow Session should let later calls through, got %v", err)
+	}
+}
+
+func TestWorkspaceFS(t *testing.T) {
+	guard, root, outside := newTestWorkspace(t)
+	fs := NewWorkspaceFS(sys.NewLocalFS(root), guard)
+
+	if err := fs.WriteFile(filepath.Join(root, "a.txt"), []byte("a")); err != nil {
+		t.Fatal(err)
+	}
+	if err := fs.WriteFile(filepath.Join(outside, "a.txt"), []byte("a")); !errors.As(err, new(*OutsideWorkspaceError)) {
+		t.Errorf("expected a write outside to be refused, got %v", err)
+	}
+	if _, err := fs.Stat(filepath.Join(outside)); err == nil {
+		t.Error("expected Stat outside to be refused")
+	}
+
+	err := fs.Batch([]sys.BatchOp{
+		{Type: sys.OpWrite, Path: filepath.Join(root, "b.txt"), Content: []byte("b")},
+		{Type: sys.OpWrite, Path: filepath.Join(outside, "b.txt"), Content: []byte("b")},
+	})
+	if err == nil {
+		t.Fatal("expected the batch to be refused")
+	}
+	if _, err := os.Stat(filepath.Join(root, "b.txt")); !os.IsNotExist(err) {
+		t.Error("a refused batch must not apply any operation")
+	}
+}
@@ -1,3 +1,8 @@
 module github.com/nathfavour/vibeauracle/internal/vibe
 
-go 1.25.5
+go 1.25.0
+
+require github.com/nathfavour/vibeauracle/tooling v0.0.0
+
+replace github.com/nathfavour/vibeauracle/tooling => ../tooling
+replace github.com/nathfavour/vibeauracle/sys => ../sys
@@ -3,10 +3,13 @@ package vibes
 import (
 	"context"
 	"fmt"
+	"os"
 	"os/exec"
 	"strings"
 	"sync"
 	"time"
+
+	"github.com/nathfavour/vibeauracle/tooling"
 )
 
 // Sandbox provides isolated execution for Vibe actions.
@@ -67,23 +70,40 @@ func (s *Sandbox) Execute(cmd string) (string, error) {
 		return "", fmt.Errorf("vibe lacks permission for shell execution")
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
-	defer cancel()
+	// Sandbox escape runs the command as the user, with the full environment.
+	if s.vibe.HasPermission(PermSandboxEscape) {
+		ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+		defer cancel()
 
-	shell := exec.CommandContext(ctx, "sh", "-c", cmd)
-	if s.workDir != "" {
+		shell := exec.CommandContext(ctx, "sh", "-c", cmd)
 		shell.Dir = s.workDir
+		output, err := shell.CombinedOutput()
+		if ctx.Err() == context.DeadlineExceeded {
+			return "", fmt.Errorf("command timed out after %v", s.timeout)
+		}
+		return string(output), err
 	}
 
-	// Restrict environment
-	shell.Env = s.filteredEnv()
-
-	output, err := shell.CombinedOutput()
-	if ctx.Err() == context.DeadlineExceeded {
-		return "", fmt.Errorf("command timed out after %v", s.timeout)
+	res, err := s.policy().Run(context.Background(), tooling.SandboxCommand{Path: "sh", Args: []string{"-c", cmd}})
+	if res == nil {
+		return "", err
 	}
+	return res.Output, err
+}
 
-	return string(output), err
+// policy confines the Vibe's commands to its working directory, without network
+// unless it has the network permission, within the configured time and memory.
+func (s *Sandbox) policy() tooling.SandboxPolicy {
+	root := s.workDir
+	if root == "" {
+		root, _ = os.Getwd()
+	}
+	p := tooling.DefaultSandboxPolicy(root)
+	p.Network = s.vibe.HasPermission(PermSystemNetwork)
+	p.MaxMemory = s.maxMemory
+	p.Timeout = s.timeout
+	p.Env = s.allowedEnv
+	return p
 }
 
 func (s *Sandbox) isBlocked(cmd string) bool {
@@ -101,25 +121,6 @@ func (s *Sandbox) isBlocked(cmd string) bool {
 	return false
 }
 
-func (s *Sandbox) filteredEnv() []string {
-	// If sandbox escape, allow all env
-	if s.vibe.HasPermission(PermSandboxEscape) {
-		return nil // nil means inherit all
-	}
-
-	var filtered []string
-	for _, key := range s.allowedEnv {
-		filtered = append(filtered, key+"="+getEnv(key))
-	}
-	return filtered
-}
-
-func getEnv(key string) string {
-	// This would normally use os.Getenv
-	// Simplified for now
-	return ""
-}
-
 // Executor manages sandboxed execution across all Vibes.
 type Executor struct {
 	mu        sync.RWMutex
@@ -132,7 +132,7 @@ func isValidPermission(perm Permission) bool {
 		PermConfigRead, PermConfigWrite, PermUITheme, PermUILayout,
 		PermSchedulerCreate, PermSchedulerCancel, PermAgentPrompt, PermAgentTools,
 		PermAgentLock, PermUpdateFrequency, PermUpdateChannel, PermBinarySelfMod,
-		PermSystemShell, PermSystemFS, PermSandboxEscape,
+		PermSystemShell, PermSystemFS, PermSystemNetwork, PermSandboxEscape,
 	}
 	for _, p := range validPerms {
 		if p == perm {
@@ -48,6 +48,7 @@ const (
 	PermBinarySelfMod   Permission = "binary.self_modify"
 	PermSystemShell     Permission = "system.shell"
 	PermSystemFS        Permission = "system.fs"
+	PermSystemNetwork   Permission = "system.network"
 	PermSandboxEscape   Permission = "sandbox.escape"
 )
 
@@ -83,15 +83,16 @@ func New() (*Watcher, error) {
 		watcher:        w,
 		subscribers:    make([]Subscriber, 0),
 		roots:          make(map[string]bool),
-		ignorePatterns: defaultIgnorePatterns(),
+		ignorePatterns: DefaultIgnorePatterns(),
 		debounceMap:    make(map[string]time.Time),
 		debounceDur:    50 * time.Millisecond, // 50ms debounce for rapid saves
 		stopCh:         make(chan struct{}),
 	}, nil
 }
 
-// defaultIgnorePatterns returns common patterns to ignore (build artifacts, etc).
-func defaultIgnorePatterns() []string {
+// DefaultIgnorePatterns returns common patterns to ignore (build artifacts, etc).
+// They are matched against base names with filepath.Match.
+func DefaultIgnorePatterns() []string {
 	return []string{
 		".git",
 		"node_modules",